@@ -1,21 +1,103 @@
 package checker
 
 import (
+	"errors"
+	"fmt"
 	"full_check/common"
-	"sync"
+	"strings"
+	"sync/atomic"
+	"time"
 	"full_check/metric"
 	"full_check/client"
 )
 
 type FullCheckParameter struct {
-	SourceHost   client.RedisHost
-	TargetHost   client.RedisHost
-	ResultDBFile string
-	CompareCount int
-	Interval     int
-	BatchCount   int
-	Parallel     int
-	FilterTree   *common.Trie
+	SourceHost      client.RedisHost
+	TargetHost      client.RedisHost
+	FanOutHosts     []client.RedisHost // more targets compared with the same source
+	SourcePrimary   []string // the primary of the replica read as SourceHost, empty unless sourcereplica is set
+	ResultDBFile    string
+	CompareCount    int
+	Interval        int
+	BatchCount      int
+	KeyScanCount    int // the COUNT of the scan enumerating the keys, 0 means use BatchCount
+	DedupWindow     int // count of the recently scanned keys to skip the duplicate, 0 means don't deduplicate
+	HscanCount      int // 0 means use BatchCount
+	SscanCount      int
+	ZscanCount      int
+	LrangeCount     int // the list longer than it is compared in windows, 0 means only the big list
+	StringWindow    int64 // byte, the string longer than it is compared in windows of getrange, 0 means disable
+	Parallel        int
+	DbParallel      int
+	PoolWarmUp      bool // establish the pooled connections of all the workers before comparing every db
+	StrictDB        bool // abort when one db fails to be connected instead of skipping it
+	StrictVersion   bool // abort when the versions are incompatible with the comparemode instead of warning
+	DBMapping       map[int32]int32 // source db -> target db
+	FilterTree      *common.Trie
+	KeyList         map[int32][][]byte
+	MatchList       []string // scan match pattern
+	TypeList        []string // scan key type
+	MaxIdleTime     int64    // second, 0 means no limit
+	TargetStaleness int64    // millisecond, the conflict keys modified within it are verified again, 0 means disable
+	MaxDuration     int64    // second, stop and report the partial result after it, 0 means no limit
+	SampleRate      float64  // (0, 1], 1 means compare all keys
+	DedupFilter     int64    // byte of the bloom filter of all the scanned keys, 0 means disable
+	DedupFpRate     float64  // false positive rate of the bloom filter, (0, 1)
+	CompareTTL      bool
+	TTLTolerance    int64 // millisecond
+	ExpireWindow    int64 // millisecond, the key missing on one side and expiring within it on the other is ignored
+	CompareEncoding bool
+	MemoryRatio     float64 // 0 means disable
+	CompareDigest   bool
+	CompareHll      bool
+	HllTolerance    float64
+	GeoMatchList    []string // zset matching the pattern is compared as geo
+	GeoTolerance    float64  // meter
+	ScoreEpsilon    float64  // max difference of the zset score regarded as equal
+	BitmapMatchList []string // string matching the pattern is compared as bitmap
+	DiffFieldLimit  int      // max count of the differing fields in the log, 0 means don't log
+	MaxValueSize    int64    // byte, 0 means no limit
+	MaxValueCount   int64    // element count, 0 means no limit
+	SkipTooLarge    bool     // skip the key too large instead of comparing incrementally
+	ShallowTooLarge bool     // compare the key too large only by the length and encoding
+	SetSpotCheck    int64    // the set larger than it is compared by sscan and sismember, 0 means disable
+	SetPrecheck     int64    // members sampled to precheck the big set before the full diff, 0 means disable
+	SetDiffSample   int      // max members recorded of each side for the set compared by sscan, 0 means no limit
+	ListHeadDrift   int      // max elements pushed or popped at the list head regarded as drift
+	ListTailDrift   int      // max elements pushed or popped at the list tail regarded as drift
+	EmptyAsMissing  bool     // the key existing but empty on one side is equal to the missing key on the other
+	OneWay          bool     // the keys only on the target are counted instead of being reported as conflicts
+	FreshRecheck    bool     // the conflict keys are verified again on new connections before being reported
+}
+
+// the COUNT hint used when fetching the big hash/set/zset by scan
+func (p *FullCheckParameter) ScanCount(tp *common.KeyType) int {
+	var count int
+	switch tp {
+	case common.HashKeyType:
+		count = p.HscanCount
+	case common.SetKeyType:
+		count = p.SscanCount
+	case common.ZsetKeyType:
+		count = p.ZscanCount
+	}
+	if count == 0 {
+		return p.BatchCount
+	}
+	return count
+}
+
+// the target db compared with the given source db
+func (p *FullCheckParameter) TargetDB(db int32) int32 {
+	if target, ok := p.DBMapping[db]; ok {
+		return target
+	}
+	return db
+}
+
+// whether compare the attributes of the keys whose value is equal
+func (p *FullCheckParameter) CompareAttribute() bool {
+	return p.CompareTTL || p.CompareEncoding || p.MemoryRatio > 0
 }
 
 type VerifierBase struct {
@@ -27,6 +109,148 @@ func (p *VerifierBase) IncrKeyStat(oneKeyInfo *common.Key) {
 	p.Stat.ConflictKey[oneKeyInfo.Tp.Index][oneKeyInfo.ConflictType].Inc(1)
 }
 
+// the key counted as equal by its value is taken back before it's counted as the attribute conflict
+func (p *VerifierBase) incrAttributeConflict(oneKeyInfo *common.Key, conflictType common.ConflictType) {
+	if oneKeyInfo.ConflictType == common.NoneConflict {
+		p.Stat.ConflictKey[oneKeyInfo.Tp.Index][common.NoneConflict].Inc(-1)
+	}
+	oneKeyInfo.ConflictType = conflictType
+	p.IncrKeyStat(oneKeyInfo)
+}
+
+// log the differing fields of the key, at most DiffFieldLimit fields are printed
+func (p *VerifierBase) LogConflictField(oneKeyInfo *common.Key) {
+	if p.Param.DiffFieldLimit <= 0 || len(oneKeyInfo.Field) == 0 {
+		return
+	}
+
+	fields := make([]string, 0, p.Param.DiffFieldLimit)
+	for i := 0; i < len(oneKeyInfo.Field) && i < p.Param.DiffFieldLimit; i++ {
+		fields = append(fields, fmt.Sprintf("%s(%s)", common.EncodeName(oneKeyInfo.Field[i].Field), oneKeyInfo.Field[i].ConflictType))
+	}
+	more := ""
+	if len(oneKeyInfo.Field) > p.Param.DiffFieldLimit {
+		more = fmt.Sprintf(" and %d more", len(oneKeyInfo.Field)-p.Param.DiffFieldLimit)
+	}
+	common.Logger.Infof("%s key[%s] conflict fields: %s%s", oneKeyInfo.Tp.Name, common.EncodeName(oneKeyInfo.Key),
+		strings.Join(fields, ", "), more)
+}
+
+/*
+ * Run the fetches of the source and target concurrently, so the latency is the slower one instead of
+ * the sum. The error of the source is returned first, and the panic of the source is passed on to
+ * the caller as the target one.
+ */
+func fetchBoth(source, target func() error) error {
+	var sourceErr error
+	var sourcePanic interface{}
+	done := make(chan struct{})
+	go func() {
+		defer func() {
+			sourcePanic = recover()
+			close(done)
+		}()
+		sourceErr = source()
+	}()
+	targetErr := target()
+	<-done
+	if sourcePanic != nil {
+		panic(sourcePanic)
+	}
+	if sourceErr != nil {
+		return sourceErr
+	}
+	return targetErr
+}
+
+// verify the key again when the key type is changed during the comparison, e.g., string vs hash
+func (p *VerifierBase) CheckTypeChanged(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, err error) bool {
+	if err != client.TypeChangedError {
+		return false
+	}
+	common.Logger.Debug(common.LogFields("key type changed during the comparison", "db", oneKeyInfo.Db, "key", common.EncodeName(oneKeyInfo.Key)))
+	p.RequeueTypeChanged(oneKeyInfo, conflictKey)
+	return true
+}
+
+/*
+ * The key is deleted and recreated in another type after its type is fetched, so the value would be
+ * fetched or compared by the stale type. Mark it to be verified again from fetching the type by the
+ * verifier, it's reported as type conflict after MaxTypeChangedRetry times.
+ */
+func (p *VerifierBase) RequeueTypeChanged(oneKeyInfo *common.Key, conflictKey chan<- *common.Key) {
+	oneKeyInfo.Field = nil
+	if oneKeyInfo.TypeRetry >= MaxTypeChangedRetry {
+		oneKeyInfo.ConflictType = common.TypeConflict
+		p.IncrKeyStat(oneKeyInfo)
+		conflictKey <- oneKeyInfo
+		return
+	}
+	oneKeyInfo.TypeRetry++
+	oneKeyInfo.Tp = common.EndKeyType
+	oneKeyInfo.ConflictType = common.EndConflict
+	oneKeyInfo.Requeued = true
+}
+
+/*
+ * The malformed reply doesn't stop the whole process, the key is reported as value conflict without
+ * fields so it's compared again in the next round.
+ */
+func (p *VerifierBase) CheckMalformedReply(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, err error) bool {
+	if errors.Is(err, client.MalformedReplyError) == false {
+		return false
+	}
+	common.Logger.Error(common.LogFields(err.Error(), "db", oneKeyInfo.Db, "key", common.EncodeName(oneKeyInfo.Key)))
+	oneKeyInfo.Field = nil
+	oneKeyInfo.ConflictType = common.ValueConflict
+	p.IncrKeyStat(oneKeyInfo)
+	conflictKey <- oneKeyInfo
+	return true
+}
+
+/*
+ * The key whose length is 0 on the target, it's either missing or existing but empty, e.g., the empty
+ * string. The key missing on the target is reported as lack_target, and the empty one as value conflict
+ * when the source isn't empty. When the source is empty as well, the key missing on the target is still
+ * reported as lack_target unless EmptyAsMissing is enabled. Return true if the key is handled.
+ */
+func (p *VerifierBase) CheckEmptyKey(oneKeyInfo *common.Key, conflictKey chan<- *common.Key) bool {
+	if oneKeyInfo.TargetAttr.ItemCount != 0 {
+		return false
+	}
+	switch {
+	case oneKeyInfo.SourceAttr.ItemCount != 0 && oneKeyInfo.TargetAbsent:
+		oneKeyInfo.ConflictType = common.LackTargetConflict
+	case oneKeyInfo.SourceAttr.ItemCount != 0:
+		oneKeyInfo.ConflictType = common.ValueConflict
+	case oneKeyInfo.SourceAbsent == false && oneKeyInfo.TargetAbsent && p.Param.EmptyAsMissing == false:
+		oneKeyInfo.ConflictType = common.LackTargetConflict
+	default:
+		// empty on both sides, or missing on both sides
+		oneKeyInfo.ConflictType = common.NoneConflict
+	}
+	p.IncrKeyStat(oneKeyInfo)
+	if oneKeyInfo.ConflictType != common.NoneConflict {
+		conflictKey <- oneKeyInfo
+	}
+	return true
+}
+
+// the key existing in different types is reported without comparing the value
+func (p *VerifierBase) CheckTypeMismatch(oneKeyInfo *common.Key, conflictKey chan<- *common.Key) bool {
+	if len(oneKeyInfo.TargetType) == 0 {
+		return false
+	}
+	oneKeyInfo.Field = []common.Field{{
+		Field:        []byte(oneKeyInfo.Tp.Name + "->" + oneKeyInfo.TargetType),
+		ConflictType: common.TypeMismatchConflict,
+	}}
+	oneKeyInfo.ConflictType = common.TypeMismatchConflict
+	p.IncrKeyStat(oneKeyInfo)
+	conflictKey <- oneKeyInfo
+	return true
+}
+
 func (p *VerifierBase) IncrFieldStat(oneKeyInfo *common.Key, conType common.ConflictType) {
 	p.Stat.ConflictField[oneKeyInfo.Tp.Index][conType].Inc(1)
 }
@@ -39,62 +263,283 @@ func (p *VerifierBase) FetchTypeAndLen(keyInfo []*common.Key, sourceClient, targ
 	}
 	for i, t := range sourceKeyTypeStr {
 		keyInfo[i].Tp = common.NewKeyType(t)
+		// the type name of the module is given by the module itself, e.g., ReJSON-RL
+		if keyInfo[i].Tp == common.EndKeyType &&
+				common.MatchValueCommand(p.Param.SourceHost.ValueCommand, keyInfo[i].Key) != nil {
+			keyInfo[i].Tp = common.ModuleKeyType
+		}
 		// fmt.Printf("key:%v, type:%v cmd:%v\n", string(keyInfo[i].Key), t, keyInfo[i].Tp.FetchLenCommand)
 	}
 
-	var wg sync.WaitGroup
-	wg.Add(1)
-	// fetch len
-	go func() {
+	// fetch the len on both sides, and the type on the target, the key existing in a different type is
+	// reported as type-mismatch
+	err = fetchBoth(func() error {
 		sourceKeyLen, err := sourceClient.PipeLenCommand(keyInfo)
 		if err != nil {
-			panic(common.Logger.Critical(err))
+			return err
 		}
 		for i, keylen := range sourceKeyLen {
 			keyInfo[i].SourceAttr.ItemCount = keylen
 		}
-		wg.Done()
-	}()
+		return nil
+	}, func() error {
+		targetKeyTypeStr, err := targetClient.PipeTypeCommand(keyInfo)
+		if err != nil {
+			return err
+		}
+		for i, t := range targetKeyTypeStr {
+			keyInfo[i].SourceAbsent = false
+			keyInfo[i].TargetAbsent = t == common.NoneKeyType.Name
+			keyInfo[i].TargetType = ""
+			if t != sourceKeyTypeStr[i] && t != common.NoneKeyType.Name && sourceKeyTypeStr[i] != common.NoneKeyType.Name {
+				keyInfo[i].TargetType = t
+			}
+		}
 
-	wg.Add(1)
-	go func() {
 		targetKeyLen, err := targetClient.PipeLenCommand(keyInfo)
 		if err != nil {
-			panic(common.Logger.Critical(err))
+			return err
 		}
 		for i, keylen := range targetKeyLen {
 			keyInfo[i].TargetAttr.ItemCount = keylen
 		}
-		wg.Done()
-	}()
-
-	wg.Wait()
+		return nil
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
 }
 
-func (p *VerifierBase) RecheckTTL(keyInfo []*common.Key, client *client.RedisClient) {
-	reCheckKeys := make([]*common.Key, 0, len(keyInfo))
+/*
+ * The key missing on one side may have expired on the other side but not been deleted lazily yet. The
+ * ttl is fetched on the side holding the key, which also deletes it if expired, and the key expired or
+ * expiring within ExpireWindow is regarded as missing on that side as well. The side holding the key
+ * only on the target is checked when ExpireWindow is set.
+ */
+func (p *VerifierBase) RecheckTTL(keyInfo []*common.Key, sourceClient, targetClient *client.RedisClient) {
+	lackTarget := make([]*common.Key, 0, len(keyInfo))
+	lackSource := make([]*common.Key, 0)
 	for _, key := range keyInfo {
 		if key.TargetAttr.ItemCount == 0 && key.SourceAttr.ItemCount > 0 {
-			reCheckKeys = append(reCheckKeys, key)
+			lackTarget = append(lackTarget, key)
+		} else if key.SourceAttr.ItemCount == 0 && key.TargetAttr.ItemCount > 0 && key.Tp != common.NoneKeyType &&
+				p.Param.ExpireWindow > 0 {
+			lackSource = append(lackSource, key)
+		}
+	}
+	for i, expire := range p.recheckTTL(lackTarget, sourceClient) {
+		if expire {
+			lackTarget[i].SourceAttr.ItemCount = 0
+			lackTarget[i].SourceAbsent = true
 		}
 	}
-	if len(reCheckKeys) != 0 {
-		p.recheckTTL(reCheckKeys, client)
+	for i, expire := range p.recheckTTL(lackSource, targetClient) {
+		if expire {
+			lackSource[i].TargetAttr.ItemCount = 0
+			lackSource[i].TargetAbsent = true
+		}
 	}
 }
 
-func (p *VerifierBase) recheckTTL(keyInfo []*common.Key, client *client.RedisClient) {
-	keyExpire, err := client.PipeTTLCommand(keyInfo)
+// return whether the keys are expired, or expiring within ExpireWindow when it's set
+func (p *VerifierBase) recheckTTL(keyInfo []*common.Key, client *client.RedisClient) []bool {
+	if len(keyInfo) == 0 {
+		return nil
+	}
+	if p.Param.ExpireWindow <= 0 {
+		keyExpire, err := client.PipeTTLCommand(keyInfo)
+		if err != nil {
+			panic(common.Logger.Critical(err))
+		}
+		return keyExpire
+	}
+
+	pttl, err := client.PipePTTLCommand(keyInfo)
 	if err != nil {
 		panic(common.Logger.Critical(err))
 	}
-	for i, expire := range keyExpire {
-		if expire {
-			keyInfo[i].SourceAttr.ItemCount = 0
+	keyExpire := make([]bool, len(pttl))
+	for i, ttl := range pttl {
+		// -2 means deleted since expired, -1 means persistent
+		keyExpire[i] = ttl == -2 || (ttl >= 0 && ttl <= p.Param.ExpireWindow)
+		if keyExpire[i] {
+			common.Logger.Debugf("key[%s] missing on one side is expiring on the other side, pttl[%d]",
+				common.EncodeName(keyInfo[i].Key), ttl)
+		}
+	}
+	return keyExpire
+}
+
+// compare the attributes of the keys whose value is equal, e.g., ttl and object encoding
+func (p *VerifierBase) VerifyAttribute(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) {
+	keyInfo = p.VerifyExpire(keyInfo, conflictKey, sourceClient, targetClient)
+	keyInfo = p.VerifyEncoding(keyInfo, conflictKey, sourceClient, targetClient)
+	p.VerifyMemoryUsage(keyInfo, conflictKey, sourceClient, targetClient)
+}
+
+/*
+ * Compare the ttl of the keys whose value is equal. The key is marked as expire conflict when
+ * the key is persistent on one side but volatile on the other, or the difference of the remaining
+ * ttl aligned to the same instant exceeds the tolerance. Return the keys without conflict.
+ */
+func (p *VerifierBase) VerifyExpire(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) []*common.Key {
+	if p.Param.CompareTTL == false || len(keyInfo) == 0 {
+		return keyInfo
+	}
+
+	// the time just before fetching the ttl on each side, to align the ttl to the same instant
+	var sourceTTL, targetTTL []int64
+	var sourceAt, targetAt time.Time
+	err := fetchBoth(func() (err error) {
+		sourceAt = time.Now()
+		sourceTTL, err = sourceClient.PipePTTLCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		targetAt = time.Now()
+		targetTTL, err = targetClient.PipePTTLCommand(keyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	equalKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	for i := 0; i < len(keyInfo); i++ {
+		// key has been expired or deleted on one side, leave it to the next round
+		if sourceTTL[i] == -2 || targetTTL[i] == -2 {
+			continue
+		}
+
+		diff := common.AlignedTTLDiff(sourceAt, sourceTTL[i], targetAt, targetTTL[i])
+		if (sourceTTL[i] == -1) != (targetTTL[i] == -1) || diff > p.Param.TTLTolerance {
+			p.incrAttributeConflict(keyInfo[i], common.ExpireConflict)
+			conflictKey <- keyInfo[i]
+			continue
+		}
+		equalKeyInfo = append(equalKeyInfo, keyInfo[i])
+	}
+	return equalKeyInfo
+}
+
+/*
+ * Compare the object encoding of the keys whose value is equal. The encoding depends on the size
+ * threshold configuration, e.g., hash-max-ziplist-entries, so it's reported as encoding conflict
+ * which is different from the value conflict. Return the keys without conflict.
+ */
+func (p *VerifierBase) VerifyEncoding(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) []*common.Key {
+	if p.Param.CompareEncoding == false || len(keyInfo) == 0 {
+		return keyInfo
+	}
+
+	var sourceEncoding, targetEncoding []string
+	err := fetchBoth(func() (err error) {
+		sourceEncoding, err = sourceClient.PipeObjectEncodingCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		targetEncoding, err = targetClient.PipeObjectEncodingCommand(keyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	equalKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	for i := 0; i < len(keyInfo); i++ {
+		// key has been deleted on one side, leave it to the next round
+		if sourceEncoding[i] == "" || targetEncoding[i] == "" {
+			continue
 		}
+
+		if sourceEncoding[i] != targetEncoding[i] {
+			common.Logger.Debugf("key[%s] encoding conflict: source[%s] target[%s]", common.EncodeName(keyInfo[i].Key),
+				sourceEncoding[i], targetEncoding[i])
+			p.incrAttributeConflict(keyInfo[i], common.EncodingConflict)
+			conflictKey <- keyInfo[i]
+			continue
+		}
+		equalKeyInfo = append(equalKeyInfo, keyInfo[i])
 	}
+	return equalKeyInfo
 }
 
+/*
+ * Compare the memory usage of the keys whose value is equal. The key is marked as memory conflict
+ * when the difference exceeds the ratio of the smaller one. "memory usage" is supported since
+ * redis 4.0, the comparison is skipped when the command isn't supported.
+ */
+func (p *VerifierBase) VerifyMemoryUsage(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) {
+	if p.Param.MemoryRatio <= 0 || len(keyInfo) == 0 {
+		return
+	}
+
+	sourceMemory, targetMemory := p.FetchMemoryUsage(keyInfo, sourceClient, targetClient)
+	if sourceMemory == nil {
+		return
+	}
+
+	for i := 0; i < len(keyInfo); i++ {
+		// key has been deleted on one side, leave it to the next round
+		if sourceMemory[i] < 0 || targetMemory[i] < 0 {
+			continue
+		}
+
+		diff, min := sourceMemory[i]-targetMemory[i], sourceMemory[i]
+		if diff < 0 {
+			diff = -diff
+			min = targetMemory[i]
+		}
+		if float64(diff) > float64(min)*p.Param.MemoryRatio {
+			common.Logger.Debugf("key[%s] memory conflict: source[%d] target[%d]", common.EncodeName(keyInfo[i].Key),
+				sourceMemory[i], targetMemory[i])
+			p.incrAttributeConflict(keyInfo[i], common.MemoryConflict)
+			conflictKey <- keyInfo[i]
+		}
+	}
+}
+
+// fetch the memory usage of both sides, nil is returned when "memory usage" isn't supported
+func (p *VerifierBase) FetchMemoryUsage(keyInfo []*common.Key, sourceClient,
+		targetClient *client.RedisClient) ([]int64, []int64) {
+	if atomic.LoadInt32(&memoryUsageUnsupported) == 1 {
+		return nil, nil
+	}
+
+	var sourceMemory, targetMemory []int64
+	fetch := func(c *client.RedisClient, memory *[]int64) error {
+		var err error
+		*memory, err = c.PipeMemoryUsageCommand(keyInfo)
+		// the command is unknown or disabled on the server
+		if client.IsErrorReply(err) {
+			if atomic.CompareAndSwapInt32(&memoryUsageUnsupported, 0, 1) {
+				common.Logger.Warnf("%v doesn't support memory usage[%v], skip memory comparison", c, err)
+			}
+			*memory = nil
+			return nil
+		}
+		return err
+	}
+	err := fetchBoth(func() error {
+		return fetch(sourceClient, &sourceMemory)
+	}, func() error {
+		return fetch(targetClient, &targetMemory)
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	if sourceMemory == nil || targetMemory == nil {
+		return nil, nil
+	}
+	return sourceMemory, targetMemory
+}
+
+// set when the "memory usage" command isn't supported
+var memoryUsageUnsupported int32
+
 type IVerifier interface {
 	VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient,
 		targetClient *client.RedisClient)
@@ -0,0 +1,181 @@
+package checker
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"full_check/client"
+	"full_check/common"
+	"full_check/metric"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+/*
+ * The redis server answering every command by reply with the arguments in lower case, e.g., ":1\r\n" or
+ * "-ERR unknown command\r\n", the connection is closed when the reply is empty. PING and SELECT are
+ * answered by the server itself.
+ */
+func fakeServer(t *testing.T, reply func(args []string) string) (string, func()) {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed[%v]", err)
+	}
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				reader := bufio.NewReader(conn)
+				for {
+					// *<n>\r\n followed by n of $<len>\r\n<arg>\r\n
+					line, err := reader.ReadString('\n')
+					if err != nil {
+						return
+					}
+					n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+					args := make([]string, 0, n)
+					for i := 0; i < n; i++ {
+						reader.ReadString('\n')
+						arg, _ := reader.ReadString('\n')
+						args = append(args, strings.ToLower(strings.TrimSpace(arg)))
+					}
+					switch args[0] {
+					case "ping":
+						conn.Write([]byte("+PONG\r\n"))
+					case "select":
+						conn.Write([]byte("+OK\r\n"))
+					default:
+						r := reply(args)
+						if len(r) == 0 {
+							return
+						}
+						conn.Write([]byte(r))
+					}
+				}
+			}(conn)
+		}
+	}()
+	return listener.Addr().String(), func() { listener.Close() }
+}
+
+func fakeClient(t *testing.T, role, addr string) *client.RedisClient {
+	c, err := client.NewRedisClient(client.RedisHost{Addr: []string{addr}, Role: role, Authtype: "auth",
+		RetryCount: 1, RetryBackoff: common.Backoff{Interval: time.Millisecond}}, 0)
+	if err != nil {
+		t.Fatalf("connect %v failed[%v]", addr, err)
+	}
+	return &c
+}
+
+// the warnings are written into the returned buffer until the logger is restored
+func captureWarning() (*bytes.Buffer, func()) {
+	var buf bytes.Buffer
+	logger := common.Logger
+	common.Logger, _ = seelog.LoggerFromWriterWithMinLevelAndFormat(&buf, seelog.WarnLvl, "%Msg%n")
+	return &buf, func() {
+		common.Logger = logger
+	}
+}
+
+// MEMORY USAGE of the key "a" is memory, the other keys don't exist
+func memoryReply(memory int) func(args []string) string {
+	return func(args []string) string {
+		if args[0] == "memory" && args[2] == "a" {
+			return fmt.Sprintf(":%d\r\n", memory)
+		}
+		return "$-1\r\n"
+	}
+}
+
+func TestFetchMemoryUsage(t *testing.T) {
+	log, restore := captureWarning()
+	defer restore()
+	defer atomic.StoreInt32(&memoryUsageUnsupported, 0)
+
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestFetchMemoryUsage case %d.\n", nr)
+
+		atomic.StoreInt32(&memoryUsageUnsupported, 0)
+		sourceAddr, closeSource := fakeServer(t, memoryReply(100))
+		defer closeSource()
+		targetAddr, closeTarget := fakeServer(t, memoryReply(300))
+		defer closeTarget()
+
+		keyInfo := []*common.Key{{Key: []byte("a"), Tp: common.StringKeyType}, {Key: []byte("b"), Tp: common.StringKeyType}}
+		p := &VerifierBase{Stat: &metric.Stat{}, Param: &FullCheckParameter{MemoryRatio: 0.5}}
+		sourceMemory, targetMemory := p.FetchMemoryUsage(keyInfo, fakeClient(t, "source", sourceAddr),
+			fakeClient(t, "target", targetAddr))
+		assert.Equal(t, []int64{100, -1}, sourceMemory, "should be equal")
+		assert.Equal(t, []int64{300, -1}, targetMemory, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestFetchMemoryUsage case %d.\n", nr)
+
+		// the memory conflict of the key counted as equal by the value is counted once
+		atomic.StoreInt32(&memoryUsageUnsupported, 0)
+		sourceAddr, closeSource := fakeServer(t, memoryReply(100))
+		defer closeSource()
+		targetAddr, closeTarget := fakeServer(t, memoryReply(300))
+		defer closeTarget()
+
+		keyInfo := []*common.Key{{Key: []byte("a"), Tp: common.StringKeyType, ConflictType: common.NoneConflict}}
+		p := &VerifierBase{Stat: &metric.Stat{}, Param: &FullCheckParameter{MemoryRatio: 0.5}}
+		p.IncrKeyStat(keyInfo[0])
+		conflictKey := make(chan *common.Key, 1)
+		p.VerifyMemoryUsage(keyInfo, conflictKey, fakeClient(t, "source", sourceAddr),
+			fakeClient(t, "target", targetAddr))
+		assert.Equal(t, 1, len(conflictKey), "should be equal")
+		assert.Equal(t, common.MemoryConflict, keyInfo[0].ConflictType, "should be equal")
+		stat := p.Stat.ConflictKey[common.StringTypeIndex]
+		assert.Equal(t, int64(0), stat[common.NoneConflict].Total(), "should be equal")
+		assert.Equal(t, int64(1), stat[common.MemoryConflict].Total(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestFetchMemoryUsage case %d.\n", nr)
+
+		// the comparison is skipped with a warning when the target doesn't support memory usage
+		atomic.StoreInt32(&memoryUsageUnsupported, 0)
+		sourceAddr, closeSource := fakeServer(t, memoryReply(100))
+		defer closeSource()
+		var targetMemoryCommands int32
+		targetAddr, closeTarget := fakeServer(t, func(args []string) string {
+			atomic.AddInt32(&targetMemoryCommands, 1)
+			return "-ERR unknown command 'memory'\r\n"
+		})
+		defer closeTarget()
+
+		keyInfo := []*common.Key{{Key: []byte("a"), Tp: common.StringKeyType, ConflictType: common.NoneConflict}}
+		p := &VerifierBase{Stat: &metric.Stat{}, Param: &FullCheckParameter{MemoryRatio: 0.5}}
+		sourceClient, targetClient := fakeClient(t, "source", sourceAddr), fakeClient(t, "target", targetAddr)
+		sourceMemory, targetMemory := p.FetchMemoryUsage(keyInfo, sourceClient, targetClient)
+		assert.Equal(t, []int64(nil), sourceMemory, "should be equal")
+		assert.Equal(t, []int64(nil), targetMemory, "should be equal")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&memoryUsageUnsupported), "should be equal")
+		assert.Equal(t, true, strings.Contains(log.String(), "doesn't support memory usage"), "should be equal")
+
+		// not sent any more
+		conflictKey := make(chan *common.Key, 1)
+		p.VerifyMemoryUsage(keyInfo, conflictKey, sourceClient, targetClient)
+		assert.Equal(t, 0, len(conflictKey), "should be equal")
+		assert.Equal(t, common.NoneConflict, keyInfo[0].ConflictType, "should be equal")
+		assert.Equal(t, int32(1), atomic.LoadInt32(&targetMemoryCommands), "should be equal")
+	}
+}
@@ -0,0 +1,170 @@
+package checker
+
+import (
+	"sync/atomic"
+
+	"full_check/client"
+	"full_check/common"
+	"full_check/metric"
+)
+
+/*
+ * DigestVerifier compares the digest(DEBUG DIGEST-VALUE) of the keys which is independent of the
+ * encoding, so the value isn't transferred. The keys are compared by the full value verifier when
+ * the debug command isn't available.
+ */
+type DigestVerifier struct {
+	VerifierBase
+	fallback *FullValueVerifier
+	script   bool // the digest is computed by the lua script instead of the debug command
+}
+
+func NewDigestVerifier(stat *metric.Stat, param *FullCheckParameter) *DigestVerifier {
+	return &DigestVerifier{
+		VerifierBase: VerifierBase{stat, param},
+		fallback:     NewFullValueVerifier(stat, param, false),
+	}
+}
+
+// the lua script loads the whole value into memory, so the big keys are compared by the full value verifier
+func NewScriptDigestVerifier(stat *metric.Stat, param *FullCheckParameter) *DigestVerifier {
+	verifier := NewDigestVerifier(stat, param)
+	verifier.script = true
+	return verifier
+}
+
+func (p *DigestVerifier) unsupported() *int32 {
+	if p.script {
+		return &scriptUnsupported
+	}
+	return &digestUnsupported
+}
+
+func (p *DigestVerifier) fetchDigest(keyInfo []*common.Key, client *client.RedisClient) ([]string, error) {
+	if p.script {
+		return client.PipeScriptDigestCommand(keyInfo)
+	}
+	return client.PipeDigestCommand(keyInfo)
+}
+
+func (p *DigestVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
+	if atomic.LoadInt32(p.unsupported()) == 1 {
+		p.fallback.VerifyOneGroupKeyInfo(keyInfo, conflictKey, sourceClient, targetClient)
+		return
+	}
+
+	p.FetchTypeAndLen(keyInfo, sourceClient, targetClient)
+
+	// re-check ttl on the source side when key missing on the target side
+	p.RecheckTTL(keyInfo, sourceClient, targetClient)
+
+	// compare, filter
+	digestKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	bigKeyInfo := make([]*common.Key, 0)
+	for i := 0; i < len(keyInfo); i++ {
+		keyInfo[i].Field = nil
+
+		// key has been deleted on the source redis
+		if keyInfo[i].Tp == common.NoneKeyType {
+			keyInfo[i].ConflictType = common.NoneConflict
+			p.IncrKeyStat(keyInfo[i])
+			continue
+		}
+
+		// type changed on the source redis
+		if keyInfo[i].SourceAttr.ItemCount == common.TypeChanged {
+			continue
+		}
+
+		// key lack in target redis, or empty on either side
+		if p.CheckEmptyKey(keyInfo[i], conflictKey) {
+			continue
+		}
+
+		if p.CheckTypeMismatch(keyInfo[i], conflictKey) {
+			continue
+		}
+
+		// type mismatch
+		if keyInfo[i].TargetAttr.ItemCount == common.TypeChanged {
+			keyInfo[i].ConflictType = common.TypeConflict
+			p.IncrKeyStat(keyInfo[i])
+			conflictKey <- keyInfo[i]
+			continue
+		}
+
+		// the length of HyperLogLog differs between sparse and dense encoding
+		if keyInfo[i].SourceAttr.ItemCount != keyInfo[i].TargetAttr.ItemCount && p.Param.CompareHll == false {
+			keyInfo[i].ConflictType = common.ValueConflict
+			p.IncrKeyStat(keyInfo[i])
+			conflictKey <- keyInfo[i]
+			continue
+		}
+
+		if p.script && keyInfo[i].SourceAttr.ItemCount > common.BigKeyThreshold {
+			keyInfo[i].ConflictType = common.EndConflict
+			bigKeyInfo = append(bigKeyInfo, keyInfo[i])
+			continue
+		}
+
+		digestKeyInfo = append(digestKeyInfo, keyInfo[i])
+	}
+	if len(bigKeyInfo) != 0 {
+		p.fallback.VerifyOneGroupKeyInfo(bigKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+	if len(digestKeyInfo) == 0 {
+		return
+	}
+
+	var sourceDigest, targetDigest []string
+	err := fetchBoth(func() (err error) {
+		sourceDigest, err = p.fetchDigest(digestKeyInfo, sourceClient)
+		return err
+	}, func() (err error) {
+		targetDigest, err = p.fetchDigest(digestKeyInfo, targetClient)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	equalKeyInfo := make([]*common.Key, 0, len(digestKeyInfo))
+	fallbackKeyInfo := make([]*common.Key, 0)
+	for i, oneKeyInfo := range digestKeyInfo {
+		if len(sourceDigest[i]) == 0 || len(targetDigest[i]) == 0 {
+			// the type and length have been fetched, compare them as the first round
+			oneKeyInfo.ConflictType = common.EndConflict
+			fallbackKeyInfo = append(fallbackKeyInfo, oneKeyInfo)
+			continue
+		}
+
+		// the HyperLogLog is compared by the value when enabled, its digest differs in different encoding
+		if sourceDigest[i] != targetDigest[i] && oneKeyInfo.Tp == common.StringKeyType && p.Param.CompareHll {
+			oneKeyInfo.ConflictType = common.EndConflict
+			fallbackKeyInfo = append(fallbackKeyInfo, oneKeyInfo)
+		} else if sourceDigest[i] != targetDigest[i] {
+			oneKeyInfo.ConflictType = common.ValueConflict
+			p.IncrKeyStat(oneKeyInfo)
+			conflictKey <- oneKeyInfo
+		} else {
+			oneKeyInfo.ConflictType = common.NoneConflict
+			p.IncrKeyStat(oneKeyInfo)
+			equalKeyInfo = append(equalKeyInfo, oneKeyInfo)
+		}
+	}
+
+	if len(fallbackKeyInfo) == len(digestKeyInfo) && atomic.CompareAndSwapInt32(p.unsupported(), 0, 1) {
+		if p.script {
+			common.Logger.Warnf("%v or %v doesn't run the digest script, fallback to compare full value",
+				sourceClient, targetClient)
+		} else {
+			common.Logger.Warnf("%v or %v doesn't support debug digest-value, fallback to compare full value",
+				sourceClient, targetClient)
+		}
+	}
+	if len(fallbackKeyInfo) != 0 {
+		p.fallback.VerifyOneGroupKeyInfo(fallbackKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+
+	p.VerifyAttribute(equalKeyInfo, conflictKey, sourceClient, targetClient)
+}
@@ -0,0 +1,155 @@
+package checker
+
+import (
+	"bytes"
+	"sync/atomic"
+
+	"full_check/client"
+	"full_check/common"
+	"full_check/metric"
+)
+
+/*
+ * DumpVerifier compares the serialized value(DUMP) of the keys without the footer of rdb version and
+ * crc, so every key is compared by one command including the module keys. The serialized value
+ * depends on the encoding, e.g., listpack or hashtable, so the keys whose payload differs are
+ * confirmed by the full value verifier except the module keys which can't be compared otherwise.
+ * The keys are also compared by the full value verifier when the rdb versions differ.
+ */
+type DumpVerifier struct {
+	VerifierBase
+	fallback      *FullValueVerifier
+	versionWarned int32
+}
+
+func NewDumpVerifier(stat *metric.Stat, param *FullCheckParameter) *DumpVerifier {
+	return &DumpVerifier{
+		VerifierBase: VerifierBase{stat, param},
+		fallback:     NewFullValueVerifier(stat, param, false),
+	}
+}
+
+func (p *DumpVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
+	if atomic.LoadInt32(&dumpUnsupported) == 1 {
+		p.fallback.VerifyOneGroupKeyInfo(keyInfo, conflictKey, sourceClient, targetClient)
+		return
+	}
+
+	p.FetchTypeAndLen(keyInfo, sourceClient, targetClient)
+
+	// re-check ttl on the source side when key missing on the target side
+	p.RecheckTTL(keyInfo, sourceClient, targetClient)
+
+	// compare, filter
+	dumpKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	bigKeyInfo := make([]*common.Key, 0)
+	for i := 0; i < len(keyInfo); i++ {
+		keyInfo[i].Field = nil
+
+		// key has been deleted on the source redis
+		if keyInfo[i].Tp == common.NoneKeyType {
+			keyInfo[i].ConflictType = common.NoneConflict
+			p.IncrKeyStat(keyInfo[i])
+			continue
+		}
+
+		// type changed on the source redis
+		if keyInfo[i].SourceAttr.ItemCount == common.TypeChanged {
+			continue
+		}
+
+		// key lack in target redis, or empty on either side
+		if p.CheckEmptyKey(keyInfo[i], conflictKey) {
+			continue
+		}
+
+		if p.CheckTypeMismatch(keyInfo[i], conflictKey) {
+			continue
+		}
+
+		// type mismatch
+		if keyInfo[i].TargetAttr.ItemCount == common.TypeChanged {
+			keyInfo[i].ConflictType = common.TypeConflict
+			p.IncrKeyStat(keyInfo[i])
+			conflictKey <- keyInfo[i]
+			continue
+		}
+
+		// the length of HyperLogLog differs between sparse and dense encoding
+		if keyInfo[i].SourceAttr.ItemCount != keyInfo[i].TargetAttr.ItemCount && p.Param.CompareHll == false {
+			keyInfo[i].ConflictType = common.ValueConflict
+			p.IncrKeyStat(keyInfo[i])
+			conflictKey <- keyInfo[i]
+			continue
+		}
+
+		// the payload carries the whole value, the big keys are fetched by scan instead
+		if keyInfo[i].Tp != common.StringKeyType && keyInfo[i].Tp != common.ModuleKeyType &&
+				keyInfo[i].SourceAttr.ItemCount > common.BigKeyThreshold {
+			keyInfo[i].ConflictType = common.EndConflict
+			bigKeyInfo = append(bigKeyInfo, keyInfo[i])
+			continue
+		}
+
+		dumpKeyInfo = append(dumpKeyInfo, keyInfo[i])
+	}
+	if len(bigKeyInfo) != 0 {
+		p.fallback.VerifyOneGroupKeyInfo(bigKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+	if len(dumpKeyInfo) == 0 {
+		return
+	}
+
+	var sourcePayload, targetPayload [][]byte
+	err := fetchBoth(func() (err error) {
+		sourcePayload, err = sourceClient.PipeDumpCommand(dumpKeyInfo)
+		return err
+	}, func() (err error) {
+		targetPayload, err = targetClient.PipeDumpCommand(dumpKeyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	equalKeyInfo := make([]*common.Key, 0, len(dumpKeyInfo))
+	fallbackKeyInfo := make([]*common.Key, 0)
+	failed := 0
+	for i, oneKeyInfo := range dumpKeyInfo {
+		sourceValue, sourceVersion, sourceOk := common.SplitDumpPayload(sourcePayload[i])
+		targetValue, targetVersion, targetOk := common.SplitDumpPayload(targetPayload[i])
+		switch {
+		case sourceOk == false || targetOk == false:
+			failed++
+		case sourceVersion != targetVersion:
+			if atomic.CompareAndSwapInt32(&p.versionWarned, 0, 1) {
+				common.Logger.Warnf("the rdb version of dump differs between %v[%d] and %v[%d], fallback to "+
+					"compare full value", sourceClient, sourceVersion, targetClient, targetVersion)
+			}
+		case bytes.Equal(sourceValue, targetValue):
+			oneKeyInfo.ConflictType = common.NoneConflict
+			p.IncrKeyStat(oneKeyInfo)
+			equalKeyInfo = append(equalKeyInfo, oneKeyInfo)
+			continue
+		case oneKeyInfo.Tp == common.ModuleKeyType &&
+				common.MatchValueCommand(p.Param.SourceHost.ValueCommand, oneKeyInfo.Key) == nil:
+			oneKeyInfo.ConflictType = common.ValueConflict
+			p.IncrKeyStat(oneKeyInfo)
+			conflictKey <- oneKeyInfo
+			continue
+		}
+		// the type and length have been fetched, compare them as the first round
+		oneKeyInfo.ConflictType = common.EndConflict
+		fallbackKeyInfo = append(fallbackKeyInfo, oneKeyInfo)
+	}
+
+	if failed == len(dumpKeyInfo) && atomic.CompareAndSwapInt32(&dumpUnsupported, 0, 1) {
+		common.Logger.Warnf("%v or %v doesn't support dump, fallback to compare full value",
+			sourceClient, targetClient)
+	}
+	if len(fallbackKeyInfo) != 0 {
+		p.fallback.VerifyOneGroupKeyInfo(fallbackKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+
+	p.VerifyAttribute(equalKeyInfo, conflictKey, sourceClient, targetClient)
+}
@@ -0,0 +1,125 @@
+package checker
+
+import (
+	"sync/atomic"
+	"time"
+
+	"full_check/client"
+	"full_check/common"
+	"full_check/metric"
+)
+
+// whether PEXPIRETIME is supported on one side, probed on the first group
+const (
+	expireTimeUnknown int32 = iota
+	expireTimeSupported
+	expireTimeUnsupported
+)
+
+/*
+ * ExpireVerifier only compares the absolute expire time of the keys(PEXPIRETIME), which doesn't drift
+ * with the time elapsed between fetching both sides as the remaining ttl does, the value isn't fetched.
+ * PEXPIRETIME is supported since redis 7.0, the side older than it fetches PTTL and turns it into the
+ * absolute time by the local clock. source_len and target_len of the conflict keys are the expire time.
+ */
+type ExpireVerifier struct {
+	VerifierBase
+	sourceSupport int32
+	targetSupport int32
+}
+
+func NewExpireVerifier(stat *metric.Stat, param *FullCheckParameter) *ExpireVerifier {
+	return &ExpireVerifier{VerifierBase: VerifierBase{stat, param}}
+}
+
+// only the error reply means unsupported, the network error is returned and it's probed again next time
+func (p *ExpireVerifier) supported(support *int32, c *client.RedisClient) (bool, error) {
+	if state := atomic.LoadInt32(support); state != expireTimeUnknown {
+		return state == expireTimeSupported, nil
+	}
+	// any key works, -2 is returned when it doesn't exist
+	if _, err := c.Do("pexpiretime", "full_check_probe"); client.IsErrorReply(err) {
+		if atomic.CompareAndSwapInt32(support, expireTimeUnknown, expireTimeUnsupported) {
+			common.Logger.Warnf("%v doesn't support pexpiretime[%v], fallback to pttl", c, err)
+		}
+		return false, nil
+	} else if err != nil {
+		return false, err
+	}
+	atomic.StoreInt32(support, expireTimeSupported)
+	return true, nil
+}
+
+func (p *ExpireVerifier) fetchExpireTime(keyInfo []*common.Key, support *int32,
+		client *client.RedisClient) ([]int64, error) {
+	if supported, err := p.supported(support, client); err != nil {
+		return nil, err
+	} else if supported {
+		return client.PipePExpireTimeCommand(keyInfo)
+	}
+
+	at := time.Now().UnixNano() / int64(time.Millisecond)
+	expire, err := client.PipePTTLCommand(keyInfo)
+	if err != nil {
+		return nil, err
+	}
+	for i := range expire {
+		if expire[i] >= 0 {
+			expire[i] += at
+		}
+	}
+	return expire, nil
+}
+
+func (p *ExpireVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
+	// the type on the source is only used in the statistics
+	var sourceType []string
+	var sourceExpire, targetExpire []int64
+	err := fetchBoth(func() (err error) {
+		if sourceType, err = sourceClient.PipeTypeCommand(keyInfo); err != nil {
+			return err
+		}
+		sourceExpire, err = p.fetchExpireTime(keyInfo, &p.sourceSupport, sourceClient)
+		return err
+	}, func() (err error) {
+		targetExpire, err = p.fetchExpireTime(keyInfo, &p.targetSupport, targetClient)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	for i, oneKeyInfo := range keyInfo {
+		oneKeyInfo.Tp = common.NewKeyType(sourceType[i])
+		// the type name of the module is given by the module itself
+		if oneKeyInfo.Tp == common.EndKeyType {
+			oneKeyInfo.Tp = common.ModuleKeyType
+		}
+		oneKeyInfo.Field = nil
+		oneKeyInfo.SourceAttr.ItemCount = sourceExpire[i]
+		oneKeyInfo.TargetAttr.ItemCount = targetExpire[i]
+
+		diff := sourceExpire[i] - targetExpire[i]
+		if diff < 0 {
+			diff = -diff
+		}
+		switch {
+		case oneKeyInfo.Tp == common.NoneKeyType || sourceExpire[i] == -2 || (sourceExpire[i] > 0 && sourceExpire[i] <= now):
+			// deleted or expired on the source
+			oneKeyInfo.ConflictType = common.NoneConflict
+		case targetExpire[i] == -2:
+			oneKeyInfo.ConflictType = common.LackTargetConflict
+		case (sourceExpire[i] == -1) != (targetExpire[i] == -1) || diff > p.Param.TTLTolerance:
+			common.Logger.Debugf("key[%s] expire conflict: source[%d] target[%d]", common.EncodeName(oneKeyInfo.Key),
+				sourceExpire[i], targetExpire[i])
+			oneKeyInfo.ConflictType = common.ExpireConflict
+		default:
+			oneKeyInfo.ConflictType = common.NoneConflict
+		}
+		p.IncrKeyStat(oneKeyInfo)
+		if oneKeyInfo.ConflictType != common.NoneConflict {
+			conflictKey <- oneKeyInfo
+		}
+	}
+}
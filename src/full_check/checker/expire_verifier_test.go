@@ -0,0 +1,86 @@
+package checker
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"full_check/common"
+	"full_check/metric"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestExpireTimeSupported(t *testing.T) {
+	log, restore := captureWarning()
+	defer restore()
+
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestExpireTimeSupported case %d.\n", nr)
+
+		// fallback to pttl with a warning when pexpiretime is unknown
+		addr, closeServer := fakeServer(t, func(args []string) string {
+			if args[0] == "pexpiretime" {
+				return "-ERR unknown command 'pexpiretime'\r\n"
+			}
+			return ":5000\r\n"
+		})
+		defer closeServer()
+
+		p := NewExpireVerifier(&metric.Stat{}, &FullCheckParameter{})
+		c := fakeClient(t, "source", addr)
+		supported, err := p.supported(&p.sourceSupport, c)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, false, supported, "should be equal")
+		assert.Equal(t, expireTimeUnsupported, p.sourceSupport, "should be equal")
+		assert.Equal(t, true, strings.Contains(log.String(), "doesn't support pexpiretime"), "should be equal")
+
+		now := time.Now().UnixNano() / int64(time.Millisecond)
+		expire, err := p.fetchExpireTime([]*common.Key{{Key: []byte("a")}}, &p.sourceSupport, c)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, true, expire[0] >= now+5000 && expire[0] < now+6000, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestExpireTimeSupported case %d.\n", nr)
+
+		addr, closeServer := fakeServer(t, func(args []string) string {
+			if args[0] == "pexpiretime" && args[1] == "a" {
+				return ":1700000000000\r\n"
+			}
+			return ":-2\r\n"
+		})
+		defer closeServer()
+
+		p := NewExpireVerifier(&metric.Stat{}, &FullCheckParameter{})
+		c := fakeClient(t, "target", addr)
+		supported, err := p.supported(&p.targetSupport, c)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, true, supported, "should be equal")
+		assert.Equal(t, expireTimeSupported, p.targetSupport, "should be equal")
+
+		expire, err := p.fetchExpireTime([]*common.Key{{Key: []byte("a")}, {Key: []byte("b")}}, &p.targetSupport, c)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []int64{1700000000000, -2}, expire, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestExpireTimeSupported case %d.\n", nr)
+
+		// the network error isn't regarded as unsupported, it's probed again next time
+		addr, closeServer := fakeServer(t, func(args []string) string {
+			return ""
+		})
+		defer closeServer()
+
+		p := NewExpireVerifier(&metric.Stat{}, &FullCheckParameter{})
+		_, err := p.supported(&p.sourceSupport, fakeClient(t, "source", addr))
+		assert.NotEqual(t, nil, err, "should be not equal")
+		assert.Equal(t, expireTimeUnknown, p.sourceSupport, "should be equal")
+	}
+}
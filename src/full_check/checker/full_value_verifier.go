@@ -3,15 +3,23 @@ package checker
 import (
 	"full_check/common"
 	"bytes"
+	"fmt"
 	"full_check/metric"
 	"full_check/client"
 	"strconv"
 	"reflect"
 	"math"
+	"sort"
+	"sync/atomic"
 )
 
 const(
 	StreamSegment = 5000
+	BitmapSegment = 64 * 1024 // byte
+	WindowBatch   = 4         // windows of the long string fetched in one pipeline
+
+	// the key recreated in other types more often is reported as type conflict
+	MaxTypeChangedRetry = 3
 )
 
 type FullValueVerifier struct {
@@ -39,11 +47,18 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 	}
 
 	// re-check ttl on the source side when key missing on the target side
-	p.RecheckTTL(keyInfo, sourceClient)
+	p.RecheckTTL(keyInfo, sourceClient, targetClient)
+
+	p.CompareDigest(keyInfo, sourceClient, targetClient)
+
+	tooLarge := p.findTooLarge(keyInfo, sourceClient, targetClient)
 
 	// compare, filter
 	fullCheckFetchAllKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	bitmapKeyInfo := make([]*common.Key, 0)
 	retryNewVerifyKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	shallowKeyInfo := make([]*common.Key, 0)
+	precheckKeyInfo := make([]*common.Key, 0)
 	for i := 0; i < len(keyInfo); i++ {
 		/************ 所有第一次比较的key，之前未比较的 key ***********/
 		if keyInfo[i].ConflictType == common.EndConflict { // 第二轮及以后比较的key，conflictType 肯定不是EndConflict
@@ -54,22 +69,19 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 				continue
 			}
 
-			// 在fetch type和之后的轮次扫描之间源端类型更改，不处理这种错误
+			// 在fetch type和之后的轮次扫描之间源端类型更改，重新取 type 后再比较
 			if keyInfo[i].SourceAttr.ItemCount == common.TypeChanged {
+				p.RequeueTypeChanged(keyInfo[i], conflictKey)
 				continue
 			}
 
-			// key lack in the target redis
-			if keyInfo[i].TargetAttr.ItemCount == 0 {
-				if keyInfo[i].TargetAttr.ItemCount != keyInfo[i].SourceAttr.ItemCount {
-					keyInfo[i].ConflictType = common.LackTargetConflict
-					p.IncrKeyStat(keyInfo[i])
-					conflictKey <- keyInfo[i]
-					continue
-				} else if keyInfo[i].SourceAttr.ItemCount == 0 {
-					// no need to check anymore
-					continue
-				}
+			// key lack in the target redis, or empty on either side
+			if p.CheckEmptyKey(keyInfo[i], conflictKey) {
+				continue
+			}
+
+			if p.CheckTypeMismatch(keyInfo[i], conflictKey) {
+				continue
 			}
 
 			// type mismatch, ItemCount == -1，表明key在target redis上的type与source不同
@@ -81,13 +93,24 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 			}
 
 			// string,  strlen mismatch, 先过滤一遍
-			if keyInfo[i].Tp == common.StringKeyType && keyInfo[i].SourceAttr.ItemCount != keyInfo[i].TargetAttr.ItemCount {
+			// the length of HyperLogLog differs between sparse and dense encoding, so compare the value later
+			if keyInfo[i].Tp == common.StringKeyType && keyInfo[i].SourceAttr.ItemCount != keyInfo[i].TargetAttr.ItemCount &&
+					p.Param.CompareHll == false {
 				keyInfo[i].ConflictType = common.ValueConflict
 				p.IncrKeyStat(keyInfo[i])
 				conflictKey <- keyInfo[i]
 				continue
 			}
 
+			if tooLarge[keyInfo[i]] && p.Param.SkipTooLarge {
+				p.SkipTooLargeKey(keyInfo[i], conflictKey)
+				continue
+			}
+			if tooLarge[keyInfo[i]] && p.Param.ShallowTooLarge {
+				shallowKeyInfo = append(shallowKeyInfo, keyInfo[i])
+				continue
+			}
+
 			// 太大的 hash、list、set、zset 特殊单独处理。
 			if keyInfo[i].Tp != common.StringKeyType &&
 					(keyInfo[i].SourceAttr.ItemCount > common.BigKeyThreshold ||
@@ -109,15 +132,28 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 				case common.HashKeyType:
 					fallthrough
 				case common.SetKeyType:
+					if p.isPrecheckSet(keyInfo[i]) && p.PrecheckSet(keyInfo[i], conflictKey, sourceClient, targetClient) {
+						precheckKeyInfo = append(precheckKeyInfo, keyInfo[i])
+						break
+					}
+					if p.isSpotCheckSet(keyInfo[i]) {
+						p.CompareSetBySismember(keyInfo[i], conflictKey, sourceClient, targetClient)
+						break
+					}
 					fallthrough
 				case common.ZsetKeyType:
-					sourceValue, err := sourceClient.FetchValueUseScan_Hash_Set_SortedSet(keyInfo[i], p.Param.BatchCount)
+					sourceValue, targetValue, err := p.fetchValueUseScan(keyInfo[i], sourceClient, targetClient)
 					if err != nil {
+						if p.CheckTypeChanged(keyInfo[i], conflictKey, err) || p.CheckMalformedReply(keyInfo[i], conflictKey, err) {
+							continue
+						}
 						panic(common.Logger.Error(err))
 					}
-					targetValue, err := targetClient.FetchValueUseScan_Hash_Set_SortedSet(keyInfo[i], p.Param.BatchCount)
-					if err != nil {
-						panic(common.Logger.Error(err))
+					if keyInfo[i].Tp == common.ZsetKeyType {
+						p.NormalizeGeo(keyInfo[i], sourceValue, targetValue, sourceClient, targetClient)
+						p.NormalizeScore(keyInfo[i], sourceValue, targetValue)
+					} else if keyInfo[i].Tp == common.HashKeyType {
+						p.TransformHash(keyInfo[i], sourceValue, targetValue)
 					}
 					p.Compare_Hash_Set_SortedSet(keyInfo[i], conflictKey, sourceValue, targetValue)
 				case common.ListKeyType:
@@ -128,12 +164,37 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 				continue
 			}
 
+			if p.isSpotCheckSet(keyInfo[i]) {
+				p.CompareSetBySismember(keyInfo[i], conflictKey, sourceClient, targetClient)
+				continue
+			}
+
+			if tooLarge[keyInfo[i]] {
+				p.CompareTooLarge(keyInfo[i], conflictKey, sourceClient, targetClient)
+				continue
+			}
+
 			// special handle for stream type
 			if keyInfo[i].Tp == common.StreamKeyType {
 				p.CompareStream(keyInfo[i], conflictKey, sourceClient, targetClient)
 				continue
 			}
 
+			if p.isLongList(keyInfo[i]) {
+				p.CheckFullBigValue_List(keyInfo[i], conflictKey, sourceClient, targetClient)
+				continue
+			}
+
+			if p.isBitmap(keyInfo[i]) {
+				bitmapKeyInfo = append(bitmapKeyInfo, keyInfo[i])
+				continue
+			}
+
+			if p.isLongString(keyInfo[i]) {
+				p.CompareStringByWindow(keyInfo[i], conflictKey, sourceClient, targetClient)
+				continue
+			}
+
 			// 剩下的都进入 fullCheckFetchAllKeyInfo(), pipeline + 一次性取全量数据的方式比较value
 			fullCheckFetchAllKeyInfo = append(fullCheckFetchAllKeyInfo, keyInfo[i])
 
@@ -143,7 +204,12 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 			// 这3种类型，重新比较
 			if keyInfo[i].ConflictType == common.LackSourceConflict ||
 				keyInfo[i].ConflictType == common.LackTargetConflict ||
-				keyInfo[i].ConflictType == common.TypeConflict {
+				keyInfo[i].ConflictType == common.TypeConflict ||
+				keyInfo[i].ConflictType == common.TypeMismatchConflict ||
+				keyInfo[i].ConflictType == common.ExpireConflict ||
+				keyInfo[i].ConflictType == common.EncodingConflict ||
+				keyInfo[i].ConflictType == common.MemoryConflict ||
+				keyInfo[i].ConflictType == common.SkippedConflict {
 				keyInfo[i].Tp = common.EndKeyType            // 重新取 type、len
 				keyInfo[i].ConflictType = common.EndConflict // 使用 第一轮比较用的方式
 				retryNewVerifyKeyInfo = append(retryNewVerifyKeyInfo, keyInfo[i])
@@ -167,14 +233,33 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 					continue
 				}
 
+				if tooLarge[keyInfo[i]] {
+					if p.Param.SkipTooLarge {
+						p.SkipTooLargeKey(keyInfo[i], conflictKey)
+						continue
+					} else if p.Param.ShallowTooLarge {
+						shallowKeyInfo = append(shallowKeyInfo, keyInfo[i])
+						continue
+					} else if keyInfo[i].Tp == common.StringKeyType || keyInfo[i].Tp == common.ListKeyType {
+						p.CompareTooLarge(keyInfo[i], conflictKey, sourceClient, targetClient)
+						continue
+					}
+				}
+
 				switch keyInfo[i].Tp {
 				// string 和 list 每次都要重新比较所有field value。
 				// list有lpush、lpop，会导致field value平移，所以需要重新比较所有field value
 				case common.StringKeyType:
-					fullCheckFetchAllKeyInfo = append(fullCheckFetchAllKeyInfo, keyInfo[i])
+					if p.isBitmap(keyInfo[i]) {
+						bitmapKeyInfo = append(bitmapKeyInfo, keyInfo[i])
+					} else if p.isLongString(keyInfo[i]) {
+						p.CompareStringByWindow(keyInfo[i], conflictKey, sourceClient, targetClient)
+					} else {
+						fullCheckFetchAllKeyInfo = append(fullCheckFetchAllKeyInfo, keyInfo[i])
+					}
 				case common.ListKeyType:
 					if keyInfo[i].SourceAttr.ItemCount > common.BigKeyThreshold ||
-							keyInfo[i].TargetAttr.ItemCount > common.BigKeyThreshold {
+							keyInfo[i].TargetAttr.ItemCount > common.BigKeyThreshold || p.isLongList(keyInfo[i]) {
 						p.CheckFullBigValue_List(keyInfo[i], conflictKey, sourceClient, targetClient)
 					} else {
 						fullCheckFetchAllKeyInfo = append(fullCheckFetchAllKeyInfo, keyInfo[i])
@@ -183,11 +268,18 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 				case common.HashKeyType:
 					p.CheckPartialValueHash(keyInfo[i], conflictKey, sourceClient, targetClient)
 				case common.SetKeyType:
-					p.CheckPartialValueSet(keyInfo[i], conflictKey, sourceClient, targetClient)
+					// only a sample of the conflict members is recorded for the large set
+					if p.isSpotCheckSet(keyInfo[i]) {
+						p.CompareSetBySismember(keyInfo[i], conflictKey, sourceClient, targetClient)
+					} else {
+						p.CheckPartialValueSet(keyInfo[i], conflictKey, sourceClient, targetClient)
+					}
 				case common.ZsetKeyType:
 					p.CheckPartialValueSortedSet(keyInfo[i], conflictKey, sourceClient, targetClient)
 				case common.StreamKeyType:
 					p.CompareStream(keyInfo[i], conflictKey, sourceClient, targetClient)
+				case common.ModuleKeyType:
+					fullCheckFetchAllKeyInfo = append(fullCheckFetchAllKeyInfo, keyInfo[i])
 				}
 				continue
 			}
@@ -197,27 +289,286 @@ func (p *FullValueVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflic
 	if len(fullCheckFetchAllKeyInfo) != 0 {
 		p.CheckFullValueFetchAll(fullCheckFetchAllKeyInfo, conflictKey, sourceClient, targetClient)
 	}
+
+	if len(bitmapKeyInfo) != 0 {
+		p.CompareBitmap(bitmapKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+
+	if len(shallowKeyInfo) != 0 {
+		p.CompareShallow(shallowKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+
+	// compare attributes of the keys whose value is equal
+	if p.Param.CompareAttribute() {
+		equalKeyInfo := make([]*common.Key, 0, len(keyInfo))
+		for _, oneKeyInfo := range keyInfo {
+			if oneKeyInfo.ConflictType == common.NoneConflict && oneKeyInfo.Tp != common.NoneKeyType {
+				equalKeyInfo = append(equalKeyInfo, oneKeyInfo)
+			}
+		}
+		p.VerifyAttribute(equalKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+	for _, oneKeyInfo := range append(shallowKeyInfo, precheckKeyInfo...) {
+		if oneKeyInfo.ConflictType == common.NoneConflict {
+			atomic.AddInt64(&p.Stat.ShallowKeys[oneKeyInfo.Tp.Index], 1)
+		}
+	}
+
+	// the keys whose type changed during the comparison are verified again from fetching the type
+	for _, oneKeyInfo := range keyInfo {
+		if oneKeyInfo.Requeued {
+			oneKeyInfo.Requeued = false
+			retryNewVerifyKeyInfo = append(retryNewVerifyKeyInfo, oneKeyInfo)
+		}
+	}
+
 	if len(retryNewVerifyKeyInfo) != 0 {
 		p.VerifyOneGroupKeyInfo(retryNewVerifyKeyInfo, conflictKey, sourceClient, targetClient)
 	}
 
 }
 
-func (p *FullValueVerifier) CheckFullValueFetchAll(keyInfo []*common.Key, conflictKey chan<- *common.Key,
+/*
+ * Compare the digest(DEBUG DIGEST-VALUE) of the keys before fetching the value, the keys whose digest
+ * are equal are marked as equal. The comparison is disabled when no digest is returned in one batch,
+ * e.g., the debug command isn't enabled.
+ */
+func (p *FullValueVerifier) CompareDigest(keyInfo []*common.Key, sourceClient, targetClient *client.RedisClient) {
+	if p.Param.CompareDigest == false || atomic.LoadInt32(&digestUnsupported) == 1 {
+		return
+	}
+
+	digestKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	for _, oneKeyInfo := range keyInfo {
+		if oneKeyInfo.ConflictType == common.ValueConflict ||
+				(oneKeyInfo.ConflictType == common.EndConflict && oneKeyInfo.Tp != common.NoneKeyType &&
+					oneKeyInfo.SourceAttr.ItemCount > 0 && oneKeyInfo.TargetAttr.ItemCount > 0) {
+			digestKeyInfo = append(digestKeyInfo, oneKeyInfo)
+		}
+	}
+	if len(digestKeyInfo) == 0 {
+		return
+	}
+
+	var sourceDigest, targetDigest []string
+	err := fetchBoth(func() (err error) {
+		sourceDigest, err = sourceClient.PipeDigestCommand(digestKeyInfo)
+		return err
+	}, func() (err error) {
+		targetDigest, err = targetClient.PipeDigestCommand(digestKeyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	fetched := false
+	for i, oneKeyInfo := range digestKeyInfo {
+		if len(sourceDigest[i]) == 0 || len(targetDigest[i]) == 0 {
+			continue
+		}
+		fetched = true
+		if sourceDigest[i] == targetDigest[i] {
+			oneKeyInfo.ConflictType = common.NoneConflict
+			oneKeyInfo.Field = nil
+			p.IncrKeyStat(oneKeyInfo)
+		}
+	}
+	if fetched == false && atomic.CompareAndSwapInt32(&digestUnsupported, 0, 1) {
+		common.Logger.Warnf("%v or %v doesn't support debug digest-value, skip digest comparison",
+			sourceClient, targetClient)
+	}
+}
+
+// set when the "debug digest-value" command isn't supported
+var digestUnsupported int32
+
+// set when the digest script fails on all the keys, e.g., scripting is disabled
+var scriptUnsupported int32
+
+// set when DUMP fails on all the keys, e.g., the command is renamed
+var dumpUnsupported int32
+
+/*
+ * Find the keys whose value exceeds maxvaluecount elements or maxvaluesize bytes. The length
+ * fetched before is used as the size of string, and "memory usage" is used for other types.
+ */
+func (p *FullValueVerifier) findTooLarge(keyInfo []*common.Key, sourceClient,
+		targetClient *client.RedisClient) map[*common.Key]bool {
+	if p.Param.MaxValueSize <= 0 && p.Param.MaxValueCount <= 0 {
+		return nil
+	}
+
+	tooLarge := make(map[*common.Key]bool)
+	memoryKeyInfo := make([]*common.Key, 0, len(keyInfo))
+	for _, oneKeyInfo := range keyInfo {
+		if oneKeyInfo.ConflictType != common.EndConflict && oneKeyInfo.ConflictType != common.ValueConflict {
+			continue
+		}
+		if oneKeyInfo.Tp == common.NoneKeyType || oneKeyInfo.Tp == common.EndKeyType ||
+				oneKeyInfo.Tp == common.ModuleKeyType {
+			continue
+		}
+
+		length := oneKeyInfo.SourceAttr.ItemCount
+		if length < oneKeyInfo.TargetAttr.ItemCount {
+			length = oneKeyInfo.TargetAttr.ItemCount
+		}
+		if oneKeyInfo.Tp == common.StringKeyType {
+			tooLarge[oneKeyInfo] = p.Param.MaxValueSize > 0 && length > p.Param.MaxValueSize
+			continue
+		}
+		if p.Param.MaxValueCount > 0 && length > p.Param.MaxValueCount {
+			tooLarge[oneKeyInfo] = true
+		} else if p.Param.MaxValueSize > 0 {
+			memoryKeyInfo = append(memoryKeyInfo, oneKeyInfo)
+		}
+	}
+
+	if len(memoryKeyInfo) != 0 {
+		sourceMemory, targetMemory := p.FetchMemoryUsage(memoryKeyInfo, sourceClient, targetClient)
+		for i := range sourceMemory {
+			if sourceMemory[i] > p.Param.MaxValueSize || targetMemory[i] > p.Param.MaxValueSize {
+				tooLarge[memoryKeyInfo[i]] = true
+			}
+		}
+	}
+	return tooLarge
+}
+
+// record the key as skipped instead of fetching its value
+func (p *FullValueVerifier) SkipTooLargeKey(oneKeyInfo *common.Key, conflictKey chan<- *common.Key) {
+	common.Logger.Debugf("skip key[%s] whose value is too large: source[%d] target[%d]", common.EncodeName(oneKeyInfo.Key),
+		oneKeyInfo.SourceAttr.ItemCount, oneKeyInfo.TargetAttr.ItemCount)
+	oneKeyInfo.ConflictType = common.SkippedConflict
+	oneKeyInfo.Field = nil
+	p.IncrKeyStat(oneKeyInfo)
+	conflictKey <- oneKeyInfo
+}
+
+/*
+ * Compare the keys too large only by the length and the object encoding when shallowtoolarge is set,
+ * so the memory isn't bounded by the value. The key whose length and encoding are both equal is
+ * regarded as equal, and counted as shallow by the caller since its value isn't compared.
+ */
+func (p *FullValueVerifier) CompareShallow(keyInfo []*common.Key, conflictKey chan<- *common.Key,
 		sourceClient, targetClient *client.RedisClient) {
-	// fetch value
-	sourceReply, err := sourceClient.PipeValueCommand(keyInfo)
+	if len(keyInfo) == 0 {
+		return
+	}
+
+	var sourceLen, targetLen []int64
+	var sourceEncoding, targetEncoding []string
+	err := fetchBoth(func() (err error) {
+		if sourceLen, err = sourceClient.PipeLenCommand(keyInfo); err != nil {
+			return err
+		}
+		sourceEncoding, err = sourceClient.PipeObjectEncodingCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		if targetLen, err = targetClient.PipeLenCommand(keyInfo); err != nil {
+			return err
+		}
+		targetEncoding, err = targetClient.PipeObjectEncodingCommand(keyInfo)
+		return err
+	})
 	if err != nil {
 		panic(common.Logger.Critical(err))
 	}
 
-	targetReply, err := targetClient.PipeValueCommand(keyInfo)
+	for i, oneKeyInfo := range keyInfo {
+		oneKeyInfo.Field = nil
+		if sourceLen[i] == common.TypeChanged || targetLen[i] == common.TypeChanged {
+			p.RequeueTypeChanged(oneKeyInfo, conflictKey)
+			continue
+		}
+		oneKeyInfo.SourceAttr.ItemCount = sourceLen[i]
+		oneKeyInfo.TargetAttr.ItemCount = targetLen[i]
+
+		if sourceLen[i] != targetLen[i] {
+			oneKeyInfo.ConflictType = common.ValueConflict
+		} else if sourceEncoding[i] != targetEncoding[i] {
+			common.Logger.Debugf("key[%s] encoding conflict: source[%s] target[%s]", common.EncodeName(oneKeyInfo.Key),
+				sourceEncoding[i], targetEncoding[i])
+			oneKeyInfo.ConflictType = common.EncodingConflict
+		} else {
+			oneKeyInfo.ConflictType = common.NoneConflict
+			p.IncrKeyStat(oneKeyInfo)
+			continue
+		}
+		p.IncrKeyStat(oneKeyInfo)
+		conflictKey <- oneKeyInfo
+	}
+}
+
+// compare the key whose value is too large incrementally instead of fetching the whole value
+func (p *FullValueVerifier) CompareTooLarge(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) {
+	switch oneKeyInfo.Tp {
+	case common.StringKeyType:
+		if p.isBitmap(oneKeyInfo) {
+			p.CompareBitmap([]*common.Key{oneKeyInfo}, conflictKey, sourceClient, targetClient)
+		} else {
+			p.CompareLargeString(oneKeyInfo, conflictKey, sourceClient, targetClient)
+		}
+	case common.HashKeyType, common.SetKeyType, common.ZsetKeyType:
+		sourceValue, targetValue, err := p.fetchValueUseScan(oneKeyInfo, sourceClient, targetClient)
+		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) || p.CheckMalformedReply(oneKeyInfo, conflictKey, err) {
+				return
+			}
+			panic(common.Logger.Error(err))
+		}
+		if oneKeyInfo.Tp == common.ZsetKeyType {
+			p.NormalizeGeo(oneKeyInfo, sourceValue, targetValue, sourceClient, targetClient)
+			p.NormalizeScore(oneKeyInfo, sourceValue, targetValue)
+		} else if oneKeyInfo.Tp == common.HashKeyType {
+			p.TransformHash(oneKeyInfo, sourceValue, targetValue)
+		}
+		p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
+	case common.ListKeyType:
+		p.CheckFullBigValue_List(oneKeyInfo, conflictKey, sourceClient, targetClient)
+	case common.StreamKeyType:
+		p.CompareStream(oneKeyInfo, conflictKey, sourceClient, targetClient)
+	}
+}
+
+// fetch the whole hash, set or zset by scan on both sides concurrently
+func (p *FullValueVerifier) fetchValueUseScan(oneKeyInfo *common.Key, sourceClient,
+		targetClient *client.RedisClient) (sourceValue, targetValue map[string][]byte, err error) {
+	err = fetchBoth(func() (err error) {
+		sourceValue, err = sourceClient.FetchValueUseScan_Hash_Set_SortedSet(oneKeyInfo, p.Param.ScanCount(oneKeyInfo.Tp))
+		return err
+	}, func() (err error) {
+		targetValue, err = targetClient.FetchValueUseScan_Hash_Set_SortedSet(oneKeyInfo, p.Param.ScanCount(oneKeyInfo.Tp))
+		return err
+	})
+	return sourceValue, targetValue, err
+}
+
+func (p *FullValueVerifier) CheckFullValueFetchAll(keyInfo []*common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) {
+	// fetch value
+	var sourceReply, targetReply []interface{}
+	err := fetchBoth(func() (err error) {
+		sourceReply, err = sourceClient.PipeValueCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		targetReply, err = targetClient.PipeValueCommand(keyInfo)
+		return err
+	})
 	if err != nil {
 		panic(common.Logger.Critical(err))
 	}
 
 	// compare value
+	hllKeyInfo := make([]*common.Key, 0)
+	var hllSource, hllTarget [][]byte
 	for i, oneKeyInfo := range keyInfo {
+		if p.checkVanished(oneKeyInfo, conflictKey, sourceReply[i], targetReply[i]) {
+			continue
+		}
+
 		switch oneKeyInfo.Tp {
 		case common.StringKeyType:
 			var sourceValue, targetValue []byte
@@ -227,20 +578,143 @@ func (p *FullValueVerifier) CheckFullValueFetchAll(keyInfo []*common.Key, confli
 			if targetReply[i] != nil {
 				targetValue = targetReply[i].([]byte)
 			}
+			if p.Param.CompareHll && isHyperLogLog(sourceValue) && isHyperLogLog(targetValue) &&
+					bytes.Equal(sourceValue, targetValue) == false {
+				hllKeyInfo = append(hllKeyInfo, oneKeyInfo)
+				hllSource = append(hllSource, sourceValue)
+				hllTarget = append(hllTarget, targetValue)
+				continue
+			}
+			sourceValue, targetValue = p.TransformString(oneKeyInfo, sourceValue, targetValue)
 			p.Compare_String(oneKeyInfo, conflictKey, sourceValue, targetValue)
 			p.IncrKeyStat(oneKeyInfo)
 		case common.HashKeyType:
-			fallthrough
+			sourceValue, targetValue := common.ValueHelper_Hash_SortedSet(sourceReply[i]), common.ValueHelper_Hash_SortedSet(targetReply[i])
+			p.TransformHash(oneKeyInfo, sourceValue, targetValue)
+			p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
 		case common.ZsetKeyType:
 			sourceValue, targetValue := common.ValueHelper_Hash_SortedSet(sourceReply[i]), common.ValueHelper_Hash_SortedSet(targetReply[i])
+			p.NormalizeGeo(oneKeyInfo, sourceValue, targetValue, sourceClient, targetClient)
+			p.NormalizeScore(oneKeyInfo, sourceValue, targetValue)
 			p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
 		case common.ListKeyType:
 			sourceValue, targetValue := common.ValueHelper_List(sourceReply[i]), common.ValueHelper_List(targetReply[i])
+			p.TransformList(oneKeyInfo, sourceValue, targetValue)
 			p.Compare_List(oneKeyInfo, conflictKey, sourceValue, targetValue)
 		case common.SetKeyType:
 			sourceValue, targetValue := common.ValueHelper_Set(sourceReply[i]), common.ValueHelper_Set(targetReply[i])
 			p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
+		case common.ModuleKeyType:
+			p.Compare_Module(oneKeyInfo, conflictKey, sourceReply[i], targetReply[i])
+			p.IncrKeyStat(oneKeyInfo)
+		}
+	}
+
+	if len(hllKeyInfo) != 0 {
+		p.CompareHyperLogLog(hllKeyInfo, hllSource, hllTarget, conflictKey, sourceClient, targetClient)
+	}
+}
+
+/*
+ * The key may be deleted or its type may be changed between fetching the type and the value, the reply
+ * is nil or isn't the expected type in this case. Mark it as lack conflict so it will be re-verified in
+ * the next round, or verify it again from fetching the type when the type is changed. Missing string
+ * is left to the string comparison.
+ */
+func (p *FullValueVerifier) checkVanished(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceReply, targetReply interface{}) bool {
+	valid := func(reply interface{}) bool {
+		if oneKeyInfo.Tp == common.StringKeyType {
+			_, ok := reply.([]byte)
+			return ok || reply == nil
+		}
+		// the reply of the value command can be any type, the error reply is converted to TypeChanged
+		if oneKeyInfo.Tp == common.ModuleKeyType {
+			v, ok := reply.(int64)
+			return reply != nil && (ok == false || v != common.TypeChanged)
+		}
+		_, ok := reply.([]interface{})
+		return ok
+	}
+	if valid(sourceReply) && valid(targetReply) {
+		return false
+	}
+
+	// the value is fetched by the command of the stale type
+	if sourceReply != nil && targetReply != nil {
+		common.Logger.Debugf("key[%s] type changed during the comparison", common.EncodeName(oneKeyInfo.Key))
+		p.RequeueTypeChanged(oneKeyInfo, conflictKey)
+		return true
+	}
+
+	if sourceReply == nil && targetReply == nil {
+		oneKeyInfo.ConflictType = common.NoneConflict
+	} else if sourceReply == nil {
+		oneKeyInfo.ConflictType = common.LackSourceConflict
+	} else {
+		oneKeyInfo.ConflictType = common.LackTargetConflict
+	}
+	common.Logger.Debugf("key[%s] disappeared during the comparison: %v", common.EncodeName(oneKeyInfo.Key),
+		oneKeyInfo.ConflictType)
+
+	oneKeyInfo.Field = nil
+	if oneKeyInfo.ConflictType != common.NoneConflict {
+		conflictKey <- oneKeyInfo
+	}
+	p.IncrKeyStat(oneKeyInfo)
+	return true
+}
+
+// HyperLogLog is stored as string with the magic "HYLL" in the header
+func isHyperLogLog(value []byte) bool {
+	return bytes.HasPrefix(value, []byte("HYLL"))
+}
+
+/*
+ * The raw bytes of HyperLogLog differ when the encoding(sparse or dense) is different even if the
+ * cardinality is the same, so compare the cardinality and report it in the item count. The raw bytes
+ * are compared instead when PFCOUNT fails on either side, e.g., the value only looks like HyperLogLog.
+ */
+func (p *FullValueVerifier) CompareHyperLogLog(keyInfo []*common.Key, sourceValue, targetValue [][]byte,
+		conflictKey chan<- *common.Key, sourceClient, targetClient *client.RedisClient) {
+	var sourceCount, targetCount []int64
+	err := fetchBoth(func() (err error) {
+		sourceCount, err = sourceClient.PipePfcountCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		targetCount, err = targetClient.PipePfcountCommand(keyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	for i, oneKeyInfo := range keyInfo {
+		// -1 means PFCOUNT got the error reply, the cardinality is inconclusive
+		if sourceCount[i] < 0 || targetCount[i] < 0 {
+			source, target := p.TransformString(oneKeyInfo, sourceValue[i], targetValue[i])
+			p.Compare_String(oneKeyInfo, conflictKey, source, target)
+			p.IncrKeyStat(oneKeyInfo)
+			continue
+		}
+
+		diff, max := sourceCount[i]-targetCount[i], sourceCount[i]
+		if diff < 0 {
+			diff = -diff
+			max = targetCount[i]
+		}
+
+		if float64(diff) > float64(max)*p.Param.HllTolerance {
+			common.Logger.Debugf("key[%s] cardinality conflict: source[%d] target[%d]", common.EncodeName(oneKeyInfo.Key),
+				sourceCount[i], targetCount[i])
+			oneKeyInfo.SourceAttr.ItemCount = sourceCount[i]
+			oneKeyInfo.TargetAttr.ItemCount = targetCount[i]
+			oneKeyInfo.ConflictType = common.ValueConflict
+			conflictKey <- oneKeyInfo
+		} else {
+			oneKeyInfo.ConflictType = common.NoneConflict
 		}
+		p.IncrKeyStat(oneKeyInfo)
 	}
 }
 
@@ -253,12 +727,21 @@ func (p *FullValueVerifier) CheckPartialValueHash(oneKeyInfo *common.Key, confli
 			args = append(args, oneKeyInfo.Field[fieldIndex].Field)
 		}
 
-		sourceReply, err := sourceClient.Do("hmget", args...)
-		if err != nil {
-			panic(common.Logger.Error(err))
-		}
-		targetReply, err := targetClient.Do("hmget", args...)
+		// the key may be rewritten differently on both sides
+		sourceArgs := append([]interface{}{sourceClient.Key(oneKeyInfo.Key)}, args[1:]...)
+		targetArgs := append([]interface{}{targetClient.Key(oneKeyInfo.Key)}, args[1:]...)
+		var sourceReply, targetReply interface{}
+		err := fetchBoth(func() (err error) {
+			sourceReply, err = sourceClient.Do("hmget", sourceArgs...)
+			return err
+		}, func() (err error) {
+			targetReply, err = targetClient.Do("hmget", targetArgs...)
+			return err
+		})
 		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
 			panic(common.Logger.Error(err))
 		}
 		sendField := args[1:]
@@ -284,12 +767,18 @@ func (p *FullValueVerifier) CheckPartialValueSet(oneKeyInfo *common.Key, conflic
 		for count := 0; count < p.Param.BatchCount && fieldIndex < len(oneKeyInfo.Field); count, fieldIndex = count+1, fieldIndex+1 {
 			sendField = append(sendField, oneKeyInfo.Field[fieldIndex].Field)
 		}
-		tmpSourceValue, err := sourceClient.PipeSismemberCommand(oneKeyInfo.Key, sendField)
-		if err != nil {
-			panic(common.Logger.Error(err))
-		}
-		tmpTargetValue, err := targetClient.PipeSismemberCommand(oneKeyInfo.Key, sendField)
+		var tmpSourceValue, tmpTargetValue []interface{}
+		err := fetchBoth(func() (err error) {
+			tmpSourceValue, err = sourceClient.PipeSismemberCommand(oneKeyInfo.Key, sendField)
+			return err
+		}, func() (err error) {
+			tmpTargetValue, err = targetClient.PipeSismemberCommand(oneKeyInfo.Key, sendField)
+			return err
+		})
 		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
 			panic(common.Logger.Error(err))
 		}
 		for i := 0; i < len(sendField); i++ {
@@ -307,54 +796,475 @@ func (p *FullValueVerifier) CheckPartialValueSet(oneKeyInfo *common.Key, conflic
 	p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
 }
 
-func (p *FullValueVerifier) CheckPartialValueSortedSet(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
-	sourceValue, targetValue := make(map[string][]byte), make(map[string][]byte)
-	for fieldIndex := 0; fieldIndex < len(oneKeyInfo.Field); {
-		sendField := make([][]byte, 0, p.Param.BatchCount)
-		for count := 0; count < p.Param.BatchCount && fieldIndex < len(oneKeyInfo.Field); count, fieldIndex = count+1, fieldIndex+1 {
-			sendField = append(sendField, oneKeyInfo.Field[fieldIndex].Field)
-		}
+// the big set is prechecked by the sampled members in the first round when setprecheck is set
+func (p *FullValueVerifier) isPrecheckSet(oneKeyInfo *common.Key) bool {
+	return p.Param.SetPrecheck > 0 && oneKeyInfo.Tp == common.SetKeyType
+}
 
-		tmpSourceValue, err := sourceClient.PipeZscoreCommand(oneKeyInfo.Key, sendField)
-		if err != nil {
-			panic(common.Logger.Error(err))
-		}
-		tmpTargetValue, err := targetClient.PipeZscoreCommand(oneKeyInfo.Key, sendField)
-		if err != nil {
-			panic(common.Logger.Error(err))
-		}
+/*
+ * Precheck the big set before the full member diff: the set is regarded as equal when the cardinality
+ * fetched by SCARD is equal on both sides and the members sampled by SRANDMEMBER on the source all
+ * exist on the target by SISMEMBER. Return false when it's inconclusive, then the full diff finds the
+ * differing members. SINTERCARD only counts the intersection of the sets in the same instance, so the
+ * overlap of the two sides can't be counted by it. Return true as well when the key is requeued since
+ * its type changed.
+ */
+func (p *FullValueVerifier) PrecheckSet(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) bool {
+	if oneKeyInfo.SourceAttr.ItemCount != oneKeyInfo.TargetAttr.ItemCount {
+		return false
+	}
 
-		for i := 0; i < len(sendField); i++ {
-			fieldStr := string(sendField[i])
-			if tmpSourceValue[i] != nil {
-				sourceValue[fieldStr] = tmpSourceValue[i].([]byte)
-			}
-			if tmpTargetValue[i] != nil {
-				targetValue[fieldStr] = tmpTargetValue[i].([]byte)
+	members, err := sourceClient.RandomSetMembers(oneKeyInfo.Key, p.Param.SetPrecheck)
+	if err == nil && len(members) != 0 {
+		var exists []interface{}
+		if exists, err = targetClient.PipeSismemberCommand(oneKeyInfo.Key, members); err == nil {
+			for _, ele := range exists {
+				if v, _ := ele.(int64); v != 1 {
+					return false
+				}
 			}
 		}
 	}
-	p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
+	if err != nil {
+		if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+			return true
+		}
+		panic(common.Logger.Error(err))
+	}
+	// deleted after fetching the length
+	if len(members) == 0 {
+		return false
+	}
+
+	common.Logger.Debugf("set key[%s] is regarded as equal by %d sampled member(s)", common.EncodeName(oneKeyInfo.Key),
+		len(members))
+	oneKeyInfo.Field = nil
+	oneKeyInfo.ConflictType = common.NoneConflict
+	p.IncrKeyStat(oneKeyInfo)
+	return true
 }
 
-func (p *FullValueVerifier) CheckFullBigValue_List(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
-		sourceClient *client.RedisClient, targetClient *client.RedisClient) {
-	conflictField := make([]common.Field, 0, oneKeyInfo.SourceAttr.ItemCount/100+1)
-	oneCmpCount := p.Param.BatchCount * 10
-	if oneCmpCount > 10240 {
-		oneCmpCount = 10240
-	}
+// the set with more members than SetSpotCheck on either side
+func (p *FullValueVerifier) isSpotCheckSet(oneKeyInfo *common.Key) bool {
+	return p.Param.SetSpotCheck > 0 && oneKeyInfo.Tp == common.SetKeyType &&
+		(oneKeyInfo.SourceAttr.ItemCount > p.Param.SetSpotCheck || oneKeyInfo.TargetAttr.ItemCount > p.Param.SetSpotCheck)
+}
 
-	startIndex := 0
-	for {
-		sourceReply, err := sourceClient.Do("lrange", oneKeyInfo.Key, startIndex, startIndex+oneCmpCount-1)
-		if err != nil {
-			panic(common.Logger.Critical(err))
+/*
+ * Compare the large set without holding all the members in memory: the members of each side are
+ * fetched by SSCAN page by page and checked by SISMEMBER on the other side. At most SetDiffSample
+ * members only on the source and only on the target are kept and recorded as the conflict fields,
+ * so the memory is bounded by the page and the sample regardless of the cardinality and the diff.
+ * The set is compared in the same way again in the later rounds.
+ */
+func (p *FullValueVerifier) CompareSetBySismember(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) {
+	onlySource, sourceCount, err := p.diffSetMembers(oneKeyInfo, sourceClient, targetClient, common.LackTargetConflict)
+	if err != nil {
+		if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) || p.CheckMalformedReply(oneKeyInfo, conflictKey, err) {
+			return
+		}
+		panic(common.Logger.Error(err))
+	}
+	onlyTarget, targetCount, err := p.diffSetMembers(oneKeyInfo, targetClient, sourceClient, common.LackSourceConflict)
+	if err != nil {
+		if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) || p.CheckMalformedReply(oneKeyInfo, conflictKey, err) {
+			return
+		}
+		panic(common.Logger.Error(err))
+	}
+
+	conflictField := make([]common.Field, 0)
+	for _, diff := range []struct {
+		members      [][]byte
+		conflictType common.ConflictType
+	}{
+		{onlySource, common.LackTargetConflict},
+		{onlyTarget, common.LackSourceConflict},
+	} {
+		for _, member := range diff.members {
+			conflictField = append(conflictField, common.Field{
+				Field:        member,
+				ConflictType: diff.conflictType})
+		}
+	}
+
+	if sourceCount != 0 || targetCount != 0 {
+		common.Logger.Infof("set key[%s] has %d member(s) only on the source and %d member(s) only on the target",
+			common.EncodeName(oneKeyInfo.Key), sourceCount, targetCount)
+		oneKeyInfo.Field = conflictField
+		oneKeyInfo.ConflictType = common.ValueConflict
+		p.LogConflictField(oneKeyInfo)
+		conflictKey <- oneKeyInfo
+	} else {
+		oneKeyInfo.Field = nil
+		oneKeyInfo.ConflictType = common.NoneConflict
+	}
+	p.IncrKeyStat(oneKeyInfo)
+}
+
+/*
+ * The smallest members scanned from scanClient but missing on checkClient in order, at most SetDiffSample
+ * ones, and the count of all of them. The count may include the member returned more than once by SSCAN
+ * during rehashing, which is only deduplicated within the sample.
+ */
+func (p *FullValueVerifier) diffSetMembers(oneKeyInfo *common.Key, scanClient, checkClient *client.RedisClient,
+		conflictType common.ConflictType) ([][]byte, int64, error) {
+	diff := newMemberSample(p.Param.SetDiffSample)
+	var diffCount int64
+	count := p.Param.ScanCount(common.SetKeyType)
+	for cursor := 0; ; {
+		next, members, err := scanClient.ScanSetMembers(oneKeyInfo.Key, cursor, count)
+		if err != nil {
+			return nil, 0, err
+		}
+		if len(members) != 0 {
+			exists, err := checkClient.PipeSismemberCommand(oneKeyInfo.Key, members)
+			if err != nil {
+				return nil, 0, err
+			}
+			for i, member := range members {
+				v, _ := exists[i].(int64)
+				if v == common.TypeChanged {
+					return nil, 0, client.TypeChangedError
+				} else if v != 0 {
+					// the equal members are counted when scanning the source
+					if conflictType == common.LackTargetConflict {
+						p.IncrFieldStat(oneKeyInfo, common.NoneConflict)
+					}
+					continue
+				}
+				if diff.add(member) {
+					diffCount++
+					p.IncrFieldStat(oneKeyInfo, conflictType)
+				}
+			}
+		}
+		if cursor = next; cursor == 0 {
+			break
+		}
+	}
+	return diff.sorted(), diffCount, nil
+}
+
+// the smallest members in order, at most limit ones, 0 means no limit
+type memberSample struct {
+	limit   int
+	members [][]byte
+	found   map[string]struct{} // only used without limit
+}
+
+func newMemberSample(limit int) *memberSample {
+	ret := &memberSample{limit: limit}
+	if limit == 0 {
+		ret.found = make(map[string]struct{})
+	}
+	return ret
+}
+
+// return false if the member is in the sample already
+func (p *memberSample) add(member []byte) bool {
+	if p.limit == 0 {
+		if _, ok := p.found[string(member)]; ok {
+			return false
+		}
+		p.found[string(member)] = struct{}{}
+		p.members = append(p.members, member)
+		return true
+	}
+
+	i := sort.Search(len(p.members), func(i int) bool {
+		return bytes.Compare(p.members[i], member) >= 0
+	})
+	if i < len(p.members) && bytes.Equal(p.members[i], member) {
+		return false
+	}
+	if i < p.limit {
+		if len(p.members) < p.limit {
+			p.members = append(p.members, nil)
+		}
+		copy(p.members[i+1:], p.members[i:])
+		p.members[i] = member
+	}
+	return true
+}
+
+func (p *memberSample) sorted() [][]byte {
+	if p.limit == 0 {
+		sort.Slice(p.members, func(i, j int) bool {
+			return bytes.Compare(p.members[i], p.members[j]) < 0
+		})
+	}
+	return p.members
+}
+
+func (p *FullValueVerifier) CheckPartialValueSortedSet(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
+	sourceValue, targetValue := make(map[string][]byte), make(map[string][]byte)
+	for fieldIndex := 0; fieldIndex < len(oneKeyInfo.Field); {
+		sendField := make([][]byte, 0, p.Param.BatchCount)
+		for count := 0; count < p.Param.BatchCount && fieldIndex < len(oneKeyInfo.Field); count, fieldIndex = count+1, fieldIndex+1 {
+			sendField = append(sendField, oneKeyInfo.Field[fieldIndex].Field)
+		}
+
+		var tmpSourceValue, tmpTargetValue []interface{}
+		err := fetchBoth(func() (err error) {
+			tmpSourceValue, err = sourceClient.PipeZscoreCommand(oneKeyInfo.Key, sendField)
+			return err
+		}, func() (err error) {
+			tmpTargetValue, err = targetClient.PipeZscoreCommand(oneKeyInfo.Key, sendField)
+			return err
+		})
+		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
+			panic(common.Logger.Error(err))
+		}
+
+		for i := 0; i < len(sendField); i++ {
+			fieldStr := string(sendField[i])
+			if tmpSourceValue[i] != nil {
+				sourceValue[fieldStr] = tmpSourceValue[i].([]byte)
+			}
+			if tmpTargetValue[i] != nil {
+				targetValue[fieldStr] = tmpTargetValue[i].([]byte)
+			}
+		}
+	}
+	p.NormalizeGeo(oneKeyInfo, sourceValue, targetValue, sourceClient, targetClient)
+	p.NormalizeScore(oneKeyInfo, sourceValue, targetValue)
+	p.Compare_Hash_Set_SortedSet(oneKeyInfo, conflictKey, sourceValue, targetValue)
+}
+
+/*
+ * The score of the geo member is the 52-bit geohash which may differ slightly even if the coordinates
+ * are geographically the same. For the geo keys, the score of the member existing on both sides is
+ * regarded as equal when the distance of the coordinates doesn't exceed the tolerance.
+ */
+func (p *FullValueVerifier) NormalizeGeo(oneKeyInfo *common.Key, sourceValue, targetValue map[string][]byte,
+		sourceClient, targetClient *client.RedisClient) {
+	if len(p.Param.GeoMatchList) == 0 || common.CheckMatch(p.Param.GeoMatchList, oneKeyInfo.Key) == false {
+		return
+	}
+
+	members := make([][]byte, 0)
+	for k, v := range sourceValue {
+		if vTarget, ok := targetValue[k]; ok && bytes.Equal(v, vTarget) == false {
+			members = append(members, []byte(k))
+		}
+	}
+
+	for start := 0; start < len(members); start += p.Param.BatchCount {
+		end := start + p.Param.BatchCount
+		if end > len(members) {
+			end = len(members)
+		}
+		sourcePos, err := sourceClient.FetchGeoPos(oneKeyInfo.Key, members[start:end])
+		if err != nil {
+			panic(common.Logger.Error(err))
+		}
+		targetPos, err := targetClient.FetchGeoPos(oneKeyInfo.Key, members[start:end])
+		if err != nil {
+			panic(common.Logger.Error(err))
+		}
+
+		for i, member := range members[start:end] {
+			// member has been removed, leave it to the comparison
+			if sourcePos[i] == nil || targetPos[i] == nil {
+				continue
+			}
+			distance := common.GeoDistance(sourcePos[i][0], sourcePos[i][1], targetPos[i][0], targetPos[i][1])
+			if distance <= p.Param.GeoTolerance {
+				targetValue[string(member)] = sourceValue[string(member)]
+			} else {
+				common.Logger.Debugf("key[%s] member[%s] geo distance %.2fm exceeds tolerance", common.EncodeName(oneKeyInfo.Key),
+					common.EncodeName(member), distance)
+			}
+		}
+	}
+}
+
+/*
+ * The format of the zset score may differ between redis versions, e.g., "1" and "1.0", so the score
+ * of the member existing on both sides is compared as float64 with the ScoreEpsilon tolerance.
+ * The member names are still compared exactly.
+ */
+func (p *FullValueVerifier) NormalizeScore(oneKeyInfo *common.Key, sourceValue, targetValue map[string][]byte) {
+	reported := 0
+	for k, v := range sourceValue {
+		vTarget, ok := targetValue[k]
+		if ok == false || bytes.Equal(v, vTarget) {
+			continue
+		}
+		sourceScore, err := strconv.ParseFloat(string(v), 64)
+		if err != nil {
+			continue
+		}
+		targetScore, err := strconv.ParseFloat(string(vTarget), 64)
+		if err != nil {
+			continue
+		}
+
+		if sourceScore == targetScore || math.Abs(sourceScore-targetScore) <= p.Param.ScoreEpsilon {
+			targetValue[k] = v
+		} else if reported < p.Param.DiffFieldLimit {
+			reported++
+			common.Logger.Infof("zset key[%s] member[%s] score differs: source[%s] target[%s]", common.EncodeName(oneKeyInfo.Key),
+				common.EncodeName([]byte(k)), v, vTarget)
+		}
+	}
+}
+
+/*
+ * The value fetched from each side is normalized by its own transform rules, e.g., the packed
+ * binary struct written in different byte orders. The missing value is kept as nil.
+ */
+func (p *FullValueVerifier) TransformString(oneKeyInfo *common.Key, sourceValue, targetValue []byte) ([]byte, []byte) {
+	if sourceValue != nil {
+		sourceValue = common.TransformValue(p.Param.SourceHost.ValueTransform, oneKeyInfo.Key, sourceValue)
+	}
+	if targetValue != nil {
+		targetValue = common.TransformValue(p.Param.TargetHost.ValueTransform, oneKeyInfo.Key, targetValue)
+	}
+	return sourceValue, targetValue
+}
+
+// the hash field values are transformed in place, the field names are kept
+func (p *FullValueVerifier) TransformHash(oneKeyInfo *common.Key, sourceValue, targetValue map[string][]byte) {
+	for _, side := range []struct {
+		rules []common.ValueTransformRule
+		value map[string][]byte
+	}{{p.Param.SourceHost.ValueTransform, sourceValue}, {p.Param.TargetHost.ValueTransform, targetValue}} {
+		if len(side.rules) == 0 {
+			continue
+		}
+		for k, v := range side.value {
+			side.value[k] = common.TransformValue(side.rules, oneKeyInfo.Key, v)
+		}
+	}
+}
+
+func (p *FullValueVerifier) TransformList(oneKeyInfo *common.Key, sourceValue, targetValue [][]byte) {
+	for i, v := range sourceValue {
+		sourceValue[i] = common.TransformValue(p.Param.SourceHost.ValueTransform, oneKeyInfo.Key, v)
+	}
+	for i, v := range targetValue {
+		targetValue[i] = common.TransformValue(p.Param.TargetHost.ValueTransform, oneKeyInfo.Key, v)
+	}
+}
+
+// the list longer than the lrange window is compared incrementally instead of fetching the whole list
+func (p *FullValueVerifier) isLongList(oneKeyInfo *common.Key) bool {
+	return oneKeyInfo.Tp == common.ListKeyType && p.Param.LrangeCount > 0 &&
+		(oneKeyInfo.SourceAttr.ItemCount > int64(p.Param.LrangeCount) ||
+			oneKeyInfo.TargetAttr.ItemCount > int64(p.Param.LrangeCount))
+}
+
+// the lrange window of the list compared incrementally
+func (p *FullValueVerifier) listWindow() int {
+	oneCmpCount := p.Param.LrangeCount
+	if oneCmpCount == 0 {
+		oneCmpCount = p.Param.BatchCount * 10
+		if oneCmpCount > 10240 {
+			oneCmpCount = 10240
+		}
+	}
+	return oneCmpCount
+}
+
+func (p *FullValueVerifier) isListDrift() bool {
+	return p.Param.ListHeadDrift > 0 || p.Param.ListTailDrift > 0
+}
+
+/*
+ * Compare the long list window by window allowing the head/tail drift. Return true when the key
+ * is done: the lists match or the type changed. Otherwise the list is compared exactly again to
+ * locate the first differing index.
+ */
+func (p *FullValueVerifier) matchBigListDrift(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient *client.RedisClient, targetClient *client.RedisClient) bool {
+	oneCmpCount := p.listWindow()
+	drift := common.NewListDrift(p.Param.ListHeadDrift, p.Param.ListTailDrift)
+	sourceLen, targetLen := -1, -1
+	for startIndex := 0; sourceLen == -1; startIndex += oneCmpCount {
+		sourceValue, err := sourceClient.Lrange(oneKeyInfo.Key, startIndex, startIndex+oneCmpCount-1)
+		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return true
+			}
+			panic(common.Logger.Critical(err))
+		}
+
+		// the target window covers the shifts at both ends
+		targetStart := startIndex - p.Param.ListHeadDrift
+		if targetStart < 0 {
+			targetStart = 0
+		}
+		targetEnd := startIndex + oneCmpCount - 1 + p.Param.ListHeadDrift
+		targetValue, err := targetClient.Lrange(oneKeyInfo.Key, targetStart, targetEnd)
+		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return true
+			}
+			panic(common.Logger.Error(err))
+		}
+
+		drift.Match(startIndex, sourceValue, targetStart, targetValue)
+		if len(sourceValue) < oneCmpCount {
+			sourceLen = startIndex + len(sourceValue)
+		}
+		if targetLen == -1 && len(targetValue) < targetEnd-targetStart+1 {
+			targetLen = targetStart + len(targetValue)
+		}
+		if drift.Alive() == false {
+			return false
+		}
+	}
+	if targetLen == -1 {
+		// the target is longer than the source window, only its length matters
+		reply, err := targetClient.Do("llen", targetClient.Key(oneKeyInfo.Key))
+		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return true
+			}
+			panic(common.Logger.Error(err))
+		}
+		targetLen = int(reply.(int64))
+	}
+	if drift.Finish(sourceLen, targetLen) == false {
+		return false
+	}
+	oneKeyInfo.Field = nil
+	oneKeyInfo.ConflictType = common.NoneConflict
+	p.IncrKeyStat(oneKeyInfo)
+	return true
+}
+
+func (p *FullValueVerifier) CheckFullBigValue_List(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient *client.RedisClient, targetClient *client.RedisClient) {
+	if p.isListDrift() && p.matchBigListDrift(oneKeyInfo, conflictKey, sourceClient, targetClient) {
+		return
+	}
+	conflictField := make([]common.Field, 0, 1)
+	oneCmpCount := p.listWindow()
+
+	startIndex := 0
+	for {
+		sourceReply, err := sourceClient.Do("lrange", sourceClient.Key(oneKeyInfo.Key), startIndex, startIndex+oneCmpCount-1)
+		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
+			panic(common.Logger.Critical(err))
 		}
 		sourceValue := sourceReply.([]interface{})
 
-		targetReply, err := targetClient.Do("lrange", oneKeyInfo.Key, startIndex, startIndex+oneCmpCount-1)
+		targetReply, err := targetClient.Do("lrange", targetClient.Key(oneKeyInfo.Key), startIndex, startIndex+oneCmpCount-1)
 		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
 			panic(common.Logger.Error(err))
 		}
 		targetValue := targetReply.([]interface{})
@@ -368,10 +1278,23 @@ func (p *FullValueVerifier) CheckFullBigValue_List(oneKeyInfo *common.Key, confl
 				}
 				conflictField = append(conflictField, field)
 				p.IncrFieldStat(oneKeyInfo, common.ValueConflict)
+				break
 			} else {
 				p.IncrFieldStat(oneKeyInfo, common.NoneConflict)
 			}
 		}
+		// one list ends earlier, the first missing index differs
+		if len(conflictField) == 0 && len(sourceValue) != len(targetValue) {
+			conflictType := common.LackTargetConflict
+			if len(sourceValue) < len(targetValue) {
+				conflictType = common.LackSourceConflict
+			}
+			conflictField = append(conflictField, common.Field{
+				Field:        []byte(strconv.FormatInt(int64(startIndex+minLen), 10)),
+				ConflictType: conflictType,
+			})
+			p.IncrFieldStat(oneKeyInfo, conflictType)
+		}
 		// list 只返回第一个不相同的位置
 		if len(conflictField) != 0 {
 			break
@@ -396,86 +1319,76 @@ func (p *FullValueVerifier) CheckFullBigValue_List(oneKeyInfo *common.Key, confl
 }
 
 func (p *FullValueVerifier) Compare_String(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, sourceValue, targetValue []byte) {
-	if len(sourceValue) == 0 {
-		if len(targetValue) == 0 {
-			oneKeyInfo.ConflictType = common.NoneConflict
-		} else {
-			oneKeyInfo.ConflictType = common.LackSourceConflict
-		}
-	} else if len(targetValue) == 0 {
-		if len(sourceValue) == 0 {
-			oneKeyInfo.ConflictType = common.NoneConflict
-		} else {
-			oneKeyInfo.ConflictType = common.LackTargetConflict
-		}
-	} else if bytes.Equal(sourceValue, targetValue) == false {
-		oneKeyInfo.ConflictType = common.ValueConflict
-	} else {
-		oneKeyInfo.ConflictType = common.NoneConflict
+	if p.compareValue(oneKeyInfo, sourceValue, targetValue) == false {
+		conflictKey <- oneKeyInfo
 	}
-	if oneKeyInfo.ConflictType != common.NoneConflict {
+}
+
+// the replies of the value command are compared as they are by default, e.g., the json of JSON.GET
+func (p *FullValueVerifier) Compare_Module(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, sourceReply, targetReply interface{}) {
+	if p.compareValue(oneKeyInfo, sourceReply, targetReply) == false {
 		conflictKey <- oneKeyInfo
 	}
 }
 
 func (p *FullValueVerifier) Compare_Hash_Set_SortedSet(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, sourceValue, targetValue map[string][]byte) {
-	conflictField := make([]common.Field, 0, len(sourceValue)/50+1)
-	for k, v := range sourceValue {
-		vTarget, ok := targetValue[k]
-		if ok == false {
-			conflictField = append(conflictField, common.Field{
-				Field: []byte(k),
-				ConflictType: common.LackTargetConflict})
-			p.IncrFieldStat(oneKeyInfo, common.LackTargetConflict)
-		} else {
-			delete(targetValue, k)
-			if bytes.Equal(v, vTarget) == false {
-				conflictField = append(conflictField, common.Field{
-					Field: []byte(k),
-					ConflictType: common.ValueConflict})
-				p.IncrFieldStat(oneKeyInfo, common.ValueConflict)
-			} else {
-				p.IncrFieldStat(oneKeyInfo, common.NoneConflict)
-			}
+	equal := p.compareValue(oneKeyInfo, sourceValue, targetValue)
+	// the fields on the source not reported are equal
+	sourceConflict := 0
+	for _, field := range oneKeyInfo.Field {
+		if _, ok := sourceValue[string(field.Field)]; ok {
+			sourceConflict++
 		}
+		p.IncrFieldStat(oneKeyInfo, field.ConflictType)
 	}
-
-	for k, _ := range targetValue {
-		conflictField = append(conflictField, common.Field{
-			Field: []byte(k),
-			ConflictType: common.LackSourceConflict})
-		p.IncrFieldStat(oneKeyInfo, common.LackSourceConflict)
+	for i := sourceConflict; i < len(sourceValue); i++ {
+		p.IncrFieldStat(oneKeyInfo, common.NoneConflict)
 	}
 
-	if len(conflictField) != 0 {
-		oneKeyInfo.Field = conflictField
-		oneKeyInfo.ConflictType = common.ValueConflict
+	if equal == false {
+		p.LogConflictField(oneKeyInfo)
 		conflictKey <- oneKeyInfo
-	} else {
-		oneKeyInfo.ConflictType = common.NoneConflict
 	}
 	p.IncrKeyStat(oneKeyInfo)
 }
 
 func (p *FullValueVerifier) Compare_List(oneKeyInfo *common.Key, conflictKey chan<- *common.Key, sourceValue, targetValue [][]byte) {
-	minLen := common.Min(len(sourceValue), len(targetValue))
-
 	oneKeyInfo.ConflictType = common.NoneConflict
-	for i := 0; i < minLen; i++ {
-		if bytes.Equal(sourceValue[i], targetValue[i]) == false {
-			// list 只保存第一个不一致的field
-			oneKeyInfo.Field = make([]common.Field, 1)
-			oneKeyInfo.Field[0] = common.Field{
-				Field: []byte(strconv.FormatInt(int64(i), 10)),
-				ConflictType: common.ValueConflict}
-			oneKeyInfo.ConflictType = common.ValueConflict
-			conflictKey <- oneKeyInfo
-			break
+	if p.isListDrift() {
+		drift := common.NewListDrift(p.Param.ListHeadDrift, p.Param.ListTailDrift)
+		drift.Match(0, sourceValue, 0, targetValue)
+		if drift.Finish(len(sourceValue), len(targetValue)) {
+			oneKeyInfo.Field = nil
+			p.IncrKeyStat(oneKeyInfo)
+			return
 		}
 	}
+	if p.compareValue(oneKeyInfo, sourceValue, targetValue) == false {
+		conflictKey <- oneKeyInfo
+	}
 	p.IncrKeyStat(oneKeyInfo)
 }
 
+/*
+ * Compare the value by the comparator registered for the type and the name of the key, the built-in
+ * one by default. The conflict type and the differing fields are set on the key, return true if equal.
+ */
+func (p *FullValueVerifier) compareValue(oneKeyInfo *common.Key, sourceValue, targetValue interface{}) bool {
+	equal, detail := common.MatchComparator(oneKeyInfo.Tp, oneKeyInfo.Key).Compare(sourceValue, targetValue)
+	if equal {
+		oneKeyInfo.ConflictType = common.NoneConflict
+		// the conflict fields of the previous round aren't counted again
+		oneKeyInfo.Field = nil
+		return true
+	}
+	oneKeyInfo.ConflictType = detail.ConflictType
+	if oneKeyInfo.ConflictType == common.TypeConflict || oneKeyInfo.ConflictType == common.NoneConflict {
+		oneKeyInfo.ConflictType = common.ValueConflict
+	}
+	oneKeyInfo.Field = detail.Fields
+	return false
+}
+
 /*
  * In this function, I separate comparison into the following steps:
  * 1. compare groups info(`xinfo groups ${stream_name}`)
@@ -485,13 +1398,19 @@ func (p *FullValueVerifier) Compare_List(oneKeyInfo *common.Key, conflictKey cha
 func (p *FullValueVerifier) CompareStream(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
 		sourceClient, targetClient *client.RedisClient) {
 	// 1. fetch source and target groups info
-	sourceGroupsInfo, err := sourceClient.Do("XINFO", "GROUPS", oneKeyInfo.Key)
+	sourceGroupsInfo, err := sourceClient.Do("XINFO", "GROUPS", sourceClient.Key(oneKeyInfo.Key))
 	if err != nil {
+		if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+			return
+		}
 		panic(common.Logger.Error(err))
 	}
 
-	targetGroupsInfo, err := targetClient.Do("XINFO", "GROUPS", oneKeyInfo.Key)
+	targetGroupsInfo, err := targetClient.Do("XINFO", "GROUPS", targetClient.Key(oneKeyInfo.Key))
 	if err != nil {
+		if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+			return
+		}
 		panic(common.Logger.Error(err))
 	}
 
@@ -533,14 +1452,20 @@ func (p *FullValueVerifier) CompareStream(oneKeyInfo *common.Key, conflictKey ch
 	for sum, startTs := int64(0), "0-0"; sum < length; sum += step {
 		// fetch all elements in stream
 		// 1. from source
-		sourceXrange, err := sourceClient.Do("XRANGE", oneKeyInfo.Key, startTs, "+", "COUNT", step)
+		sourceXrange, err := sourceClient.Do("XRANGE", sourceClient.Key(oneKeyInfo.Key), startTs, "+", "COUNT", step)
 		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
 			panic(common.Logger.Error(err))
 		}
 
 		// 2. from target
-		targetXrange, err := targetClient.Do("XRANGE", oneKeyInfo.Key, startTs, "+", "COUNT", step)
+		targetXrange, err := targetClient.Do("XRANGE", targetClient.Key(oneKeyInfo.Key), startTs, "+", "COUNT", step)
 		if err != nil {
+			if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+				return
+			}
 			panic(common.Logger.Error(err))
 		}
 
@@ -565,15 +1490,21 @@ func (p *FullValueVerifier) CompareStream(oneKeyInfo *common.Key, conflictKey ch
 	for _, groupEle := range groupsBasic {
 		step := int64(math.Max(float64(StreamSegment), float64(length) / 20))
 		for sum, startTs := int64(0), "0-0"; sum < length; sum += step {
-			sourceXpending, err := sourceClient.Do("XPENDING", oneKeyInfo.Key, groupEle.name, startTs,
+			sourceXpending, err := sourceClient.Do("XPENDING", sourceClient.Key(oneKeyInfo.Key), groupEle.name, startTs,
 				"+", step)
 			if err != nil {
+				if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+					return
+				}
 				panic(common.Logger.Error(err))
 			}
 
-			targetXpending, err := targetClient.Do("XPENDING", oneKeyInfo.Key, groupEle.name, startTs,
+			targetXpending, err := targetClient.Do("XPENDING", targetClient.Key(oneKeyInfo.Key), groupEle.name, startTs,
 				"+", step)
 			if err != nil {
+				if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+					return
+				}
 				panic(common.Logger.Error(err))
 			}
 
@@ -612,4 +1543,266 @@ func (p *FullValueVerifier) CompareStream(oneKeyInfo *common.Key, conflictKey ch
 			startTs = string(lastTs)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func (p *FullValueVerifier) isBitmap(oneKeyInfo *common.Key) bool {
+	return oneKeyInfo.Tp == common.StringKeyType && len(p.Param.BitmapMatchList) != 0 &&
+		common.CheckMatch(p.Param.BitmapMatchList, oneKeyInfo.Key)
+}
+
+/*
+ * Compare the bitmap without fetching the whole value: regarded as equal when both the length and
+ * bitcount are equal. Otherwise the differing byte ranges are reported as the fields, e.g., "0-65535".
+ */
+func (p *FullValueVerifier) CompareBitmap(keyInfo []*common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) {
+	var sourceLen, targetLen, sourceCount, targetCount []int64
+	err := fetchBoth(func() (err error) {
+		if sourceLen, err = sourceClient.PipeLenCommand(keyInfo); err != nil {
+			return err
+		}
+		sourceCount, err = sourceClient.PipeBitcountCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		if targetLen, err = targetClient.PipeLenCommand(keyInfo); err != nil {
+			return err
+		}
+		targetCount, err = targetClient.PipeBitcountCommand(keyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	for i, oneKeyInfo := range keyInfo {
+		oneKeyInfo.SourceAttr.ItemCount, oneKeyInfo.TargetAttr.ItemCount = sourceLen[i], targetLen[i]
+		oneKeyInfo.Field = nil
+		if sourceLen[i] == 0 && targetLen[i] == 0 {
+			oneKeyInfo.ConflictType = common.NoneConflict
+		} else if sourceLen[i] == 0 {
+			oneKeyInfo.ConflictType = common.LackSourceConflict
+		} else if targetLen[i] == 0 {
+			oneKeyInfo.ConflictType = common.LackTargetConflict
+		} else if sourceLen[i] == targetLen[i] && sourceCount[i] == targetCount[i] {
+			oneKeyInfo.ConflictType = common.NoneConflict
+		} else {
+			p.diffBitmapRange(oneKeyInfo, sourceClient, targetClient)
+			if len(oneKeyInfo.Field) != 0 {
+				oneKeyInfo.ConflictType = common.ValueConflict
+			} else {
+				oneKeyInfo.ConflictType = common.NoneConflict
+			}
+		}
+
+		if oneKeyInfo.ConflictType != common.NoneConflict {
+			conflictKey <- oneKeyInfo
+		}
+		p.IncrKeyStat(oneKeyInfo)
+	}
+}
+
+// compare the string by getrange segment by segment, the differing byte ranges are reported as fields
+func (p *FullValueVerifier) CompareLargeString(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) {
+	if p.fetchStringLen(oneKeyInfo, sourceClient, targetClient) {
+		length := oneKeyInfo.SourceAttr.ItemCount
+		diff := make([]bool, (length+BitmapSegment-1)/BitmapSegment)
+		p.diffByGetrange(oneKeyInfo, diff, sourceClient, targetClient)
+		p.appendSegmentField(oneKeyInfo, diff, length)
+		if len(oneKeyInfo.Field) != 0 {
+			oneKeyInfo.ConflictType = common.ValueConflict
+		} else {
+			oneKeyInfo.ConflictType = common.NoneConflict
+		}
+	}
+
+	if oneKeyInfo.ConflictType != common.NoneConflict {
+		conflictKey <- oneKeyInfo
+	}
+	p.IncrKeyStat(oneKeyInfo)
+}
+
+/*
+ * Fetch the length of the string again since the value may have changed, and decide the conflict type
+ * by it. Return true when the length is equal and not 0, then the content should be compared.
+ */
+func (p *FullValueVerifier) fetchStringLen(oneKeyInfo *common.Key, sourceClient,
+		targetClient *client.RedisClient) bool {
+	keyInfo := []*common.Key{oneKeyInfo}
+	var sourceLen, targetLen []int64
+	err := fetchBoth(func() (err error) {
+		sourceLen, err = sourceClient.PipeLenCommand(keyInfo)
+		return err
+	}, func() (err error) {
+		targetLen, err = targetClient.PipeLenCommand(keyInfo)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	oneKeyInfo.SourceAttr.ItemCount, oneKeyInfo.TargetAttr.ItemCount = sourceLen[0], targetLen[0]
+	oneKeyInfo.Field = nil
+	if sourceLen[0] == common.TypeChanged || targetLen[0] == common.TypeChanged {
+		oneKeyInfo.ConflictType = common.TypeConflict
+	} else if sourceLen[0] == 0 && targetLen[0] == 0 {
+		oneKeyInfo.ConflictType = common.NoneConflict
+	} else if sourceLen[0] == 0 {
+		oneKeyInfo.ConflictType = common.LackSourceConflict
+	} else if targetLen[0] == 0 {
+		oneKeyInfo.ConflictType = common.LackTargetConflict
+	} else if sourceLen[0] != targetLen[0] {
+		oneKeyInfo.ConflictType = common.ValueConflict
+	} else {
+		return true
+	}
+	return false
+}
+
+// the string longer than stringwindow is compared window by window instead of fetching the whole value
+func (p *FullValueVerifier) isLongString(oneKeyInfo *common.Key) bool {
+	return oneKeyInfo.Tp == common.StringKeyType && p.Param.StringWindow > 0 && p.Param.CompareHll == false &&
+		len(p.Param.SourceHost.ValueTransform) == 0 && len(p.Param.TargetHost.ValueTransform) == 0 &&
+		(oneKeyInfo.SourceAttr.ItemCount > p.Param.StringWindow ||
+			oneKeyInfo.TargetAttr.ItemCount > p.Param.StringWindow)
+}
+
+/*
+ * Compare the long string by getrange window by window, and stop at the first differing window which
+ * is reported as the field "start-end". WindowBatch windows are fetched in one pipeline and compared
+ * at once, so at most WindowBatch windows of each side are held instead of the whole values.
+ */
+func (p *FullValueVerifier) CompareStringByWindow(oneKeyInfo *common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) {
+	if p.fetchStringLen(oneKeyInfo, sourceClient, targetClient) {
+		oneKeyInfo.ConflictType = common.NoneConflict
+		length := oneKeyInfo.SourceAttr.ItemCount
+		window := p.Param.StringWindow
+		for batch := int64(0); batch < length; batch += window * WindowBatch {
+			start := make([]int64, 0, WindowBatch)
+			end := make([]int64, 0, WindowBatch)
+			for i := batch; i < length && i < batch+window*WindowBatch; i += window {
+				start = append(start, i)
+				if i+window-1 < length {
+					end = append(end, i+window-1)
+				} else {
+					end = append(end, length-1)
+				}
+			}
+			var sourceValue, targetValue []interface{}
+			err := fetchBoth(func() (err error) {
+				sourceValue, err = sourceClient.PipeGetrangeCommand(oneKeyInfo.Key, start, end)
+				return err
+			}, func() (err error) {
+				targetValue, err = targetClient.PipeGetrangeCommand(oneKeyInfo.Key, start, end)
+				return err
+			})
+			if err != nil {
+				if p.CheckTypeChanged(oneKeyInfo, conflictKey, err) {
+					return
+				}
+				panic(common.Logger.Error(err))
+			}
+			for i := range start {
+				if bytes.Equal(sourceValue[i].([]byte), targetValue[i].([]byte)) == false {
+					oneKeyInfo.Field = []common.Field{{
+						Field:        []byte(fmt.Sprintf("%d-%d", start[i], end[i])),
+						ConflictType: common.ValueConflict,
+					}}
+					oneKeyInfo.ConflictType = common.ValueConflict
+					p.IncrFieldStat(oneKeyInfo, common.ValueConflict)
+					break
+				}
+			}
+			if oneKeyInfo.ConflictType != common.NoneConflict {
+				break
+			}
+		}
+	}
+
+	if oneKeyInfo.ConflictType != common.NoneConflict {
+		conflictKey <- oneKeyInfo
+	}
+	p.IncrKeyStat(oneKeyInfo)
+}
+
+// locate the differing segments by bitcount, then by getrange if the bitcount of every segment is equal
+func (p *FullValueVerifier) diffBitmapRange(oneKeyInfo *common.Key, sourceClient, targetClient *client.RedisClient) {
+	length := oneKeyInfo.SourceAttr.ItemCount
+	if length < oneKeyInfo.TargetAttr.ItemCount {
+		length = oneKeyInfo.TargetAttr.ItemCount
+	}
+
+	var sourceCount, targetCount []int64
+	err := fetchBoth(func() (err error) {
+		sourceCount, err = sourceClient.PipeBitcountSegmentCommand(oneKeyInfo.Key, BitmapSegment, length)
+		return err
+	}, func() (err error) {
+		targetCount, err = targetClient.PipeBitcountSegmentCommand(oneKeyInfo.Key, BitmapSegment, length)
+		return err
+	})
+	if err != nil {
+		panic(common.Logger.Error(err))
+	}
+	diff := make([]bool, len(sourceCount))
+	found := false
+	for i := range sourceCount {
+		diff[i] = sourceCount[i] != targetCount[i]
+		found = found || diff[i]
+	}
+
+	if found == false {
+		p.diffByGetrange(oneKeyInfo, diff, sourceClient, targetClient)
+	}
+	p.appendSegmentField(oneKeyInfo, diff, length)
+}
+
+// compare every segment of the string by getrange, diff[i] is set when the i-th segment differs
+func (p *FullValueVerifier) diffByGetrange(oneKeyInfo *common.Key, diff []bool, sourceClient,
+		targetClient *client.RedisClient) {
+	for segment := 0; segment < len(diff); segment += p.Param.BatchCount {
+		start := make([]int64, 0, p.Param.BatchCount)
+		end := make([]int64, 0, p.Param.BatchCount)
+		for i := segment; i < len(diff) && i < segment+p.Param.BatchCount; i++ {
+			start = append(start, int64(i)*BitmapSegment)
+			end = append(end, int64(i+1)*BitmapSegment-1)
+		}
+		var sourceValue, targetValue []interface{}
+		err := fetchBoth(func() (err error) {
+			sourceValue, err = sourceClient.PipeGetrangeCommand(oneKeyInfo.Key, start, end)
+			return err
+		}, func() (err error) {
+			targetValue, err = targetClient.PipeGetrangeCommand(oneKeyInfo.Key, start, end)
+			return err
+		})
+		if err != nil {
+			panic(common.Logger.Error(err))
+		}
+		for i := range start {
+			diff[segment+i] = bytes.Equal(sourceValue[i].([]byte), targetValue[i].([]byte)) == false
+		}
+	}
+}
+
+// merge the adjacent differing segments and report them as fields "start-end"
+func (p *FullValueVerifier) appendSegmentField(oneKeyInfo *common.Key, diff []bool, length int64) {
+	for i := 0; i < len(diff); i++ {
+		if diff[i] == false {
+			continue
+		}
+		j := i
+		for j+1 < len(diff) && diff[j+1] {
+			j++
+		}
+		end := int64(j+1)*BitmapSegment - 1
+		if end >= length {
+			end = length - 1
+		}
+		oneKeyInfo.Field = append(oneKeyInfo.Field, common.Field{
+			Field:        []byte(fmt.Sprintf("%d-%d", int64(i)*BitmapSegment, end)),
+			ConflictType: common.ValueConflict,
+		})
+		p.IncrFieldStat(oneKeyInfo, common.ValueConflict)
+		i = j
+	}
+}
@@ -0,0 +1,71 @@
+package checker
+
+import (
+	"full_check/client"
+	"full_check/common"
+	"full_check/metric"
+)
+
+/*
+ * KeyExistenceVerifier only compares whether the key exists on both sides, the value isn't fetched.
+ * The keys scanned from the target but missing on the source are also verified by it, so both
+ * lack_target and lack_source are reported.
+ */
+type KeyExistenceVerifier struct {
+	KeyOutlineVerifier
+}
+
+func NewKeyExistenceVerifier(stat *metric.Stat, param *FullCheckParameter) *KeyExistenceVerifier {
+	return &KeyExistenceVerifier{KeyOutlineVerifier{VerifierBase{stat, param}}}
+}
+
+func (p *KeyExistenceVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
+	// the type on the source, and the existence on the target
+	p.FetchKeys(keyInfo, sourceClient, targetClient)
+
+	// fetch the type of the keys only on the target
+	onlyTargetKeyInfo := make([]*common.Key, 0)
+	for _, oneKeyInfo := range keyInfo {
+		if oneKeyInfo.Tp == common.NoneKeyType {
+			oneKeyInfo.SourceAttr.ItemCount = 0
+			if oneKeyInfo.TargetAttr.ItemCount > 0 {
+				onlyTargetKeyInfo = append(onlyTargetKeyInfo, oneKeyInfo)
+			}
+		}
+	}
+	if len(onlyTargetKeyInfo) != 0 {
+		targetKeyTypeStr, err := targetClient.PipeTypeCommand(onlyTargetKeyInfo)
+		if err != nil {
+			panic(common.Logger.Critical(err))
+		}
+		for i, t := range targetKeyTypeStr {
+			onlyTargetKeyInfo[i].Tp = common.NewKeyType(t)
+		}
+	}
+
+	// re-check ttl on the source side when key missing on the target side
+	p.RecheckTTL(keyInfo, sourceClient, targetClient)
+
+	for _, oneKeyInfo := range keyInfo {
+		// the type name of the module is given by the module itself
+		if oneKeyInfo.Tp == common.EndKeyType {
+			oneKeyInfo.Tp = common.ModuleKeyType
+		}
+
+		oneKeyInfo.Field = nil
+		switch {
+		case oneKeyInfo.SourceAttr.ItemCount > 0 && oneKeyInfo.TargetAttr.ItemCount == 0:
+			oneKeyInfo.ConflictType = common.LackTargetConflict
+		case oneKeyInfo.SourceAttr.ItemCount == 0 && oneKeyInfo.TargetAttr.ItemCount > 0 &&
+				oneKeyInfo.Tp != common.NoneKeyType:
+			oneKeyInfo.ConflictType = common.LackSourceConflict
+		default:
+			// exist on both sides or deleted on both sides
+			oneKeyInfo.ConflictType = common.NoneConflict
+		}
+		p.IncrKeyStat(oneKeyInfo)
+		if oneKeyInfo.ConflictType != common.NoneConflict {
+			conflictKey <- oneKeyInfo
+		}
+	}
+}
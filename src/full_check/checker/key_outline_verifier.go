@@ -2,7 +2,6 @@ package checker
 
 import (
 	"full_check/common"
-	"sync"
 	"full_check/metric"
 	"full_check/client"
 )
@@ -17,12 +16,10 @@ func NewKeyOutlineVerifier(stat *metric.Stat, param *FullCheckParameter) *KeyOut
 
 func (p *KeyOutlineVerifier) FetchKeys(keyInfo []*common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
 	// fetch type
-	var wg sync.WaitGroup
-	wg.Add(1)
-	go func() {
+	err := fetchBoth(func() error {
 		sourceKeyTypeStr, err := sourceClient.PipeTypeCommand(keyInfo)
 		if err != nil {
-			panic(common.Logger.Critical(err))
+			return err
 		}
 		for i, t := range sourceKeyTypeStr {
 			keyInfo[i].Tp = common.NewKeyType(t)
@@ -34,31 +31,30 @@ func (p *KeyOutlineVerifier) FetchKeys(keyInfo []*common.Key, sourceClient *clie
 			 */
 			keyInfo[i].SourceAttr.ItemCount = 1
 		}
-		wg.Done()
-	}()
-
-	wg.Add(1)
-	go func() {
+		return nil
+	}, func() error {
 		targetKeyTypeStr, err := targetClient.PipeExistsCommand(keyInfo)
 		if err != nil {
-			panic(common.Logger.Critical(err))
+			return err
 		}
 		for i, t := range targetKeyTypeStr {
 			keyInfo[i].TargetAttr.ItemCount = t
 		}
-		wg.Done()
-	}()
-
-	wg.Wait()
+		return nil
+	})
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
 }
 
 func (p *KeyOutlineVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient *client.RedisClient, targetClient *client.RedisClient) {
 	p.FetchKeys(keyInfo, sourceClient, targetClient)
 
 	// re-check ttl on the source side when key missing on the target side
-	p.RecheckTTL(keyInfo, sourceClient)
+	p.RecheckTTL(keyInfo, sourceClient, targetClient)
 
 	// compare, filter
+	equalKeyInfo := make([]*common.Key, 0, len(keyInfo))
 	for i := 0; i < len(keyInfo); i++ {
 		// 在fetch type和之后的轮次扫描之间源端类型更改，不处理这种错误
 		if keyInfo[i].SourceAttr.ItemCount == common.TypeChanged {
@@ -71,6 +67,11 @@ func (p *KeyOutlineVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, confli
 			keyInfo[i].ConflictType = common.LackTargetConflict
 			p.IncrKeyStat(keyInfo[i])
 			conflictKey <- keyInfo[i]
+			continue
 		}
+
+		equalKeyInfo = append(equalKeyInfo, keyInfo[i])
 	} // end of for i := 0; i < len(keyInfo); i++
+
+	p.VerifyAttribute(equalKeyInfo, conflictKey, sourceClient, targetClient)
 }
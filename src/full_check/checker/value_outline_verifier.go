@@ -14,9 +14,10 @@ func (p *ValueOutlineVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conf
 	p.FetchTypeAndLen(keyInfo, sourceClient, targetClient)
 
 	// re-check ttl on the source side when key missing on the target side
-	p.RecheckTTL(keyInfo, sourceClient)
+	p.RecheckTTL(keyInfo, sourceClient, targetClient)
 
 	// compare, filter
+	equalKeyInfo := make([]*common.Key, 0, len(keyInfo))
 	for i := 0; i < len(keyInfo); i++ {
 		// 取type时，source redis上key已经被删除，认为是没有不一致
 		if keyInfo[i].Tp == common.NoneKeyType {
@@ -30,11 +31,12 @@ func (p *ValueOutlineVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conf
 			continue
 		}
 
-		// key lack in target redis
-		if keyInfo[i].TargetAttr.ItemCount == 0 && keyInfo[i].TargetAttr.ItemCount != keyInfo[i].SourceAttr.ItemCount {
-			keyInfo[i].ConflictType = common.LackTargetConflict
-			p.IncrKeyStat(keyInfo[i])
-			conflictKey <- keyInfo[i]
+		// key lack in target redis, or empty on either side
+		if p.CheckEmptyKey(keyInfo[i], conflictKey) {
+			continue
+		}
+
+		if p.CheckTypeMismatch(keyInfo[i], conflictKey) {
 			continue
 		}
 
@@ -53,5 +55,9 @@ func (p *ValueOutlineVerifier) VerifyOneGroupKeyInfo(keyInfo []*common.Key, conf
 			conflictKey <- keyInfo[i]
 			continue
 		}
+
+		equalKeyInfo = append(equalKeyInfo, keyInfo[i])
 	} // end of for i := 0; i < len(keyInfo); i++
+
+	p.VerifyAttribute(equalKeyInfo, conflictKey, sourceClient, targetClient)
 }
\ No newline at end of file
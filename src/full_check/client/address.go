@@ -13,9 +13,28 @@ const (
 
 	RoleMaster = "master"
 	RoleSlave  = "slave"
+
+	UnixSocketPrefix = "unix://"
+	RdbFilePrefix    = "rdb://"
 )
 
-func HandleAddress(address, password, authType string) ([]string, error) {
+// split the network type from the address, e.g., "unix:///tmp/redis.sock" returns "unix" and "/tmp/redis.sock"
+func ParseNetwork(address string) (string, string) {
+	if strings.HasPrefix(address, UnixSocketPrefix) {
+		return "unix", strings.TrimPrefix(address, UnixSocketPrefix)
+	}
+	if strings.HasPrefix(address, RdbFilePrefix) {
+		return "rdb", strings.TrimPrefix(address, RdbFilePrefix)
+	}
+	return "tcp", common.NormalizeAddress(address)
+}
+
+/*
+ * Parse the input address into node list. For cluster db type, the input list is treated as seed
+ * nodes when it isn't the whole master or slave list, and all the master nodes are discovered from
+ * the first reachable seed.
+ */
+func HandleAddress(address, password, authType string, dbType int) ([]string, error) {
 	if strings.Contains(address, AddressSplitter) {
 		arr := strings.Split(address, AddressSplitter)
 		if len(arr) != 2 {
@@ -36,12 +55,18 @@ func HandleAddress(address, password, authType string) ([]string, error) {
 		return fetchNodeList(clusterList[0], password, authType, role)
 	} else {
 		clusterList := strings.Split(address, AddressClusterSplitter)
-		if len(clusterList) <= 1 {
+		for i := range clusterList {
+			if strings.HasPrefix(clusterList[i], UnixSocketPrefix) == false &&
+				strings.HasPrefix(clusterList[i], RdbFilePrefix) == false {
+				clusterList[i] = common.NormalizeAddress(clusterList[i])
+			}
+		}
+		if len(clusterList) <= 1 && dbType != common.TypeCluster {
 			return clusterList, nil
 		}
 
 		// fetch master
-		masterList, err := fetchNodeList(clusterList[0], password, authType, common.TypeMaster)
+		masterList, err := fetchNodeListFromSeeds(clusterList, password, authType, common.TypeMaster)
 		if err != nil {
 			return nil, err
 		}
@@ -50,7 +75,7 @@ func HandleAddress(address, password, authType string) ([]string, error) {
 			return clusterList, nil
 		}
 
-		slaveList, err := fetchNodeList(clusterList[0], password, authType, common.TypeSlave)
+		slaveList, err := fetchNodeListFromSeeds(clusterList, password, authType, common.TypeSlave)
 		if err != nil {
 			return nil, err
 		}
@@ -59,12 +84,30 @@ func HandleAddress(address, password, authType string) ([]string, error) {
 			return clusterList, nil
 		}
 
+		// input list is seed nodes, use all the masters discovered
+		if dbType == common.TypeCluster {
+			return masterList, nil
+		}
+
 		return nil, fmt.Errorf("if type isn't cluster, should only used 1 node. if type is cluster, " +
 			"input list should be all master or all slave: 'master1;master2;master3...' or " +
 			"'slave1;slave2;slave3...'")
 	}
 }
 
+// try the seed nodes one by one until the node list is fetched
+func fetchNodeListFromSeeds(seedList []string, password, authType, role string) ([]string, error) {
+	var err error
+	for _, seed := range seedList {
+		var nodeList []string
+		if nodeList, err = fetchNodeList(seed, password, authType, role); err == nil {
+			return nodeList, nil
+		}
+		common.Logger.Warnf("fetch node list from seed[%v] failed[%v]", seed, err)
+	}
+	return nil, err
+}
+
 func fetchNodeList(oneNode, password, authType, role string) ([]string, error) {
 	// create client to fetch
 	client, err := NewRedisClient(RedisHost{
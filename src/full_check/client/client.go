@@ -1,11 +1,14 @@
 package client
 
 import (
+	"bytes"
+	"context"
 	"fmt"
 	"io"
+	"math/rand"
 	"net"
-	"strconv"
 	"strings"
+	"sync/atomic"
 	"time"
 	"errors"
 
@@ -18,40 +21,170 @@ import (
 
 var (
 	emptyError = errors.New("empty")
+
+	// the type of the key is changed since fetching the type, e.g., the target key is rewritten as a hash
+	TypeChangedError = errors.New("key type changed")
+
+	// the reply isn't in the format expected by the command, the key can't be compared this time
+	MalformedReplyError = errors.New("malformed reply")
+
+	// the network error lasts after all the retries, the keys being compared are left unverified
+	RetryExhaustedError = errors.New("retry count exhausted")
+
+	netErrorInterval = time.Second // wait before reconnecting after the network error by default
+
+	// given as the specialErrorPrefix, the error reply is returned instead of being taken as TypeChanged
+	// since it never contains the line break
+	errorReplyReturned = "\r\n"
+
+	netErrorRetryCount   int64 // retry times caused by network error of all the clients
+	serverBusyRetryCount int64 // retry times caused by LOADING or BUSY error reply of all the clients
+
+	// the error replies which mean the server can't serve for now, the wait is doubled on every retry
+	serverBusyBackoff = []struct {
+		prefix   string
+		interval time.Duration
+		max      time.Duration
+	}{
+		{"LOADING ", time.Second, 10 * time.Second},        // loading the dataset may take minutes
+		{"BUSY ", 100 * time.Millisecond, 2 * time.Second}, // running a slow script
+	}
 )
 
+/*
+ * CredentialProvider returns the password used by AUTH. It's called on every new connection, so the
+ * short-lived token is refreshed transparently across the reconnects.
+ */
+type CredentialProvider func() (string, error)
+
+func NetErrorRetryCount() int64 {
+	return atomic.LoadInt64(&netErrorRetryCount)
+}
+
+func ServerBusyRetryCount() int64 {
+	return atomic.LoadInt64(&serverBusyRetryCount)
+}
+
 type RedisHost struct {
 	Addr         []string
 	Password     string
-	TimeoutMs    uint64
 	Role         string // "source" or "target"
 	Authtype     string // "auth" or "adminauth"
 	DBType       int
 	DBFilterList map[int]struct{} // whitelist
+	ReadOnly     bool             // send READONLY so the reads can be served by the cluster replica
+	NoSelect     bool             // don't send SELECT, e.g., twemproxy, only db 0 is served
+	NoTouch      bool             // send CLIENT NO-TOUCH ON so the reads don't update the LRU/LFU of the keys
+
+	PoolMaxIdle     int // connection pool is disabled when it's 0
+	PoolMaxActive   int // 0 means no limit
+	PoolIdleTimeout int // second
+	PoolKeepAlive   int // second, ping the idle connections of the pool every interval, 0 means disable
+
+	ConnectTimeoutMs uint64 // 0 means no timeout
+	CommandTimeoutMs uint64 // read and write timeout, 0 means no timeout
+	PipelineBatch    int    // max commands in one pipeline, 0 means no limit
+	AdaptiveScan     bool   // tune the COUNT of hscan/sscan/zscan by the latency and size of every page
+	RecordLatency    bool   // record the latency of the commands by key type, see LatencyStats
+
+	RetryCount   int            // tries of the command on the network error, 0 means common.MaxRetryCount
+	RetryBackoff common.Backoff // wait before reconnecting after the network error, 0 interval means 1 second
+
+	Bandwidth *common.ByteLimiter // limit the bytes of the replies, shared by the source and target, nil means no limit
+
+	Credential CredentialProvider // overrides Password when given
+
+	ValueTransform []common.ValueTransformRule // normalize the value fetched from this side before comparison
+
+	SentinelList   []string // Addr is the master resolved from sentinel when given
+	SentinelMaster string
+
+	KeyMap       *common.KeyMap        // the mapped key name is used as it is, the others are rewritten below
+	KeyRewrite   []common.KeyRewrite   // rewrite the key name before sending the command
+	KeyHashTag   []common.KeyHashTag   // applied to the key name after KeyRewrite
+	KeyTransform func([]byte) []byte   // overrides KeyRewrite and KeyHashTag when given
+	ValueCommand []common.ValueCommand // fetch the value of the module key
 }
 
 func (p RedisHost) String() string {
 	return fmt.Sprintf("%s redis addr: %s", p.Role, p.Addr)
 }
 
+// the password of the new connection
+func (p RedisHost) password() (string, error) {
+	if p.Credential == nil {
+		return p.Password, nil
+	}
+	password, err := p.Credential()
+	if err != nil {
+		return "", fmt.Errorf("fetch %s credential failed[%v]", p.Role, err)
+	}
+	return password, nil
+}
+
+func (p RedisHost) retryCount() int {
+	if p.RetryCount <= 0 {
+		return common.MaxRetryCount
+	}
+	return p.RetryCount
+}
+
+func (p RedisHost) retryWait(retry int) time.Duration {
+	if p.RetryBackoff.Interval <= 0 {
+		return netErrorInterval
+	}
+	return p.RetryBackoff.Wait(retry)
+}
+
 func (p RedisHost) IsCluster() bool {
 	return p.DBType == common.TypeCluster
 }
 
+// cluster driver has its own connection pool
+func (p RedisHost) IsPooled() bool {
+	return p.PoolMaxIdle > 0 && p.IsCluster() == false
+}
+
 type RedisClient struct {
 	redisHost RedisHost
 	db        int32
 	conn      redis.Conn
+	ctx       context.Context        // the retries are aborted when it's done
+	replies   map[string]interface{} // cached replies, see CacheReplies
+	exhausted bool                   // a command failed after exhausting the retries, see RetryExhausted
+	retries   int                    // the network errors of the current command, see CheckHandleNetError
 }
 
 func (p RedisClient) String() string {
 	return p.redisHost.String()
 }
 
+// the key name on this redis, the cluster routes the command by it including the hash tag
+func (p *RedisClient) Key(key []byte) []byte {
+	if p.redisHost.KeyTransform != nil {
+		return p.redisHost.KeyTransform(key)
+	}
+	if p.redisHost.KeyMap != nil {
+		if target, ok := p.redisHost.KeyMap.Target(key); ok {
+			return target
+		}
+	}
+	return common.HashTagKey(p.redisHost.KeyHashTag, common.RewriteKey(p.redisHost.KeyRewrite, key))
+}
+
 func NewRedisClient(redisHost RedisHost, db int32) (RedisClient, error) {
+	return NewRedisClientContext(context.Background(), redisHost, db)
+}
+
+/*
+ * The command being executed is still bounded by the command timeout when ctx is done, but it
+ * won't be retried any more and the error of ctx is returned.
+ */
+func NewRedisClientContext(ctx context.Context, redisHost RedisHost, db int32) (RedisClient, error) {
 	rc := RedisClient{
 		redisHost: redisHost,
 		db:        db,
+		ctx:       ctx,
 	}
 
 	// send ping command first
@@ -63,68 +196,226 @@ func NewRedisClient(redisHost RedisHost, db int32) (RedisClient, error) {
 }
 
 func (p *RedisClient) CheckHandleNetError(err error) bool {
-	if err == io.EOF { // 对方断开网络
+	if err == io.EOF || isNetError(err) { // 对方断开网络或连接失败
+		atomic.AddInt64(&netErrorRetryCount, 1)
 		if p.conn != nil {
 			p.conn.Close()
 			p.conn = nil
-			// 网络相关错误1秒后重试
-			time.Sleep(time.Second)
-		}
-		return true
-	} else if _, ok := err.(net.Error); ok {
-		if p.conn != nil {
-			p.conn.Close()
-			p.conn = nil
-			// 网络相关错误1秒后重试
-			time.Sleep(time.Second)
 		}
+		// 网络相关错误按 RetryBackoff 等待后重试, 重连失败也同样退避
+		p.sleep(jitter(p.redisHost.retryWait(p.retries)))
+		p.retries++
 		return true
 	}
 	return false
 }
 
+/*
+ * The workers disconnected at the same time, e.g., by a failover, would reconnect in unison and make
+ * the server busier. The wait is randomized in [d/2, 3d/2) to spread their retries.
+ */
+func jitter(d time.Duration) time.Duration {
+	if d <= 0 {
+		return d
+	}
+	return d/2 + time.Duration(rand.Int63n(int64(d)))
+}
+
+// the retries of the network error are exhausted, the caller fails with the returned error
+func (p *RedisClient) exhaust(err error) error {
+	p.exhausted = true
+	return fmt.Errorf("%w after %d attempts, the last error: %v", RetryExhaustedError, p.redisHost.retryCount(), err)
+}
+
+// whether a command failed after exhausting the retries since the last call
+func (p *RedisClient) RetryExhausted() bool {
+	exhausted := p.exhausted
+	p.exhausted = false
+	return exhausted
+}
+
+// wake up early when the context is done, the caller checks the context before retrying
+func (p *RedisClient) sleep(d time.Duration) {
+	if p.ctx == nil {
+		time.Sleep(d)
+		return
+	}
+	select {
+	case <-time.After(d):
+	case <-p.ctx.Done():
+	}
+}
+
+func (p *RedisClient) contextErr() error {
+	if p.ctx == nil {
+		return nil
+	}
+	return p.ctx.Err()
+}
+
+func isWrongType(err error) bool {
+	return strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
+
+// the error reply of the server, e.g., the unknown command, rather than the network or the client error
+func IsErrorReply(err error) bool {
+	_, ok := err.(redis.Error)
+	return ok
+}
+
+// the commands of the single key get the error reply WRONGTYPE when the key type is changed
+func checkTypeChanged(ret []interface{}) error {
+	for _, ele := range ret {
+		if v, ok := ele.(int64); ok && v == common.TypeChanged {
+			return TypeChangedError
+		}
+	}
+	return nil
+}
+
+func isNetError(err error) bool {
+	_, ok := err.(net.Error)
+	return ok
+}
+
+// wait for a while and return true when the error reply is LOADING or BUSY. these are retried
+// separately from the network error because the connection is still fine.
+func (p *RedisClient) CheckHandleServerBusy(err error, busyCount int) bool {
+	if busyCount >= common.MaxBusyRetryCount {
+		return false
+	}
+	wait := serverBusyWait(err, busyCount)
+	if wait == 0 {
+		return false
+	}
+	wait = jitter(wait)
+
+	atomic.AddInt64(&serverBusyRetryCount, 1)
+	common.Logger.Warn(common.LogFields(fmt.Sprintf("%v is busy[%v], retry after %v", p.redisHost.Addr, err, wait),
+		"db", p.db, "retry_count", busyCount+1))
+	p.sleep(wait)
+	return true
+}
+
+func serverBusyWait(err error, busyCount int) time.Duration {
+	if err == nil || isNetError(err) {
+		return 0
+	}
+	for _, ele := range serverBusyBackoff {
+		if strings.HasPrefix(err.Error(), ele.prefix) {
+			if busyCount > 16 {
+				return ele.max
+			}
+			wait := ele.interval << uint(busyCount)
+			if wait > ele.max {
+				wait = ele.max
+			}
+			return wait
+		}
+	}
+	return 0
+}
+
 func (p *RedisClient) Connect() error {
 	if p.conn != nil {
 		return nil
 	}
 
-	var err error
+	if p.redisHost.IsPooled() {
+		conn := getPool(p.redisHost, p.db).Get()
+		if err := conn.Err(); err != nil {
+			conn.Close()
+			return err
+		}
+		p.conn = conn
+		return nil
+	}
+	return p.dial()
+}
+
+// return the borrowed connection to the pool
+func (p *RedisClient) release() {
+	if p.redisHost.IsPooled() && p.conn != nil {
+		p.conn.Close()
+		p.conn = nil
+	}
+}
+
+// build a new connection and then auth and select db
+func (p *RedisClient) dial() error {
+	password, err := p.redisHost.password()
+	if err != nil {
+		return err
+	}
 	if p.redisHost.IsCluster() == false {
 		// single db or proxy
-		if p.redisHost.TimeoutMs == 0 {
-			p.conn, err = redis.Dial("tcp", p.redisHost.Addr[0])
+		addr := p.redisHost.Addr[0]
+		if len(p.redisHost.SentinelList) != 0 {
+			// resolve the master every time so the new master is used after failover
+			if addr, err = ResolveSentinelMaster(p.redisHost.SentinelList, p.redisHost.SentinelMaster); err != nil {
+				return err
+			}
+			if addr != p.redisHost.Addr[0] {
+				common.Logger.Infof("%s master[%v] switched from %v to %v", p.redisHost.Role,
+					p.redisHost.SentinelMaster, p.redisHost.Addr[0], addr)
+			}
+		}
+		network, address := ParseNetwork(addr)
+		if network == "rdb" {
+			var file *common.RdbFile
+			if file, err = openRdbFile(address); err == nil {
+				p.conn = common.NewRdbConn(file)
+			}
 		} else {
-			p.conn, err = redis.DialTimeout("tcp", p.redisHost.Addr[0], time.Millisecond*time.Duration(p.redisHost.TimeoutMs),
-				time.Millisecond*time.Duration(p.redisHost.TimeoutMs), time.Millisecond*time.Duration(p.redisHost.TimeoutMs))
+			commandTimeout := time.Millisecond * time.Duration(p.redisHost.CommandTimeoutMs)
+			p.conn, err = redis.DialTimeout(network, address, time.Millisecond*time.Duration(p.redisHost.ConnectTimeoutMs),
+				commandTimeout, commandTimeout)
 		}
 	} else {
 		// cluster
-		cluster, err := redigoCluster.NewCluster(
+		var cluster *redigoCluster.Cluster
+		cluster, err = redigoCluster.NewCluster(
 			&redigoCluster.Options{
 				StartNodes:   p.redisHost.Addr,
-				ConnTimeout:  time.Duration(p.redisHost.TimeoutMs) * time.Millisecond,
-				ReadTimeout:  0,
-				WriteTimeout: 0,
+				ConnTimeout:  time.Duration(p.redisHost.ConnectTimeoutMs) * time.Millisecond,
+				ReadTimeout:  time.Duration(p.redisHost.CommandTimeoutMs) * time.Millisecond,
+				WriteTimeout: time.Duration(p.redisHost.CommandTimeoutMs) * time.Millisecond,
 				KeepAlive:    16,
 				AliveTime:    60 * time.Second,
-				Password:     p.redisHost.Password,
+				Password:     password,
 			})
 		if err == nil {
-			p.conn = common.NewClusterConn(cluster, 0)
+			p.conn = common.NewClusterConn(cluster, 0, p.redisHost.Addr, p.dialNode(password), p.redisHost.ReadOnly)
 		}
 	}
 	if err != nil {
 		return err
 	}
 
-	if len(p.redisHost.Password) != 0 {
-		_, err = p.conn.Do(p.redisHost.Authtype, p.redisHost.Password)
+	// cluster driver has already done the auth on every node by the password in options
+	if len(password) != 0 && p.redisHost.IsCluster() == false {
+		_, err = p.conn.Do(p.redisHost.Authtype, password)
 		if err != nil {
 			return err
 		}
 	}
 
-	if p.redisHost.DBType != common.TypeCluster {
+	// the cluster sends READONLY on the node connections in dialNode, the driver only sends to the masters
+	if p.redisHost.ReadOnly && p.redisHost.IsCluster() == false {
+		if _, err = p.conn.Do("readonly"); err != nil {
+			return fmt.Errorf("send readonly to %v failed[%v]", p.redisHost.Addr, err)
+		}
+	}
+
+	// TYPE, TTL, OBJECT and EXISTS never touch the key, but the commands fetching the length and value do
+	if p.redisHost.NoTouch && p.redisHost.IsCluster() == false {
+		if _, err = p.conn.Do("client", "no-touch", "on"); err != nil {
+			return fmt.Errorf("send client no-touch to %v failed[%v], it's supported since redis 7.2",
+				p.redisHost.Addr, err)
+		}
+	}
+
+	if p.redisHost.DBType != common.TypeCluster && p.redisHost.NoSelect == false {
 		_, err = p.conn.Do("select", p.db)
 		if err != nil {
 			return err
@@ -137,30 +428,134 @@ func (p *RedisClient) Connect() error {
 	return nil
 }
 
+// connect to one node of the cluster, used to send the commands whose first argument isn't the key and
+// all the commands with the key when the reads are served by the replicas
+func (p *RedisClient) dialNode(password string) func(addr string) (redis.Conn, error) {
+	return func(addr string) (redis.Conn, error) {
+		commandTimeout := time.Millisecond * time.Duration(p.redisHost.CommandTimeoutMs)
+		conn, err := redis.DialTimeout("tcp", addr, time.Millisecond*time.Duration(p.redisHost.ConnectTimeoutMs),
+			commandTimeout, commandTimeout)
+		if err != nil {
+			return nil, err
+		}
+		if len(password) != 0 {
+			if _, err = conn.Do(p.redisHost.Authtype, password); err != nil {
+				conn.Close()
+				return nil, err
+			}
+		}
+		if p.redisHost.ReadOnly {
+			if _, err = conn.Do("readonly"); err != nil {
+				conn.Close()
+				return nil, fmt.Errorf("send readonly to %v failed[%v]", addr, err)
+			}
+		}
+		return conn, nil
+	}
+}
+
 func (p *RedisClient) Do(commandName string, args ...interface{}) (interface{}, error) {
+	if p.replies != nil {
+		if reply, ok := p.replies[replyCacheKey(commandName, args)]; ok {
+			return reply, nil
+		}
+	}
+	defer p.release()
+
 	var err error
 	var result interface{}
-	for tryCount := 0; tryCount < common.MaxRetryCount; tryCount++ {
+	busyCount := 0
+	p.retries = 0
+	for tryCount := 0; tryCount < p.redisHost.retryCount(); tryCount++ {
+		if ctxErr := p.contextErr(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		if p.conn == nil {
 			err = p.Connect()
 			if err != nil {
 				if p.CheckHandleNetError(err) {
 					continue
 				}
+				if p.CheckHandleServerBusy(err, busyCount) {
+					// auth or select is rejected, build the connection again
+					p.Close()
+					busyCount++
+					tryCount--
+					continue
+				}
 				return nil, err
 			}
 		}
 
+		start := time.Now()
 		result, err = p.conn.Do(commandName, args...)
+		if err == nil && p.redisHost.RecordLatency {
+			observeLatency(p.redisHost.Role, common.CommandKeyType(commandName), time.Since(start))
+		}
 		if err != nil {
 			if p.CheckHandleNetError(err) {
 				continue
 			}
+			if isWrongType(err) {
+				return nil, TypeChangedError
+			}
+			if p.CheckHandleServerBusy(err, busyCount) {
+				busyCount++
+				tryCount-- // doesn't count as the network retry
+				continue
+			}
 			return nil, err
 		}
 		break
 	} // end for {}
-	return result, err
+	if err != nil {
+		return nil, p.exhaust(err)
+	}
+	p.limitBandwidth(result)
+	if p.replies != nil {
+		p.replies[replyCacheKey(commandName, args)] = result
+	}
+	return result, nil
+}
+
+/*
+ * The replies are kept after CacheReplies(true), and the same command sent again is answered from
+ * the cache until CacheReplies(false). It's used to fetch the source value once when it's compared
+ * with several targets.
+ */
+func (p *RedisClient) CacheReplies(enable bool) {
+	if enable {
+		p.replies = make(map[string]interface{})
+	} else {
+		p.replies = nil
+	}
+}
+
+func replyCacheKey(commandName string, args []interface{}) string {
+	var buf bytes.Buffer
+	buf.WriteString(strings.ToLower(commandName))
+	for _, arg := range args {
+		buf.WriteByte(0)
+		switch v := arg.(type) {
+		case []byte:
+			buf.Write(v)
+		case string:
+			buf.WriteString(v)
+		default:
+			fmt.Fprint(&buf, v)
+		}
+	}
+	return buf.String()
+}
+
+// wait until the bytes of the reply are allowed by the bandwidth limit
+func (p *RedisClient) limitBandwidth(reply interface{}) {
+	if p.redisHost.Bandwidth == nil {
+		return
+	}
+	if wait := p.redisHost.Bandwidth.Reserve(common.ReplySize(reply)); wait > 0 {
+		p.sleep(wait)
+	}
 }
 
 func (p *RedisClient) Close() {
@@ -190,21 +585,69 @@ func (p *RedisClient) PipeRawCommand(commands []combine, specialErrorPrefix stri
 		return nil, emptyError
 	}
 
+	batch := p.redisHost.PipelineBatch
+	if batch <= 0 || len(commands) <= batch {
+		return p.pipeRawCommand(commands, specialErrorPrefix)
+	}
+
+	// send and receive in chunks so the output buffer of the server doesn't grow too large
+	result := make([]interface{}, 0, len(commands))
+	for start := 0; start < len(commands); start += batch {
+		end := start + batch
+		if end > len(commands) {
+			end = len(commands)
+		}
+		ret, err := p.pipeRawCommand(commands[start:end], specialErrorPrefix)
+		if err != nil {
+			return nil, err
+		}
+		result = append(result, ret...)
+	}
+	return result, nil
+}
+
+func (p *RedisClient) pipeRawCommand(commands []combine, specialErrorPrefix string) ([]interface{}, error) {
 	result := make([]interface{}, len(commands))
+	if p.replies != nil {
+		cached := true
+		for i, ele := range commands {
+			if result[i], cached = p.replies[replyCacheKey(ele.command, ele.params)]; !cached {
+				break
+			}
+		}
+		if cached {
+			return result, nil
+		}
+	}
+	defer p.release()
+
 	var err error
+	busyCount := 0
+	succeeded := false
+	p.retries = 0
 begin:
-	for tryCount := 0; tryCount < common.MaxRetryCount; tryCount++ {
+	for tryCount := 0; tryCount < p.redisHost.retryCount(); tryCount++ {
+		if ctxErr := p.contextErr(); ctxErr != nil {
+			return nil, ctxErr
+		}
 		if p.conn == nil {
 			err = p.Connect()
 			if err != nil {
 				if p.CheckHandleNetError(err) {
 					continue
 				}
+				if p.CheckHandleServerBusy(err, busyCount) {
+					p.Close()
+					busyCount++
+					tryCount--
+					continue
+				}
 				common.Logger.Errorf("connect failed[%v]", err)
 				return nil, err
 			}
 		}
 
+		start := time.Now()
 		for _, ele := range commands {
 			err = p.conn.Send(ele.command, ele.params...)
 			if err != nil {
@@ -224,12 +667,19 @@ begin:
 			return nil, err
 		}
 
+		var busyErr error
 		for i := 0; i < len(commands); i++ {
-			reply, err := p.conn.Receive()
+			var reply interface{}
+			reply, err = p.conn.Receive()
 			if err != nil {
 				if p.CheckHandleNetError(err) {
 					continue begin
 				}
+				// read all the replies out before retrying the whole pipeline
+				if serverBusyWait(err, busyCount) != 0 {
+					busyErr = err
+					continue
+				}
 				// 此处处理不太好，但是别人代码写死了，我只能这么改了
 				if strings.HasPrefix(err.Error(), specialErrorPrefix) {
 					// this error means the type between initial 'scan' and the following round comparison
@@ -242,8 +692,30 @@ begin:
 			}
 			result[i] = reply
 		}
+		if busyErr != nil {
+			if p.CheckHandleServerBusy(busyErr, busyCount) {
+				busyCount++
+				tryCount--
+				continue
+			}
+			common.Logger.Errorf("receive command failed[%v]", busyErr)
+			return nil, busyErr
+		}
+		if p.redisHost.RecordLatency {
+			observeLatency(p.redisHost.Role, pipelineKeyType(commands), time.Since(start))
+		}
+		succeeded = true
 		break
 	} // end for {}
+	if succeeded == false {
+		return nil, p.exhaust(err)
+	}
+	p.limitBandwidth(result)
+	if p.replies != nil {
+		for i, ele := range commands {
+			p.replies[replyCacheKey(ele.command, ele.params)] = result[i]
+		}
+	}
 	return result, nil
 }
 
@@ -252,7 +724,7 @@ func (p *RedisClient) PipeTypeCommand(keyInfo []*common.Key) ([]string, error) {
 	for i, key := range keyInfo {
 		commands[i] = combine{
 			command: "type",
-			params:  []interface{}{key.Key},
+			params:  []interface{}{p.Key(key.Key)},
 		}
 	}
 
@@ -282,7 +754,7 @@ func (p *RedisClient) PipeExistsCommand(keyInfo []*common.Key) ([]int64, error)
 	for i, key := range keyInfo {
 		commands[i] = combine{
 			command: "exists",
-			params:  []interface{}{key.Key},
+			params:  []interface{}{p.Key(key.Key)},
 		}
 	}
 
@@ -311,7 +783,7 @@ func (p *RedisClient) PipeLenCommand(keyInfo []*common.Key) ([]int64, error) {
 	for i, key := range keyInfo {
 		commands[i] = combine{
 			command: key.Tp.FetchLenCommand,
-			params:  []interface{}{key.Key},
+			params:  []interface{}{p.Key(key.Key)},
 		}
 	}
 
@@ -340,7 +812,7 @@ func (p *RedisClient) PipeTTLCommand(keyInfo []*common.Key) ([]bool, error) {
 	for i, key := range keyInfo {
 		commands[i] = combine{
 			command: "ttl",
-			params:  []interface{}{key.Key},
+			params:  []interface{}{p.Key(key.Key)},
 		}
 	}
 
@@ -364,6 +836,301 @@ func (p *RedisClient) PipeTTLCommand(keyInfo []*common.Key) ([]bool, error) {
 	return result, nil
 }
 
+// return the remaining time to live in milliseconds, -1 means no expire, -2 means key not exists
+func (p *RedisClient) PipePTTLCommand(keyInfo []*common.Key) ([]int64, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "pttl",
+			params:  []interface{}{p.Key(key.Key)},
+		}
+	}
+
+	result := make([]int64, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if v, ok := ele.(int64); ok {
+				result[i] = v
+			} else {
+				err := fmt.Errorf("run PipeRawCommand with commands[%s] return element[%v] isn't type int64[%v]",
+					printCombinList(commands), ele, reflect.TypeOf(ele))
+				common.Logger.Error(err)
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// return the object encoding, empty string means key not exists
+func (p *RedisClient) PipeObjectEncodingCommand(keyInfo []*common.Key) ([]string, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "object",
+			params:  []interface{}{[]byte("encoding"), p.Key(key.Key)},
+		}
+	}
+
+	result := make([]string, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if ele == nil {
+				continue
+			}
+			if v, ok := ele.([]byte); ok {
+				result[i] = string(v)
+			} else {
+				err := fmt.Errorf("run PipeRawCommand with commands[%s] return element[%v] isn't type []byte[%v]",
+					printCombinList(commands), ele, reflect.TypeOf(ele))
+				common.Logger.Error(err)
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+/*
+ * return the idle time in seconds, -1 means key not exists. The idle time isn't tracked under the lfu
+ * maxmemory-policy, the error reply is returned then and IsErrorReply tells it.
+ */
+func (p *RedisClient) PipeObjectIdletimeCommand(keyInfo []*common.Key) ([]int64, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "object",
+			params:  []interface{}{[]byte("idletime"), p.Key(key.Key)},
+		}
+	}
+
+	result := make([]int64, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, errorReplyReturned); err != nil {
+		if IsErrorReply(err) {
+			// the replies after the error one aren't read
+			p.Close()
+			return nil, err
+		}
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if ele == nil {
+				result[i] = -1
+			} else if v, ok := ele.(int64); ok {
+				result[i] = v
+			} else {
+				err := fmt.Errorf("run PipeRawCommand with commands[%s] return element[%v] isn't type int64[%v]",
+					printCombinList(commands), ele, reflect.TypeOf(ele))
+				common.Logger.Error(err)
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// cardinality of the HyperLogLog keys, 0 when key isn't exist and -1 on the error reply, e.g., INVALIDOBJ
+func (p *RedisClient) PipePfcountCommand(keyInfo []*common.Key) ([]int64, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "pfcount",
+			params:  []interface{}{p.Key(key.Key)},
+		}
+	}
+
+	result := make([]int64, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if v, ok := ele.(int64); ok {
+				result[i] = v
+			} else {
+				err := fmt.Errorf("run PipeRawCommand with commands[%s] return element[%v] isn't type int64[%v]",
+					printCombinList(commands), ele, reflect.TypeOf(ele))
+				common.Logger.Error(err)
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// bitcount of the whole keys
+func (p *RedisClient) PipeBitcountCommand(keyInfo []*common.Key) ([]int64, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "bitcount",
+			params:  []interface{}{p.Key(key.Key)},
+		}
+	}
+	return p.pipeInt64Command(commands)
+}
+
+// bitcount of every segment of the key, the last segment may be shorter
+func (p *RedisClient) PipeBitcountSegmentCommand(key []byte, segment, length int64) ([]int64, error) {
+	key = p.Key(key)
+	commands := make([]combine, 0, length/segment+1)
+	for start := int64(0); start < length; start += segment {
+		commands = append(commands, combine{
+			command: "bitcount",
+			params:  []interface{}{key, start, start + segment - 1},
+		})
+	}
+	ret, err := p.pipeInt64Command(commands)
+	if err != nil {
+		return nil, err
+	}
+	for _, count := range ret {
+		if count == common.TypeChanged {
+			return nil, TypeChangedError
+		}
+	}
+	return ret, nil
+}
+
+// the absolute unix time in milliseconds when the key expires, -1 means no expire and -2 means not exists
+func (p *RedisClient) PipePExpireTimeCommand(keyInfo []*common.Key) ([]int64, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "pexpiretime",
+			params:  []interface{}{p.Key(key.Key)},
+		}
+	}
+	return p.pipeInt64Command(commands)
+}
+
+func (p *RedisClient) pipeInt64Command(commands []combine) ([]int64, error) {
+	result := make([]int64, len(commands))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if v, ok := ele.(int64); ok {
+				result[i] = v
+			} else {
+				err := fmt.Errorf("run PipeRawCommand with commands[%s] return element[%v] isn't type int64[%v]",
+					printCombinList(commands), ele, reflect.TypeOf(ele))
+				common.Logger.Error(err)
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// return the memory usage in bytes, -1 means key not exists
+func (p *RedisClient) PipeMemoryUsageCommand(keyInfo []*common.Key) ([]int64, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "memory",
+			params:  []interface{}{[]byte("usage"), p.Key(key.Key)},
+		}
+	}
+
+	result := make([]int64, len(keyInfo))
+	// the error reply, e.g., the unknown command before redis 4.0, is returned so the caller can skip it
+	if ret, err := p.PipeRawCommand(commands, errorReplyReturned); err != nil {
+		if IsErrorReply(err) {
+			// the replies after the error one aren't read
+			p.Close()
+			return nil, err
+		}
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if ele == nil {
+				result[i] = -1
+			} else if v, ok := ele.(int64); ok {
+				result[i] = v
+			} else {
+				err := fmt.Errorf("run PipeRawCommand with commands[%s] return element[%v] isn't type int64[%v]",
+					printCombinList(commands), ele, reflect.TypeOf(ele))
+				common.Logger.Error(err)
+				return nil, err
+			}
+		}
+	}
+	return result, nil
+}
+
+// the digest of the value(DEBUG DIGEST-VALUE), empty string when the command fails
+func (p *RedisClient) PipeDigestCommand(keyInfo []*common.Key) ([]string, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "debug",
+			params:  []interface{}{[]byte("digest-value"), p.Key(key.Key)},
+		}
+	}
+
+	result := make([]string, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			// one digest per key in the array
+			if list, ok := ele.([]interface{}); ok && len(list) == 1 {
+				ele = list[0]
+			}
+			switch v := ele.(type) {
+			case string:
+				result[i] = v
+			case []byte:
+				result[i] = string(v)
+			}
+		}
+	}
+	return result, nil
+}
+
+// the serialized value of every key, nil when the key is missing or DUMP fails, e.g., it's renamed
+func (p *RedisClient) PipeDumpCommand(keyInfo []*common.Key) ([][]byte, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: "dump",
+			params:  []interface{}{p.Key(key.Key)},
+		}
+	}
+
+	result := make([][]byte, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			if payload, ok := ele.([]byte); ok {
+				result[i] = payload
+			}
+		}
+	}
+	return result, nil
+}
+
 func (p *RedisClient) PipeValueCommand(keyInfo []*common.Key) ([]interface{}, error) {
 	commands := make([]combine, len(keyInfo))
 	for i, key := range keyInfo {
@@ -371,32 +1138,41 @@ func (p *RedisClient) PipeValueCommand(keyInfo []*common.Key) ([]interface{}, er
 		case common.StringKeyType:
 			commands[i] = combine{
 				command: "get",
-				params:  []interface{}{key.Key},
+				params:  []interface{}{p.Key(key.Key)},
 			}
 		case common.HashKeyType:
 			commands[i] = combine{
 				command: "hgetall",
-				params:  []interface{}{key.Key},
+				params:  []interface{}{p.Key(key.Key)},
 			}
 		case common.ListKeyType:
 			commands[i] = combine{
 				command: "lrange",
-				params:  []interface{}{key.Key, "0", "-1"},
+				params:  []interface{}{p.Key(key.Key), "0", "-1"},
 			}
 		case common.SetKeyType:
 			commands[i] = combine{
 				command: "smembers",
-				params:  []interface{}{key.Key},
+				params:  []interface{}{p.Key(key.Key)},
 			}
 		case common.ZsetKeyType:
 			commands[i] = combine{
 				command: "zrange",
-				params:  []interface{}{key.Key, "0", "-1", "WITHSCORES"},
+				params:  []interface{}{p.Key(key.Key), "0", "-1", "WITHSCORES"},
+			}
+		case common.ModuleKeyType:
+			if valueCommand := common.MatchValueCommand(p.redisHost.ValueCommand, key.Key); valueCommand != nil {
+				commands[i] = combine{
+					command: valueCommand.Command,
+					params:  valueCommand.BuildArgs(p.Key(key.Key)),
+				}
+				break
 			}
+			fallthrough
 		default:
 			commands[i] = combine{
 				command: "get",
-				params:  []interface{}{key.Key},
+				params:  []interface{}{p.Key(key.Key)},
 			}
 		}
 	}
@@ -409,6 +1185,7 @@ func (p *RedisClient) PipeValueCommand(keyInfo []*common.Key) ([]interface{}, er
 }
 
 func (p *RedisClient) PipeSismemberCommand(key []byte, field [][]byte) ([]interface{}, error) {
+	key = p.Key(key)
 	commands := make([]combine, len(field))
 	for i, ele := range field {
 		commands[i] = combine{
@@ -420,11 +1197,12 @@ func (p *RedisClient) PipeSismemberCommand(key []byte, field [][]byte) ([]interf
 	if ret, err := p.PipeRawCommand(commands, ""); err != nil && err != emptyError {
 		return nil, err
 	} else {
-		return ret, nil
+		return ret, checkTypeChanged(ret)
 	}
 }
 
 func (p *RedisClient) PipeZscoreCommand(key []byte, field [][]byte) ([]interface{}, error) {
+	key = p.Key(key)
 	commands := make([]combine, len(field))
 	for i, ele := range field {
 		commands[i] = combine{
@@ -436,8 +1214,62 @@ func (p *RedisClient) PipeZscoreCommand(key []byte, field [][]byte) ([]interface
 	if ret, err := p.PipeRawCommand(commands, ""); err != nil && err != emptyError {
 		return nil, err
 	} else {
-		return ret, nil
+		return ret, checkTypeChanged(ret)
+	}
+}
+
+func (p *RedisClient) PipeGetrangeCommand(key []byte, start, end []int64) ([]interface{}, error) {
+	key = p.Key(key)
+	commands := make([]combine, len(start))
+	for i := range start {
+		commands[i] = combine{
+			command: "getrange",
+			params:  []interface{}{key, start[i], end[i]},
+		}
+	}
+
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil && err != emptyError {
+		return nil, err
+	} else {
+		return ret, checkTypeChanged(ret)
+	}
+}
+
+// longitude and latitude of the geo members, nil when member isn't exist
+func (p *RedisClient) FetchGeoPos(key []byte, members [][]byte) ([][]float64, error) {
+	key = p.Key(key)
+	args := make([]interface{}, 0, len(members)+1)
+	args = append(args, key)
+	for _, member := range members {
+		args = append(args, member)
+	}
+
+	reply, err := p.Do("geopos", args...)
+	if err != nil {
+		return nil, err
+	}
+	replyList, ok := reply.([]interface{})
+	if ok == false || len(replyList) != len(members) {
+		return nil, fmt.Errorf("geopos %s failed, result: %+v", key, reply)
+	}
+
+	result := make([][]float64, len(members))
+	for i, ele := range replyList {
+		if ele == nil {
+			continue
+		}
+		pos, ok := ele.([]interface{})
+		if ok == false || len(pos) != 2 {
+			return nil, fmt.Errorf("geopos %s failed, result: %+v", key, reply)
+		}
+		result[i] = make([]float64, 2)
+		for j := range pos {
+			if result[i][j], err = redis.Float64(pos[j], nil); err != nil {
+				return nil, fmt.Errorf("geopos %s failed[%v], result: %+v", key, err, reply)
+			}
+		}
 	}
+	return result, nil
 }
 
 func (p *RedisClient) FetchValueUseScan_Hash_Set_SortedSet(oneKeyInfo *common.Key, onceScanCount int) (map[string][]byte, error) {
@@ -452,45 +1284,45 @@ func (p *RedisClient) FetchValueUseScan_Hash_Set_SortedSet(oneKeyInfo *common.Ke
 	default:
 		return nil, fmt.Errorf("key type %s is not hash/set/zset", oneKeyInfo.Tp)
 	}
+	var adaptive *common.AdaptiveCount
+	if p.redisHost.AdaptiveScan {
+		adaptive = common.NewAdaptiveCount(onceScanCount)
+	}
 	cursor := 0
 	value := make(map[string][]byte)
 	for {
-		reply, err := p.Do(scanCmd, oneKeyInfo.Key, cursor, "count", onceScanCount)
+		if adaptive != nil {
+			onceScanCount = adaptive.Count()
+		}
+		start := time.Now()
+		reply, err := p.Do(scanCmd, p.Key(oneKeyInfo.Key), cursor, "count", onceScanCount)
 		if err != nil {
 			return nil, err
 		}
-
-		replyList, ok := reply.([]interface{})
-		if ok == false || len(replyList) != 2 {
-			return nil, fmt.Errorf("%s %s %d count %d failed, result: %+v", scanCmd, string(oneKeyInfo.Key),
-				cursor, onceScanCount, reply)
+		if adaptive != nil {
+			adaptive.Observe(time.Since(start), common.ReplySize(reply))
 		}
 
-		cursorBytes, ok := replyList[0].([]byte)
-		if ok == false {
-			return nil, fmt.Errorf("%s %s %d count %d failed, result: %+v", scanCmd, string(oneKeyInfo.Key),
-				cursor, onceScanCount, reply)
+		next, keylist, err := common.ParseScanReply(reply)
+		if err == nil && oneKeyInfo.Tp != common.SetKeyType && len(keylist)%2 != 0 {
+			err = fmt.Errorf("odd element count %d", len(keylist))
 		}
-
-		cursor, err = strconv.Atoi(string(cursorBytes))
 		if err != nil {
-			return nil, err
+			return nil, fmt.Errorf("%w: %s %s %d count %d failed[%v], result: %+v", MalformedReplyError, scanCmd,
+				common.EncodeName(oneKeyInfo.Key), cursor, onceScanCount, err, reply)
 		}
+		cursor = next
 
-		keylist, ok := replyList[1].([]interface{})
-		if ok == false {
-			panic(common.Logger.Criticalf("%s %s failed, result: %+v", scanCmd, string(oneKeyInfo.Key), reply))
-		}
 		switch oneKeyInfo.Tp {
 		case common.HashKeyType:
 			fallthrough
 		case common.ZsetKeyType:
 			for i := 0; i < len(keylist); i += 2 {
-				value[string(keylist[i].([]byte))] = keylist[i+1].([]byte)
+				value[string(keylist[i])] = keylist[i+1]
 			}
 		case common.SetKeyType:
 			for i := 0; i < len(keylist); i++ {
-				value[string(keylist[i].([]byte))] = nil
+				value[string(keylist[i])] = nil
 			}
 		default:
 			return nil, fmt.Errorf("key type %s is not hash/set/zset", oneKeyInfo.Tp)
@@ -503,6 +1335,38 @@ func (p *RedisClient) FetchValueUseScan_Hash_Set_SortedSet(oneKeyInfo *common.Ke
 	return value, nil
 }
 
+// one page of sscan, the member may be returned more than once
+func (p *RedisClient) ScanSetMembers(key []byte, cursor, count int) (int, [][]byte, error) {
+	reply, err := p.Do("sscan", p.Key(key), cursor, "count", count)
+	if err != nil {
+		return 0, nil, err
+	}
+	next, members, err := common.ParseScanReply(reply)
+	if err != nil {
+		return 0, nil, fmt.Errorf("%w: sscan %s %d count %d failed[%v], result: %+v", MalformedReplyError,
+			common.EncodeName(key), cursor, count, err, reply)
+	}
+	return next, members, nil
+}
+
+// at most count distinct members picked randomly by srandmember
+func (p *RedisClient) RandomSetMembers(key []byte, count int64) ([][]byte, error) {
+	reply, err := p.Do("srandmember", p.Key(key), count)
+	if err != nil {
+		return nil, err
+	}
+	return redis.ByteSlices(reply, nil)
+}
+
+// the list elements in [start, end]
+func (p *RedisClient) Lrange(key []byte, start, end int) ([][]byte, error) {
+	reply, err := p.Do("lrange", p.Key(key), start, end)
+	if err != nil {
+		return nil, err
+	}
+	return redis.ByteSlices(reply, nil)
+}
+
 func printCombinList(input []combine) string {
 	ret := make([]string, 0, len(input))
 	for _, ele := range input {
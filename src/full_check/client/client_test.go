@@ -0,0 +1,244 @@
+package client
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"testing"
+
+	"full_check/common"
+
+	"github.com/cihub/seelog"
+	"github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// the connection answering every command by reply, the pipelined commands are answered on Receive
+type fakeConn struct {
+	reply   func(command string, args []interface{}) (interface{}, error)
+	pending []combine
+	closed  bool
+}
+
+func (c *fakeConn) Close() error {
+	c.closed = true
+	return nil
+}
+
+func (c *fakeConn) Err() error   { return nil }
+func (c *fakeConn) Flush() error { return nil }
+
+func (c *fakeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	return c.reply(commandName, args)
+}
+
+func (c *fakeConn) Send(commandName string, args ...interface{}) error {
+	c.pending = append(c.pending, combine{command: commandName, params: args})
+	return nil
+}
+
+func (c *fakeConn) Receive() (interface{}, error) {
+	command := c.pending[0]
+	c.pending = c.pending[1:]
+	return c.reply(command.command, command.params)
+}
+
+func fakeClient(conn *fakeConn) *RedisClient {
+	return &RedisClient{redisHost: RedisHost{Role: "source", RetryCount: 1}, conn: conn}
+}
+
+func keys(names ...string) []*common.Key {
+	keyInfo := make([]*common.Key, len(names))
+	for i, name := range names {
+		keyInfo[i] = &common.Key{Key: []byte(name), Tp: common.StringKeyType}
+	}
+	return keyInfo
+}
+
+func TestPipeMemoryUsageCommand(t *testing.T) {
+	logger := common.Logger
+	common.Logger = seelog.Disabled
+	defer func() {
+		common.Logger = logger
+	}()
+
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestPipeMemoryUsageCommand case %d.\n", nr)
+
+		// nil is returned for the key not exists
+		conn := &fakeConn{reply: func(command string, args []interface{}) (interface{}, error) {
+			if string(args[1].([]byte)) == "a" {
+				return int64(100), nil
+			}
+			return nil, nil
+		}}
+		memory, err := fakeClient(conn).PipeMemoryUsageCommand(keys("a", "b"))
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []int64{100, -1}, memory, "should be equal")
+		assert.Equal(t, false, conn.closed, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestPipeMemoryUsageCommand case %d.\n", nr)
+
+		// the error reply is returned, and the connection with the replies unread is closed
+		conn := &fakeConn{reply: func(command string, args []interface{}) (interface{}, error) {
+			return nil, redis.Error("ERR unknown command 'memory'")
+		}}
+		c := fakeClient(conn)
+		memory, err := c.PipeMemoryUsageCommand(keys("a", "b"))
+		assert.Equal(t, true, IsErrorReply(err), "should be equal")
+		assert.Equal(t, []int64(nil), memory, "should be equal")
+		assert.Equal(t, true, conn.closed, "should be equal")
+		assert.Equal(t, nil, c.conn, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestPipeMemoryUsageCommand case %d.\n", nr)
+
+		// the reply of the other type isn't the error reply
+		conn := &fakeConn{reply: func(command string, args []interface{}) (interface{}, error) {
+			return []byte("100"), nil
+		}}
+		_, err := fakeClient(conn).PipeMemoryUsageCommand(keys("a"))
+		assert.NotEqual(t, nil, err, "should be equal")
+		assert.Equal(t, false, IsErrorReply(err), "should be equal")
+	}
+}
+
+func TestScanSetMembers(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestScanSetMembers case %d.\n", nr)
+
+		conn := &fakeConn{reply: func(command string, args []interface{}) (interface{}, error) {
+			return []interface{}{[]byte("7"), []interface{}{[]byte("a"), []byte("b")}}, nil
+		}}
+		next, members, err := fakeClient(conn).ScanSetMembers([]byte("set"), 0, 10)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 7, next, "should be equal")
+		assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, members, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestScanSetMembers case %d.\n", nr)
+
+		// the malformed reply, e.g., returned by the proxy, is reported as MalformedReplyError
+		for _, reply := range []interface{}{
+			[]byte("OK"),
+			[]interface{}{[]byte("7")},
+			[]interface{}{int64(7), []interface{}{}},
+			[]interface{}{[]byte("7"), []interface{}{int64(1)}},
+		} {
+			reply := reply
+			conn := &fakeConn{reply: func(command string, args []interface{}) (interface{}, error) {
+				return reply, nil
+			}}
+			_, _, err := fakeClient(conn).ScanSetMembers([]byte("set"), 0, 10)
+			assert.Equal(t, true, errors.Is(err, MalformedReplyError), "should be equal")
+		}
+	}
+}
+
+func TestIsErrorReply(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestIsErrorReply case %d.\n", nr)
+
+		assert.Equal(t, true, IsErrorReply(redis.Error("ERR unknown command")), "should be equal")
+		assert.Equal(t, false, IsErrorReply(io.EOF), "should be equal")
+		assert.Equal(t, false, IsErrorReply(nil), "should be equal")
+		assert.Equal(t, false, IsErrorReply(fmt.Errorf("ERR unknown command")), "should be equal")
+	}
+}
+
+// serve one connection, every command is answered by +OK and its name is sent to commands
+func fakeServer(t *testing.T, commands chan<- string) string {
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("listen failed[%v]", err)
+	}
+	go func() {
+		defer listener.Close()
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		reader := bufio.NewReader(conn)
+		for {
+			// *<n>\r\n followed by n of $<len>\r\n<arg>\r\n
+			line, err := reader.ReadString('\n')
+			if err != nil {
+				close(commands)
+				return
+			}
+			n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+			args := make([]string, 0, n)
+			for i := 0; i < n; i++ {
+				reader.ReadString('\n')
+				arg, _ := reader.ReadString('\n')
+				args = append(args, strings.TrimSpace(arg))
+			}
+			commands <- strings.ToLower(strings.Join(args, " "))
+			conn.Write([]byte("+OK\r\n"))
+		}
+	}()
+	return listener.Addr().String()
+}
+
+func TestDialNode(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestDialNode case %d.\n", nr)
+
+		// the node connection of the cluster read from the replica sends READONLY after AUTH
+		commands := make(chan string, 10)
+		addr := fakeServer(t, commands)
+		p := &RedisClient{redisHost: RedisHost{Authtype: "auth", ReadOnly: true, ConnectTimeoutMs: 1000,
+			CommandTimeoutMs: 1000}}
+		conn, err := p.dialNode("secret")(addr)
+		assert.Equal(t, nil, err, "should be equal")
+		_, err = conn.Do("get", "foo")
+		assert.Equal(t, nil, err, "should be equal")
+		conn.Close()
+
+		sent := make([]string, 0)
+		for command := range commands {
+			sent = append(sent, command)
+		}
+		assert.Equal(t, []string{"auth secret", "readonly", "get foo"}, sent, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestDialNode case %d.\n", nr)
+
+		commands := make(chan string, 10)
+		addr := fakeServer(t, commands)
+		p := &RedisClient{redisHost: RedisHost{Authtype: "auth", ConnectTimeoutMs: 1000, CommandTimeoutMs: 1000}}
+		conn, err := p.dialNode("")(addr)
+		assert.Equal(t, nil, err, "should be equal")
+		_, err = conn.Do("memory", "usage", "foo")
+		assert.Equal(t, nil, err, "should be equal")
+		conn.Close()
+
+		sent := make([]string, 0)
+		for command := range commands {
+			sent = append(sent, command)
+		}
+		assert.Equal(t, []string{"memory usage foo"}, sent, "should be equal")
+	}
+}
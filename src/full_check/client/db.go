@@ -18,7 +18,17 @@ import (
 func (p *RedisClient) FetchBaseInfo(isCluster bool) (map[int32]int64, []string, error) {
 	var logicalDBMap map[int32]int64
 
-	if !isCluster {
+	if p.redisHost.NoSelect {
+		// only one logical db, the proxy may not support INFO either
+		logicalDBMap = map[int32]int64{0: 0}
+		if keyspaceContent, err := redis.Bytes(p.Do("info", "Keyspace")); err != nil {
+			common.Logger.Warnf("%s get keyspace failed[%v], the key count is unknown", p.redisHost.Role, err)
+		} else if keyspace, err := common.ParseKeyspace(keyspaceContent); err != nil {
+			common.Logger.Warnf("%s parse keyspace failed[%v], the key count is unknown", p.redisHost.Role, err)
+		} else {
+			logicalDBMap[0] = keyspace[0]
+		}
+	} else if !isCluster {
 		// get keyspace
 		keyspaceContent, err := p.Do("info", "Keyspace")
 		if err != nil {
@@ -72,7 +82,7 @@ func (p *RedisClient) FetchBaseInfo(isCluster bool) (map[int32]int64, []string,
 		}
 	case common.TypeTencentProxy:
 		var err error
-		physicalDBList, err = common.GetAllClusterNode(p.conn, "master", "id")
+		physicalDBList, err = common.GetAllClusterNode(p, "master", "id")
 		if err != nil {
 			return nil, nil, fmt.Errorf("get tencent cluster node failed[%v]", err)
 		}
@@ -0,0 +1,58 @@
+package client
+
+import (
+	"sync"
+	"time"
+
+	"full_check/common"
+)
+
+var (
+	latencyMap  = make(map[string]map[string]*common.LatencyHistogram) // role -> key type -> histogram
+	latencyLock sync.RWMutex
+)
+
+func observeLatency(role, keyType string, d time.Duration) {
+	latencyLock.RLock()
+	histogram := latencyMap[role][keyType]
+	latencyLock.RUnlock()
+
+	if histogram == nil {
+		latencyLock.Lock()
+		if _, ok := latencyMap[role]; !ok {
+			latencyMap[role] = make(map[string]*common.LatencyHistogram)
+		}
+		if histogram = latencyMap[role][keyType]; histogram == nil {
+			histogram = new(common.LatencyHistogram)
+			latencyMap[role][keyType] = histogram
+		}
+		latencyLock.Unlock()
+	}
+	histogram.Observe(d)
+}
+
+// the key type of the pipeline, "mixed" when the commands fetch different types
+func pipelineKeyType(commands []combine) string {
+	keyType := common.CommandKeyType(commands[0].command)
+	for _, ele := range commands[1:] {
+		if common.CommandKeyType(ele.command) != keyType {
+			return "mixed"
+		}
+	}
+	return keyType
+}
+
+// the latency of the commands and pipelines sent by the hosts with RecordLatency, role -> key type -> stat
+func LatencyStats() map[string]map[string]common.LatencyStat {
+	latencyLock.RLock()
+	defer latencyLock.RUnlock()
+
+	stats := make(map[string]map[string]common.LatencyStat, len(latencyMap))
+	for role, histograms := range latencyMap {
+		stats[role] = make(map[string]common.LatencyStat, len(histograms))
+		for keyType, histogram := range histograms {
+			stats[role][keyType] = histogram.Stat()
+		}
+	}
+	return stats
+}
@@ -0,0 +1,223 @@
+package client
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+var (
+	poolMap    = make(map[string]*redis.Pool) // host+db -> pool
+	keepAlives = make(map[string]*keepAlive)  // host+db -> keepalive of the pool, only when enabled
+	poolLock   sync.Mutex
+)
+
+/*
+ * The pooled connection pinged by the keepalive while it's idle in the pool. The pool flushes the
+ * connection by Do("") when it's returned, and calls TestOnBorrow when it's borrowed again, so the
+ * connection is idle in between. The lock keeps the ping from running along with the borrower.
+ */
+type keepAliveConn struct {
+	redis.Conn
+	owner *keepAlive
+	lock  sync.Mutex
+	idle  bool
+	since time.Time // the last command sent
+	err   error     // the ping failed while idle
+}
+
+func (c *keepAliveConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	reply, err := c.Conn.Do(commandName, args...)
+	if commandName == "" {
+		c.lock.Lock()
+		c.idle, c.since = true, time.Now()
+		c.lock.Unlock()
+	}
+	return reply, err
+}
+
+func (c *keepAliveConn) Close() error {
+	c.owner.remove(c)
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	return c.Conn.Close()
+}
+
+// mark the connection busy, return the error of the ping while idle
+func (c *keepAliveConn) borrow() error {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	c.idle = false
+	return c.err
+}
+
+func (c *keepAliveConn) ping(interval time.Duration) {
+	c.lock.Lock()
+	defer c.lock.Unlock()
+	if c.idle == false || c.err != nil || time.Since(c.since) < interval {
+		return
+	}
+	if _, err := c.Conn.Do("ping"); err != nil {
+		c.err = err
+		return
+	}
+	c.since = time.Now()
+}
+
+// ping the idle connections of one pool every interval so they aren't closed by the server timeout
+type keepAlive struct {
+	interval time.Duration
+	lock     sync.Mutex
+	conns    map[*keepAliveConn]struct{}
+	stop     chan struct{}
+}
+
+func newKeepAlive(interval time.Duration) *keepAlive {
+	p := &keepAlive{
+		interval: interval,
+		conns:    make(map[*keepAliveConn]struct{}),
+		stop:     make(chan struct{}),
+	}
+	go p.run()
+	return p
+}
+
+func (p *keepAlive) wrap(conn redis.Conn) redis.Conn {
+	c := &keepAliveConn{Conn: conn, owner: p, since: time.Now()}
+	p.lock.Lock()
+	p.conns[c] = struct{}{}
+	p.lock.Unlock()
+	return c
+}
+
+func (p *keepAlive) remove(c *keepAliveConn) {
+	p.lock.Lock()
+	delete(p.conns, c)
+	p.lock.Unlock()
+}
+
+func (p *keepAlive) run() {
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-p.stop:
+			return
+		case <-ticker.C:
+		}
+
+		p.lock.Lock()
+		conns := make([]*keepAliveConn, 0, len(p.conns))
+		for c := range p.conns {
+			conns = append(conns, c)
+		}
+		p.lock.Unlock()
+		for _, c := range conns {
+			c.ping(p.interval)
+		}
+	}
+}
+
+// get the connection pool of the given host and db, create if not exists
+func getPool(redisHost RedisHost, db int32) *redis.Pool {
+	name := fmt.Sprintf("%s-%d", strings.Join(redisHost.Addr, AddressClusterSplitter), db)
+
+	poolLock.Lock()
+	defer poolLock.Unlock()
+
+	if pool, ok := poolMap[name]; ok {
+		return pool
+	}
+
+	var alive *keepAlive
+	if redisHost.PoolKeepAlive > 0 {
+		alive = newKeepAlive(time.Duration(redisHost.PoolKeepAlive) * time.Second)
+		keepAlives[name] = alive
+	}
+
+	pool := &redis.Pool{
+		Dial: func() (redis.Conn, error) {
+			rc := RedisClient{
+				redisHost: redisHost,
+				db:        db,
+			}
+			if err := rc.dial(); err != nil {
+				if rc.conn != nil {
+					rc.conn.Close()
+				}
+				return nil, err
+			}
+			if alive != nil {
+				return alive.wrap(rc.conn), nil
+			}
+			return rc.conn, nil
+		},
+		TestOnBorrow: func(c redis.Conn, t time.Time) error {
+			if conn, ok := c.(*keepAliveConn); ok {
+				if err := conn.borrow(); err != nil {
+					return err
+				}
+			}
+			if time.Since(t) < time.Minute {
+				return nil
+			}
+			_, err := c.Do("ping")
+			return err
+		},
+		MaxIdle:     redisHost.PoolMaxIdle,
+		MaxActive:   redisHost.PoolMaxActive,
+		IdleTimeout: time.Duration(redisHost.PoolIdleTimeout) * time.Second,
+		Wait:        redisHost.PoolMaxActive > 0,
+	}
+	poolMap[name] = pool
+	return pool
+}
+
+/*
+ * Establish count connections of the pool concurrently and return them to the pool as idle, so
+ * the connect, auth and select of all the workers aren't paid on their first commands. The count
+ * shouldn't exceed the max idle or active connections of the pool.
+ */
+func WarmUpPool(redisHost RedisHost, db int32, count int) error {
+	pool := getPool(redisHost, db)
+	conns := make([]redis.Conn, count)
+	errs := make(chan error, count)
+	var wg sync.WaitGroup
+	wg.Add(count)
+	for i := range conns {
+		go func(i int) {
+			defer wg.Done()
+			conns[i] = pool.Get()
+			if err := conns[i].Err(); err != nil {
+				errs <- err
+			} else if _, err := conns[i].Do("ping"); err != nil {
+				errs <- err
+			}
+		}(i)
+	}
+	wg.Wait()
+	close(errs)
+
+	for _, conn := range conns {
+		conn.Close()
+	}
+	return <-errs
+}
+
+// close all the connection pools
+func ClosePools() {
+	poolLock.Lock()
+	defer poolLock.Unlock()
+
+	for name, pool := range poolMap {
+		pool.Close()
+		delete(poolMap, name)
+	}
+	for name, alive := range keepAlives {
+		close(alive.stop)
+		delete(keepAlives, name)
+	}
+}
@@ -0,0 +1,32 @@
+package client
+
+import (
+	"sync"
+
+	"full_check/common"
+)
+
+var (
+	rdbFileLock sync.Mutex
+	rdbFiles    = make(map[string]*common.RdbFile) // path -> file, parsed once and shared by all the clients
+)
+
+func openRdbFile(path string) (*common.RdbFile, error) {
+	rdbFileLock.Lock()
+	defer rdbFileLock.Unlock()
+
+	if file, ok := rdbFiles[path]; ok {
+		return file, nil
+	}
+	common.Logger.Infof("parsing rdb file[%v]", path)
+	file, err := common.OpenRdb(path)
+	if err != nil {
+		return nil, err
+	}
+	if file.Skipped() != 0 {
+		common.Logger.Warnf("rdb file[%v]: %d stream or module keys aren't supported and skipped",
+			path, file.Skipped())
+	}
+	rdbFiles[path] = file
+	return file, nil
+}
@@ -0,0 +1,116 @@
+package client
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+
+	"full_check/common"
+)
+
+/*
+ * digestScript returns the sha1 of the value of KEYS[1] computed on the server, so only the digest is
+ * transferred. The elements are sorted when the order depends on the encoding, and every element is
+ * prefixed by its length, so the digest is independent of the encoding but differs in the type.
+ * Empty string is returned for the types not supported.
+ */
+const digestScript = `
+local key = KEYS[1]
+local t = redis.call('type', key)['ok']
+local v
+if t == 'string' then
+	v = {redis.call('get', key)}
+elseif t == 'list' then
+	v = redis.call('lrange', key, 0, -1)
+elseif t == 'set' then
+	v = redis.call('smembers', key)
+	table.sort(v)
+elseif t == 'zset' then
+	v = redis.call('zrange', key, 0, -1, 'withscores')
+elseif t == 'hash' then
+	local kv = redis.call('hgetall', key)
+	local value = {}
+	local fields = {}
+	for i = 1, #kv, 2 do
+		value[kv[i]] = kv[i + 1]
+		fields[#fields + 1] = kv[i]
+	end
+	table.sort(fields)
+	v = {}
+	for _, f in ipairs(fields) do
+		v[#v + 1] = f
+		v[#v + 1] = value[f]
+	end
+else
+	return ''
+end
+for i, e in ipairs(v) do
+	v[i] = string.len(e) .. ':' .. e
+end
+return redis.sha1hex(t .. '|' .. table.concat(v))
+`
+
+var digestScriptSha = func() string {
+	sum := sha1.Sum([]byte(digestScript))
+	return hex.EncodeToString(sum[:])
+}()
+
+/*
+ * The digest of the value computed by the lua script, empty string when the script fails, e.g.,
+ * scripting is disabled. EVALSHA is tried first, and the keys failed, e.g., NOSCRIPT after the script
+ * cache is flushed, are retried by EVAL which loads the script as SCRIPT LOAD does. EVAL also works on
+ * every node of the cluster while SCRIPT LOAD can't be routed by the key.
+ */
+func (p *RedisClient) PipeScriptDigestCommand(keyInfo []*common.Key) ([]string, error) {
+	result, err := p.pipeScriptDigest(keyInfo, "evalsha", digestScriptSha)
+	if err != nil {
+		return nil, err
+	}
+
+	retryIndex := make([]int, 0)
+	retryKeyInfo := make([]*common.Key, 0)
+	for i, digest := range result {
+		if len(digest) == 0 {
+			retryIndex = append(retryIndex, i)
+			retryKeyInfo = append(retryKeyInfo, keyInfo[i])
+		}
+	}
+	if len(retryKeyInfo) == 0 {
+		return result, nil
+	}
+
+	retry, err := p.pipeScriptDigest(retryKeyInfo, "eval", digestScript)
+	if err != nil {
+		return nil, err
+	}
+	for i, digest := range retry {
+		result[retryIndex[i]] = digest
+	}
+	return result, nil
+}
+
+func (p *RedisClient) pipeScriptDigest(keyInfo []*common.Key, command string, script string) ([]string, error) {
+	commands := make([]combine, len(keyInfo))
+	for i, key := range keyInfo {
+		commands[i] = combine{
+			command: command,
+			params:  []interface{}{script, 1, p.Key(key.Key)},
+		}
+	}
+
+	result := make([]string, len(keyInfo))
+	if ret, err := p.PipeRawCommand(commands, ""); err != nil {
+		if err != emptyError {
+			return nil, err
+		}
+	} else {
+		for i, ele := range ret {
+			switch v := ele.(type) {
+			case string:
+				result[i] = v
+			case []byte:
+				result[i] = string(v)
+			}
+		}
+	}
+	return result, nil
+}
@@ -0,0 +1,48 @@
+package client
+
+import (
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/garyburd/redigo/redis"
+)
+
+const sentinelTimeout = 5 * time.Second
+
+// the input address is the sentinel list when the master name is given
+func HandleSentinelAddress(address, masterName string) ([]string, []string, error) {
+	sentinelList := strings.Split(address, AddressClusterSplitter)
+	master, err := ResolveSentinelMaster(sentinelList, masterName)
+	if err != nil {
+		return nil, nil, err
+	}
+	return sentinelList, []string{master}, nil
+}
+
+// query the sentinels in order and return the address of the current master
+func ResolveSentinelMaster(sentinelList []string, masterName string) (string, error) {
+	var lastErr error
+	for _, sentinel := range sentinelList {
+		network, address := ParseNetwork(sentinel)
+		conn, err := redis.DialTimeout(network, address, sentinelTimeout, sentinelTimeout, sentinelTimeout)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		reply, err := redis.Strings(conn.Do("sentinel", "get-master-addr-by-name", masterName))
+		conn.Close()
+		if err != nil {
+			lastErr = fmt.Errorf("sentinel[%v] reply error[%v]", sentinel, err)
+			continue
+		}
+		if len(reply) != 2 {
+			lastErr = fmt.Errorf("sentinel[%v] reply invalid master address[%v]", sentinel, reply)
+			continue
+		}
+		return net.JoinHostPort(reply[0], reply[1]), nil
+	}
+	return "", fmt.Errorf("resolve master[%v] by sentinel%v failed[%v]", masterName, sentinelList, lastErr)
+}
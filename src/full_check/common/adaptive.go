@@ -0,0 +1,50 @@
+package common
+
+import (
+	"time"
+)
+
+const (
+	AdaptiveScanLatency   = 10 * time.Millisecond // the latency of one scan page expected
+	AdaptiveScanReplySize = 1024 * 1024           // the bytes of one scan page expected
+	AdaptiveScanMinCount  = 10
+	AdaptiveScanMaxCount  = 10000
+)
+
+/*
+ * AdaptiveCount tunes the COUNT of the scan page by page: it's halved when the last page is slower
+ * or larger than expected, e.g., the fields are huge, and doubled when the page is far below both,
+ * e.g., the fields are tiny, so the round trips are fewer while the reply stays bounded.
+ */
+type AdaptiveCount struct {
+	count int
+}
+
+func NewAdaptiveCount(base int) *AdaptiveCount {
+	p := &AdaptiveCount{count: base}
+	p.bound()
+	return p
+}
+
+// the COUNT of the next page
+func (p *AdaptiveCount) Count() int {
+	return p.count
+}
+
+// adjust the COUNT by the latency and the reply bytes of the last page
+func (p *AdaptiveCount) Observe(latency time.Duration, size int64) {
+	if latency > AdaptiveScanLatency || size > AdaptiveScanReplySize {
+		p.count /= 2
+	} else if latency < AdaptiveScanLatency/4 && size < AdaptiveScanReplySize/4 {
+		p.count *= 2
+	}
+	p.bound()
+}
+
+func (p *AdaptiveCount) bound() {
+	if p.count < AdaptiveScanMinCount {
+		p.count = AdaptiveScanMinCount
+	} else if p.count > AdaptiveScanMaxCount {
+		p.count = AdaptiveScanMaxCount
+	}
+}
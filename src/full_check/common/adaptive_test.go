@@ -0,0 +1,55 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAdaptiveCount(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestAdaptiveCount case %d.\n", nr)
+
+		assert.Equal(t, 256, NewAdaptiveCount(256).Count(), "should be equal")
+		assert.Equal(t, AdaptiveScanMinCount, NewAdaptiveCount(1).Count(), "should be equal")
+		assert.Equal(t, AdaptiveScanMaxCount, NewAdaptiveCount(100000).Count(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestAdaptiveCount case %d.\n", nr)
+
+		count := NewAdaptiveCount(256)
+		// fast and small page
+		count.Observe(time.Millisecond, 1024)
+		assert.Equal(t, 512, count.Count(), "should be equal")
+		// neither far below nor above
+		count.Observe(5*time.Millisecond, 1024)
+		assert.Equal(t, 512, count.Count(), "should be equal")
+		// slow page
+		count.Observe(20*time.Millisecond, 1024)
+		assert.Equal(t, 256, count.Count(), "should be equal")
+		// large page
+		count.Observe(time.Millisecond, 2*AdaptiveScanReplySize)
+		assert.Equal(t, 128, count.Count(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestAdaptiveCount case %d.\n", nr)
+
+		count := NewAdaptiveCount(256)
+		for i := 0; i < 20; i++ {
+			count.Observe(0, 0)
+		}
+		assert.Equal(t, AdaptiveScanMaxCount, count.Count(), "should be equal")
+		for i := 0; i < 20; i++ {
+			count.Observe(time.Second, 0)
+		}
+		assert.Equal(t, AdaptiveScanMinCount, count.Count(), "should be equal")
+	}
+}
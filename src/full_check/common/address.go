@@ -0,0 +1,38 @@
+package common
+
+import (
+	"net"
+	"strings"
+)
+
+const DefaultPort = "6379"
+
+/*
+ * NormalizeAddress returns the address that can be passed to net.Dial: the default port is appended
+ * when missing and the IPv6 literal is bracketed, e.g., "[2001:db8::1]:6379", "[2001:db8::1]" and
+ * "2001:db8::1" all return "[2001:db8::1]:6379". The IPv6 literal with port must be bracketed.
+ */
+func NormalizeAddress(address string) string {
+	if host, port, err := net.SplitHostPort(address); err == nil {
+		return net.JoinHostPort(host, port)
+	}
+
+	host := address
+	if strings.HasPrefix(host, "[") && strings.HasSuffix(host, "]") {
+		host = host[1 : len(host)-1]
+	}
+	if strings.Contains(host, ":") && net.ParseIP(host) == nil {
+		// invalid address, leave it to net.Dial to report the error
+		return address
+	}
+	return net.JoinHostPort(host, DefaultPort)
+}
+
+// the IPv6 address isn't bracketed in "cluster nodes", e.g., "2001:db8::1:6379"
+func parseClusterNodeAddress(address string) string {
+	if strings.Count(address, ":") <= 1 || strings.HasPrefix(address, "[") {
+		return address
+	}
+	idx := strings.LastIndex(address, ":")
+	return net.JoinHostPort(address[:idx], address[idx+1:])
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNormalizeAddress(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestNormalizeAddress case %d.\n", nr)
+
+		assert.Equal(t, "10.1.1.1:6380", NormalizeAddress("10.1.1.1:6380"), "should be equal")
+		assert.Equal(t, "10.1.1.1:6379", NormalizeAddress("10.1.1.1"), "should be equal")
+		assert.Equal(t, "localhost:6379", NormalizeAddress("localhost"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestNormalizeAddress case %d.\n", nr)
+
+		assert.Equal(t, "[2001:db8::1]:6380", NormalizeAddress("[2001:db8::1]:6380"), "should be equal")
+		assert.Equal(t, "[2001:db8::1]:6379", NormalizeAddress("[2001:db8::1]"), "should be equal")
+		assert.Equal(t, "[2001:db8::1]:6379", NormalizeAddress("2001:db8::1"), "should be equal")
+		assert.Equal(t, "[::1]:6379", NormalizeAddress("::1"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestNormalizeAddress case %d.\n", nr)
+
+		assert.Equal(t, "10.1.1.1:21333", parseClusterNodeAddress("10.1.1.1:21333"), "should be equal")
+		assert.Equal(t, "[2001:db8::1]:21333", parseClusterNodeAddress("2001:db8::1:21333"), "should be equal")
+		assert.Equal(t, "[2001:db8::1]:21333", parseClusterNodeAddress("[2001:db8::1]:21333"), "should be equal")
+
+		nodes := ParseClusterNode([]byte("d49a4c7b516b8da222d46a0a589b77f381285977 2001:db8::1:21333@31333 " +
+			"myself,master - 0 1557996786000 3 connected 10923-16383\n"))
+		assert.Equal(t, 1, len(nodes), "should be equal")
+		assert.Equal(t, "[2001:db8::1]:21333", nodes[0].Address, "should be equal")
+	}
+}
@@ -0,0 +1,52 @@
+package common
+
+import (
+	"fmt"
+	"time"
+)
+
+const (
+	BackoffConstant    = "constant"
+	BackoffExponential = "exponential"
+)
+
+/*
+ * Backoff gives the wait before the Nth retry of the same command, the first retry is 0. The wait is
+ * Interval every time by the constant strategy, and doubled on every retry up to Max by the
+ * exponential one.
+ */
+type Backoff struct {
+	Interval    time.Duration
+	Exponential bool
+	Max         time.Duration // cap of the exponential wait, 0 means no cap
+}
+
+func NewBackoff(strategy string, interval, max time.Duration) (Backoff, error) {
+	switch strategy {
+	case BackoffConstant:
+		return Backoff{Interval: interval}, nil
+	case BackoffExponential:
+		return Backoff{Interval: interval, Exponential: true, Max: max}, nil
+	default:
+		return Backoff{}, fmt.Errorf("unknown backoff strategy[%s], expect %s or %s", strategy, BackoffConstant,
+			BackoffExponential)
+	}
+}
+
+func (p Backoff) Wait(retry int) time.Duration {
+	wait := p.Interval
+	if p.Exponential == false || wait <= 0 {
+		return wait
+	}
+	for i := 0; i < retry; i++ {
+		// stop doubling before overflow
+		if (p.Max > 0 && wait >= p.Max) || wait >= time.Duration(1<<62) {
+			break
+		}
+		wait *= 2
+	}
+	if p.Max > 0 && wait > p.Max {
+		return p.Max
+	}
+	return wait
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBackoff(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestBackoff case %d.\n", nr)
+
+		backoff, err := NewBackoff("constant", 100*time.Millisecond, time.Second)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 100*time.Millisecond, backoff.Wait(0), "should be equal")
+		assert.Equal(t, 100*time.Millisecond, backoff.Wait(10), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestBackoff case %d.\n", nr)
+
+		backoff, err := NewBackoff("exponential", 100*time.Millisecond, time.Second)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 100*time.Millisecond, backoff.Wait(0), "should be equal")
+		assert.Equal(t, 200*time.Millisecond, backoff.Wait(1), "should be equal")
+		assert.Equal(t, 800*time.Millisecond, backoff.Wait(3), "should be equal")
+		// capped by the max
+		assert.Equal(t, time.Second, backoff.Wait(4), "should be equal")
+		assert.Equal(t, time.Second, backoff.Wait(1000), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestBackoff case %d.\n", nr)
+
+		// no cap, and no overflow
+		backoff, _ := NewBackoff("exponential", time.Millisecond, 0)
+		assert.Equal(t, 1024*time.Millisecond, backoff.Wait(10), "should be equal")
+		assert.Equal(t, true, backoff.Wait(1000) > 0, "should be equal")
+
+		_, err := NewBackoff("linear", time.Second, 0)
+		assert.NotEqual(t, nil, err, "should be not equal")
+	}
+}
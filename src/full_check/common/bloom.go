@@ -0,0 +1,92 @@
+package common
+
+import (
+	"hash/fnv"
+	"math"
+	"sync"
+)
+
+/*
+ * BloomFilter remembers all the keys scanned in a bounded memory, unlike DedupWindow which only remembers
+ * the recent ones, so the duplicate returned long after is skipped as well. The key never scanned is
+ * regarded as duplicate by the false positive, so it's skipped without verifying. The false positive
+ * rate grows beyond the given one when more keys than the capacity are added. It's safe for concurrent use.
+ */
+type BloomFilter struct {
+	bits     []uint64
+	size     uint64 // count of the bits
+	hashes   int    // count of the bits set for every key
+	capacity int64  // keys added before the false positive rate exceeds the given one
+	count    int64
+	lock     sync.Mutex
+}
+
+// the filter of the given bytes, falsePositive is in (0, 1)
+func NewBloomFilter(bytes int64, falsePositive float64) *BloomFilter {
+	size := uint64(bytes) * 8
+	if size < 64 {
+		size = 64
+	}
+	hashes := int(math.Ceil(-math.Log2(falsePositive)))
+	if hashes < 1 {
+		hashes = 1
+	}
+	return &BloomFilter{
+		bits:     make([]uint64, size/64),
+		size:     size / 64 * 64,
+		hashes:   hashes,
+		capacity: int64(float64(size) * math.Ln2 * math.Ln2 / -math.Log(falsePositive)),
+	}
+}
+
+// return true when the key may have been added, otherwise add it. The nil filter never regards the key as duplicate
+func (p *BloomFilter) Seen(key []byte) bool {
+	if p == nil {
+		return false
+	}
+	// double hashing by the two halves of fnv-128a, mixed since the high bits of fnv differ little
+	h := fnv.New128a()
+	h.Write(key)
+	sum := h.Sum(nil)
+	var h1, h2 uint64
+	for i := 0; i < 8; i++ {
+		h1 = h1<<8 | uint64(sum[i])
+		h2 = h2<<8 | uint64(sum[i+8])
+	}
+	h1, h2 = mix64(h1), mix64(h2)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	seen := true
+	for i := 0; i < p.hashes; i++ {
+		bit := (h1 + uint64(i)*h2) % p.size
+		if p.bits[bit/64]&(1<<(bit%64)) == 0 {
+			seen = false
+			p.bits[bit/64] |= 1 << (bit % 64)
+		}
+	}
+	if !seen {
+		p.count++
+	}
+	return seen
+}
+
+// the finalizer of murmur3
+func mix64(h uint64) uint64 {
+	h ^= h >> 33
+	h *= 0xff51afd7ed558ccd
+	h ^= h >> 33
+	h *= 0xc4ceb9fe1a85ec53
+	h ^= h >> 33
+	return h
+}
+
+// return true when more keys than the capacity are added
+func (p *BloomFilter) Overflow() bool {
+	if p == nil {
+		return false
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	return p.count > p.capacity
+}
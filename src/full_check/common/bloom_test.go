@@ -0,0 +1,60 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestBloomFilter(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestBloomFilter case %d.\n", nr)
+
+		filter := NewBloomFilter(1024, 0.01)
+		assert.Equal(t, 7, filter.hashes, "should be equal")
+		assert.Equal(t, uint64(8192), filter.size, "should be equal")
+		assert.Equal(t, false, filter.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, true, filter.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, false, filter.Seen([]byte("b")), "should be equal")
+		assert.Equal(t, false, filter.Overflow(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestBloomFilter case %d.\n", nr)
+
+		// the false positive rate is about the given one within the capacity
+		filter := NewBloomFilter(1<<20, 0.01)
+		for i := int64(0); i < filter.capacity; i++ {
+			filter.Seen([]byte("key" + strconv.FormatInt(i, 10)))
+		}
+		assert.Equal(t, false, filter.Overflow(), "should be equal")
+		falsePositive := 0
+		for i := 0; i < 10000; i++ {
+			if filter.Seen([]byte("other" + strconv.Itoa(i))) {
+				falsePositive++
+			}
+		}
+		assert.Equal(t, true, falsePositive < 200, "should be equal")
+
+		filter = NewBloomFilter(8, 0.5)
+		for i := 0; i < 1000; i++ {
+			filter.Seen([]byte("key" + strconv.Itoa(i)))
+		}
+		assert.Equal(t, true, filter.Overflow(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestBloomFilter case %d.\n", nr)
+
+		var filter *BloomFilter
+		assert.Equal(t, false, filter.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, false, filter.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, false, filter.Overflow(), "should be equal")
+	}
+}
@@ -1,14 +1,43 @@
 package common
 
 import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+
 	redigoCluster "github.com/vinllen/redis-go-cluster"
 	redigo "github.com/garyburd/redigo/redis"
 )
 
 const(
 	RecvChanSize = 4096
+	SlotCount    = 16384
 )
 
+/*
+ * The position of the key in the commands whose first argument is the subcommand. The cluster driver
+ * routes every command by the first argument, so these commands are sent to the node owning the key
+ * by the slot map of the cluster itself, e.g., the source and target of different slot layouts.
+ */
+var subcommandKeyIndex = map[string]int{
+	"object":  1,
+	"memory":  1,
+	"debug":   1,
+	"eval":    2, // the script, numkeys and the first key
+	"evalsha": 2,
+}
+
+// the commands without the key sent by the driver even all the others are routed to the replicas
+var driverCommands = map[string]bool{
+	"info":    true,
+	"cluster": true,
+	"script":  true,
+	"config":  true,
+	"client":  true,
+	"ping":    true,
+}
+
 /* implement redigo.Conn(https://github.com/garyburd/redigo)
  * Embed redis-go-cluster(https://github.com/chasex/redis-go-cluster)
  * The reason I create this struct is that redis-go-cluster isn't fulfill redigo.Conn
@@ -18,6 +47,21 @@ type ClusterConn struct {
 	client   *redigoCluster.Cluster
 	recvChan chan reply
 	batcher  *redigoCluster.Batch
+
+	// route the subcommands by the slot map, the driver is used when dial isn't given
+	dial    func(addr string) (redigo.Conn, error)
+	seeds   []string
+	replica bool                   // route all the commands with the key to the replica of the slot
+	slots   []string               // slot -> address of the node serving it, loaded lazily
+	nodes   map[string]redigo.Conn // address -> connection of the subcommands
+	sent    []sentCommand          // the commands sent since the last flush in order
+}
+
+// the command sent to the node directly, or by the driver when node is empty
+type sentCommand struct {
+	node    string
+	command string
+	args    []interface{}
 }
 
 type reply struct {
@@ -25,7 +69,14 @@ type reply struct {
 	err    error
 }
 
-func NewClusterConn(clusterClient *redigoCluster.Cluster, recvChanSize int) redigo.Conn {
+/*
+ * dial connects to one node of the cluster, the subcommands are routed by the slot map fetched from
+ * the seeds(CLUSTER SLOTS) through it. nil means all the commands are routed by the driver. The driver
+ * always sends to the masters, so all the commands with the key are routed to the first replica of the
+ * slot by the slot map when replica is set, and dial should send READONLY on the connection.
+ */
+func NewClusterConn(clusterClient *redigoCluster.Cluster, recvChanSize int, seeds []string,
+	dial func(addr string) (redigo.Conn, error), replica bool) redigo.Conn {
 	if recvChanSize == 0 {
 		recvChanSize = RecvChanSize
 	}
@@ -33,24 +84,189 @@ func NewClusterConn(clusterClient *redigoCluster.Cluster, recvChanSize int) redi
 	return &ClusterConn{
 		client:   clusterClient,
 		recvChan: make(chan reply, recvChanSize),
+		dial:     dial,
+		seeds:    seeds,
+		replica:  replica,
+		nodes:    make(map[string]redigo.Conn),
 	}
 }
 
 func (cc *ClusterConn) Close() error {
 	cc.client.Close()
+	for addr, conn := range cc.nodes {
+		conn.Close()
+		delete(cc.nodes, addr)
+	}
 	return nil
 }
 
+// the node serving the key of the command, empty string when it's routed by the driver
+func (cc *ClusterConn) route(commandName string, args []interface{}) (string, error) {
+	name := strings.ToLower(commandName)
+	index, ok := subcommandKeyIndex[name]
+	if ok == false && cc.replica && driverCommands[name] == false {
+		index, ok = 0, true
+	}
+	if ok == false || cc.dial == nil || len(args) <= index {
+		return "", nil
+	}
+	if cc.slots == nil {
+		if err := cc.loadSlots(); err != nil {
+			return "", err
+		}
+	}
+	var key []byte
+	switch v := args[index].(type) {
+	case []byte:
+		key = v
+	case string:
+		key = []byte(v)
+	default:
+		return "", fmt.Errorf("unknown key type[%T] of command[%v]", args[index], commandName)
+	}
+	addr := cc.slots[KeySlot(key)]
+	if len(addr) == 0 {
+		return "", fmt.Errorf("slot[%v] of key isn't served by any node", KeySlot(key))
+	}
+	return addr, nil
+}
+
+func (cc *ClusterConn) node(addr string) (redigo.Conn, error) {
+	if conn, ok := cc.nodes[addr]; ok {
+		return conn, nil
+	}
+	conn, err := cc.dial(addr)
+	if err != nil {
+		return nil, err
+	}
+	cc.nodes[addr] = conn
+	return conn, nil
+}
+
+// fetch the slot map by CLUSTER SLOTS from the first seed available
+func (cc *ClusterConn) loadSlots() error {
+	var err error
+	for _, seed := range cc.seeds {
+		var conn redigo.Conn
+		if conn, err = cc.node(seed); err != nil {
+			continue
+		}
+		var reply interface{}
+		if reply, err = conn.Do("cluster", "slots"); err != nil {
+			continue
+		}
+		var slots []string
+		if slots, err = parseClusterSlots(reply, cc.replica); err == nil {
+			cc.slots = slots
+			return nil
+		}
+	}
+	return fmt.Errorf("fetch cluster slots from %v failed[%v]", cc.seeds, err)
+}
+
+/*
+ * Parse the reply of CLUSTER SLOTS into the address of the master owning every slot, e.g.,
+ * [[0, 5460, ["10.1.1.1", 6379, "id"], ["10.1.1.2", 6379, "id"]], ...], the replicas are ignored.
+ */
+func ParseClusterSlots(reply interface{}) ([]string, error) {
+	return parseClusterSlots(reply, false)
+}
+
+// the same as ParseClusterSlots, but the address of the first replica, the master when there is none
+func ParseClusterReplicaSlots(reply interface{}) ([]string, error) {
+	return parseClusterSlots(reply, true)
+}
+
+func parseClusterSlots(reply interface{}, replica bool) ([]string, error) {
+	ranges, ok := reply.([]interface{})
+	if ok == false {
+		return nil, fmt.Errorf("invalid cluster slots[%v]", reply)
+	}
+	slots := make([]string, SlotCount)
+	for _, item := range ranges {
+		fields, ok := item.([]interface{})
+		if ok == false || len(fields) < 3 {
+			return nil, fmt.Errorf("invalid slot range[%v]", item)
+		}
+		start, ok1 := fields[0].(int64)
+		end, ok2 := fields[1].(int64)
+		node, ok3 := fields[2].([]interface{})
+		if replica && len(fields) > 3 {
+			node, ok3 = fields[3].([]interface{})
+		}
+		if !ok1 || !ok2 || !ok3 || len(node) < 2 || start < 0 || end >= SlotCount || start > end {
+			return nil, fmt.Errorf("invalid slot range[%v]", item)
+		}
+		host, ok1 := node[0].([]byte)
+		port, ok2 := node[1].(int64)
+		if !ok1 || !ok2 {
+			return nil, fmt.Errorf("invalid slot range[%v]", item)
+		}
+		addr := NormalizeAddress(string(host) + ":" + strconv.FormatInt(port, 10))
+		for slot := start; slot <= end; slot++ {
+			slots[slot] = addr
+		}
+	}
+	return slots, nil
+}
+
+// send the command again to the node given by the MOVED or ASK error, the slot map is reloaded next time
+func (cc *ClusterConn) redirect(command sentCommand, err error) (interface{}, error) {
+	fields := strings.Fields(err.Error())
+	if len(fields) != 3 || (fields[0] != "MOVED" && fields[0] != "ASK") {
+		return nil, err
+	}
+	cc.slots = nil
+	conn, dialErr := cc.node(NormalizeAddress(fields[2]))
+	if dialErr != nil {
+		return nil, dialErr
+	}
+	if fields[0] == "ASK" {
+		if _, err := conn.Do("asking"); err != nil {
+			return nil, err
+		}
+	}
+	return conn.Do(command.command, command.args...)
+}
+
 func (cc *ClusterConn) Err() error {
 	return nil
 }
 
 func (cc *ClusterConn) Do(commandName string, args ...interface{}) (reply interface{}, err error) {
-	return cc.client.Do(commandName, args...)
+	addr, err := cc.route(commandName, args)
+	if err != nil {
+		return nil, err
+	} else if len(addr) == 0 {
+		return cc.client.Do(commandName, args...)
+	}
+	conn, err := cc.node(addr)
+	if err != nil {
+		return nil, err
+	}
+	command := sentCommand{node: addr, command: commandName, args: args}
+	if reply, err = conn.Do(commandName, args...); err != nil {
+		if _, ok := err.(redigo.Error); ok {
+			return cc.redirect(command, err)
+		}
+	}
+	return reply, err
 }
 
-// just add into batcher
+// just add into batcher, or send to the node owning the key of the subcommand
 func (cc *ClusterConn) Send(commandName string, args ...interface{}) error {
+	addr, err := cc.route(commandName, args)
+	if err != nil {
+		return err
+	}
+	cc.sent = append(cc.sent, sentCommand{node: addr, command: commandName, args: args})
+	if len(addr) != 0 {
+		conn, err := cc.node(addr)
+		if err != nil {
+			return err
+		}
+		return conn.Send(commandName, args...)
+	}
 	if cc.batcher == nil {
 		cc.batcher = cc.client.NewBatch()
 	}
@@ -59,11 +275,17 @@ func (cc *ClusterConn) Send(commandName string, args ...interface{}) error {
 
 // send batcher and put the return into recvChan
 func (cc *ClusterConn) Flush() error {
-	ret, err := cc.client.RunBatch(cc.batcher)
+	sent := cc.sent
 	defer func() {
 		cc.batcher = nil // reset batcher
+		cc.sent = nil
 	}()
 
+	var ret []interface{}
+	var err error
+	if cc.batcher != nil {
+		ret, err = cc.client.RunBatch(cc.batcher)
+	}
 	if err != nil {
 		cc.recvChan <- reply{
 			answer: nil,
@@ -73,6 +295,12 @@ func (cc *ClusterConn) Flush() error {
 		return err
 	}
 
+	for _, command := range sent {
+		if len(command.node) != 0 {
+			return cc.flushNodes(sent, ret)
+		}
+	}
+
 	// for redis-go-cluster driver, "Receive" function returns all the replies once flushed.
 	// However, this action is different with redigo driver that "Receive" only returns 1
 	// reply each time.
@@ -94,6 +322,87 @@ func (cc *ClusterConn) Flush() error {
 	return err
 }
 
+// merge the replies of the subcommands sent to the nodes directly into the replies of the driver in order
+func (cc *ClusterConn) flushNodes(sent []sentCommand, batchReplies []interface{}) error {
+	fail := func(err error) error {
+		cc.recvChan <- reply{
+			answer: nil,
+			err:    err,
+		}
+		return err
+	}
+
+	for _, command := range sent {
+		if len(command.node) != 0 {
+			if err := cc.nodes[command.node].Flush(); err != nil {
+				return fail(err)
+			}
+		}
+	}
+
+	replies := make([]reply, len(sent))
+	redirects := make([]int, 0)
+	index := 0
+	for i, command := range sent {
+		if len(command.node) == 0 {
+			if index >= len(batchReplies) {
+				return fail(fmt.Errorf("cluster batch returns %d replies less than expected", len(batchReplies)))
+			}
+			replies[i].answer = batchReplies[index]
+			index++
+			continue
+		}
+		answer, err := cc.nodes[command.node].Receive()
+		if _, ok := err.(redigo.Error); err != nil && ok == false {
+			return fail(err)
+		} else if ok && (strings.HasPrefix(err.Error(), "MOVED ") || strings.HasPrefix(err.Error(), "ASK ")) {
+			redirects = append(redirects, i)
+		}
+		replies[i] = reply{answer: answer, err: err}
+	}
+	// all the replies have been read out, the connections can be used again
+	for _, i := range redirects {
+		answer, err := cc.redirect(sent[i], replies[i].err)
+		if _, ok := err.(redigo.Error); err != nil && ok == false {
+			return fail(err)
+		}
+		replies[i] = reply{answer: answer, err: err}
+	}
+
+	if availableSize := cap(cc.recvChan) - len(cc.recvChan); availableSize < len(replies) {
+		Logger.Warnf("available channel size[%v] less than current returned batch size[%v]", availableSize,
+			len(replies))
+	}
+	for _, ele := range replies {
+		cc.recvChan <- ele
+	}
+	return nil
+}
+
+/*
+ * The hash slot of the key, CRC16(XMODEM) of the key modulo 16384. Only the hash tag between the
+ * first "{" and the following "}" is hashed when it isn't empty, e.g., "{user1}.name".
+ */
+func KeySlot(key []byte) int {
+	if start := bytes.IndexByte(key, '{'); start >= 0 {
+		if end := bytes.IndexByte(key[start+1:], '}'); end > 0 {
+			key = key[start+1 : start+1+end]
+		}
+	}
+	var crc uint16
+	for _, b := range key {
+		crc ^= uint16(b) << 8
+		for i := 0; i < 8; i++ {
+			if crc&0x8000 != 0 {
+				crc = crc<<1 ^ 0x1021
+			} else {
+				crc <<= 1
+			}
+		}
+	}
+	return int(crc) % SlotCount
+}
+
 // read recvChan
 func (cc *ClusterConn) Receive() (reply interface{}, err error) {
 	ret := <- cc.recvChan
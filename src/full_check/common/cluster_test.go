@@ -0,0 +1,205 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	redigo "github.com/garyburd/redigo/redis"
+	"github.com/stretchr/testify/assert"
+)
+
+// the node connection recording the commands, the reply of every command is given by do
+type fakeNodeConn struct {
+	commands []string
+	pending  []string
+	do       func(command string) (interface{}, error)
+}
+
+func (c *fakeNodeConn) Close() error { return nil }
+func (c *fakeNodeConn) Err() error   { return nil }
+func (c *fakeNodeConn) Flush() error { return nil }
+
+func (c *fakeNodeConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	command := strings.TrimSpace(fmt.Sprintln(append([]interface{}{commandName}, args...)...))
+	c.commands = append(c.commands, command)
+	return c.do(command)
+}
+
+func (c *fakeNodeConn) Send(commandName string, args ...interface{}) error {
+	command := strings.TrimSpace(fmt.Sprintln(append([]interface{}{commandName}, args...)...))
+	c.commands = append(c.commands, command)
+	c.pending = append(c.pending, command)
+	return nil
+}
+
+func (c *fakeNodeConn) Receive() (interface{}, error) {
+	command := c.pending[0]
+	c.pending = c.pending[1:]
+	return c.do(command)
+}
+
+func TestKeySlot(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestKeySlot case %d.\n", nr)
+
+		assert.Equal(t, 12739, KeySlot([]byte("123456789")), "should be equal")
+		assert.Equal(t, 12182, KeySlot([]byte("foo")), "should be equal")
+		assert.Equal(t, 5061, KeySlot([]byte("bar")), "should be equal")
+		assert.Equal(t, 0, KeySlot([]byte("")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestKeySlot case %d.\n", nr)
+
+		// only the hash tag is hashed
+		assert.Equal(t, KeySlot([]byte("user1000")), KeySlot([]byte("{user1000}.following")), "should be equal")
+		assert.Equal(t, KeySlot([]byte("user1000")), KeySlot([]byte("{user1000}.followers")), "should be equal")
+		assert.Equal(t, KeySlot([]byte("bar")), KeySlot([]byte("foo{bar}{zap}")), "should be equal")
+		assert.Equal(t, KeySlot([]byte("{bar")), KeySlot([]byte("foo{{bar}}zap")), "should be equal")
+
+		// the empty hash tag is ignored
+		assert.NotEqual(t, KeySlot([]byte("bar")), KeySlot([]byte("foo{}{bar}")), "should be equal")
+		assert.NotEqual(t, KeySlot([]byte("")), KeySlot([]byte("foo{}{bar}")), "should be equal")
+	}
+}
+
+func TestParseClusterSlots(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestParseClusterSlots case %d.\n", nr)
+
+		reply := []interface{}{
+			[]interface{}{int64(0), int64(5460),
+				[]interface{}{[]byte("10.1.1.1"), int64(6379), []byte("id1")},
+				[]interface{}{[]byte("10.1.1.4"), int64(6379), []byte("id4")}},
+			[]interface{}{int64(5461), int64(16383),
+				[]interface{}{[]byte("10.1.1.2"), int64(6380), []byte("id2")}},
+		}
+		slots, err := ParseClusterSlots(reply)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, SlotCount, len(slots), "should be equal")
+		assert.Equal(t, "10.1.1.1:6379", slots[0], "should be equal")
+		assert.Equal(t, "10.1.1.1:6379", slots[5460], "should be equal")
+		assert.Equal(t, "10.1.1.2:6380", slots[5461], "should be equal")
+		assert.Equal(t, "10.1.1.2:6380", slots[16383], "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseClusterSlots case %d.\n", nr)
+
+		// the slots not served are empty
+		reply := []interface{}{
+			[]interface{}{int64(100), int64(200), []interface{}{[]byte("10.1.1.1"), int64(6379)}},
+		}
+		slots, err := ParseClusterSlots(reply)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, "", slots[99], "should be equal")
+		assert.Equal(t, "10.1.1.1:6379", slots[150], "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseClusterSlots case %d.\n", nr)
+
+		_, err := ParseClusterSlots([]interface{}{[]interface{}{int64(0), int64(16384),
+			[]interface{}{[]byte("10.1.1.1"), int64(6379)}}})
+		assert.NotEqual(t, nil, err, "should be equal")
+		_, err = ParseClusterSlots([]interface{}{[]interface{}{int64(0)}})
+		assert.NotEqual(t, nil, err, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseClusterSlots case %d.\n", nr)
+
+		// the first replica, or the master of the slots without the replica
+		reply := []interface{}{
+			[]interface{}{int64(0), int64(5460),
+				[]interface{}{[]byte("10.1.1.1"), int64(6379), []byte("id1")},
+				[]interface{}{[]byte("10.1.1.4"), int64(6379), []byte("id4")},
+				[]interface{}{[]byte("10.1.1.5"), int64(6379), []byte("id5")}},
+			[]interface{}{int64(5461), int64(16383),
+				[]interface{}{[]byte("10.1.1.2"), int64(6380), []byte("id2")}},
+		}
+		slots, err := ParseClusterReplicaSlots(reply)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, "10.1.1.4:6379", slots[0], "should be equal")
+		assert.Equal(t, "10.1.1.4:6379", slots[5460], "should be equal")
+		assert.Equal(t, "10.1.1.2:6380", slots[5461], "should be equal")
+
+		_, err = ParseClusterReplicaSlots([]interface{}{[]interface{}{int64(0), int64(100),
+			[]interface{}{[]byte("10.1.1.1"), int64(6379)}, []interface{}{[]byte("10.1.1.4")}}})
+		assert.NotEqual(t, nil, err, "should be equal")
+	}
+}
+
+func TestClusterConnReplica(t *testing.T) {
+	slotsReply := []interface{}{
+		[]interface{}{int64(0), int64(16383),
+			[]interface{}{[]byte("10.1.1.1"), int64(6379), []byte("id1")},
+			[]interface{}{[]byte("10.1.1.4"), int64(6379), []byte("id4")}},
+	}
+	newConn := func(replica bool) (*ClusterConn, map[string]*fakeNodeConn) {
+		nodes := make(map[string]*fakeNodeConn)
+		dial := func(addr string) (redigo.Conn, error) {
+			conn := &fakeNodeConn{do: func(command string) (interface{}, error) {
+				if command == "cluster slots" {
+					return slotsReply, nil
+				}
+				return []byte(addr), nil
+			}}
+			nodes[addr] = conn
+			return conn, nil
+		}
+		return NewClusterConn(nil, 0, []string{"10.1.1.1:6379"}, dial, replica).(*ClusterConn), nodes
+	}
+
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestClusterConnReplica case %d.\n", nr)
+
+		// the reads are served by the replica
+		cc, nodes := newConn(true)
+		reply, err := cc.Do("get", "foo")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []byte("10.1.1.4:6379"), reply, "should be equal")
+		assert.Equal(t, []string{"cluster slots"}, nodes["10.1.1.1:6379"].commands, "should be equal")
+
+		assert.Equal(t, nil, cc.Send("type", "foo"), "should be equal")
+		assert.Equal(t, nil, cc.Send("object", "idletime", "bar"), "should be equal")
+		assert.Equal(t, nil, cc.Flush(), "should be equal")
+		for i := 0; i < 2; i++ {
+			reply, err = cc.Receive()
+			assert.Equal(t, nil, err, "should be equal")
+			assert.Equal(t, []byte("10.1.1.4:6379"), reply, "should be equal")
+		}
+		assert.Equal(t, []string{"get foo", "type foo", "object idletime bar"}, nodes["10.1.1.4:6379"].commands,
+			"should be equal")
+
+		// the commands without the key are still sent by the driver
+		addr, err := cc.route("info", []interface{}{"Keyspace"})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, "", addr, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestClusterConnReplica case %d.\n", nr)
+
+		// only the subcommands are routed by the slot map to the master
+		cc, _ := newConn(false)
+		addr, err := cc.route("get", []interface{}{"foo"})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, "", addr, "should be equal")
+		addr, err = cc.route("memory", []interface{}{"usage", "foo"})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, "10.1.1.1:6379", addr, "should be equal")
+	}
+}
@@ -4,8 +4,6 @@ import (
 	"bytes"
 	"fmt"
 	"strconv"
-
-	redigo "github.com/garyburd/redigo/redis"
 )
 
 type ClusterNodeInfo struct {
@@ -83,7 +81,7 @@ func ParseClusterNode(content []byte) []*ClusterNodeInfo {
 		}
 		ret = append(ret, &ClusterNodeInfo{
 			Id:          string(items[0]),
-			Address:     string(address[0]),
+			Address:     parseClusterNodeAddress(string(address[0])),
 			Flags:       role,
 			Master:      string(items[3]),
 			PingSent:    string(items[4]),
@@ -109,8 +107,13 @@ func ClusterNodeChoose(input []*ClusterNodeInfo, role string) []*ClusterNodeInfo
 	return ret
 }
 
+// the client that can run a single command, e.g., redigo.Conn
+type CommandRunner interface {
+	Do(commandName string, args ...interface{}) (interface{}, error)
+}
+
 // return id list if  choose == "id", otherwise address
-func GetAllClusterNode(client redigo.Conn, role string, choose string) ([]string, error) {
+func GetAllClusterNode(client CommandRunner, role string, choose string) ([]string, error) {
 	ret, err := client.Do("cluster", "nodes")
 	if err != nil {
 		return nil, err
@@ -154,4 +157,32 @@ func CompareUnorderedList(a, b []string) bool {
 	}
 
 	return true
-}
\ No newline at end of file
+}
+// the next cursor and the elements of the scan/hscan/sscan/zscan reply
+func ParseScanReply(reply interface{}) (int, [][]byte, error) {
+	replyList, ok := reply.([]interface{})
+	if ok == false || len(replyList) != 2 {
+		return 0, nil, fmt.Errorf("invalid reply")
+	}
+	cursorBytes, ok := replyList[0].([]byte)
+	if ok == false {
+		return 0, nil, fmt.Errorf("invalid cursor")
+	}
+	cursor, err := strconv.Atoi(string(cursorBytes))
+	if err != nil {
+		return 0, nil, fmt.Errorf("invalid cursor[%v]", err)
+	}
+	elementList, ok := replyList[1].([]interface{})
+	if ok == false {
+		return 0, nil, fmt.Errorf("invalid element list")
+	}
+	elements := make([][]byte, 0, len(elementList))
+	for _, element := range elementList {
+		if bytes, ok := element.([]byte); ok {
+			elements = append(elements, bytes)
+		} else {
+			return 0, nil, fmt.Errorf("invalid element")
+		}
+	}
+	return cursor, elements, nil
+}
@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseScanReply(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestParseScanReply case %d.\n", nr)
+
+		cursor, elements, err := ParseScanReply([]interface{}{[]byte("17"), []interface{}{[]byte("a"), []byte("b")}})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 17, cursor, "should be equal")
+		assert.Equal(t, [][]byte{[]byte("a"), []byte("b")}, elements, "should be equal")
+
+		cursor, elements, err = ParseScanReply([]interface{}{[]byte("0"), []interface{}{}})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 0, cursor, "should be equal")
+		assert.Equal(t, 0, len(elements), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseScanReply case %d.\n", nr)
+
+		// malformed replies, e.g., returned by the proxy or module
+		malformed := []interface{}{
+			nil,
+			[]byte("OK"),
+			[]interface{}{[]byte("0")},
+			[]interface{}{int64(0), []interface{}{}},
+			[]interface{}{[]byte("x"), []interface{}{}},
+			[]interface{}{[]byte("0"), []byte("a")},
+			[]interface{}{[]byte("0"), []interface{}{[]byte("a"), int64(1)}},
+			[]interface{}{[]byte("0"), []interface{}{}, []interface{}{}},
+			[]interface{}{nil, []interface{}{}},
+			[]interface{}{[]byte("0"), nil},
+			[]interface{}{[]byte("0"), []interface{}{nil}},
+			[]interface{}{[]byte(""), []interface{}{}},
+		}
+		for _, reply := range malformed {
+			_, _, err := ParseScanReply(reply)
+			assert.NotEqual(t, nil, err, "should be equal")
+		}
+	}
+}
@@ -7,6 +7,7 @@ import (
 
 const (
 	MaxRetryCount     = 20 // client attribute
+	MaxBusyRetryCount = 60 // client attribute, retry times when the server is loading or busy
 	StatRollFrequency = 2  // client attribute
 
 	TypeChanged int64 = -1 // marks the given key type is change, e.g. from string to list
@@ -0,0 +1,157 @@
+package common
+
+import (
+	"bytes"
+	"reflect"
+	"sort"
+	"strconv"
+	"sync"
+)
+
+/*
+ * ConflictDetail tells how the value differs. ConflictType is the conflict of the whole key, ValueConflict
+ * is used if it's left unset. Fields are the differing fields of hash, set and zset or the differing
+ * index of list.
+ */
+type ConflictDetail struct {
+	ConflictType ConflictType
+	Fields       []Field
+}
+
+/*
+ * Comparator compares the whole value of one key fetched from both sides, return true if equal.
+ * The value is []byte for string, [][]byte for list, map[string][]byte for hash(field->value),
+ * zset(member->score) and set(member->nil), and the raw reply of the value command for module.
+ * The value shouldn't be modified.
+ */
+type Comparator interface {
+	Compare(source, target interface{}) (bool, ConflictDetail)
+}
+
+// ComparatorFunc adapts the function to Comparator
+type ComparatorFunc func(source, target interface{}) (bool, ConflictDetail)
+
+func (f ComparatorFunc) Compare(source, target interface{}) (bool, ConflictDetail) {
+	return f(source, target)
+}
+
+// the comparator used for the keys of the type whose name matches pattern
+type comparatorRule struct {
+	pattern    string
+	comparator Comparator
+}
+
+var (
+	comparators    = make(map[KeyTypeIndex][]comparatorRule)
+	comparatorLock sync.RWMutex
+)
+
+func init() {
+	RegisterComparator(StringKeyType, "*", ComparatorFunc(CompareStringValue))
+	RegisterComparator(ListKeyType, "*", ComparatorFunc(CompareListValue))
+	RegisterComparator(HashKeyType, "*", ComparatorFunc(CompareMapValue))
+	RegisterComparator(SetKeyType, "*", ComparatorFunc(CompareMapValue))
+	RegisterComparator(ZsetKeyType, "*", ComparatorFunc(CompareMapValue))
+	RegisterComparator(ModuleKeyType, "*", ComparatorFunc(CompareReplyValue))
+}
+
+/*
+ * RegisterComparator compares the value of the keys of keyType whose name matches pattern by the
+ * comparator, e.g., a tolerance-based or semantic one. The rule registered later is tried first, so it
+ * overrides the built-in one registered by default with "*". It should be called before the check starts.
+ */
+func RegisterComparator(keyType *KeyType, pattern string, comparator Comparator) {
+	comparatorLock.Lock()
+	defer comparatorLock.Unlock()
+	rules := comparators[keyType.Index]
+	comparators[keyType.Index] = append([]comparatorRule{{pattern: pattern, comparator: comparator}}, rules...)
+}
+
+// MatchComparator returns the comparator of the key, nil if no one is registered for the type
+func MatchComparator(keyType *KeyType, key []byte) Comparator {
+	comparatorLock.RLock()
+	defer comparatorLock.RUnlock()
+	for _, rule := range comparators[keyType.Index] {
+		if StringMatch([]byte(rule.pattern), key) {
+			return rule.comparator
+		}
+	}
+	return nil
+}
+
+// the built-in comparator of string, the empty value is regarded as missing
+func CompareStringValue(source, target interface{}) (bool, ConflictDetail) {
+	sourceValue, _ := source.([]byte)
+	targetValue, _ := target.([]byte)
+	switch {
+	case len(sourceValue) == 0 && len(targetValue) == 0:
+		return true, ConflictDetail{}
+	case len(sourceValue) == 0:
+		return false, ConflictDetail{ConflictType: LackSourceConflict}
+	case len(targetValue) == 0:
+		return false, ConflictDetail{ConflictType: LackTargetConflict}
+	case bytes.Equal(sourceValue, targetValue) == false:
+		return false, ConflictDetail{ConflictType: ValueConflict}
+	}
+	return true, ConflictDetail{}
+}
+
+// the built-in comparator of list, only the first differing index is given
+func CompareListValue(source, target interface{}) (bool, ConflictDetail) {
+	sourceValue, _ := source.([][]byte)
+	targetValue, _ := target.([][]byte)
+	minLen := Min(len(sourceValue), len(targetValue))
+	for i := 0; i < minLen; i++ {
+		if bytes.Equal(sourceValue[i], targetValue[i]) == false {
+			return false, ConflictDetail{ConflictType: ValueConflict, Fields: []Field{{
+				Field:        []byte(strconv.FormatInt(int64(i), 10)),
+				ConflictType: ValueConflict}}}
+		}
+	}
+	// one list ends earlier, the first missing index differs
+	if len(sourceValue) == len(targetValue) {
+		return true, ConflictDetail{}
+	}
+	conflictType := LackTargetConflict
+	if len(sourceValue) < len(targetValue) {
+		conflictType = LackSourceConflict
+	}
+	return false, ConflictDetail{ConflictType: ValueConflict, Fields: []Field{{
+		Field:        []byte(strconv.FormatInt(int64(minLen), 10)),
+		ConflictType: conflictType}}}
+}
+
+// the built-in comparator of hash, set and zset, the differing fields are sorted
+func CompareMapValue(source, target interface{}) (bool, ConflictDetail) {
+	sourceValue, _ := source.(map[string][]byte)
+	targetValue, _ := target.(map[string][]byte)
+	fields := make([]Field, 0)
+	for k, v := range sourceValue {
+		vTarget, ok := targetValue[k]
+		if ok == false {
+			fields = append(fields, Field{Field: []byte(k), ConflictType: LackTargetConflict})
+		} else if bytes.Equal(v, vTarget) == false {
+			fields = append(fields, Field{Field: []byte(k), ConflictType: ValueConflict})
+		}
+	}
+	for k := range targetValue {
+		if _, ok := sourceValue[k]; ok == false {
+			fields = append(fields, Field{Field: []byte(k), ConflictType: LackSourceConflict})
+		}
+	}
+	if len(fields) == 0 {
+		return true, ConflictDetail{}
+	}
+	sort.Slice(fields, func(i, j int) bool {
+		return bytes.Compare(fields[i].Field, fields[j].Field) < 0
+	})
+	return false, ConflictDetail{ConflictType: ValueConflict, Fields: fields}
+}
+
+// the built-in comparator of module, the replies of the value command are compared as they are
+func CompareReplyValue(source, target interface{}) (bool, ConflictDetail) {
+	if reflect.DeepEqual(source, target) {
+		return true, ConflictDetail{}
+	}
+	return false, ConflictDetail{ConflictType: ValueConflict}
+}
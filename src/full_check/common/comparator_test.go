@@ -0,0 +1,75 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestComparator(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestComparator case %d.\n", nr)
+
+		equal, detail := CompareStringValue([]byte("a"), []byte("a"))
+		assert.Equal(t, true, equal, "should be equal")
+		equal, detail = CompareStringValue([]byte("a"), []byte("b"))
+		assert.Equal(t, false, equal, "should be equal")
+		assert.Equal(t, ValueConflict, detail.ConflictType, "should be equal")
+		equal, detail = CompareStringValue([]byte(nil), []byte("b"))
+		assert.Equal(t, LackSourceConflict, detail.ConflictType, "should be equal")
+		equal, detail = CompareStringValue([]byte("a"), []byte(nil))
+		assert.Equal(t, LackTargetConflict, detail.ConflictType, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestComparator case %d.\n", nr)
+
+		source := [][]byte{[]byte("a"), []byte("b"), []byte("c")}
+		equal, _ := CompareListValue(source, [][]byte{[]byte("a"), []byte("b"), []byte("c")})
+		assert.Equal(t, true, equal, "should be equal")
+		equal, detail := CompareListValue(source, [][]byte{[]byte("a"), []byte("x"), []byte("c")})
+		assert.Equal(t, false, equal, "should be equal")
+		assert.Equal(t, []Field{{Field: []byte("1"), ConflictType: ValueConflict}}, detail.Fields, "should be equal")
+		equal, detail = CompareListValue(source, [][]byte{[]byte("a")})
+		assert.Equal(t, []Field{{Field: []byte("1"), ConflictType: LackTargetConflict}}, detail.Fields, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestComparator case %d.\n", nr)
+
+		source := map[string][]byte{"a": []byte("1"), "b": []byte("2"), "c": []byte("3")}
+		target := map[string][]byte{"a": []byte("1"), "b": []byte("x"), "d": []byte("4")}
+		equal, detail := CompareMapValue(source, target)
+		assert.Equal(t, false, equal, "should be equal")
+		assert.Equal(t, []Field{
+			{Field: []byte("b"), ConflictType: ValueConflict},
+			{Field: []byte("c"), ConflictType: LackTargetConflict},
+			{Field: []byte("d"), ConflictType: LackSourceConflict},
+		}, detail.Fields, "should be equal")
+		// the input isn't modified
+		assert.Equal(t, 3, len(target), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestComparator case %d.\n", nr)
+
+		builtin := MatchComparator(StringKeyType, []byte("json:1"))
+		equal, _ := builtin.Compare([]byte("a"), []byte("A"))
+		assert.Equal(t, false, equal, "should be equal")
+
+		RegisterComparator(StringKeyType, "json:*", ComparatorFunc(func(source, target interface{}) (bool, ConflictDetail) {
+			return len(source.([]byte)) == len(target.([]byte)), ConflictDetail{}
+		}))
+		equal, _ = MatchComparator(StringKeyType, []byte("json:1")).Compare([]byte("a"), []byte("A"))
+		assert.Equal(t, true, equal, "should be equal")
+		equal, _ = MatchComparator(StringKeyType, []byte("other")).Compare([]byte("a"), []byte("A"))
+		assert.Equal(t, false, equal, "should be equal")
+		assert.Equal(t, nil, MatchComparator(StreamKeyType, []byte("json:1")), "should be equal")
+	}
+}
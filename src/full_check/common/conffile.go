@@ -0,0 +1,206 @@
+package common
+
+import (
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	ConfigYaml = "yaml"
+	ConfigToml = "toml"
+)
+
+// one option of the config file, the option given by the list is returned once per element
+type ConfigOption struct {
+	Name  string
+	Value string
+}
+
+// the format of the config file by the extension, empty for the INI file and the others
+func ConfigFormat(file string) string {
+	switch strings.ToLower(filepath.Ext(file)) {
+	case ".yaml", ".yml":
+		return ConfigYaml
+	case ".toml":
+		return ConfigToml
+	}
+	return ""
+}
+
+/*
+ * ParseConfigFile parses the YAML or TOML config file into the options. Only the flat key-value pairs
+ * are supported: the key is the long option name, and the value is the scalar or the list giving the
+ * option more than once, e.g., `keyhashtag: [a, b]` in YAML or `keyhashtag = ["a", "b"]` in TOML. The
+ * nested mappings, the tables and the multi-line strings aren't.
+ */
+func ParseConfigFile(format string, data []byte) ([]ConfigOption, error) {
+	separator := ":"
+	if format == ConfigToml {
+		separator = "="
+	} else if format != ConfigYaml {
+		return nil, fmt.Errorf("unknown config format[%v]", format)
+	}
+
+	lines := strings.Split(string(data), "\n")
+	ret := make([]ConfigOption, 0)
+	blockList := "" // the key whose block list "- value" of YAML is being read
+	for i := 0; i < len(lines); i++ {
+		lineno := i + 1
+		line := strings.TrimSpace(lines[i])
+		if len(line) == 0 || line[0] == '#' || (format == ConfigYaml && line == "---") {
+			continue
+		}
+
+		if format == ConfigYaml && (line == "-" || strings.HasPrefix(line, "- ")) {
+			if len(blockList) == 0 {
+				return nil, fmt.Errorf("line %d: list element[%v] without the key", lineno, line)
+			}
+			value, err := readConfigScalar(strings.TrimSpace(line[1:]))
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineno, err)
+			}
+			ret = append(ret, ConfigOption{Name: blockList, Value: value})
+			continue
+		}
+		blockList = ""
+
+		if format == ConfigToml && line[0] == '[' {
+			return nil, fmt.Errorf("line %d: table %v isn't supported, give the options at the top level",
+				lineno, line)
+		}
+		if format == ConfigYaml && lines[i][0] != line[0] {
+			return nil, fmt.Errorf("line %d: nested mapping[%v] isn't supported", lineno, line)
+		}
+		index := strings.Index(line, separator)
+		if index <= 0 {
+			return nil, fmt.Errorf("line %d: invalid line[%v], expect KEY%s VALUE", lineno, line, separator)
+		}
+		key, value := strings.TrimSpace(line[:index]), strings.TrimSpace(line[index+1:])
+		if len(value) == 0 || value[0] == '#' {
+			if format == ConfigToml {
+				return nil, fmt.Errorf("line %d: value of %v is empty", lineno, key)
+			}
+			blockList = key
+			continue
+		}
+
+		if value[0] != '[' {
+			scalar, err := readConfigScalar(value)
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", lineno, err)
+			}
+			ret = append(ret, ConfigOption{Name: key, Value: scalar})
+			continue
+		}
+		// the list may span the lines
+		next := func() (string, bool) {
+			if i+1 >= len(lines) {
+				return "", false
+			}
+			i++
+			return lines[i], true
+		}
+		values, err := readConfigList(value[1:], next)
+		if err != nil {
+			return nil, fmt.Errorf("line %d: %v of %v", lineno, err, key)
+		}
+		for _, element := range values {
+			ret = append(ret, ConfigOption{Name: key, Value: element})
+		}
+	}
+	return ret, nil
+}
+
+// read the whole scalar, only the comment can follow it
+func readConfigScalar(s string) (string, error) {
+	value, rest, err := readConfigValue(s, "")
+	if err != nil {
+		return "", err
+	}
+	if rest = strings.TrimSpace(rest); len(rest) != 0 && rest[0] != '#' {
+		return "", fmt.Errorf("unexpected[%v] after the value", rest)
+	}
+	return value, nil
+}
+
+// read the elements of the list after '[', more lines are read by next until ']'
+func readConfigList(s string, next func() (string, bool)) ([]string, error) {
+	values := make([]string, 0)
+	for {
+		s = strings.TrimSpace(s)
+		if len(s) == 0 || s[0] == '#' {
+			var ok bool
+			if s, ok = next(); !ok {
+				return nil, fmt.Errorf("unterminated list")
+			}
+			continue
+		}
+		if s[0] == ']' {
+			break
+		}
+
+		value, rest, err := readConfigValue(s, ",]")
+		if err != nil {
+			return nil, err
+		}
+		values = append(values, value)
+		if s = strings.TrimSpace(rest); len(s) != 0 && s[0] == ',' {
+			s = s[1:]
+		} else if len(s) != 0 && s[0] != ']' && s[0] != '#' {
+			return nil, fmt.Errorf("unexpected[%v] in the list", s)
+		}
+	}
+	if rest := strings.TrimSpace(s[1:]); len(rest) != 0 && rest[0] != '#' {
+		return nil, fmt.Errorf("unexpected[%v] after the list", rest)
+	}
+	return values, nil
+}
+
+/*
+ * read one value at the beginning of s and return the rest. The double-quoted string is unescaped, and
+ * '' in the single-quoted one is the single quote. The plain value ends at the comment or any of stops.
+ */
+func readConfigValue(s string, stops string) (string, string, error) {
+	if strings.HasPrefix(s, `"`) {
+		for i := 1; i < len(s); i++ {
+			if s[i] == '\\' {
+				i++
+			} else if s[i] == '"' {
+				value, err := strconv.Unquote(s[:i+1])
+				if err != nil {
+					return "", "", fmt.Errorf("invalid string[%v]: %v", s[:i+1], err)
+				}
+				return value, s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string[%v]", s)
+	}
+
+	if strings.HasPrefix(s, "'") {
+		value := make([]byte, 0, len(s))
+		for i := 1; i < len(s); i++ {
+			if s[i] != '\'' {
+				value = append(value, s[i])
+			} else if i+1 < len(s) && s[i+1] == '\'' {
+				value = append(value, '\'')
+				i++
+			} else {
+				return string(value), s[i+1:], nil
+			}
+		}
+		return "", "", fmt.Errorf("unterminated string[%v]", s)
+	}
+
+	end := len(s)
+	if index := strings.IndexAny(s, stops); len(stops) != 0 && index >= 0 {
+		end = index
+	}
+	for _, comment := range []string{" #", "\t#"} {
+		if index := strings.Index(s, comment); index >= 0 && index < end {
+			end = index
+		}
+	}
+	return strings.TrimSpace(s[:end]), s[end:], nil
+}
@@ -0,0 +1,113 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestConfigFormat(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestConfigFormat case %d.\n", nr)
+
+		assert.Equal(t, ConfigYaml, ConfigFormat("check.yaml"), "should be equal")
+		assert.Equal(t, ConfigYaml, ConfigFormat("/etc/check.YML"), "should be equal")
+		assert.Equal(t, ConfigToml, ConfigFormat("check.toml"), "should be equal")
+		assert.Equal(t, "", ConfigFormat("check.ini"), "should be equal")
+		assert.Equal(t, "", ConfigFormat("check"), "should be equal")
+	}
+}
+
+func TestParseConfigFile(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestParseConfigFile case %d.\n", nr)
+
+		options, err := ParseConfigFile(ConfigYaml, []byte(`---
+# the source
+source: 10.1.1.1:6379
+sourcepassword: "pa#ss\tword" # quoted
+target: 'it''s'
+qps: 10000  # comment
+filterlist: abc*|efg
+keyhashtag:
+  - '^(user:\d+):(.+)$=>{$1}:$2'
+  - "x=>y"
+keyrewrite: [a, "b,c", 'd']
+comparetimes: 3
+`))
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []ConfigOption{
+			{"source", "10.1.1.1:6379"},
+			{"sourcepassword", "pa#ss\tword"},
+			{"target", "it's"},
+			{"qps", "10000"},
+			{"filterlist", "abc*|efg"},
+			{"keyhashtag", `^(user:\d+):(.+)$=>{$1}:$2`},
+			{"keyhashtag", "x=>y"},
+			{"keyrewrite", "a"},
+			{"keyrewrite", "b,c"},
+			{"keyrewrite", "d"},
+			{"comparetimes", "3"},
+		}, options, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseConfigFile case %d.\n", nr)
+
+		options, err := ParseConfigFile(ConfigToml, []byte(`
+# the source
+source = "10.1.1.1:6379"
+sourcepassword = 'C:\path'
+qps = 10000 # comment
+oneway = true
+keyhashtag = [
+  "a=>b", # first
+  'c=>d',
+]
+keyrewrite = []
+`))
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []ConfigOption{
+			{"source", "10.1.1.1:6379"},
+			{"sourcepassword", `C:\path`},
+			{"qps", "10000"},
+			{"oneway", "true"},
+			{"keyhashtag", "a=>b"},
+			{"keyhashtag", "c=>d"},
+		}, options, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseConfigFile case %d.\n", nr)
+
+		for _, data := range []string{
+			"- a",
+			"source:\n  host: 10.1.1.1",
+			"source 10.1.1.1",
+			"source: \"10.1.1.1",
+			"source: 'a' b",
+			"keyrewrite: [a, b",
+			"keyrewrite: [\"a\" b]",
+		} {
+			_, err := ParseConfigFile(ConfigYaml, []byte(data))
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+		for _, data := range []string{
+			"[source]\nhost = \"10.1.1.1\"",
+			"source =",
+			"keyhashtag = [\"a\",\n\"b\"",
+		} {
+			_, err := ParseConfigFile(ConfigToml, []byte(data))
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+		_, err := ParseConfigFile("json", []byte("{}"))
+		assert.NotEqual(t, nil, err, "should be not equal")
+	}
+}
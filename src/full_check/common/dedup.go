@@ -0,0 +1,43 @@
+package common
+
+/*
+ * SCAN guarantees every key is returned at least once, but the key may be returned more than once,
+ * e.g., when the dict is rehashing. DedupWindow remembers the most recently scanned keys, the
+ * duplicate is very likely to be returned again soon, so a bounded window is enough.
+ */
+type DedupWindow struct {
+	size int
+	keys []string // ring of the keys in the window
+	next int      // position in keys to be replaced
+	seen map[string]struct{}
+}
+
+// size is the count of the keys remembered, 0 means never regard the key as duplicate
+func NewDedupWindow(size int) *DedupWindow {
+	return &DedupWindow{
+		size: size,
+		keys: make([]string, 0, size),
+		seen: make(map[string]struct{}, size),
+	}
+}
+
+// return true when the key is in the window, otherwise put it into the window
+func (p *DedupWindow) Seen(key []byte) bool {
+	if p.size == 0 {
+		return false
+	}
+	if _, ok := p.seen[string(key)]; ok {
+		return true
+	}
+
+	name := string(key)
+	if len(p.keys) < p.size {
+		p.keys = append(p.keys, name)
+	} else {
+		delete(p.seen, p.keys[p.next])
+		p.keys[p.next] = name
+		p.next = (p.next + 1) % p.size
+	}
+	p.seen[name] = struct{}{}
+	return false
+}
@@ -0,0 +1,50 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestDedupWindow(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestDedupWindow case %d.\n", nr)
+
+		window := NewDedupWindow(0)
+		assert.Equal(t, false, window.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, false, window.Seen([]byte("a")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestDedupWindow case %d.\n", nr)
+
+		window := NewDedupWindow(3)
+		assert.Equal(t, false, window.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, false, window.Seen([]byte("b")), "should be equal")
+		assert.Equal(t, true, window.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, false, window.Seen([]byte("c")), "should be equal")
+		assert.Equal(t, false, window.Seen([]byte("d")), "should be equal")
+		// a is out of the window
+		assert.Equal(t, false, window.Seen([]byte("a")), "should be equal")
+		assert.Equal(t, true, window.Seen([]byte("d")), "should be equal")
+		assert.Equal(t, false, window.Seen([]byte("b")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestDedupWindow case %d.\n", nr)
+
+		window := NewDedupWindow(100)
+		for i := 0; i < 1000; i++ {
+			assert.Equal(t, false, window.Seen([]byte("key:"+strconv.Itoa(i))), "should be equal")
+		}
+		assert.Equal(t, 100, len(window.seen), "should be equal")
+		assert.Equal(t, true, window.Seen([]byte("key:999")), "should be equal")
+		assert.Equal(t, false, window.Seen([]byte("key:0")), "should be equal")
+	}
+}
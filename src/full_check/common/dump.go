@@ -0,0 +1,21 @@
+package common
+
+import (
+	"encoding/binary"
+)
+
+// the DUMP payload ends with the 2 bytes rdb version and the 8 bytes crc64, both little endian
+const dumpFooterLen = 10
+
+/*
+ * Split the DUMP payload into the serialized value and the rdb version. The footer is dropped so
+ * the payloads of the same version can be compared by the serialized value. ok is false when the
+ * payload is too short to carry the footer.
+ */
+func SplitDumpPayload(payload []byte) (value []byte, version uint16, ok bool) {
+	if len(payload) < dumpFooterLen {
+		return nil, 0, false
+	}
+	footer := payload[len(payload)-dumpFooterLen:]
+	return payload[:len(payload)-dumpFooterLen], binary.LittleEndian.Uint16(footer[:2]), true
+}
@@ -0,0 +1,46 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSplitDumpPayload(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestSplitDumpPayload case %d.\n", nr)
+
+		// DUMP of the string "bar" with rdb version 10
+		payload := []byte("\x00\x03bar\x0a\x00\x8e\x9d\x8b\x1d\x7c\x2e\x4b\x4a")
+		value, version, ok := SplitDumpPayload(payload)
+		assert.Equal(t, true, ok, "should be equal")
+		assert.Equal(t, []byte("\x00\x03bar"), value, "should be equal")
+		assert.Equal(t, uint16(10), version, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestSplitDumpPayload case %d.\n", nr)
+
+		// the same value of another version differs only in the footer
+		source, sourceVersion, _ := SplitDumpPayload([]byte("\x00\x03bar\x09\x00\x01\x02\x03\x04\x05\x06\x07\x08"))
+		target, targetVersion, _ := SplitDumpPayload([]byte("\x00\x03bar\x0b\x00\x11\x12\x13\x14\x15\x16\x17\x18"))
+		assert.Equal(t, source, target, "should be equal")
+		assert.Equal(t, uint16(9), sourceVersion, "should be equal")
+		assert.Equal(t, uint16(11), targetVersion, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestSplitDumpPayload case %d.\n", nr)
+
+		// too short to carry the footer
+		_, _, ok := SplitDumpPayload([]byte("\x0a\x00\x01"))
+		assert.Equal(t, false, ok, "should be equal")
+		_, _, ok = SplitDumpPayload(nil)
+		assert.Equal(t, false, ok, "should be equal")
+	}
+}
@@ -0,0 +1,17 @@
+package common
+
+import (
+	"math"
+)
+
+// the same as the earth radius used by redis GEODIST
+const EarthRadiusMeter = 6372797.560856
+
+// distance in meters between two coordinates calculated by the haversine formula
+func GeoDistance(lon1, lat1, lon2, lat2 float64) float64 {
+	lat1r, lon1r := lat1*math.Pi/180, lon1*math.Pi/180
+	lat2r, lon2r := lat2*math.Pi/180, lon2*math.Pi/180
+	u := math.Sin((lat2r - lat1r) / 2)
+	v := math.Sin((lon2r - lon1r) / 2)
+	return 2.0 * EarthRadiusMeter * math.Asin(math.Sqrt(u*u+math.Cos(lat1r)*math.Cos(lat2r)*v*v))
+}
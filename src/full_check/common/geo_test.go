@@ -0,0 +1,28 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestGeoDistance(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestGeoDistance case %d.\n", nr)
+
+		assert.Equal(t, float64(0), GeoDistance(13.361389, 38.115556, 13.361389, 38.115556), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestGeoDistance case %d.\n", nr)
+
+		// GEODIST Sicily Palermo Catania
+		distance := GeoDistance(13.361389, 38.115556, 15.087269, 37.502669)
+		assert.Equal(t, true, math.Abs(distance-166274.1516) < 1, "should be equal")
+	}
+}
@@ -1,41 +1,96 @@
 package common
 
+import (
+	"strconv"
+)
+
 func ValueHelper_Hash_SortedSet(reply interface{}) map[string][]byte {
 	if reply == nil {
 		return nil
 	}
 
-	tmpValue := reply.([]interface{})
+	tmpValue, ok := reply.([]interface{})
+	if ok == false {
+		return nil
+	}
+	tmpValue = flattenPairs(tmpValue)
 	if len(tmpValue) == 0 {
 		return nil
 	}
 	value := make(map[string][]byte)
 	for i := 0; i < len(tmpValue); i += 2 {
-		value[string(tmpValue[i].([]byte))] = tmpValue[i+1].([]byte)
+		value[string(bytesHelper(tmpValue[i]))] = bytesHelper(tmpValue[i+1])
 	}
 	return value
 }
 
 func ValueHelper_Set(reply interface{}) map[string][]byte {
-	tmpValue := reply.([]interface{})
-	if len(tmpValue) == 0 {
+	if reply == nil {
+		return nil
+	}
+
+	tmpValue, ok := reply.([]interface{})
+	if ok == false || len(tmpValue) == 0 {
 		return nil
 	}
 	value := make(map[string][]byte)
 	for i := 0; i < len(tmpValue); i++ {
-		value[string(tmpValue[i].([]byte))] = nil
+		value[string(bytesHelper(tmpValue[i]))] = nil
 	}
 	return value
 }
 
 func ValueHelper_List(reply interface{}) [][]byte {
-	tmpValue := reply.([]interface{})
-	if len(tmpValue) == 0 {
+	if reply == nil {
+		return nil
+	}
+
+	tmpValue, ok := reply.([]interface{})
+	if ok == false || len(tmpValue) == 0 {
 		return nil
 	}
 	value := make([][]byte, len(tmpValue))
 	for i := 0; i < len(tmpValue); i++ {
-		value[i] = tmpValue[i].([]byte)
+		value[i] = bytesHelper(tmpValue[i])
 	}
 	return value
-}
\ No newline at end of file
+}
+
+/*
+ * RESP2 replies the field-value pairs of hgetall/zrange withscores in a flat array, while RESP3 replies
+ * a map or an array of pairs. Flatten the pairs so both shapes can be handled in the same way.
+ */
+func flattenPairs(tmpValue []interface{}) []interface{} {
+	if len(tmpValue) == 0 {
+		return tmpValue
+	}
+	if _, ok := tmpValue[0].([]interface{}); !ok {
+		return tmpValue
+	}
+
+	value := make([]interface{}, 0, len(tmpValue)*2)
+	for _, ele := range tmpValue {
+		pair := ele.([]interface{})
+		if len(pair) != 2 {
+			panic(Logger.Errorf("invalid pair in reply: %v", ele))
+		}
+		value = append(value, pair...)
+	}
+	return value
+}
+
+// RESP3 replies the score as double and the other types as blob string
+func bytesHelper(v interface{}) []byte {
+	switch v := v.(type) {
+	case []byte:
+		return v
+	case string:
+		return []byte(v)
+	case int64:
+		return []byte(strconv.FormatInt(v, 10))
+	case float64:
+		return []byte(strconv.FormatFloat(v, 'f', -1, 64))
+	default:
+		panic(Logger.Errorf("unknown type[%T] in reply: %v", v, v))
+	}
+}
@@ -0,0 +1,87 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueHelper_Hash_SortedSet(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestValueHelper_Hash_SortedSet case %d.\n", nr)
+
+		// RESP2: flat array
+		reply := []interface{}{[]byte("a"), []byte("1"), []byte("b"), []byte("2")}
+		expect := map[string][]byte{"a": []byte("1"), "b": []byte("2")}
+		assert.Equal(t, expect, ValueHelper_Hash_SortedSet(reply), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueHelper_Hash_SortedSet case %d.\n", nr)
+
+		// RESP3: array of pairs with double score
+		reply := []interface{}{
+			[]interface{}{[]byte("a"), float64(1)},
+			[]interface{}{[]byte("b"), float64(2.5)},
+		}
+		expect := map[string][]byte{"a": []byte("1"), "b": []byte("2.5")}
+		assert.Equal(t, expect, ValueHelper_Hash_SortedSet(reply), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueHelper_Hash_SortedSet case %d.\n", nr)
+
+		assert.Equal(t, map[string][]byte(nil), ValueHelper_Hash_SortedSet(nil), "should be equal")
+		assert.Equal(t, map[string][]byte(nil), ValueHelper_Hash_SortedSet([]interface{}{}), "should be equal")
+		assert.Equal(t, map[string][]byte(nil), ValueHelper_Hash_SortedSet(int64(-1)), "should be equal")
+	}
+}
+
+func TestValueHelper_Set(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestValueHelper_Set case %d.\n", nr)
+
+		reply := []interface{}{[]byte("a"), "b", int64(3)}
+		expect := map[string][]byte{"a": nil, "b": nil, "3": nil}
+		assert.Equal(t, expect, ValueHelper_Set(reply), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueHelper_Set case %d.\n", nr)
+
+		// key deleted or type changed after fetching the type
+		assert.Equal(t, map[string][]byte(nil), ValueHelper_Set(nil), "should be equal")
+		assert.Equal(t, map[string][]byte(nil), ValueHelper_Set(int64(-1)), "should be equal")
+		assert.Equal(t, map[string][]byte(nil), ValueHelper_Set([]byte("a")), "should be equal")
+	}
+}
+
+func TestValueHelper_List(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestValueHelper_List case %d.\n", nr)
+
+		reply := []interface{}{[]byte("a"), []byte("b"), []byte("a")}
+		expect := [][]byte{[]byte("a"), []byte("b"), []byte("a")}
+		assert.Equal(t, expect, ValueHelper_List(reply), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueHelper_List case %d.\n", nr)
+
+		// key deleted or type changed after fetching the type
+		assert.Equal(t, [][]byte(nil), ValueHelper_List(nil), "should be equal")
+		assert.Equal(t, [][]byte(nil), ValueHelper_List(int64(-1)), "should be equal")
+		assert.Equal(t, [][]byte(nil), ValueHelper_List([]byte("a")), "should be equal")
+	}
+}
@@ -0,0 +1,108 @@
+package common
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+)
+
+/*
+ * KeyMap maps the source key names to the target ones by the exact pairs first, then by the regex
+ * rules in order. The key matching neither is left unmapped. Only the pairs can be reversed to find
+ * the source key of the target key, since the regex rules aren't invertible.
+ */
+type KeyMap struct {
+	pairs   map[string][]byte
+	reverse map[string][]byte
+	rules   []KeyHashTag
+}
+
+// LoadKeyMap loads the key map from the file, see ParseKeyMap for the format
+func LoadKeyMap(path string) (*KeyMap, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	return ParseKeyMap(file)
+}
+
+/*
+ * ParseKeyMap reads one mapping per line. The line "SOURCE<TAB>TARGET" maps the source key to the
+ * target key, the names encoded as "hex:..." are decoded. Otherwise the line is a regex rule in the
+ * form of keyhashtag, e.g., `^user:(\d+)$=>u:{$1}`. The empty lines and the lines beginning with '#'
+ * are skipped. Two source keys mapped to the same target key are rejected.
+ */
+func ParseKeyMap(reader io.Reader) (*KeyMap, error) {
+	ret := &KeyMap{
+		pairs:   make(map[string][]byte),
+		reverse: make(map[string][]byte),
+	}
+	scanner := bufio.NewScanner(reader)
+	scanner.Buffer(make([]byte, 0, 64*1024), 64*1024*1024)
+	for nr := 1; scanner.Scan(); nr++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		idx := strings.IndexByte(line, '\t')
+		if idx == -1 {
+			rules, err := ParseKeyHashTag([]string{line})
+			if err != nil {
+				return nil, fmt.Errorf("line %d: %v", nr, err)
+			}
+			ret.rules = append(ret.rules, rules...)
+			continue
+		}
+
+		source, err := DecodeName(line[:idx])
+		if err != nil || len(source) == 0 {
+			return nil, fmt.Errorf("invalid source key[%s] in line %d", line[:idx], nr)
+		}
+		target, err := DecodeName(line[idx+1:])
+		if err != nil || len(target) == 0 {
+			return nil, fmt.Errorf("invalid target key[%s] in line %d", line[idx+1:], nr)
+		}
+		if _, ok := ret.pairs[string(source)]; ok {
+			return nil, fmt.Errorf("duplicate source key[%s] in line %d", line[:idx], nr)
+		}
+		if _, ok := ret.reverse[string(target)]; ok {
+			return nil, fmt.Errorf("duplicate target key[%s] in line %d", line[idx+1:], nr)
+		}
+		ret.pairs[string(source)] = target
+		ret.reverse[string(target)] = source
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return ret, nil
+}
+
+// the target key of the source key, false if the key isn't mapped
+func (p *KeyMap) Target(key []byte) ([]byte, bool) {
+	if target, ok := p.pairs[string(key)]; ok {
+		return target, true
+	}
+	for _, rule := range p.rules {
+		if match := rule.Pattern.FindSubmatchIndex(key); match != nil {
+			return rule.Pattern.Expand(nil, rule.Template, key, match), true
+		}
+	}
+	return key, false
+}
+
+// the source key of the target key by the pairs, the key is returned as it is if it isn't mapped
+func (p *KeyMap) Source(key []byte) []byte {
+	if source, ok := p.reverse[string(key)]; ok {
+		return source
+	}
+	return key
+}
+
+// whether the source key can be found from every target key, i.e., there's no regex rule
+func (p *KeyMap) Reversible() bool {
+	return len(p.rules) == 0
+}
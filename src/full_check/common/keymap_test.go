@@ -0,0 +1,56 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyMap(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestKeyMap case %d.\n", nr)
+
+		keyMap, err := ParseKeyMap(strings.NewReader("# renamed keys\n" +
+			"order:1\tshop:order:1\r\n" +
+			"hex:00ff\tbinary\n" +
+			"\n" +
+			"^user:(\\d+):(.+)$=>{user:$1}:$2\n" +
+			"^user:.*$=>never\n"))
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, false, keyMap.Reversible(), "should be equal")
+
+		target, ok := keyMap.Target([]byte("order:1"))
+		assert.Equal(t, true, ok, "should be equal")
+		assert.Equal(t, []byte("shop:order:1"), target, "should be equal")
+		target, ok = keyMap.Target([]byte{0x00, 0xff})
+		assert.Equal(t, []byte("binary"), target, "should be equal")
+		target, ok = keyMap.Target([]byte("user:7:profile"))
+		assert.Equal(t, true, ok, "should be equal")
+		assert.Equal(t, []byte("{user:7}:profile"), target, "should be equal")
+		target, ok = keyMap.Target([]byte("other"))
+		assert.Equal(t, false, ok, "should be equal")
+		assert.Equal(t, []byte("other"), target, "should be equal")
+
+		assert.Equal(t, []byte("order:1"), keyMap.Source([]byte("shop:order:1")), "should be equal")
+		assert.Equal(t, []byte("other"), keyMap.Source([]byte("other")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestKeyMap case %d.\n", nr)
+
+		keyMap, err := ParseKeyMap(strings.NewReader("a\tb\n"))
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, true, keyMap.Reversible(), "should be equal")
+
+		for _, content := range []string{"a\tb\na\tc\n", "a\tc\nb\tc\n", "\tb\n", "a\t\n", "a\thex:zz\n", "(=>x\n",
+			"nosplitter\n"} {
+			_, err := ParseKeyMap(strings.NewReader(content))
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+	}
+}
@@ -9,6 +9,7 @@ const (
 	SetTypeIndex
 	ZsetTypeIndex
 	StreamTypeIndex
+	ModuleTypeIndex
 	NoneTypeIndex
 	EndKeyTypeIndex
 )
@@ -27,6 +28,8 @@ func (p KeyTypeIndex) String() string {
 		return "zset"
 	case StreamTypeIndex:
 		return "stream"
+	case ModuleTypeIndex:
+		return "module"
 	case NoneTypeIndex:
 		return "none"
 	default:
@@ -80,6 +83,13 @@ var StreamKeyType = &KeyType{
 	FetchLenCommand: "xlen",
 }
 
+// the key of the module type fetched by the value command, only the existence is taken as the length
+var ModuleKeyType = &KeyType{
+	Name:            "module",
+	Index:           ModuleTypeIndex,
+	FetchLenCommand: "exists",
+}
+
 var NoneKeyType = &KeyType{
 	Name:            "none",
 	Index:           NoneTypeIndex,
@@ -106,6 +116,8 @@ func NewKeyType(a string) *KeyType {
 		return ZsetKeyType
 	case "stream":
 		return StreamKeyType
+	case "module":
+		return ModuleKeyType
 	case "none":
 		return NoneKeyType
 	default:
@@ -129,6 +141,11 @@ type Key struct {
 	ConflictType ConflictType
 	SourceAttr   Attribute
 	TargetAttr   Attribute
+	TargetType   string // the type name on the target when it differs from the source, e.g., "string"
+	TypeRetry    int    // times verified again since the type changed during the comparison
+	Requeued     bool   // verify again from fetching the type in the same round
+	SourceAbsent bool   // the key expired on the source when rechecking the ttl
+	TargetAbsent bool   // the key doesn't exist on the target when fetching the type
 
 	Field []Field
 }
@@ -140,6 +157,11 @@ const (
 	ValueConflict
 	LackSourceConflict
 	LackTargetConflict
+	ExpireConflict
+	EncodingConflict
+	MemoryConflict
+	SkippedConflict      // value is too large and skipped
+	TypeMismatchConflict // the key exists on both sides in different types
 	NoneConflict
 	EndConflict
 )
@@ -154,6 +176,16 @@ func (p ConflictType) String() string {
 		return "lack_source"
 	case LackTargetConflict:
 		return "lack_target"
+	case ExpireConflict:
+		return "expire"
+	case EncodingConflict:
+		return "encoding"
+	case MemoryConflict:
+		return "memory"
+	case SkippedConflict:
+		return "skipped-too-large"
+	case TypeMismatchConflict:
+		return "type-mismatch"
 	case NoneConflict:
 		return "equal"
 	default:
@@ -171,6 +203,16 @@ func NewConflictType(a string) ConflictType {
 		return LackSourceConflict
 	case "lack_target":
 		return LackTargetConflict
+	case "expire":
+		return ExpireConflict
+	case "encoding":
+		return EncodingConflict
+	case "memory":
+		return MemoryConflict
+	case "skipped-too-large":
+		return SkippedConflict
+	case "type-mismatch":
+		return TypeMismatchConflict
 	case "equal":
 		return NoneConflict
 	default:
@@ -0,0 +1,98 @@
+package common
+
+import (
+	"math"
+	"math/bits"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+const latencyBuckets = 32 // the last bucket holds the durations longer than 2^30 microseconds
+
+/*
+ * LatencyHistogram counts the durations in the buckets growing by power of 2 from 1 microsecond,
+ * bucket i holds [2^(i-1), 2^i) microseconds. The percentile is the upper bound of the bucket, so
+ * it's less than 2 times of the real value.
+ */
+type LatencyHistogram struct {
+	buckets [latencyBuckets]int64
+	count   int64
+	max     int64 // nanosecond
+}
+
+type LatencyStat struct {
+	Count int64 `json:"count"`
+	P50Us int64 `json:"p50_us"`
+	P95Us int64 `json:"p95_us"`
+	P99Us int64 `json:"p99_us"`
+	MaxUs int64 `json:"max_us"`
+}
+
+func (p *LatencyHistogram) Observe(d time.Duration) {
+	i := bits.Len64(uint64(d / time.Microsecond))
+	if i >= latencyBuckets {
+		i = latencyBuckets - 1
+	}
+	atomic.AddInt64(&p.buckets[i], 1)
+	atomic.AddInt64(&p.count, 1)
+	for {
+		max := atomic.LoadInt64(&p.max)
+		if int64(d) <= max || atomic.CompareAndSwapInt64(&p.max, max, int64(d)) {
+			break
+		}
+	}
+}
+
+// the duration that percent of the observed ones don't exceed, e.g., 99 for p99
+func (p *LatencyHistogram) Percentile(percent float64) time.Duration {
+	count := atomic.LoadInt64(&p.count)
+	if count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(float64(count) * percent / 100))
+	if rank < 1 {
+		rank = 1
+	}
+	max := time.Duration(atomic.LoadInt64(&p.max))
+	var sum int64
+	for i := 0; i < latencyBuckets-1; i++ {
+		if sum += atomic.LoadInt64(&p.buckets[i]); sum >= rank {
+			if bound := time.Duration(1<<uint(i)) * time.Microsecond; bound < max {
+				return bound
+			}
+			return max
+		}
+	}
+	return max
+}
+
+func (p *LatencyHistogram) Stat() LatencyStat {
+	return LatencyStat{
+		Count: atomic.LoadInt64(&p.count),
+		P50Us: int64(p.Percentile(50) / time.Microsecond),
+		P95Us: int64(p.Percentile(95) / time.Microsecond),
+		P99Us: int64(p.Percentile(99) / time.Microsecond),
+		MaxUs: atomic.LoadInt64(&p.max) / int64(time.Microsecond),
+	}
+}
+
+// the key type of the value fetched by the command, "other" for the commands not fetching the value, e.g., type
+func CommandKeyType(command string) string {
+	switch strings.ToLower(command) {
+	case "get", "strlen", "getrange", "bitcount", "pfcount":
+		return StringKeyType.Name
+	case "hgetall", "hscan", "hmget", "hlen":
+		return HashKeyType.Name
+	case "lrange", "llen":
+		return ListKeyType.Name
+	case "smembers", "sscan", "sismember", "scard":
+		return SetKeyType.Name
+	case "zrange", "zscan", "zscore", "zcard", "geopos":
+		return ZsetKeyType.Name
+	case "xrange", "xinfo", "xpending", "xlen":
+		return StreamKeyType.Name
+	default:
+		return "other"
+	}
+}
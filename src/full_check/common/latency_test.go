@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLatencyHistogram(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestLatencyHistogram case %d.\n", nr)
+
+		var histogram LatencyHistogram
+		assert.Equal(t, time.Duration(0), histogram.Percentile(50), "should be equal")
+		assert.Equal(t, LatencyStat{}, histogram.Stat(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestLatencyHistogram case %d.\n", nr)
+
+		var histogram LatencyHistogram
+		// 90 calls of 100us and 10 calls of 5ms
+		for i := 0; i < 90; i++ {
+			histogram.Observe(100 * time.Microsecond)
+		}
+		for i := 0; i < 10; i++ {
+			histogram.Observe(5 * time.Millisecond)
+		}
+		// the upper bound of the bucket [64us, 128us)
+		assert.Equal(t, 128*time.Microsecond, histogram.Percentile(50), "should be equal")
+		assert.Equal(t, 128*time.Microsecond, histogram.Percentile(90), "should be equal")
+		// capped by the max
+		assert.Equal(t, 5*time.Millisecond, histogram.Percentile(95), "should be equal")
+		assert.Equal(t, 5*time.Millisecond, histogram.Percentile(100), "should be equal")
+
+		stat := histogram.Stat()
+		assert.Equal(t, int64(100), stat.Count, "should be equal")
+		assert.Equal(t, int64(128), stat.P50Us, "should be equal")
+		assert.Equal(t, int64(5000), stat.P99Us, "should be equal")
+		assert.Equal(t, int64(5000), stat.MaxUs, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestLatencyHistogram case %d.\n", nr)
+
+		var histogram LatencyHistogram
+		// less than 1us and longer than the last bucket
+		histogram.Observe(time.Nanosecond)
+		histogram.Observe(time.Hour)
+		assert.Equal(t, time.Microsecond, histogram.Percentile(50), "should be equal")
+		assert.Equal(t, time.Hour, histogram.Percentile(99), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestLatencyHistogram case %d.\n", nr)
+
+		assert.Equal(t, "hash", CommandKeyType("HSCAN"), "should be equal")
+		assert.Equal(t, "string", CommandKeyType("get"), "should be equal")
+		assert.Equal(t, "zset", CommandKeyType("geopos"), "should be equal")
+		assert.Equal(t, "other", CommandKeyType("type"), "should be equal")
+	}
+}
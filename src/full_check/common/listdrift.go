@@ -0,0 +1,104 @@
+package common
+
+import (
+	"bytes"
+)
+
+/*
+ * ListDrift compares two lists allowing the drift at the head and tail, e.g., the hot queue being
+ * pushed and popped during the comparison. The elements pushed or popped at the head shift the whole
+ * list, so the source element i is compared with the target element i+d for every shift d in
+ * [-head, head]. The differing elements among the first head or the last tail elements of both lists
+ * are ignored, and at most tail elements at the end of either list may have no counterpart. The lists
+ * are regarded as equal when any shift matches.
+ */
+type ListDrift struct {
+	head, tail int
+	shifts     []listShift
+	sourceSeen int // count of the source elements compared
+	targetSeen int
+}
+
+type listShift struct {
+	d        int
+	mismatch int  // the first source index differing out of the head, -1 means none
+	dropped  bool // the mismatch is out of the tail for sure
+}
+
+func NewListDrift(head, tail int) *ListDrift {
+	p := &ListDrift{head: head, tail: tail}
+	for d := -head; d <= head; d++ {
+		p.shifts = append(p.shifts, listShift{d: d, mismatch: -1})
+	}
+	return p
+}
+
+/*
+ * Compare the source elements from index sourceStart with the target elements from index
+ * targetStart. The target window should cover [sourceStart-head, sourceStart+len(source)+head)
+ * unless the target ends, and the windows should be given in order.
+ */
+func (p *ListDrift) Match(sourceStart int, source [][]byte, targetStart int, target [][]byte) {
+	for k := range p.shifts {
+		shift := &p.shifts[k]
+		if shift.dropped || shift.mismatch != -1 {
+			continue
+		}
+		for i := sourceStart; i < sourceStart+len(source); i++ {
+			j := i + shift.d
+			if j < targetStart || j >= targetStart+len(target) || (i < p.head && j < p.head) {
+				continue
+			}
+			if bytes.Equal(source[i-sourceStart], target[j-targetStart]) == false {
+				shift.mismatch = i
+				break
+			}
+		}
+	}
+
+	if end := sourceStart + len(source); end > p.sourceSeen {
+		p.sourceSeen = end
+	}
+	if end := targetStart + len(target); end > p.targetSeen {
+		p.targetSeen = end
+	}
+	// the mismatch followed by more than tail elements on either side isn't in the tail
+	for k := range p.shifts {
+		shift := &p.shifts[k]
+		if shift.mismatch != -1 && (p.sourceSeen > shift.mismatch+p.tail ||
+			p.targetSeen > shift.mismatch+shift.d+p.tail) {
+			shift.dropped = true
+		}
+	}
+}
+
+// false when no shift can match any more
+func (p *ListDrift) Alive() bool {
+	for _, shift := range p.shifts {
+		if shift.dropped == false {
+			return true
+		}
+	}
+	return false
+}
+
+// whether the lists match after all the elements compared, given the length of both lists
+func (p *ListDrift) Finish(sourceLen, targetLen int) bool {
+	for _, shift := range p.shifts {
+		if shift.dropped {
+			continue
+		}
+		if shift.mismatch != -1 && (shift.mismatch < sourceLen-p.tail || shift.mismatch+shift.d < targetLen-p.tail) {
+			continue
+		}
+		// the elements without counterpart at the end
+		end := Min(sourceLen, targetLen-shift.d)
+		if end < 0 {
+			end = 0
+		}
+		if sourceLen-end <= p.tail && targetLen-(end+shift.d) <= p.tail {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,104 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func listOf(elements string) [][]byte {
+	ret := make([][]byte, 0)
+	for _, element := range strings.Split(elements, " ") {
+		if element != "" {
+			ret = append(ret, []byte(element))
+		}
+	}
+	return ret
+}
+
+func matchList(head, tail int, source, target string) bool {
+	drift := NewListDrift(head, tail)
+	sourceList, targetList := listOf(source), listOf(target)
+	drift.Match(0, sourceList, 0, targetList)
+	return drift.Finish(len(sourceList), len(targetList))
+}
+
+func TestListDrift(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestListDrift case %d.\n", nr)
+
+		// no drift allowed
+		assert.Equal(t, true, matchList(0, 0, "a b c", "a b c"), "should be equal")
+		assert.Equal(t, false, matchList(0, 0, "a b c", "a b d"), "should be equal")
+		assert.Equal(t, false, matchList(0, 0, "a b c", "a b"), "should be equal")
+		assert.Equal(t, true, matchList(0, 0, "", ""), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestListDrift case %d.\n", nr)
+
+		// the queue popped at the head and pushed at the tail
+		assert.Equal(t, true, matchList(2, 2, "a b c d e f", "c d e f g h"), "should be equal")
+		assert.Equal(t, false, matchList(1, 2, "a b c d e f", "c d e f g h"), "should be equal")
+		assert.Equal(t, false, matchList(2, 1, "a b c d e f", "c d e f g h"), "should be equal")
+		// pushed at the head
+		assert.Equal(t, true, matchList(1, 0, "a b c d", "x a b c d"), "should be equal")
+		assert.Equal(t, true, matchList(1, 0, "x a b c d", "a b c d"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestListDrift case %d.\n", nr)
+
+		// the differing elements at the ends
+		assert.Equal(t, true, matchList(1, 0, "x b c d", "y b c d"), "should be equal")
+		assert.Equal(t, true, matchList(0, 1, "a b c x", "a b c y"), "should be equal")
+		assert.Equal(t, true, matchList(0, 1, "a b c", "a b c y"), "should be equal")
+		// the difference in the middle is reported
+		assert.Equal(t, false, matchList(1, 1, "a b c d e", "a b x d e"), "should be equal")
+		assert.Equal(t, false, matchList(2, 2, "a b c d e f", "c d x f g h"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestListDrift case %d.\n", nr)
+
+		// compared window by window
+		source := listOf("a b c d e f g h")
+		target := listOf("c d e f g h i")
+		drift := NewListDrift(2, 1)
+		for start := 0; start < len(source); start += 3 {
+			end := Min(start+3, len(source))
+			targetStart := start - 2
+			if targetStart < 0 {
+				targetStart = 0
+			}
+			targetEnd := Min(end+2, len(target))
+			if targetStart > targetEnd {
+				targetStart = targetEnd
+			}
+			drift.Match(start, source[start:end], targetStart, target[targetStart:targetEnd])
+			assert.Equal(t, true, drift.Alive(), "should be equal")
+		}
+		assert.Equal(t, true, drift.Finish(len(source), len(target)), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestListDrift case %d.\n", nr)
+
+		// the mismatch followed by enough elements drops the shift early
+		source := listOf("a b c d e f")
+		target := listOf("a x c d e f")
+		drift := NewListDrift(0, 1)
+		drift.Match(0, source[:2], 0, target[:2])
+		assert.Equal(t, true, drift.Alive(), "should be equal")
+		drift.Match(2, source[2:4], 2, target[2:4])
+		assert.Equal(t, false, drift.Alive(), "should be equal")
+	}
+}
@@ -1,10 +1,42 @@
 package common
 
 import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"strings"
+
 	"github.com/cihub/seelog"
 )
 
-func InitLog(logFile string, logLevel string) (seelog.LoggerInterface, error) {
+const (
+	LogFormatText = "text"
+	LogFormatJson = "json"
+
+	// wraps the fields encoded at the head of the message in json format
+	logFieldsMark = "\x1e"
+)
+
+var logJson bool
+
+func init() {
+	seelog.RegisterCustomFormatter("JsonLine", func(param string) seelog.FormatterFunc {
+		return formatJsonLine
+	})
+}
+
+func InitLog(logFile string, logLevel string, logFormat string) (seelog.LoggerInterface, error) {
+	format := "[%LEVEL %Date-%Time %File:%Line]: %Msg%n"
+	switch logFormat {
+	case "", LogFormatText:
+		logJson = false
+	case LogFormatJson:
+		logJson = true
+		format = "%JsonLine%n"
+	default:
+		return nil, fmt.Errorf("unknown log format[%v]", logFormat)
+	}
+
 	var logConfig string
 	if len(logFile) == 0 {
 		logConfig = `
@@ -15,7 +47,7 @@ func InitLog(logFile string, logLevel string) (seelog.LoggerInterface, error) {
                     </filter>
 				</outputs>
 				<formats>
-					<format id="main" format="[%LEVEL %Date-%Time %File:%Line]: %Msg%n"/>
+					<format id="main" format="` + format + `"/>
 				</formats>
 			</seelog>`
 	} else {
@@ -27,9 +59,59 @@ func InitLog(logFile string, logLevel string) (seelog.LoggerInterface, error) {
 					</filter>
 				</outputs>
 				<formats>
-					<format id="main" format="[%LEVEL %Date-%Time %File:%Line]: %Msg%n"/>
+					<format id="main" format="` + format + `"/>
 				</formats>
 			</seelog>`
 	}
 	return seelog.LoggerFromConfigAsBytes([]byte(logConfig))
 }
+
+/*
+ * Attach the fields given by the key-value pairs to the message, e.g.,
+ * LogFields("retry", "db", 0, "key", key). They are appended as "db[0] key[abc]" in text format and
+ * become the fields of the log line in json format.
+ */
+func LogFields(msg string, kv ...interface{}) string {
+	var buf bytes.Buffer
+	if logJson {
+		buf.WriteString(logFieldsMark)
+	} else {
+		buf.WriteString(msg)
+	}
+	for i := 0; i+1 < len(kv); i += 2 {
+		value := kv[i+1]
+		if v, ok := value.([]byte); ok {
+			value = string(v)
+		}
+		if logJson == false {
+			fmt.Fprintf(&buf, " %v[%v]", kv[i], value)
+			continue
+		}
+		encoded, err := json.Marshal(value)
+		if err != nil {
+			encoded, _ = json.Marshal(fmt.Sprint(value))
+		}
+		name, _ := json.Marshal(fmt.Sprint(kv[i]))
+		fmt.Fprintf(&buf, ",%s:%s", name, encoded)
+	}
+	if logJson {
+		buf.WriteString(logFieldsMark)
+		buf.WriteString(msg)
+	}
+	return buf.String()
+}
+
+// one json object per log line with the time, level, file, message and the fields of LogFields
+func formatJsonLine(message string, level seelog.LogLevel, context seelog.LogContextInterface) interface{} {
+	var fields string
+	if strings.HasPrefix(message, logFieldsMark) {
+		if end := strings.Index(message[1:], logFieldsMark); end != -1 {
+			fields = message[1 : end+1]
+			message = message[end+2:]
+		}
+	}
+	msg, _ := json.Marshal(message)
+	return fmt.Sprintf(`{"time":"%s","level":"%s","file":"%s:%d","msg":%s%s}`,
+		context.CallTime().Format("2006-01-02T15:04:05.000Z07:00"), level, context.FileName(), context.Line(),
+		msg, fields)
+}
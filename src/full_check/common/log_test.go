@@ -0,0 +1,66 @@
+package common
+
+import (
+	"encoding/json"
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/cihub/seelog"
+	"github.com/stretchr/testify/assert"
+)
+
+type fakeLogContext struct{}
+
+func (fakeLogContext) Func() string               { return "main.main" }
+func (fakeLogContext) Line() int                  { return 12 }
+func (fakeLogContext) ShortPath() string          { return "client.go" }
+func (fakeLogContext) FullPath() string           { return "/full_check/client/client.go" }
+func (fakeLogContext) FileName() string           { return "client.go" }
+func (fakeLogContext) IsValid() bool              { return true }
+func (fakeLogContext) CallTime() time.Time        { return time.Unix(0, 0) }
+func (fakeLogContext) CustomContext() interface{} { return nil }
+
+func TestLogFields(t *testing.T) {
+	defer func() {
+		logJson = false
+	}()
+
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestLogFields case %d.\n", nr)
+
+		logJson = false
+		assert.Equal(t, "retry db[1] key[abc]", LogFields("retry", "db", 1, "key", []byte("abc")), "should be equal")
+		assert.Equal(t, "retry", LogFields("retry"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestLogFields case %d.\n", nr)
+
+		logJson = true
+		line := formatJsonLine(LogFields("retry \"now\"", "db", 1, "key", []byte("abc"), "retry_count", 3),
+			seelog.LogLevel(0), fakeLogContext{})
+		var fields map[string]interface{}
+		assert.Equal(t, nil, json.Unmarshal([]byte(line.(string)), &fields), "should be equal")
+		assert.Equal(t, "retry \"now\"", fields["msg"], "should be equal")
+		assert.Equal(t, float64(1), fields["db"], "should be equal")
+		assert.Equal(t, "abc", fields["key"], "should be equal")
+		assert.Equal(t, float64(3), fields["retry_count"], "should be equal")
+		assert.Equal(t, "client.go:12", fields["file"], "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestLogFields case %d.\n", nr)
+
+		// the message without fields
+		logJson = true
+		line := formatJsonLine("plain", seelog.LogLevel(0), fakeLogContext{})
+		var fields map[string]interface{}
+		assert.Equal(t, nil, json.Unmarshal([]byte(line.(string)), &fields), "should be equal")
+		assert.Equal(t, "plain", fields["msg"], "should be equal")
+	}
+}
@@ -0,0 +1,49 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStringMatch(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestStringMatch case %d.\n", nr)
+
+		assert.Equal(t, true, StringMatch([]byte("*"), []byte("")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("*"), []byte("abc")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("session:*"), []byte("session:1")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("session:*"), []byte("session:")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("session:*"), []byte("sessions:1")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("a*c*e"), []byte("abcde")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("a*c*e"), []byte("abcdf")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestStringMatch case %d.\n", nr)
+
+		assert.Equal(t, true, StringMatch([]byte("h?llo"), []byte("hello")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("h?llo"), []byte("hllo")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("h[ae]llo"), []byte("hallo")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("h[ae]llo"), []byte("hillo")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("h[^e]llo"), []byte("hallo")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("h[^e]llo"), []byte("hello")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("h[a-b]llo"), []byte("hbllo")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("h[a-b]llo"), []byte("hcllo")), "should be equal")
+		assert.Equal(t, true, StringMatch([]byte("a\\*b"), []byte("a*b")), "should be equal")
+		assert.Equal(t, false, StringMatch([]byte("a\\*b"), []byte("acb")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestStringMatch case %d.\n", nr)
+
+		assert.Equal(t, true, CheckMatch(nil, []byte("abc")), "should be equal")
+		assert.Equal(t, true, CheckMatch([]string{"x*", "ab?"}, []byte("abc")), "should be equal")
+		assert.Equal(t, false, CheckMatch([]string{"x*", "ab"}, []byte("abc")), "should be equal")
+	}
+}
@@ -2,6 +2,7 @@ package common
 
 import (
 	"bytes"
+	"fmt"
 	"strings"
 	"strconv"
 )
@@ -28,26 +29,74 @@ func ParseInfo(content []byte) map[string]string {
 	return result
 }
 
-func FilterDBList(dbs string) map[int]struct{} {
+// FilterDBList convert "0;5;10-15" to the set of db 0, 5 and 10 to 15, "-1" means all and is converted to the empty set.
+func FilterDBList(dbs string) (map[int]struct{}, error) {
 	ret := make(map[int]struct{})
 	// empty
 	if dbs == "-1" {
-		return ret
+		return ret, nil
 	}
 
-	// empty
-	dbList := strings.Split(dbs, Splitter)
-	if len(dbList) == 0 {
-		return ret
+	for _, ele := range strings.Split(dbs, Splitter) {
+		first, last := ele, ele
+		if i := strings.Index(ele, "-"); i > 0 {
+			first, last = ele[:i], ele[i+1:]
+		}
+		start, err := strconv.Atoi(first)
+		if err != nil || start < 0 {
+			return nil, fmt.Errorf("invalid db[%v]", ele)
+		}
+		end, err := strconv.Atoi(last)
+		if err != nil || end < start {
+			return nil, fmt.Errorf("invalid db range[%v]", ele)
+		}
+
+		for db := start; db <= end; db++ {
+			ret[db] = struct{}{}
+		}
+	}
+	return ret, nil
+}
+// ParseDBMapping convert "0:3;1:4" to map[int32]int32{0: 3, 1: 4} which maps the source db to the target db.
+func ParseDBMapping(mapping string) (map[int32]int32, error) {
+	ret := make(map[int32]int32)
+	if len(mapping) == 0 {
+		return ret, nil
 	}
 
-	for _, ele := range dbList {
-		val, err := strconv.Atoi(ele)
-		if err != nil {
-			panic(err)
+	for _, ele := range strings.Split(mapping, Splitter) {
+		items := strings.Split(ele, ":")
+		if len(items) != 2 {
+			return nil, fmt.Errorf("invalid db mapping[%v]", ele)
 		}
+		source, err := strconv.Atoi(items[0])
+		if err != nil || source < 0 {
+			return nil, fmt.Errorf("invalid source db in mapping[%v]", ele)
+		}
+		target, err := strconv.Atoi(items[1])
+		if err != nil || target < 0 {
+			return nil, fmt.Errorf("invalid target db in mapping[%v]", ele)
+		}
+		if _, ok := ret[int32(source)]; ok {
+			return nil, fmt.Errorf("duplicate source db in mapping[%v]", ele)
+		}
+		ret[int32(source)] = int32(target)
+	}
+	return ret, nil
+}
 
-		ret[val] = struct{}{}
+// ParseVersion convert the redis_version in INFO, e.g., "7.2.4", to the major and minor version
+func ParseVersion(version string) (major, minor int, ok bool) {
+	items := strings.Split(version, ".")
+	if len(items) < 2 {
+		return 0, 0, false
+	}
+	var err error
+	if major, err = strconv.Atoi(items[0]); err != nil {
+		return 0, 0, false
 	}
-	return ret
-}
\ No newline at end of file
+	if minor, err = strconv.Atoi(items[1]); err != nil {
+		return 0, 0, false
+	}
+	return major, minor, true
+}
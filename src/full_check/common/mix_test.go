@@ -0,0 +1,97 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestParseDBMapping(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestParseDBMapping case %d.\n", nr)
+
+		mapping, err := ParseDBMapping("")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 0, len(mapping), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseDBMapping case %d.\n", nr)
+
+		mapping, err := ParseDBMapping("0:3;1:4")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, map[int32]int32{0: 3, 1: 4}, mapping, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseDBMapping case %d.\n", nr)
+
+		for _, mapping := range []string{"0", "0:", "a:1", "0:-1", "0:1:2", "0:1;0:2"} {
+			_, err := ParseDBMapping(mapping)
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+	}
+}
+
+func TestFilterDBList(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestFilterDBList case %d.\n", nr)
+
+		dbs, err := FilterDBList("-1")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 0, len(dbs), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestFilterDBList case %d.\n", nr)
+
+		dbs, err := FilterDBList("0;5;10-12;11")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, map[int]struct{}{0: {}, 5: {}, 10: {}, 11: {}, 12: {}}, dbs, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestFilterDBList case %d.\n", nr)
+
+		for _, dbs := range []string{"", "a", "-2", "3-1", "0-", "1;;2", "0-a"} {
+			_, err := FilterDBList(dbs)
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+	}
+}
+
+func TestParseVersion(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestParseVersion case %d.\n", nr)
+
+		major, minor, ok := ParseVersion("7.2.4")
+		assert.Equal(t, true, ok, "should be equal")
+		assert.Equal(t, 7, major, "should be equal")
+		assert.Equal(t, 2, minor, "should be equal")
+
+		major, minor, ok = ParseVersion("255.255.255")
+		assert.Equal(t, true, ok, "should be equal")
+		assert.Equal(t, 255, major, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestParseVersion case %d.\n", nr)
+
+		for _, version := range []string{"", "7", "a.b.c", "7.x"} {
+			_, _, ok := ParseVersion(version)
+			assert.Equal(t, false, ok, "should be equal")
+		}
+	}
+}
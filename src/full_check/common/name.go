@@ -0,0 +1,47 @@
+package common
+
+import (
+	"encoding/hex"
+	"strings"
+	"unicode"
+	"unicode/utf8"
+)
+
+// marks the key or field name encoded in hex in the log and result
+const EncodedNamePrefix = "hex:"
+
+// encode all the key and field names in the output, set by encodekey
+var AlwaysEncodeName bool
+
+/*
+ * The key or field name written in the log and result. The name which isn't printable utf8, e.g., binary
+ * or containing the tab and newline, is encoded as "hex:" followed by the hex string, so it can be
+ * decoded by DecodeName and queried again. The name beginning with "hex:" is also encoded to keep it
+ * reversible.
+ */
+func EncodeName(name []byte) string {
+	if AlwaysEncodeName == false && isPrintable(name) && strings.HasPrefix(string(name), EncodedNamePrefix) == false {
+		return string(name)
+	}
+	return EncodedNamePrefix + hex.EncodeToString(name)
+}
+
+// the reverse of EncodeName, the name without the prefix is returned as it is
+func DecodeName(name string) ([]byte, error) {
+	if strings.HasPrefix(name, EncodedNamePrefix) == false {
+		return []byte(name), nil
+	}
+	return hex.DecodeString(name[len(EncodedNamePrefix):])
+}
+
+func isPrintable(name []byte) bool {
+	if utf8.Valid(name) == false {
+		return false
+	}
+	for _, r := range string(name) {
+		if unicode.IsPrint(r) == false {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,62 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncodeName(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestEncodeName case %d.\n", nr)
+
+		assert.Equal(t, "abc", EncodeName([]byte("abc")), "should be equal")
+		assert.Equal(t, "a b:中文", EncodeName([]byte("a b:中文")), "should be equal")
+		assert.Equal(t, "", EncodeName([]byte("")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestEncodeName case %d.\n", nr)
+
+		// binary, invalid utf8, control characters and the prefix itself are encoded
+		assert.Equal(t, "hex:00ff", EncodeName([]byte{0x00, 0xff}), "should be equal")
+		assert.Equal(t, "hex:610962", EncodeName([]byte("a\tb")), "should be equal")
+		assert.Equal(t, "hex:610a", EncodeName([]byte("a\n")), "should be equal")
+		assert.Equal(t, "hex:6865783a61", EncodeName([]byte("hex:a")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestEncodeName case %d.\n", nr)
+
+		AlwaysEncodeName = true
+		defer func() { AlwaysEncodeName = false }()
+		assert.Equal(t, "hex:616263", EncodeName([]byte("abc")), "should be equal")
+	}
+}
+
+func TestDecodeName(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestDecodeName case %d.\n", nr)
+
+		for _, name := range [][]byte{[]byte("abc"), {0x00, 0xff}, []byte("a\tb"), []byte("hex:a")} {
+			decoded, err := DecodeName(EncodeName(name))
+			assert.Equal(t, nil, err, "should be equal")
+			assert.Equal(t, name, decoded, "should be equal")
+		}
+	}
+
+	{
+		nr++
+		fmt.Printf("TestDecodeName case %d.\n", nr)
+
+		_, err := DecodeName("hex:zz")
+		assert.NotEqual(t, nil, err, "should be not equal")
+	}
+}
@@ -0,0 +1,995 @@
+package common
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// opcodes and value types of the RDB file, refer to rdb.h of redis
+const (
+	rdbOpSlotInfo     = 244
+	rdbOpFunction2    = 245
+	rdbOpModuleAux    = 247
+	rdbOpIdle         = 248
+	rdbOpFreq         = 249
+	rdbOpAux          = 250
+	rdbOpResizeDB     = 251
+	rdbOpExpireTimeMs = 252
+	rdbOpExpireTime   = 253
+	rdbOpSelectDB     = 254
+	rdbOpEOF          = 255
+
+	rdbTypeString          = 0
+	rdbTypeList            = 1
+	rdbTypeSet             = 2
+	rdbTypeZset            = 3
+	rdbTypeHash            = 4
+	rdbTypeZset2           = 5
+	rdbTypeModule2         = 7
+	rdbTypeListZiplist     = 10
+	rdbTypeSetIntset       = 11
+	rdbTypeZsetZiplist     = 12
+	rdbTypeHashZiplist     = 13
+	rdbTypeListQuicklist   = 14
+	rdbTypeStreamListpacks = 15
+	rdbTypeHashListpack    = 16
+	rdbTypeZsetListpack    = 17
+	rdbTypeListQuicklist2  = 18
+	rdbTypeStream2         = 19
+	rdbTypeSetListpack     = 20
+	rdbTypeStream3         = 21
+
+	rdbEncInt8  = 0
+	rdbEncInt16 = 1
+	rdbEncInt32 = 2
+	rdbEncLzf   = 3
+
+	rdbModuleOpEOF    = 0
+	rdbModuleOpSint   = 1
+	rdbModuleOpUint   = 2
+	rdbModuleOpFloat  = 3
+	rdbModuleOpDouble = 4
+	rdbModuleOpString = 5
+
+	quicklistNodePlain = 1
+)
+
+// the value loaded from the RDB file, the hash is flattened as field/value pairs
+type RdbValue struct {
+	Type     string    // string, list, set, zset or hash
+	ExpireMs int64     // unix time in milliseconds, 0 means no expire
+	Str      []byte    // string
+	Elems    [][]byte  // list, set, zset members and hash field/value pairs
+	Scores   []float64 // zset scores in the same order of the members
+}
+
+type rdbEntry struct {
+	offset   int64 // offset of the value type in the file
+	tp       byte
+	expireMs int64
+}
+
+type rdbDB struct {
+	keys    []string // sorted, the cursor of scan is the index
+	entries map[string]rdbEntry
+	expires int64
+}
+
+/*
+ * RdbFile is the index of the keys in the RDB file. The file is parsed once in streaming, only the
+ * key names and the offsets of the values are kept in memory, and the value is parsed from the
+ * offset when fetched. The expired keys are skipped as redis does when loading. The streams and
+ * the module values aren't supported and are skipped.
+ */
+type RdbFile struct {
+	path    string
+	file    *os.File
+	size    int64
+	dbs     map[int32]*rdbDB
+	skipped int64 // the stream and module keys
+}
+
+func OpenRdb(path string) (*RdbFile, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return nil, err
+	}
+
+	f := &RdbFile{
+		path: path,
+		file: file,
+		size: info.Size(),
+		dbs:  make(map[int32]*rdbDB),
+	}
+	if err := f.buildIndex(); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("parse rdb file[%v] failed: %v", path, err)
+	}
+	return f, nil
+}
+
+func (f *RdbFile) Close() error {
+	return f.file.Close()
+}
+
+func (f *RdbFile) buildIndex() error {
+	r := newRdbReader(f.file, 0, f.size)
+	header, err := r.readFull(9)
+	if err != nil {
+		return err
+	}
+	if string(header[:5]) != "REDIS" {
+		return fmt.Errorf("invalid header %q", header)
+	}
+	if _, err := strconv.Atoi(string(header[5:])); err != nil {
+		return fmt.Errorf("invalid version %q", header[5:])
+	}
+
+	now := time.Now().UnixNano() / int64(time.Millisecond)
+	db := f.selectDB(0)
+	var expireMs int64
+	for {
+		tp, err := r.readByte()
+		if err != nil {
+			return err
+		}
+
+		switch tp {
+		case rdbOpEOF:
+			// the checksum is ignored
+			for _, db := range f.dbs {
+				sort.Strings(db.keys)
+			}
+			return nil
+		case rdbOpSelectDB:
+			n, err := r.readLength()
+			if err != nil {
+				return err
+			}
+			db = f.selectDB(int32(n))
+			continue
+		case rdbOpResizeDB:
+			if _, err := r.readLength(); err != nil {
+				return err
+			}
+			if _, err := r.readLength(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpSlotInfo:
+			for i := 0; i < 3; i++ {
+				if _, err := r.readLength(); err != nil {
+					return err
+				}
+			}
+			continue
+		case rdbOpAux:
+			if _, err := r.readString(); err != nil {
+				return err
+			}
+			if _, err := r.readString(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpFunction2:
+			if _, err := r.readString(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpModuleAux:
+			if _, err := r.readLength(); err != nil {
+				return err
+			}
+			if err := r.skipModuleValue(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpFreq:
+			if _, err := r.readByte(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpIdle:
+			if _, err := r.readLength(); err != nil {
+				return err
+			}
+			continue
+		case rdbOpExpireTimeMs:
+			b, err := r.readFull(8)
+			if err != nil {
+				return err
+			}
+			expireMs = int64(binary.LittleEndian.Uint64(b))
+			continue
+		case rdbOpExpireTime:
+			b, err := r.readFull(4)
+			if err != nil {
+				return err
+			}
+			expireMs = int64(binary.LittleEndian.Uint32(b)) * 1000
+			continue
+		}
+
+		// key value pair
+		offset := r.pos - 1
+		key, err := r.readString()
+		if err != nil {
+			return err
+		}
+		skipped := false
+		switch tp {
+		case rdbTypeStreamListpacks, rdbTypeStream2, rdbTypeStream3:
+			err = r.skipStream(tp)
+			skipped = true
+		case rdbTypeModule2:
+			if _, err = r.readLength(); err == nil {
+				err = r.skipModuleValue()
+			}
+			skipped = true
+		default:
+			_, err = r.readValue(tp)
+		}
+		if err != nil {
+			return fmt.Errorf("key[%s] type[%d]: %v", key, tp, err)
+		}
+
+		if skipped {
+			f.skipped++
+		} else if expireMs == 0 || expireMs > now {
+			if _, ok := db.entries[string(key)]; !ok {
+				db.keys = append(db.keys, string(key))
+			}
+			db.entries[string(key)] = rdbEntry{offset: offset, tp: tp, expireMs: expireMs}
+			if expireMs != 0 {
+				db.expires++
+			}
+		}
+		expireMs = 0
+	}
+}
+
+func (f *RdbFile) selectDB(n int32) *rdbDB {
+	if db, ok := f.dbs[n]; ok {
+		return db
+	}
+	db := &rdbDB{entries: make(map[string]rdbEntry)}
+	f.dbs[n] = db
+	return db
+}
+
+// the count of the stream and module keys skipped
+func (f *RdbFile) Skipped() int64 {
+	return f.skipped
+}
+
+// the key count and the expire count of every db
+func (f *RdbFile) Keyspace() map[int32][2]int64 {
+	ret := make(map[int32][2]int64)
+	for n, db := range f.dbs {
+		if len(db.keys) != 0 {
+			ret[n] = [2]int64{int64(len(db.keys)), db.expires}
+		}
+	}
+	return ret
+}
+
+// the sorted keys from the cursor, the next cursor is 0 when finished
+func (f *RdbFile) Scan(n int32, cursor, count int) ([]string, int) {
+	db, ok := f.dbs[n]
+	if !ok || cursor >= len(db.keys) {
+		return nil, 0
+	}
+	end := cursor + count
+	if end >= len(db.keys) {
+		return db.keys[cursor:], 0
+	}
+	return db.keys[cursor:end], end
+}
+
+// the type name, empty string means the key doesn't exist
+func (f *RdbFile) Type(n int32, key string) string {
+	entry, ok := f.lookup(n, key)
+	if !ok {
+		return ""
+	}
+	return rdbTypeName(entry.tp)
+}
+
+// nil means the key doesn't exist
+func (f *RdbFile) Load(n int32, key string) (*RdbValue, error) {
+	entry, ok := f.lookup(n, key)
+	if !ok {
+		return nil, nil
+	}
+
+	r := newRdbReader(f.file, entry.offset, f.size)
+	tp, err := r.readByte()
+	if err != nil {
+		return nil, err
+	}
+	if _, err := r.readString(); err != nil {
+		return nil, err
+	}
+	value, err := r.readValue(tp)
+	if err != nil {
+		return nil, fmt.Errorf("load key[%s] from rdb file[%v] failed: %v", key, f.path, err)
+	}
+	value.ExpireMs = entry.expireMs
+	return value, nil
+}
+
+func (f *RdbFile) lookup(n int32, key string) (rdbEntry, bool) {
+	db, ok := f.dbs[n]
+	if !ok {
+		return rdbEntry{}, false
+	}
+	entry, ok := db.entries[key]
+	if ok && entry.expireMs != 0 && entry.expireMs <= time.Now().UnixNano()/int64(time.Millisecond) {
+		return rdbEntry{}, false
+	}
+	return entry, ok
+}
+
+func rdbTypeName(tp byte) string {
+	switch tp {
+	case rdbTypeString:
+		return "string"
+	case rdbTypeList, rdbTypeListZiplist, rdbTypeListQuicklist, rdbTypeListQuicklist2:
+		return "list"
+	case rdbTypeSet, rdbTypeSetIntset, rdbTypeSetListpack:
+		return "set"
+	case rdbTypeZset, rdbTypeZset2, rdbTypeZsetZiplist, rdbTypeZsetListpack:
+		return "zset"
+	case rdbTypeHash, rdbTypeHashZiplist, rdbTypeHashListpack:
+		return "hash"
+	}
+	return ""
+}
+
+type rdbReader struct {
+	r   *bufio.Reader
+	pos int64 // offset in the file
+}
+
+func newRdbReader(file io.ReaderAt, offset, size int64) *rdbReader {
+	return &rdbReader{
+		r:   bufio.NewReader(io.NewSectionReader(file, offset, size-offset)),
+		pos: offset,
+	}
+}
+
+func (r *rdbReader) readByte() (byte, error) {
+	b, err := r.r.ReadByte()
+	if err != nil {
+		return 0, unexpectedEOF(err)
+	}
+	r.pos++
+	return b, nil
+}
+
+func (r *rdbReader) readFull(n uint64) ([]byte, error) {
+	buf := make([]byte, n)
+	if _, err := io.ReadFull(r.r, buf); err != nil {
+		return nil, unexpectedEOF(err)
+	}
+	r.pos += int64(n)
+	return buf, nil
+}
+
+func unexpectedEOF(err error) error {
+	if err == io.EOF {
+		return io.ErrUnexpectedEOF
+	}
+	return err
+}
+
+func (r *rdbReader) readLength() (uint64, error) {
+	length, encoded, err := r.readEncodedLength()
+	if err == nil && encoded {
+		err = fmt.Errorf("unexpected encoded length")
+	}
+	return length, err
+}
+
+// the encoded length is the encoding type of the string
+func (r *rdbReader) readEncodedLength() (uint64, bool, error) {
+	b, err := r.readByte()
+	if err != nil {
+		return 0, false, err
+	}
+
+	switch b >> 6 {
+	case 0:
+		return uint64(b & 0x3f), false, nil
+	case 1:
+		next, err := r.readByte()
+		if err != nil {
+			return 0, false, err
+		}
+		return uint64(b&0x3f)<<8 | uint64(next), false, nil
+	case 2:
+		switch b {
+		case 0x80:
+			buf, err := r.readFull(4)
+			if err != nil {
+				return 0, false, err
+			}
+			return uint64(binary.BigEndian.Uint32(buf)), false, nil
+		case 0x81:
+			buf, err := r.readFull(8)
+			if err != nil {
+				return 0, false, err
+			}
+			return binary.BigEndian.Uint64(buf), false, nil
+		}
+		return 0, false, fmt.Errorf("unknown length encoding %#x", b)
+	default:
+		return uint64(b & 0x3f), true, nil
+	}
+}
+
+func (r *rdbReader) readString() ([]byte, error) {
+	length, encoded, err := r.readEncodedLength()
+	if err != nil {
+		return nil, err
+	}
+	if !encoded {
+		return r.readFull(length)
+	}
+
+	switch length {
+	case rdbEncInt8:
+		b, err := r.readByte()
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(int8(b)), 10)), nil
+	case rdbEncInt16:
+		buf, err := r.readFull(2)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(int16(binary.LittleEndian.Uint16(buf))), 10)), nil
+	case rdbEncInt32:
+		buf, err := r.readFull(4)
+		if err != nil {
+			return nil, err
+		}
+		return []byte(strconv.FormatInt(int64(int32(binary.LittleEndian.Uint32(buf))), 10)), nil
+	case rdbEncLzf:
+		compressedLen, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		rawLen, err := r.readLength()
+		if err != nil {
+			return nil, err
+		}
+		compressed, err := r.readFull(compressedLen)
+		if err != nil {
+			return nil, err
+		}
+		return lzfDecompress(compressed, int(rawLen))
+	}
+	return nil, fmt.Errorf("unknown string encoding %d", length)
+}
+
+// the score of RDB_TYPE_ZSET is saved as string
+func (r *rdbReader) readStringDouble() (float64, error) {
+	length, err := r.readByte()
+	if err != nil {
+		return 0, err
+	}
+	switch length {
+	case 253:
+		return math.NaN(), nil
+	case 254:
+		return math.Inf(1), nil
+	case 255:
+		return math.Inf(-1), nil
+	}
+	buf, err := r.readFull(uint64(length))
+	if err != nil {
+		return 0, err
+	}
+	return strconv.ParseFloat(string(buf), 64)
+}
+
+func (r *rdbReader) readBinaryDouble() (float64, error) {
+	buf, err := r.readFull(8)
+	if err != nil {
+		return 0, err
+	}
+	return math.Float64frombits(binary.LittleEndian.Uint64(buf)), nil
+}
+
+func (r *rdbReader) readStrings(n uint64) ([][]byte, error) {
+	elems := make([][]byte, 0, n)
+	for i := uint64(0); i < n; i++ {
+		s, err := r.readString()
+		if err != nil {
+			return nil, err
+		}
+		elems = append(elems, s)
+	}
+	return elems, nil
+}
+
+func (r *rdbReader) readValue(tp byte) (*RdbValue, error) {
+	value := &RdbValue{Type: rdbTypeName(tp)}
+	if len(value.Type) == 0 {
+		return nil, fmt.Errorf("unsupported value type %d", tp)
+	}
+
+	var err error
+	switch tp {
+	case rdbTypeString:
+		value.Str, err = r.readString()
+	case rdbTypeList, rdbTypeSet:
+		var n uint64
+		if n, err = r.readLength(); err == nil {
+			value.Elems, err = r.readStrings(n)
+		}
+	case rdbTypeHash:
+		var n uint64
+		if n, err = r.readLength(); err == nil {
+			value.Elems, err = r.readStrings(n * 2)
+		}
+	case rdbTypeZset, rdbTypeZset2:
+		var n uint64
+		if n, err = r.readLength(); err != nil {
+			break
+		}
+		for i := uint64(0); i < n && err == nil; i++ {
+			var member []byte
+			var score float64
+			if member, err = r.readString(); err != nil {
+				break
+			}
+			if tp == rdbTypeZset {
+				score, err = r.readStringDouble()
+			} else {
+				score, err = r.readBinaryDouble()
+			}
+			value.Elems = append(value.Elems, member)
+			value.Scores = append(value.Scores, score)
+		}
+	case rdbTypeSetIntset:
+		var buf []byte
+		if buf, err = r.readString(); err == nil {
+			value.Elems, err = parseIntset(buf)
+		}
+	case rdbTypeListZiplist, rdbTypeHashZiplist, rdbTypeZsetZiplist:
+		var buf []byte
+		if buf, err = r.readString(); err == nil {
+			value.Elems, err = parseZiplist(buf)
+		}
+	case rdbTypeHashListpack, rdbTypeZsetListpack, rdbTypeSetListpack:
+		var buf []byte
+		if buf, err = r.readString(); err == nil {
+			value.Elems, err = parseListpack(buf)
+		}
+	case rdbTypeListQuicklist, rdbTypeListQuicklist2:
+		var n uint64
+		if n, err = r.readLength(); err != nil {
+			break
+		}
+		for i := uint64(0); i < n && err == nil; i++ {
+			container := uint64(0)
+			if tp == rdbTypeListQuicklist2 {
+				if container, err = r.readLength(); err != nil {
+					break
+				}
+			}
+			var buf []byte
+			if buf, err = r.readString(); err != nil {
+				break
+			}
+			switch {
+			case tp == rdbTypeListQuicklist2 && container == quicklistNodePlain:
+				value.Elems = append(value.Elems, buf)
+			case tp == rdbTypeListQuicklist2:
+				var node [][]byte
+				node, err = parseListpack(buf)
+				value.Elems = append(value.Elems, node...)
+			default:
+				var node [][]byte
+				node, err = parseZiplist(buf)
+				value.Elems = append(value.Elems, node...)
+			}
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// the members and scores are saved in pairs in ziplist and listpack
+	if tp == rdbTypeZsetZiplist || tp == rdbTypeZsetListpack {
+		if len(value.Elems)%2 != 0 {
+			return nil, fmt.Errorf("zset elements count %d is odd", len(value.Elems))
+		}
+		pairs := value.Elems
+		value.Elems = make([][]byte, 0, len(pairs)/2)
+		value.Scores = make([]float64, 0, len(pairs)/2)
+		for i := 0; i < len(pairs); i += 2 {
+			score, err := strconv.ParseFloat(string(pairs[i+1]), 64)
+			if err != nil {
+				return nil, fmt.Errorf("invalid zset score %q", pairs[i+1])
+			}
+			value.Elems = append(value.Elems, pairs[i])
+			value.Scores = append(value.Scores, score)
+		}
+	}
+	return value, nil
+}
+
+func (r *rdbReader) skipModuleValue() error {
+	for {
+		opcode, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		switch opcode {
+		case rdbModuleOpEOF:
+			return nil
+		case rdbModuleOpSint, rdbModuleOpUint:
+			_, err = r.readLength()
+		case rdbModuleOpFloat:
+			_, err = r.readFull(4)
+		case rdbModuleOpDouble:
+			_, err = r.readFull(8)
+		case rdbModuleOpString:
+			_, err = r.readString()
+		default:
+			return fmt.Errorf("unknown module opcode %d", opcode)
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+func (r *rdbReader) skipLengths(n int) error {
+	for i := 0; i < n; i++ {
+		if _, err := r.readLength(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func (r *rdbReader) skipStream(tp byte) error {
+	listpacks, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < listpacks*2; i++ {
+		// master id and listpack
+		if _, err := r.readString(); err != nil {
+			return err
+		}
+	}
+
+	// length and last id, then first id, max deleted id and entries added since v2
+	lengths := 3
+	if tp != rdbTypeStreamListpacks {
+		lengths += 5
+	}
+	if err := r.skipLengths(lengths); err != nil {
+		return err
+	}
+
+	groups, err := r.readLength()
+	if err != nil {
+		return err
+	}
+	for i := uint64(0); i < groups; i++ {
+		if _, err := r.readString(); err != nil {
+			return err
+		}
+		// last id, and entries read since v2
+		lengths := 2
+		if tp != rdbTypeStreamListpacks {
+			lengths++
+		}
+		if err := r.skipLengths(lengths); err != nil {
+			return err
+		}
+
+		pending, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < pending; j++ {
+			// raw id and delivery time
+			if _, err := r.readFull(16 + 8); err != nil {
+				return err
+			}
+			if _, err := r.readLength(); err != nil {
+				return err
+			}
+		}
+
+		consumers, err := r.readLength()
+		if err != nil {
+			return err
+		}
+		for j := uint64(0); j < consumers; j++ {
+			if _, err := r.readString(); err != nil {
+				return err
+			}
+			// seen time, and active time since v3
+			timeLen := uint64(8)
+			if tp == rdbTypeStream3 {
+				timeLen += 8
+			}
+			if _, err := r.readFull(timeLen); err != nil {
+				return err
+			}
+			pending, err := r.readLength()
+			if err != nil {
+				return err
+			}
+			if _, err := r.readFull(pending * 16); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
+func lzfDecompress(in []byte, rawLen int) ([]byte, error) {
+	out := make([]byte, 0, rawLen)
+	for i := 0; i < len(in); {
+		ctrl := int(in[i])
+		i++
+		if ctrl < 32 {
+			// literal run
+			n := ctrl + 1
+			if i+n > len(in) {
+				return nil, fmt.Errorf("invalid lzf data")
+			}
+			out = append(out, in[i:i+n]...)
+			i += n
+			continue
+		}
+
+		// back reference
+		length := ctrl >> 5
+		if length == 7 {
+			if i >= len(in) {
+				return nil, fmt.Errorf("invalid lzf data")
+			}
+			length += int(in[i])
+			i++
+		}
+		if i >= len(in) {
+			return nil, fmt.Errorf("invalid lzf data")
+		}
+		ref := len(out) - (ctrl&0x1f)<<8 - int(in[i]) - 1
+		i++
+		if ref < 0 {
+			return nil, fmt.Errorf("invalid lzf data")
+		}
+		for k := 0; k < length+2; k++ {
+			out = append(out, out[ref+k])
+		}
+	}
+	if len(out) != rawLen {
+		return nil, fmt.Errorf("lzf length %d mismatch, expect %d", len(out), rawLen)
+	}
+	return out, nil
+}
+
+func parseIntset(buf []byte) ([][]byte, error) {
+	if len(buf) < 8 {
+		return nil, fmt.Errorf("invalid intset")
+	}
+	encoding := int(binary.LittleEndian.Uint32(buf))
+	n := int(binary.LittleEndian.Uint32(buf[4:]))
+	if encoding != 2 && encoding != 4 && encoding != 8 || len(buf) < 8+n*encoding {
+		return nil, fmt.Errorf("invalid intset")
+	}
+
+	elems := make([][]byte, 0, n)
+	for i := 0; i < n; i++ {
+		b := buf[8+i*encoding:]
+		var v int64
+		switch encoding {
+		case 2:
+			v = int64(int16(binary.LittleEndian.Uint16(b)))
+		case 4:
+			v = int64(int32(binary.LittleEndian.Uint32(b)))
+		case 8:
+			v = int64(binary.LittleEndian.Uint64(b))
+		}
+		elems = append(elems, []byte(strconv.FormatInt(v, 10)))
+	}
+	return elems, nil
+}
+
+func parseZiplist(buf []byte) ([][]byte, error) {
+	invalid := fmt.Errorf("invalid ziplist")
+	pos := 10 // zlbytes, zltail and zllen
+	elems := make([][]byte, 0)
+	for {
+		if pos >= len(buf) {
+			return nil, invalid
+		}
+		if buf[pos] == 0xff {
+			return elems, nil
+		}
+
+		// previous entry length
+		if buf[pos] < 254 {
+			pos++
+		} else {
+			pos += 5
+		}
+		if pos >= len(buf) {
+			return nil, invalid
+		}
+
+		enc := buf[pos]
+		var length, header int
+		var v int64
+		isInt := true
+		switch {
+		case enc>>6 == 0:
+			length, header, isInt = int(enc&0x3f), 1, false
+		case enc>>6 == 1:
+			if pos+2 > len(buf) {
+				return nil, invalid
+			}
+			length, header, isInt = int(enc&0x3f)<<8|int(buf[pos+1]), 2, false
+		case enc>>6 == 2:
+			if pos+5 > len(buf) {
+				return nil, invalid
+			}
+			length, header, isInt = int(binary.BigEndian.Uint32(buf[pos+1:])), 5, false
+		case enc == 0xc0:
+			length, header = 2, 1
+		case enc == 0xd0:
+			length, header = 4, 1
+		case enc == 0xe0:
+			length, header = 8, 1
+		case enc == 0xf0:
+			length, header = 3, 1
+		case enc == 0xfe:
+			length, header = 1, 1
+		case enc >= 0xf1 && enc <= 0xfd:
+			length, header = 0, 1
+			v = int64(enc&0x0f) - 1
+		default:
+			return nil, invalid
+		}
+		pos += header
+		if pos+length > len(buf) {
+			return nil, invalid
+		}
+
+		data := buf[pos : pos+length]
+		pos += length
+		if !isInt {
+			elems = append(elems, data)
+			continue
+		}
+		switch enc {
+		case 0xc0:
+			v = int64(int16(binary.LittleEndian.Uint16(data)))
+		case 0xd0:
+			v = int64(int32(binary.LittleEndian.Uint32(data)))
+		case 0xe0:
+			v = int64(binary.LittleEndian.Uint64(data))
+		case 0xf0:
+			v = int64(int32(uint32(data[0])<<8|uint32(data[1])<<16|uint32(data[2])<<24) >> 8)
+		case 0xfe:
+			v = int64(int8(data[0]))
+		}
+		elems = append(elems, []byte(strconv.FormatInt(v, 10)))
+	}
+}
+
+func parseListpack(buf []byte) ([][]byte, error) {
+	invalid := fmt.Errorf("invalid listpack")
+	pos := 6 // total bytes and elements count
+	elems := make([][]byte, 0)
+	for {
+		if pos >= len(buf) {
+			return nil, invalid
+		}
+		enc := buf[pos]
+		if enc == 0xff {
+			return elems, nil
+		}
+
+		start := pos
+		var length, header int
+		var v int64
+		isInt := true
+		switch {
+		case enc&0x80 == 0:
+			header = 1
+			v = int64(enc & 0x7f)
+		case enc&0xc0 == 0x80:
+			length, header, isInt = int(enc&0x3f), 1, false
+		case enc&0xe0 == 0xc0:
+			if pos+2 > len(buf) {
+				return nil, invalid
+			}
+			header = 2
+			v = int64(enc&0x1f)<<8 | int64(buf[pos+1])
+			if v >= 1<<12 {
+				v -= 1 << 13
+			}
+		case enc&0xf0 == 0xe0:
+			if pos+2 > len(buf) {
+				return nil, invalid
+			}
+			length, header, isInt = int(enc&0x0f)<<8|int(buf[pos+1]), 2, false
+		case enc == 0xf0:
+			if pos+5 > len(buf) {
+				return nil, invalid
+			}
+			length, header, isInt = int(binary.LittleEndian.Uint32(buf[pos+1:])), 5, false
+		case enc == 0xf1:
+			length, header = 2, 1
+		case enc == 0xf2:
+			length, header = 3, 1
+		case enc == 0xf3:
+			length, header = 4, 1
+		case enc == 0xf4:
+			length, header = 8, 1
+		default:
+			return nil, invalid
+		}
+		pos += header
+		if pos+length > len(buf) {
+			return nil, invalid
+		}
+
+		data := buf[pos : pos+length]
+		pos += length
+		if isInt {
+			switch enc {
+			case 0xf1:
+				v = int64(int16(binary.LittleEndian.Uint16(data)))
+			case 0xf2:
+				v = int64(int32(uint32(data[0])<<8|uint32(data[1])<<16|uint32(data[2])<<24) >> 8)
+			case 0xf3:
+				v = int64(int32(binary.LittleEndian.Uint32(data)))
+			case 0xf4:
+				v = int64(binary.LittleEndian.Uint64(data))
+			}
+			elems = append(elems, []byte(strconv.FormatInt(v, 10)))
+		} else {
+			elems = append(elems, data)
+		}
+
+		// skip the backlen
+		switch entryLen := pos - start; {
+		case entryLen <= 127:
+			pos++
+		case entryLen < 16383:
+			pos += 2
+		case entryLen < 2097151:
+			pos += 3
+		case entryLen < 268435455:
+			pos += 4
+		default:
+			pos += 5
+		}
+	}
+}
@@ -0,0 +1,380 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	redigo "github.com/garyburd/redigo/redis"
+)
+
+const RdbScanDefaultCount = 10
+
+/* implement redigo.Conn(https://github.com/garyburd/redigo) on the RDB file
+ * Only the read commands used by the comparison are emulated, the replies are the same types as
+ * redigo returns: status is string, bulk is []byte, integer is int64 and array is []interface{}.
+ */
+type RdbConn struct {
+	file    *RdbFile
+	db      int32
+	replies []reply
+}
+
+func NewRdbConn(file *RdbFile) redigo.Conn {
+	return &RdbConn{
+		file: file,
+	}
+}
+
+// the file is shared by all the connections
+func (rc *RdbConn) Close() error {
+	return nil
+}
+
+func (rc *RdbConn) Err() error {
+	return nil
+}
+
+func (rc *RdbConn) Send(commandName string, args ...interface{}) error {
+	answer, err := rc.Do(commandName, args...)
+	rc.replies = append(rc.replies, reply{answer: answer, err: err})
+	return nil
+}
+
+func (rc *RdbConn) Flush() error {
+	return nil
+}
+
+func (rc *RdbConn) Receive() (interface{}, error) {
+	if len(rc.replies) == 0 {
+		return nil, fmt.Errorf("no more replies")
+	}
+	ret := rc.replies[0]
+	rc.replies = rc.replies[1:]
+	return ret.answer, ret.err
+}
+
+func (rc *RdbConn) Do(commandName string, args ...interface{}) (interface{}, error) {
+	command := strings.ToLower(commandName)
+	if command == "" {
+		// flush the pending replies as redigo does
+		return nil, nil
+	}
+
+	strArgs := make([]string, len(args))
+	for i, arg := range args {
+		strArgs[i] = rdbArgString(arg)
+	}
+
+	switch command {
+	case "ping":
+		return "PONG", nil
+	case "auth", "adminauth", "readonly", "client":
+		return "OK", nil
+	case "select":
+		if len(strArgs) != 1 {
+			break
+		}
+		db, err := strconv.Atoi(strArgs[0])
+		if err != nil {
+			return nil, redigo.Error("ERR invalid DB index")
+		}
+		rc.db = int32(db)
+		return "OK", nil
+	case "info":
+		return rc.info(), nil
+	case "scan":
+		if len(strArgs) >= 1 {
+			return rc.scan(strArgs)
+		}
+	}
+	if len(strArgs) == 0 {
+		return nil, redigo.Error(fmt.Sprintf("ERR unsupported command '%s' of rdb source", commandName))
+	}
+
+	key := strArgs[0]
+	switch command {
+	case "type":
+		if tp := rc.file.Type(rc.db, key); tp != "" {
+			return tp, nil
+		}
+		return "none", nil
+	case "exists":
+		if rc.file.Type(rc.db, key) != "" {
+			return int64(1), nil
+		}
+		return int64(0), nil
+	case "ttl", "pttl":
+		value, err := rc.load(key, "")
+		if err != nil || value == nil {
+			return int64(-2), err
+		}
+		if value.ExpireMs == 0 {
+			return int64(-1), nil
+		}
+		ttl := value.ExpireMs - time.Now().UnixNano()/int64(time.Millisecond)
+		if command == "ttl" {
+			ttl = (ttl + 500) / 1000
+		}
+		return ttl, nil
+	case "get", "strlen", "getrange":
+		value, err := rc.load(key, "string")
+		if err != nil {
+			return nil, err
+		}
+		var str []byte
+		if value != nil {
+			str = value.Str
+		}
+		switch {
+		case command == "strlen":
+			return int64(len(str)), nil
+		case command == "getrange" && len(strArgs) == 3:
+			start, end, ok := rdbRange(strArgs[1], strArgs[2], len(str))
+			if !ok {
+				return []byte{}, nil
+			}
+			return str[start : end+1], nil
+		case command == "get":
+			if value == nil {
+				return nil, nil
+			}
+			return str, nil
+		}
+	case "hlen", "hgetall", "hmget", "hscan":
+		value, err := rc.load(key, "hash")
+		if err != nil {
+			return nil, err
+		}
+		var elems [][]byte
+		if value != nil {
+			elems = value.Elems
+		}
+		switch command {
+		case "hlen":
+			return int64(len(elems) / 2), nil
+		case "hgetall":
+			return rdbArray(elems), nil
+		case "hscan":
+			return []interface{}{[]byte("0"), rdbArray(elems)}, nil
+		case "hmget":
+			ret := make([]interface{}, len(strArgs)-1)
+			for i, field := range strArgs[1:] {
+				for j := 0; j+1 < len(elems); j += 2 {
+					if string(elems[j]) == field {
+						ret[i] = elems[j+1]
+						break
+					}
+				}
+			}
+			return ret, nil
+		}
+	case "llen", "lrange":
+		value, err := rc.load(key, "list")
+		if err != nil {
+			return nil, err
+		}
+		var elems [][]byte
+		if value != nil {
+			elems = value.Elems
+		}
+		switch {
+		case command == "llen":
+			return int64(len(elems)), nil
+		case len(strArgs) == 3:
+			start, end, ok := rdbRange(strArgs[1], strArgs[2], len(elems))
+			if !ok {
+				return []interface{}{}, nil
+			}
+			return rdbArray(elems[start : end+1]), nil
+		}
+	case "scard", "smembers", "sismember", "sscan", "srandmember":
+		value, err := rc.load(key, "set")
+		if err != nil {
+			return nil, err
+		}
+		var elems [][]byte
+		if value != nil {
+			elems = value.Elems
+		}
+		switch {
+		case command == "scard":
+			return int64(len(elems)), nil
+		case command == "smembers":
+			return rdbArray(elems), nil
+		case command == "sscan":
+			return []interface{}{[]byte("0"), rdbArray(elems)}, nil
+		case command == "sismember" && len(strArgs) == 2:
+			for _, member := range elems {
+				if string(member) == strArgs[1] {
+					return int64(1), nil
+				}
+			}
+			return int64(0), nil
+		case command == "srandmember" && len(strArgs) == 2:
+			// the members of the file don't change, so the first ones are as good as the random ones
+			count, err := strconv.Atoi(strArgs[1])
+			if err != nil || count < 0 {
+				return nil, redigo.Error("ERR value is out of range, must be positive")
+			}
+			if count > len(elems) {
+				count = len(elems)
+			}
+			return rdbArray(elems[:count]), nil
+		}
+	case "zcard", "zrange", "zscore", "zscan":
+		value, err := rc.load(key, "zset")
+		if err != nil {
+			return nil, err
+		}
+		if value == nil {
+			value = &RdbValue{}
+		}
+		switch {
+		case command == "zcard":
+			return int64(len(value.Elems)), nil
+		case command == "zscan":
+			return []interface{}{[]byte("0"), rdbZsetArray(value, 0, len(value.Elems)-1)}, nil
+		case command == "zrange" && len(strArgs) >= 3:
+			start, end, ok := rdbRange(strArgs[1], strArgs[2], len(value.Elems))
+			if !ok {
+				return []interface{}{}, nil
+			}
+			if len(strArgs) == 4 && strings.ToLower(strArgs[3]) == "withscores" {
+				return rdbZsetArray(value, start, end), nil
+			}
+			return rdbArray(value.Elems[start : end+1]), nil
+		case command == "zscore" && len(strArgs) == 2:
+			for i, member := range value.Elems {
+				if string(member) == strArgs[1] {
+					return rdbFormatScore(value.Scores[i]), nil
+				}
+			}
+			return nil, nil
+		}
+	}
+	return nil, redigo.Error(fmt.Sprintf("ERR unsupported command '%s' of rdb source", commandName))
+}
+
+// nil is returned when the key doesn't exist, empty tp means any type
+func (rc *RdbConn) load(key, tp string) (*RdbValue, error) {
+	if keyType := rc.file.Type(rc.db, key); keyType == "" {
+		return nil, nil
+	} else if tp != "" && keyType != tp {
+		return nil, redigo.Error("WRONGTYPE Operation against a key holding the wrong kind of value")
+	}
+
+	value, err := rc.file.Load(rc.db, key)
+	if err != nil {
+		return nil, redigo.Error(fmt.Sprintf("ERR %v", err))
+	}
+	return value, nil
+}
+
+func (rc *RdbConn) info() []byte {
+	var buf bytes.Buffer
+	buf.WriteString("# Keyspace\r\n")
+	for db, count := range rc.file.Keyspace() {
+		buf.WriteString(fmt.Sprintf("db%d:keys=%d,expires=%d,avg_ttl=0\r\n", db, count[0], count[1]))
+	}
+	return buf.Bytes()
+}
+
+// the cursor is the index of the sorted keys
+func (rc *RdbConn) scan(args []string) (interface{}, error) {
+	cursor, err := strconv.Atoi(args[0])
+	if err != nil || cursor < 0 {
+		return nil, redigo.Error("ERR invalid cursor")
+	}
+	count := RdbScanDefaultCount
+	var match []string
+	var tp string
+	for i := 1; i+1 < len(args); i += 2 {
+		switch strings.ToLower(args[i]) {
+		case "count":
+			if count, err = strconv.Atoi(args[i+1]); err != nil || count < 1 {
+				return nil, redigo.Error("ERR syntax error")
+			}
+		case "match":
+			match = []string{args[i+1]}
+		case "type":
+			tp = strings.ToLower(args[i+1])
+		default:
+			return nil, redigo.Error("ERR syntax error")
+		}
+	}
+
+	keys, next := rc.file.Scan(rc.db, cursor, count)
+	keyList := make([]interface{}, 0, len(keys))
+	for _, key := range keys {
+		if !CheckMatch(match, []byte(key)) || tp != "" && rc.file.Type(rc.db, key) != tp {
+			continue
+		}
+		keyList = append(keyList, []byte(key))
+	}
+	return []interface{}{[]byte(strconv.Itoa(next)), keyList}, nil
+}
+
+func rdbArgString(arg interface{}) string {
+	switch v := arg.(type) {
+	case []byte:
+		return string(v)
+	case string:
+		return v
+	}
+	return fmt.Sprint(arg)
+}
+
+func rdbArray(elems [][]byte) []interface{} {
+	ret := make([]interface{}, len(elems))
+	for i, elem := range elems {
+		ret[i] = elem
+	}
+	return ret
+}
+
+func rdbZsetArray(value *RdbValue, start, end int) []interface{} {
+	ret := make([]interface{}, 0, 2*(end-start+1))
+	for i := start; i <= end; i++ {
+		ret = append(ret, value.Elems[i], rdbFormatScore(value.Scores[i]))
+	}
+	return ret
+}
+
+func rdbFormatScore(score float64) []byte {
+	switch {
+	case math.IsInf(score, 1):
+		return []byte("inf")
+	case math.IsInf(score, -1):
+		return []byte("-inf")
+	}
+	return []byte(strconv.FormatFloat(score, 'g', -1, 64))
+}
+
+// convert the start and end index as redis does, false means the range is empty
+func rdbRange(startArg, endArg string, length int) (int, int, bool) {
+	start, err1 := strconv.Atoi(startArg)
+	end, err2 := strconv.Atoi(endArg)
+	if err1 != nil || err2 != nil {
+		return 0, 0, false
+	}
+	if start < 0 {
+		start += length
+	}
+	if end < 0 {
+		end += length
+	}
+	if start < 0 {
+		start = 0
+	}
+	if end >= length {
+		end = length - 1
+	}
+	if start > end || start >= length {
+		return 0, 0, false
+	}
+	return start, end, true
+}
@@ -0,0 +1,175 @@
+package common
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func rdbTestString(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+func writeRdbTestFile(t *testing.T) string {
+	var buf bytes.Buffer
+	buf.WriteString("REDIS0009")
+	buf.WriteByte(rdbOpAux)
+	buf.Write(rdbTestString("redis-ver"))
+	buf.Write(rdbTestString("5.0.0"))
+	buf.WriteByte(rdbOpSelectDB)
+	buf.WriteByte(0)
+	buf.WriteByte(rdbOpResizeDB)
+	buf.Write([]byte{6, 1})
+
+	// string encoded as integer
+	buf.WriteByte(rdbTypeString)
+	buf.Write(rdbTestString("str"))
+	buf.Write([]byte{0xc0 | rdbEncInt16, 0x39, 0x30})
+
+	// lzf compressed string "aaaaaa"
+	buf.WriteByte(rdbTypeString)
+	buf.Write(rdbTestString("lzf"))
+	buf.Write([]byte{0xc0 | rdbEncLzf, 4, 6, 0x00, 'a', 0x60, 0x00})
+
+	buf.WriteByte(rdbTypeList)
+	buf.Write(rdbTestString("list"))
+	buf.WriteByte(3)
+	buf.Write(rdbTestString("a"))
+	buf.Write(rdbTestString("b"))
+	buf.Write(rdbTestString("c"))
+
+	// listpack of f=v, n=5
+	buf.WriteByte(rdbTypeHashListpack)
+	buf.Write(rdbTestString("hash"))
+	listpack := []byte{18, 0, 0, 0, 4, 0, 0x81, 'f', 2, 0x81, 'v', 2, 0x81, 'n', 2, 0x05, 1, 0xff}
+	buf.Write(append([]byte{byte(len(listpack))}, listpack...))
+
+	// intset of -2, 1
+	buf.WriteByte(rdbTypeSetIntset)
+	buf.Write(rdbTestString("set"))
+	intset := []byte{2, 0, 0, 0, 2, 0, 0, 0, 0xfe, 0xff, 0x01, 0x00}
+	buf.Write(append([]byte{byte(len(intset))}, intset...))
+
+	buf.WriteByte(rdbTypeZset2)
+	buf.Write(rdbTestString("zset"))
+	buf.WriteByte(1)
+	buf.Write(rdbTestString("m"))
+	score := make([]byte, 8)
+	binary.LittleEndian.PutUint64(score, math.Float64bits(1.5))
+	buf.Write(score)
+
+	// expired already
+	buf.WriteByte(rdbOpExpireTimeMs)
+	buf.Write([]byte{0xe8, 0x03, 0, 0, 0, 0, 0, 0})
+	buf.WriteByte(rdbTypeString)
+	buf.Write(rdbTestString("expired"))
+	buf.Write(rdbTestString("v"))
+
+	buf.WriteByte(rdbOpSelectDB)
+	buf.WriteByte(2)
+	buf.WriteByte(rdbTypeString)
+	buf.Write(rdbTestString("db2"))
+	buf.Write(rdbTestString("v"))
+
+	buf.WriteByte(rdbOpEOF)
+	buf.Write(make([]byte, 8))
+
+	file, err := ioutil.TempFile("", "full_check_rdb")
+	assert.Equal(t, nil, err, "should be equal")
+	_, err = file.Write(buf.Bytes())
+	assert.Equal(t, nil, err, "should be equal")
+	file.Close()
+	return file.Name()
+}
+
+func TestRdbFile(t *testing.T) {
+	var nr int
+	path := writeRdbTestFile(t)
+	defer os.Remove(path)
+
+	f, err := OpenRdb(path)
+	assert.Equal(t, nil, err, "should be equal")
+	defer f.Close()
+
+	{
+		nr++
+		fmt.Printf("TestRdbFile case %d.\n", nr)
+
+		assert.Equal(t, map[int32][2]int64{0: {6, 0}, 2: {1, 0}}, f.Keyspace(), "should be equal")
+		keys, cursor := f.Scan(0, 0, 4)
+		assert.Equal(t, []string{"hash", "list", "lzf", "set"}, keys, "should be equal")
+		assert.Equal(t, 4, cursor, "should be equal")
+		keys, cursor = f.Scan(0, cursor, 4)
+		assert.Equal(t, []string{"str", "zset"}, keys, "should be equal")
+		assert.Equal(t, 0, cursor, "should be equal")
+		assert.Equal(t, "", f.Type(0, "expired"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestRdbFile case %d.\n", nr)
+
+		value, err := f.Load(0, "str")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []byte("12345"), value.Str, "should be equal")
+
+		value, err = f.Load(0, "lzf")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []byte("aaaaaa"), value.Str, "should be equal")
+
+		value, err = f.Load(0, "hash")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, [][]byte{[]byte("f"), []byte("v"), []byte("n"), []byte("5")}, value.Elems, "should be equal")
+
+		value, err = f.Load(0, "set")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, [][]byte{[]byte("-2"), []byte("1")}, value.Elems, "should be equal")
+
+		value, err = f.Load(0, "none")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, (*RdbValue)(nil), value, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestRdbFile case %d.\n", nr)
+
+		conn := NewRdbConn(f)
+		ret, err := conn.Do("scan", 0, "count", 10, "match", "l*")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []interface{}{[]byte("0"), []interface{}{[]byte("list"), []byte("lzf")}}, ret, "should be equal")
+
+		ret, err = conn.Do("type", []byte("zset"))
+		assert.Equal(t, "zset", ret, "should be equal")
+		ret, err = conn.Do("zrange", []byte("zset"), "0", "-1", "WITHSCORES")
+		assert.Equal(t, []interface{}{[]byte("m"), []byte("1.5")}, ret, "should be equal")
+		ret, err = conn.Do("lrange", []byte("list"), 1, 5)
+		assert.Equal(t, []interface{}{[]byte("b"), []byte("c")}, ret, "should be equal")
+		ret, err = conn.Do("hmget", []byte("hash"), []byte("n"), []byte("x"))
+		assert.Equal(t, []interface{}{[]byte("5"), nil}, ret, "should be equal")
+		ret, err = conn.Do("pttl", []byte("str"))
+		assert.Equal(t, int64(-1), ret, "should be equal")
+
+		_, err = conn.Do("llen", []byte("hash"))
+		assert.Equal(t, true, strings.HasPrefix(err.Error(), "WRONGTYPE"), "should be equal")
+
+		// pipeline
+		conn.Send("select", 2)
+		conn.Send("get", []byte("db2"))
+		conn.Send("exists", []byte("str"))
+		assert.Equal(t, nil, conn.Flush(), "should be equal")
+		ret, err = conn.Receive()
+		assert.Equal(t, "OK", ret, "should be equal")
+		ret, err = conn.Receive()
+		assert.Equal(t, []byte("v"), ret, "should be equal")
+		ret, err = conn.Receive()
+		assert.Equal(t, int64(0), ret, "should be equal")
+	}
+}
@@ -0,0 +1,85 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+)
+
+const KeyRewriteSplitter = "=>"
+
+// replace the prefix From of the key name with To
+type KeyRewrite struct {
+	From []byte
+	To   []byte
+}
+
+/*
+ * ParseKeyRewrite convert "app:=>prod:app:|tmp:=>" to the rewrite rules, the rules are split by '|'.
+ * The From of every rule can't be empty.
+ */
+func ParseKeyRewrite(rules string) ([]KeyRewrite, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	ret := make([]KeyRewrite, 0)
+	for _, rule := range strings.Split(rules, "|") {
+		items := strings.Split(rule, KeyRewriteSplitter)
+		if len(items) != 2 || len(items[0]) == 0 {
+			return nil, fmt.Errorf("invalid key rewrite rule[%v], expect FROM%sTO", rule, KeyRewriteSplitter)
+		}
+		ret = append(ret, KeyRewrite{From: []byte(items[0]), To: []byte(items[1])})
+	}
+	return ret, nil
+}
+
+// rewrite the key by the first rule whose prefix matches, the key is returned as it is if no one matches
+func RewriteKey(rules []KeyRewrite, key []byte) []byte {
+	for _, rule := range rules {
+		if bytes.HasPrefix(key, rule.From) {
+			ret := make([]byte, 0, len(rule.To)+len(key)-len(rule.From))
+			ret = append(ret, rule.To...)
+			return append(ret, key[len(rule.From):]...)
+		}
+	}
+	return key
+}
+
+/*
+ * The key name matching Pattern is replaced by Template expanded with the submatches, e.g., the rule
+ * `^(user:\d+):(.+)$=>{$1}:$2` adds the hash tag to colocate the keys of one user in the cluster.
+ */
+type KeyHashTag struct {
+	Pattern  *regexp.Regexp
+	Template []byte
+}
+
+// ParseKeyHashTag convert the rules "REGEX=>TEMPLATE" to the hash tag rules, one rule per item
+func ParseKeyHashTag(rules []string) ([]KeyHashTag, error) {
+	ret := make([]KeyHashTag, 0, len(rules))
+	for _, rule := range rules {
+		// the template never contains the splitter, but the regex may
+		pos := strings.LastIndex(rule, KeyRewriteSplitter)
+		if pos <= 0 {
+			return nil, fmt.Errorf("invalid key hash tag rule[%v], expect REGEX%sTEMPLATE", rule, KeyRewriteSplitter)
+		}
+		pattern, err := regexp.Compile(rule[:pos])
+		if err != nil {
+			return nil, fmt.Errorf("invalid regex of key hash tag rule[%v]: %v", rule, err)
+		}
+		ret = append(ret, KeyHashTag{Pattern: pattern, Template: []byte(rule[pos+len(KeyRewriteSplitter):])})
+	}
+	return ret, nil
+}
+
+// the template of the first rule whose pattern matches expanded, the key is returned as it is if no one matches
+func HashTagKey(rules []KeyHashTag, key []byte) []byte {
+	for _, rule := range rules {
+		if match := rule.Pattern.FindSubmatchIndex(key); match != nil {
+			return rule.Pattern.Expand(nil, rule.Template, key, match)
+		}
+	}
+	return key
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestKeyRewrite(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestKeyRewrite case %d.\n", nr)
+
+		rules, err := ParseKeyRewrite("")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 0, len(rules), "should be equal")
+		assert.Equal(t, []byte("app:1"), RewriteKey(rules, []byte("app:1")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestKeyRewrite case %d.\n", nr)
+
+		rules, err := ParseKeyRewrite("app:=>prod:app:|tmp:=>|app=>x")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 3, len(rules), "should be equal")
+		assert.Equal(t, []byte("prod:app:1"), RewriteKey(rules, []byte("app:1")), "should be equal")
+		assert.Equal(t, []byte("1"), RewriteKey(rules, []byte("tmp:1")), "should be equal")
+		assert.Equal(t, []byte("x1"), RewriteKey(rules, []byte("app1")), "should be equal")
+		assert.Equal(t, []byte("other"), RewriteKey(rules, []byte("other")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestKeyRewrite case %d.\n", nr)
+
+		for _, rule := range []string{"app:", "=>prod:", "a=>b=>c", "a=>b|"} {
+			_, err := ParseKeyRewrite(rule)
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+	}
+}
+
+func TestKeyHashTag(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestKeyHashTag case %d.\n", nr)
+
+		rules, err := ParseKeyHashTag([]string{`^(user:\d+):(.+)$=>{$1}:$2`, `^order:(\w+)$=>{order}:$1`})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 2, len(rules), "should be equal")
+		assert.Equal(t, []byte("{user:12}:profile"), HashTagKey(rules, []byte("user:12:profile")), "should be equal")
+		assert.Equal(t, []byte("{order}:a1"), HashTagKey(rules, []byte("order:a1")), "should be equal")
+		assert.Equal(t, []byte("user:x:profile"), HashTagKey(rules, []byte("user:x:profile")), "should be equal")
+		// the tagged keys of one user are in the same slot
+		assert.Equal(t, KeySlot(HashTagKey(rules, []byte("user:12:a"))), KeySlot(HashTagKey(rules, []byte("user:12:b"))),
+			"should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestKeyHashTag case %d.\n", nr)
+
+		rules, err := ParseKeyHashTag(nil)
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []byte("user:1"), HashTagKey(rules, []byte("user:1")), "should be equal")
+
+		// the regex may contain the splitter
+		rules, err = ParseKeyHashTag([]string{`^a=>b$=>{x}`})
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []byte("{x}"), HashTagKey(rules, []byte("a=>b")), "should be equal")
+
+		for _, rule := range []string{"abc", "=>{x}", "(=>{x}"} {
+			_, err := ParseKeyHashTag([]string{rule})
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+	}
+}
@@ -0,0 +1,140 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"sync"
+)
+
+/*
+ * RotateWriter appends to the file and rolls it when it reaches maxSize bytes, the rotated files are
+ * renamed to "FILE.1", "FILE.2"... from the newest and only the latest maxFiles ones are kept. The
+ * file is rolled at the end of the line, so the record of one line isn't split, and Header is written
+ * at the beginning of every new file, e.g., the csv header. 0 maxSize means never rotate. The file is
+ * opened on the first write. It's safe for the concurrent use.
+ */
+type RotateWriter struct {
+	Header func(w io.Writer)
+
+	path     string
+	maxSize  int64
+	maxFiles int
+	lock     sync.Mutex
+	file     *os.File
+	size     int64
+	lineEnd  bool
+}
+
+func NewRotateWriter(path string, maxSize int64, maxFiles int) *RotateWriter {
+	return &RotateWriter{
+		path:     path,
+		maxSize:  maxSize,
+		maxFiles: maxFiles,
+		lineEnd:  true,
+	}
+}
+
+func (p *RotateWriter) Write(b []byte) (n int, err error) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.file == nil {
+		if err = p.open(os.O_APPEND); err != nil {
+			return 0, err
+		}
+	}
+	for len(b) > 0 {
+		if p.maxSize > 0 && p.size >= p.maxSize && p.lineEnd {
+			if err = p.rotate(); err != nil {
+				return n, err
+			}
+		}
+
+		// stop at the end of the line reaching the max size, the rest is written to the next file
+		chunk := b
+		if from := p.maxSize - p.size - 1; p.maxSize > 0 && from < int64(len(b)) {
+			if from < 0 {
+				from = 0
+			}
+			if i := bytes.IndexByte(b[from:], '\n'); i != -1 {
+				chunk = b[:from+int64(i)+1]
+			}
+		}
+		m, err := p.file.Write(chunk)
+		n += m
+		p.size += int64(m)
+		if err != nil {
+			return n, err
+		}
+		p.lineEnd = chunk[len(chunk)-1] == '\n'
+		b = b[m:]
+	}
+	return n, nil
+}
+
+func (p *RotateWriter) open(flag int) error {
+	file, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE|flag, 0666)
+	if err != nil {
+		return err
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return err
+	}
+	p.file = file
+	p.size = info.Size()
+	p.lineEnd = true
+	return nil
+}
+
+// shift FILE.N-1 to FILE.N ... FILE to FILE.1, and FILE.N is dropped
+func (p *RotateWriter) rotate() error {
+	p.file.Close()
+	p.file = nil
+
+	os.Remove(p.name(p.maxFiles))
+	for i := p.maxFiles - 1; i >= 1; i-- {
+		if err := os.Rename(p.name(i), p.name(i+1)); err != nil && !os.IsNotExist(err) {
+			return err
+		}
+	}
+	if p.maxFiles > 0 {
+		if err := os.Rename(p.path, p.name(1)); err != nil {
+			return err
+		}
+	}
+	if err := p.open(os.O_TRUNC); err != nil {
+		return err
+	}
+	if p.Header != nil {
+		var buf bytes.Buffer
+		p.Header(&buf)
+		n, err := p.file.Write(buf.Bytes())
+		p.size += int64(n)
+		return err
+	}
+	return nil
+}
+
+// the Nth rotated file, FILE itself for 0
+func (p *RotateWriter) name(n int) string {
+	if n == 0 {
+		return p.path
+	}
+	return fmt.Sprintf("%s.%d", p.path, n)
+}
+
+func (p *RotateWriter) Close() {
+	if p == nil {
+		return
+	}
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+}
@@ -0,0 +1,81 @@
+package common
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRotateWriter(t *testing.T) {
+	dir, err := ioutil.TempDir("", "rotate")
+	assert.Equal(t, nil, err, "should be equal")
+	defer os.RemoveAll(dir)
+
+	read := func(name string) string {
+		content, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			return ""
+		}
+		return string(content)
+	}
+
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestRotateWriter case %d.\n", nr)
+
+		// never rotated without the max size
+		writer := NewRotateWriter(filepath.Join(dir, "plain"), 0, 2)
+		writer.Write([]byte("aaaa\nbbbb\n"))
+		writer.Write([]byte("cccc\n"))
+		writer.Close()
+		assert.Equal(t, "aaaa\nbbbb\ncccc\n", read("plain"), "should be equal")
+		assert.Equal(t, "", read("plain.1"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestRotateWriter case %d.\n", nr)
+
+		writer := NewRotateWriter(filepath.Join(dir, "result"), 8, 2)
+		writer.Header = func(w io.Writer) {
+			w.Write([]byte("h\n"))
+		}
+		// the line reaching the max size is finished in the current file
+		n, err := writer.Write([]byte("aaaa\nbbbb\ncc"))
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 12, n, "should be equal")
+		// split in the middle of the line by the buffered writer
+		writer.Write([]byte("cc\ndd"))
+		writer.Write([]byte("dd\neeeeeeeeee\nf\n"))
+		writer.Close()
+
+		// the oldest file "aaaa\nbbbb\n" is dropped since only 2 rotated files are kept
+		assert.Equal(t, "h\ncccc\ndddd\n", read("result.2"), "should be equal")
+		assert.Equal(t, "h\neeeeeeeeee\n", read("result.1"), "should be equal")
+		assert.Equal(t, "h\nf\n", read("result"), "should be equal")
+		assert.Equal(t, "", read("result.3"), "should be equal")
+
+		// appended after reopening
+		writer.Write([]byte("g\n"))
+		writer.Close()
+		assert.Equal(t, "h\nf\ng\n", read("result"), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestRotateWriter case %d.\n", nr)
+
+		// no rotated file is kept
+		writer := NewRotateWriter(filepath.Join(dir, "drop"), 4, 0)
+		writer.Write([]byte("aaaa\nbb\n"))
+		writer.Close()
+		assert.Equal(t, "bb\n", read("drop"), "should be equal")
+		assert.Equal(t, "", read("drop.1"), "should be equal")
+	}
+}
@@ -0,0 +1,19 @@
+package common
+
+import (
+	"hash/fnv"
+)
+
+/*
+ * Return true when the key is in the sample. The key is selected by the hash of its name, so the
+ * sample is the same across runs and doesn't depend on the scan order. rate is in (0, 1], 1 means
+ * all keys are selected.
+ */
+func CheckSample(key []byte, rate float64) bool {
+	if rate >= 1 {
+		return true
+	}
+	h := fnv.New32a()
+	h.Write(key)
+	return float64(h.Sum32()) < rate*(1<<32)
+}
@@ -0,0 +1,47 @@
+package common
+
+import (
+	"fmt"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckSample(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestCheckSample case %d.\n", nr)
+
+		assert.Equal(t, true, CheckSample([]byte("abc"), 1), "should be equal")
+		assert.Equal(t, true, CheckSample([]byte(""), 1), "should be equal")
+		assert.Equal(t, CheckSample([]byte("abc"), 0.5), CheckSample([]byte("abc"), 0.5), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestCheckSample case %d.\n", nr)
+
+		// the key selected with a small rate is also selected with a larger one
+		for i := 0; i < 1000; i++ {
+			key := []byte("key:" + strconv.Itoa(i))
+			if CheckSample(key, 0.01) {
+				assert.Equal(t, true, CheckSample(key, 0.1), "should be equal")
+			}
+		}
+	}
+
+	{
+		nr++
+		fmt.Printf("TestCheckSample case %d.\n", nr)
+
+		count := 0
+		for i := 0; i < 100000; i++ {
+			if CheckSample([]byte("key:"+strconv.Itoa(i)), 0.01) {
+				count++
+			}
+		}
+		assert.Equal(t, true, count > 800 && count < 1200, "should be equal")
+	}
+}
@@ -0,0 +1,103 @@
+package common
+
+import (
+	"math"
+	"math/bits"
+)
+
+const sizeBuckets = 64 // the last bucket holds the sizes not less than 2^62
+
+/*
+ * SizeHistogram counts the sizes, e.g., the element count or the bytes of the keys, in the buckets
+ * growing by power of 2, bucket i holds [2^(i-1), 2^i) and bucket 0 holds 0. The largest size is
+ * kept along with its key to spot the big keys. It isn't safe for the concurrent use.
+ */
+type SizeHistogram struct {
+	buckets [sizeBuckets]int64
+	count   int64
+	total   int64
+	max     int64
+	maxKey  string
+}
+
+// one bucket of the histogram, the sizes in it are not greater than Le
+type SizeBucket struct {
+	Le    int64 `json:"le"`
+	Count int64 `json:"count"`
+}
+
+type SizeStat struct {
+	Count   int64        `json:"count"`
+	Total   int64        `json:"total"`
+	P50     int64        `json:"p50"`
+	P95     int64        `json:"p95"`
+	P99     int64        `json:"p99"`
+	Max     int64        `json:"max"`
+	MaxKey  string       `json:"max_key"`
+	Buckets []SizeBucket `json:"buckets"` // the empty buckets are omitted
+}
+
+// the negative size, e.g., the key expired before fetching, is dropped
+func (p *SizeHistogram) Observe(key string, size int64) {
+	if size < 0 {
+		return
+	}
+	i := bits.Len64(uint64(size))
+	if i >= sizeBuckets {
+		i = sizeBuckets - 1
+	}
+	p.buckets[i]++
+	p.count++
+	p.total += size
+	if size > p.max || p.count == 1 {
+		p.max = size
+		p.maxKey = key
+	}
+}
+
+// the size that percent of the observed ones don't exceed, the upper bound of the bucket capped by the max
+func (p *SizeHistogram) Percentile(percent float64) int64 {
+	if p.count == 0 {
+		return 0
+	}
+	rank := int64(math.Ceil(float64(p.count) * percent / 100))
+	if rank < 1 {
+		rank = 1
+	}
+	var sum int64
+	for i := 0; i < sizeBuckets-1; i++ {
+		if sum += p.buckets[i]; sum >= rank {
+			if bound := bucketBound(i); bound < p.max {
+				return bound
+			}
+			return p.max
+		}
+	}
+	return p.max
+}
+
+func (p *SizeHistogram) Stat() SizeStat {
+	stat := SizeStat{
+		Count:  p.count,
+		Total:  p.total,
+		P50:    p.Percentile(50),
+		P95:    p.Percentile(95),
+		P99:    p.Percentile(99),
+		Max:    p.max,
+		MaxKey: p.maxKey,
+	}
+	for i, count := range p.buckets {
+		if count != 0 {
+			stat.Buckets = append(stat.Buckets, SizeBucket{Le: bucketBound(i), Count: count})
+		}
+	}
+	return stat
+}
+
+// the largest size in bucket i
+func bucketBound(i int) int64 {
+	if i >= sizeBuckets-1 {
+		return math.MaxInt64
+	}
+	return 1<<uint(i) - 1
+}
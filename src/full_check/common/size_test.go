@@ -0,0 +1,64 @@
+package common
+
+import (
+	"fmt"
+	"math"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestSizeHistogram(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestSizeHistogram case %d.\n", nr)
+
+		var histogram SizeHistogram
+		assert.Equal(t, int64(0), histogram.Percentile(50), "should be equal")
+		assert.Equal(t, SizeStat{}, histogram.Stat(), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestSizeHistogram case %d.\n", nr)
+
+		var histogram SizeHistogram
+		// 90 keys of 100 elements and 10 keys of 5000 elements, the last one is the largest
+		for i := 0; i < 90; i++ {
+			histogram.Observe("small", 100)
+		}
+		for i := 0; i < 9; i++ {
+			histogram.Observe("big", 5000)
+		}
+		histogram.Observe("biggest", 10000)
+		histogram.Observe("expired", -1)
+
+		// the upper bound of the bucket [64, 128)
+		assert.Equal(t, int64(127), histogram.Percentile(50), "should be equal")
+		assert.Equal(t, int64(127), histogram.Percentile(90), "should be equal")
+		// the upper bound of the bucket [8192, 16384) capped by the max
+		assert.Equal(t, int64(8191), histogram.Percentile(95), "should be equal")
+		assert.Equal(t, int64(10000), histogram.Percentile(100), "should be equal")
+
+		stat := histogram.Stat()
+		assert.Equal(t, int64(100), stat.Count, "should be equal")
+		assert.Equal(t, int64(90*100+9*5000+10000), stat.Total, "should be equal")
+		assert.Equal(t, int64(10000), stat.Max, "should be equal")
+		assert.Equal(t, "biggest", stat.MaxKey, "should be equal")
+		assert.Equal(t, []SizeBucket{{127, 90}, {8191, 9}, {16383, 1}}, stat.Buckets, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestSizeHistogram case %d.\n", nr)
+
+		var histogram SizeHistogram
+		// the empty key and the one beyond the last bucket
+		histogram.Observe("empty", 0)
+		histogram.Observe("huge", math.MaxInt64)
+		assert.Equal(t, int64(0), histogram.Percentile(50), "should be equal")
+		assert.Equal(t, int64(math.MaxInt64), histogram.Percentile(99), "should be equal")
+		assert.Equal(t, []SizeBucket{{0, 1}, {math.MaxInt64, 1}}, histogram.Stat().Buckets, "should be equal")
+	}
+}
@@ -1,6 +1,11 @@
 package common
 
-import "time"
+import (
+	"sync"
+	"time"
+)
+
+const QosTicksPerSecond = 10
 
 type Qos struct {
 	Bucket chan struct{}
@@ -9,27 +14,37 @@ type Qos struct {
 	close bool
 }
 
+// the bucket is filled at once so the comparison starts without waiting for the first tick
 func StartQoS(limit int) *Qos {
 	q := new(Qos)
 	q.limit = limit
 	q.Bucket = make(chan struct{}, limit)
+	q.fill(limit)
 
 	go q.timer()
 	return q
 }
 
+// refill limit/QosTicksPerSecond tokens every tick to smooth the bursts, limit tokens per second in total
 func (q *Qos) timer() {
-	for range time.NewTicker(1 * time.Second).C {
+	ticker := time.NewTicker(time.Second / QosTicksPerSecond)
+	defer ticker.Stop()
+	for tick := 0; ; tick = (tick + 1) % QosTicksPerSecond {
+		<-ticker.C
 		if q.close {
 			return
 		}
-		for i := 0; i < q.limit; i++ {
-			select {
-			case q.Bucket <- struct{}{}:
-			default:
-				// break if bucket if full
-				break
-			}
+		q.fill(q.limit*(tick+1)/QosTicksPerSecond - q.limit*tick/QosTicksPerSecond)
+	}
+}
+
+func (q *Qos) fill(n int) {
+	for i := 0; i < n; i++ {
+		select {
+		case q.Bucket <- struct{}{}:
+		default:
+			// bucket is full
+			return
 		}
 	}
 }
@@ -37,3 +52,61 @@ func (q *Qos) timer() {
 func (q *Qos) Close() {
 	q.close = true
 }
+
+/*
+ * ByteLimiter limits the bytes per second. The bytes are consumed after they are received since the
+ * size isn't known before, so the budget may go negative and the following callers wait until it's
+ * refilled.
+ */
+type ByteLimiter struct {
+	limit     int64 // bytes per second
+	lock      sync.Mutex
+	available int64
+	last      time.Time
+}
+
+func NewByteLimiter(limit int64) *ByteLimiter {
+	return &ByteLimiter{
+		limit:     limit,
+		available: limit,
+		last:      time.Now(),
+	}
+}
+
+// consume n bytes and return how long the caller should wait
+func (l *ByteLimiter) Reserve(n int64) time.Duration {
+	l.lock.Lock()
+	defer l.lock.Unlock()
+
+	now := time.Now()
+	l.available += int64(now.Sub(l.last).Seconds() * float64(l.limit))
+	if l.available > l.limit {
+		l.available = l.limit
+	}
+	l.last = now
+
+	l.available -= n
+	if l.available >= 0 {
+		return 0
+	}
+	return time.Duration(float64(-l.available) / float64(l.limit) * float64(time.Second))
+}
+
+// the bytes of the redis reply, 8 bytes for the integer and the length of the string or bulk
+func ReplySize(reply interface{}) int64 {
+	switch v := reply.(type) {
+	case []byte:
+		return int64(len(v))
+	case string:
+		return int64(len(v))
+	case int64:
+		return 8
+	case []interface{}:
+		var size int64
+		for _, ele := range v {
+			size += ReplySize(ele)
+		}
+		return size
+	}
+	return 0
+}
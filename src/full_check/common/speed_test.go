@@ -0,0 +1,69 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestQos(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestQos case %d.\n", nr)
+
+		q := StartQoS(20)
+		defer q.Close()
+		assert.Equal(t, 20, len(q.Bucket), "should be equal")
+		for i := 0; i < 20; i++ {
+			<-q.Bucket
+		}
+	}
+
+	{
+		nr++
+		fmt.Printf("TestQos case %d.\n", nr)
+
+		// 2 tokens every 100ms
+		q := StartQoS(20)
+		defer q.Close()
+		for i := 0; i < 20; i++ {
+			<-q.Bucket
+		}
+		start := time.Now()
+		for i := 0; i < 4; i++ {
+			<-q.Bucket
+		}
+		elapsed := time.Since(start)
+		assert.Equal(t, true, elapsed >= 150*time.Millisecond && elapsed < 900*time.Millisecond, "should be equal")
+	}
+}
+
+func TestByteLimiter(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestByteLimiter case %d.\n", nr)
+
+		assert.Equal(t, int64(0), ReplySize(nil), "should be equal")
+		assert.Equal(t, int64(3), ReplySize([]byte("abc")), "should be equal")
+		assert.Equal(t, int64(2), ReplySize("OK"), "should be equal")
+		assert.Equal(t, int64(8), ReplySize(int64(1)), "should be equal")
+		assert.Equal(t, int64(12), ReplySize([]interface{}{[]byte("a"), nil, []interface{}{"ab", int64(5)}, []byte("c")}),
+			"should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestByteLimiter case %d.\n", nr)
+
+		limiter := NewByteLimiter(1000)
+		assert.Equal(t, time.Duration(0), limiter.Reserve(600), "should be equal")
+		assert.Equal(t, time.Duration(0), limiter.Reserve(400), "should be equal")
+		// 2000 bytes in debt, about 2 seconds to refill
+		wait := limiter.Reserve(2000)
+		assert.Equal(t, true, wait > 1900*time.Millisecond && wait <= 2*time.Second, "should be equal")
+	}
+}
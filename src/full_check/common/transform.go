@@ -0,0 +1,85 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// ValueTransform normalizes the value of one side before comparison, the input shouldn't be modified
+type ValueTransform func(value []byte) []byte
+
+var (
+	valueTransforms = map[string]ValueTransform{
+		"swap2": SwapBytes(2),
+		"swap4": SwapBytes(4),
+		"swap8": SwapBytes(8),
+	}
+	valueTransformLock sync.RWMutex
+)
+
+// the transform applied to the value of the key whose name matches Pattern
+type ValueTransformRule struct {
+	Pattern   string
+	Name      string
+	Transform ValueTransform
+}
+
+/*
+ * RegisterValueTransform makes the transform available to the rules by the name, e.g., to decode
+ * the packed binary struct into a canonical form. It should be called before parsing the rules.
+ */
+func RegisterValueTransform(name string, transform ValueTransform) {
+	valueTransformLock.Lock()
+	defer valueTransformLock.Unlock()
+	valueTransforms[name] = transform
+}
+
+// SwapBytes reverses the byte order of every width bytes, the trailing bytes shorter than width are kept
+func SwapBytes(width int) ValueTransform {
+	return func(value []byte) []byte {
+		ret := make([]byte, len(value))
+		copy(ret, value)
+		for start := 0; start+width <= len(ret); start += width {
+			for i, j := start, start+width-1; i < j; i, j = i+1, j-1 {
+				ret[i], ret[j] = ret[j], ret[i]
+			}
+		}
+		return ret
+	}
+}
+
+// ParseValueTransform convert "pkt:*=>swap4|hdr:*=>swap8" to the rules, the rules are split by '|'
+func ParseValueTransform(rules string) ([]ValueTransformRule, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	valueTransformLock.RLock()
+	defer valueTransformLock.RUnlock()
+
+	ret := make([]ValueTransformRule, 0)
+	for _, rule := range strings.Split(rules, "|") {
+		items := strings.Split(rule, KeyRewriteSplitter)
+		if len(items) != 2 || len(items[0]) == 0 {
+			return nil, fmt.Errorf("invalid value transform rule[%v], expect PATTERN%sNAME", rule, KeyRewriteSplitter)
+		}
+		name := strings.TrimSpace(items[1])
+		transform, ok := valueTransforms[name]
+		if !ok {
+			return nil, fmt.Errorf("unknown value transform[%v] in rule[%v]", name, rule)
+		}
+		ret = append(ret, ValueTransformRule{Pattern: items[0], Name: name, Transform: transform})
+	}
+	return ret, nil
+}
+
+// the value transformed by the first rule whose pattern matches the key, unchanged if no one matches
+func TransformValue(rules []ValueTransformRule, key, value []byte) []byte {
+	for i := range rules {
+		if StringMatch([]byte(rules[i].Pattern), key) {
+			return rules[i].Transform(value)
+		}
+	}
+	return value
+}
@@ -0,0 +1,64 @@
+package common
+
+import (
+	"bytes"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueTransform(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestValueTransform case %d.\n", nr)
+
+		assert.Equal(t, []byte{2, 1, 4, 3, 5}, SwapBytes(2)([]byte{1, 2, 3, 4, 5}), "should be equal")
+		assert.Equal(t, []byte{4, 3, 2, 1, 8, 7, 6, 5}, SwapBytes(4)([]byte{1, 2, 3, 4, 5, 6, 7, 8}), "should be equal")
+		assert.Equal(t, []byte{1, 2, 3}, SwapBytes(8)([]byte{1, 2, 3}), "should be equal")
+		assert.Equal(t, []byte{}, SwapBytes(4)([]byte{}), "should be equal")
+
+		// the input isn't modified
+		input := []byte{1, 2}
+		SwapBytes(2)(input)
+		assert.Equal(t, []byte{1, 2}, input, "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueTransform case %d.\n", nr)
+
+		rules, err := ParseValueTransform("pkt:*=>swap4|hdr:*=>swap2")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 2, len(rules), "should be equal")
+		assert.Equal(t, "swap4", rules[0].Name, "should be equal")
+		assert.Equal(t, []byte{4, 3, 2, 1}, TransformValue(rules, []byte("pkt:1"), []byte{1, 2, 3, 4}), "should be equal")
+		assert.Equal(t, []byte{2, 1, 4, 3}, TransformValue(rules, []byte("hdr:1"), []byte{1, 2, 3, 4}), "should be equal")
+		assert.Equal(t, []byte{1, 2, 3, 4}, TransformValue(rules, []byte("other"), []byte{1, 2, 3, 4}), "should be equal")
+
+		rules, err = ParseValueTransform("")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 0, len(rules), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueTransform case %d.\n", nr)
+
+		_, err := ParseValueTransform("pkt:*=>swap3")
+		assert.NotEqual(t, nil, err, "should be equal")
+		_, err = ParseValueTransform("pkt:*")
+		assert.NotEqual(t, nil, err, "should be equal")
+		_, err = ParseValueTransform("=>swap2")
+		assert.NotEqual(t, nil, err, "should be equal")
+
+		// registered by the library
+		RegisterValueTransform("upper", func(value []byte) []byte {
+			return bytes.ToUpper(value)
+		})
+		rules, err := ParseValueTransform("*=>upper")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, []byte("ABC"), TransformValue(rules, []byte("k"), []byte("abc")), "should be equal")
+	}
+}
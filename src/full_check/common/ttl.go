@@ -0,0 +1,20 @@
+package common
+
+import (
+	"time"
+)
+
+/*
+ * The difference in milliseconds of the remaining ttl fetched by PTTL on both sides, aligned to the
+ * same instant. The ttl fetched later is shorter by the time elapsed in between, so each ttl is
+ * turned into the deadline from the time just before it's fetched, and the deadlines are compared.
+ */
+func AlignedTTLDiff(sourceAt time.Time, sourceTTL int64, targetAt time.Time, targetTTL int64) int64 {
+	sourceDeadline := sourceAt.UnixNano()/int64(time.Millisecond) + sourceTTL
+	targetDeadline := targetAt.UnixNano()/int64(time.Millisecond) + targetTTL
+	diff := sourceDeadline - targetDeadline
+	if diff < 0 {
+		diff = -diff
+	}
+	return diff
+}
@@ -0,0 +1,34 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestAlignedTTLDiff(t *testing.T) {
+	var nr int
+	now := time.Unix(1700000000, 0)
+	{
+		nr++
+		fmt.Printf("TestAlignedTTLDiff case %d.\n", nr)
+
+		// fetched at the same instant
+		assert.Equal(t, int64(0), AlignedTTLDiff(now, 5000, now, 5000), "should be equal")
+		assert.Equal(t, int64(300), AlignedTTLDiff(now, 5000, now, 4700), "should be equal")
+		assert.Equal(t, int64(300), AlignedTTLDiff(now, 4700, now, 5000), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestAlignedTTLDiff case %d.\n", nr)
+
+		// the target is fetched 800ms later, so its ttl is shorter by the elapsed time
+		later := now.Add(800 * time.Millisecond)
+		assert.Equal(t, int64(0), AlignedTTLDiff(now, 5000, later, 4200), "should be equal")
+		assert.Equal(t, int64(0), AlignedTTLDiff(later, 4200, now, 5000), "should be equal")
+		assert.Equal(t, int64(800), AlignedTTLDiff(now, 5000, later, 5000), "should be equal")
+	}
+}
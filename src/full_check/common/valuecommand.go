@@ -0,0 +1,73 @@
+package common
+
+import (
+	"fmt"
+	"strings"
+)
+
+const ValueCommandKeyHolder = "{key}"
+
+// fetch the value of the module key whose name matches Pattern by the command
+type ValueCommand struct {
+	Pattern string
+	Command string
+	Args    []string // ValueCommandKeyHolder is replaced by the key name
+}
+
+/*
+ * ParseValueCommand convert "json:*=>JSON.GET {key} .|bf:*=>BF.DEBUG {key}" to the value commands,
+ * the rules are split by '|' and the arguments are split by spaces. The key name must be given by
+ * ValueCommandKeyHolder in the arguments.
+ */
+func ParseValueCommand(rules string) ([]ValueCommand, error) {
+	if len(rules) == 0 {
+		return nil, nil
+	}
+
+	ret := make([]ValueCommand, 0)
+	for _, rule := range strings.Split(rules, "|") {
+		items := strings.Split(rule, KeyRewriteSplitter)
+		if len(items) != 2 || len(items[0]) == 0 {
+			return nil, fmt.Errorf("invalid value command rule[%v], expect PATTERN%sCOMMAND ARGS...", rule,
+				KeyRewriteSplitter)
+		}
+		fields := strings.Fields(items[1])
+		if len(fields) < 2 {
+			return nil, fmt.Errorf("invalid value command rule[%v], the command and %s are required", rule,
+				ValueCommandKeyHolder)
+		}
+		hasKey := false
+		for _, arg := range fields[1:] {
+			hasKey = hasKey || arg == ValueCommandKeyHolder
+		}
+		if !hasKey {
+			return nil, fmt.Errorf("invalid value command rule[%v], %s isn't given in the arguments", rule,
+				ValueCommandKeyHolder)
+		}
+		ret = append(ret, ValueCommand{Pattern: items[0], Command: fields[0], Args: fields[1:]})
+	}
+	return ret, nil
+}
+
+// the first value command whose pattern matches the key, nil if no one matches
+func MatchValueCommand(commands []ValueCommand, key []byte) *ValueCommand {
+	for i := range commands {
+		if StringMatch([]byte(commands[i].Pattern), key) {
+			return &commands[i]
+		}
+	}
+	return nil
+}
+
+// the arguments with the key name filled
+func (p *ValueCommand) BuildArgs(key []byte) []interface{} {
+	args := make([]interface{}, len(p.Args))
+	for i, arg := range p.Args {
+		if arg == ValueCommandKeyHolder {
+			args[i] = key
+		} else {
+			args[i] = arg
+		}
+	}
+	return args
+}
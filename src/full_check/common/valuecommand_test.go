@@ -0,0 +1,51 @@
+package common
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValueCommand(t *testing.T) {
+	var nr int
+	{
+		nr++
+		fmt.Printf("TestValueCommand case %d.\n", nr)
+
+		commands, err := ParseValueCommand("")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 0, len(commands), "should be equal")
+		assert.Equal(t, (*ValueCommand)(nil), MatchValueCommand(commands, []byte("json:1")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueCommand case %d.\n", nr)
+
+		commands, err := ParseValueCommand("json:*=>JSON.GET {key} .|bf:*=>BF.DEBUG  {key}")
+		assert.Equal(t, nil, err, "should be equal")
+		assert.Equal(t, 2, len(commands), "should be equal")
+
+		command := MatchValueCommand(commands, []byte("json:1"))
+		assert.Equal(t, "JSON.GET", command.Command, "should be equal")
+		assert.Equal(t, []interface{}{[]byte("prod:json:1"), "."}, command.BuildArgs([]byte("prod:json:1")), "should be equal")
+
+		command = MatchValueCommand(commands, []byte("bf:1"))
+		assert.Equal(t, "BF.DEBUG", command.Command, "should be equal")
+		assert.Equal(t, []interface{}{[]byte("bf:1")}, command.BuildArgs([]byte("bf:1")), "should be equal")
+
+		assert.Equal(t, (*ValueCommand)(nil), MatchValueCommand(commands, []byte("other")), "should be equal")
+	}
+
+	{
+		nr++
+		fmt.Printf("TestValueCommand case %d.\n", nr)
+
+		for _, rule := range []string{"json:*", "=>JSON.GET {key}", "json:*=>JSON.GET", "json:*=>JSON.GET key",
+			"json:*=>JSON.GET {key}|"} {
+			_, err := ParseValueCommand(rule)
+			assert.NotEqual(t, nil, err, "should be not equal")
+		}
+	}
+}
@@ -1,32 +1,138 @@
 package conf
 
-var Opts struct {
-	SourceAddr         string `short:"s" long:"source" value-name:"SOURCE"  description:"Set host:port of source redis. If db type is cluster, split by semicolon(;'), e.g., 10.1.1.1:1000;10.2.2.2:2000;10.3.3.3:3000. We also support auto-detection, so \"master@10.1.1.1:1000\" or \"slave@10.1.1.1:1000\" means choose master or slave. Only need to give a role in the master or slave."`
+type Options struct {
+	SourceAddr         string `short:"s" long:"source" value-name:"SOURCE"  description:"Set host:port of source redis. If db type is cluster, split by semicolon(;'), e.g., 10.1.1.1:1000;10.2.2.2:2000;10.3.3.3:3000. The list may also be part of the cluster nodes that used as seeds to discover all the masters. We also support auto-detection, so \"master@10.1.1.1:1000\" or \"slave@10.1.1.1:1000\" means choose master or slave. Only need to give a role in the master or slave. Unix socket is supported by \"unix:///path/to/redis.sock\". The RDB file can also be the source by \"rdb:///path/to/dump.rdb\", the expired keys are skipped and the stream and module keys aren't supported."`
 	SourcePassword     string `short:"p" long:"sourcepassword" value-name:"Password" description:"Set source redis password"`
+	SourcePasswordEnv  string `long:"sourcepasswordenv" value-name:"NAME" description:"read the source password from the environment variable on every new connection instead of sourcepassword"`
 	SourceAuthType     string `long:"sourceauthtype" value-name:"AUTH-TYPE" default:"auth" description:"useless for opensource redis, valid value:auth/adminauth" `
 	SourceDBType       int    `long:"sourcedbtype" default:"0" description:"0: db, 1: cluster 2: aliyun proxy, 3: tencent proxy"`
-	SourceDBFilterList string `long:"sourcedbfilterlist" default:"-1" description:"db white list that need to be compared, -1 means fetch all, \"0;5;15\" means fetch db 0, 5, and 15"`
-	TargetAddr         string `short:"t" long:"target" value-name:"TARGET"  description:"Set host:port of target redis. If db type is cluster, split by semicolon(;'), e.g., 10.1.1.1:1000;10.2.2.2:2000;10.3.3.3:3000. We also support auto-detection, so \"master@10.1.1.1:1000\" or \"slave@10.1.1.1:1000\" means choose master or slave. Only need to give a role in the master or slave."`
+	SourceDBFilterList string `long:"sourcedbfilterlist" default:"-1" description:"db white list that need to be compared, -1 means fetch all, \"0;5;15\" means fetch db 0, 5, and 15, \"0-15\" means fetch db 0 to 15"`
+	SourceSentinel     string `long:"sourcesentinel" value-name:"MASTER-NAME" description:"the master name monitored by sentinel. When given, the source address is the sentinel list split by semicolon(;) and the current master is resolved from sentinel on every connection. Only used in sourcedbtype 0"`
+	SourceNoSelect     bool   `long:"sourcenoselect" description:"don't send SELECT to the source, e.g., twemproxy or codis proxy rejecting it. Only db 0 is compared and INFO Keyspace is optional. Not used in sourcedbtype 1"`
+	SourceReadOnly     bool   `long:"sourcereadonly" description:"send READONLY so the reads can be served by the replica, e.g., \"slave@10.1.1.1:1000\". For the cluster, the commands with the key are sent to the first replica of the slot by CLUSTER SLOTS on the node connections sending READONLY"`
+	SourceReplica      string `long:"sourcereplica" value-name:"REPLICA" default:"" description:"read the source from the given replica of the source instead, so the busy primary isn't loaded. The replica detached by REPLICAOF NO ONE is a point-in-time snapshot of the primary and gives the comparison a stable reference. The replication offset of the replica is recorded before the first round and after the last one, the view is stable when it doesn't move, and the offset lag behind the primary is logged when it's still replicating. Only used in sourcedbtype 0 without sentinel"`
+	SourceNoTouch      bool   `long:"sourcenotouch" description:"send CLIENT NO-TOUCH ON after connecting to the source so the reads of the comparison don't update the LRU/LFU of the keys, supported since redis 7.2. The TTL is never changed by the reads. Not supported for the cluster whose connections are managed by the cluster driver"`
+	TargetAddr         string `short:"t" long:"target" value-name:"TARGET"  description:"Set host:port of target redis. If db type is cluster, split by semicolon(;'), e.g., 10.1.1.1:1000;10.2.2.2:2000;10.3.3.3:3000. The list may also be part of the cluster nodes that used as seeds to discover all the masters. We also support auto-detection, so \"master@10.1.1.1:1000\" or \"slave@10.1.1.1:1000\" means choose master or slave. Only need to give a role in the master or slave. Unix socket is supported by \"unix:///path/to/redis.sock\"."`
 	TargetPassword     string `short:"a" long:"targetpassword" value-name:"Password" description:"Set target redis password"`
+	TargetPasswordEnv  string `long:"targetpasswordenv" value-name:"NAME" description:"read the target password from the environment variable on every new connection instead of targetpassword"`
 	TargetAuthType     string `long:"targetauthtype" value-name:"AUTH-TYPE" default:"auth" description:"useless for opensource redis, valid value:auth/adminauth" `
 	TargetDBType       int    `long:"targetdbtype" default:"0" description:"0: db, 1: cluster 2: aliyun proxy 3: tencent proxy"`
-	TargetDBFilterList string `long:"targetdbfilterlist" default:"-1" description:"db white list that need to be compared, -1 means fetch all, \"0;5;15\" means fetch db 0, 5, and 15"`
+	TargetDBFilterList string `long:"targetdbfilterlist" default:"-1" description:"db white list that need to be compared, -1 means fetch all, \"0;5;15\" means fetch db 0, 5, and 15, \"0-15\" means fetch db 0 to 15"`
+	TargetSentinel     string `long:"targetsentinel" value-name:"MASTER-NAME" description:"the master name monitored by sentinel. When given, the target address is the sentinel list split by semicolon(;) and the current master is resolved from sentinel on every connection. Only used in targetdbtype 0"`
+	TargetNoSelect     bool   `long:"targetnoselect" description:"don't send SELECT to the target, e.g., twemproxy or codis proxy rejecting it. The source dbs other than 0 should be mapped to 0 by dbmapping. Not used in targetdbtype 1"`
+	TargetReadOnly     bool   `long:"targetreadonly" description:"send READONLY so the reads can be served by the replica. For the cluster, the commands with the key are sent to the first replica of the slot by CLUSTER SLOTS on the node connections sending READONLY"`
+	FanOutTarget       string `long:"fanouttarget" value-name:"TARGET" default:"" description:"more targets replicated from the same source, split by '|', e.g., '10.1.1.2:6379|10.1.1.3:6379'. Each of them uses the same db type, password and the other target options as --target. The source is scanned once and the value is fetched once for all the targets in the first round. The conflicts of the Nth target in the list are stored in the result db and result file suffixed by '.targetN'. Not supported with targetsentinel, dbparallel, checkpoint, dryrun or comparemode 6"`
+	KeyMapFile         string `long:"keymapfile" value-name:"FILE" default:"" description:"map the source key to the target key by the file, one mapping per line. The line 'SOURCE<TAB>TARGET' maps the source key to the target key, the names encoded as 'hex:...' are decoded. The other lines are the regex rules in the form of keyhashtag, e.g., '^user:(\\d+)$=>u:{$1}', tried in order after the exact pairs. The empty lines and the lines beginning with '#' are skipped. The mapped key is used on the target as it is, and the unmapped one is rewritten by keyrewrite and keyhashtag, or used as it is. Only the exact pairs are supported in comparemode 6, which maps the keys scanned from the target back to the source"`
+	KeyRewrite         string `long:"keyrewrite" value-name:"RULE" default:"" description:"rewrite the prefix of the key name before fetching from the target, e.g., 'app:=>prod:app:' means the source key 'app:1' is compared with the target key 'prod:app:1'. Multiple rules are split by '|' and the first matching one is used. The conflict is reported with the source key name"`
+	ValueCommand       string `long:"valuecommand" value-name:"RULE" default:"" description:"fetch the value of the module key, e.g., RedisJSON or RedisBloom, by the given command and compare the replies byte by byte, e.g., 'json:*=>JSON.GET {key} .|bf:*=>BF.DEBUG {key}'. Multiple rules are split by '|', the first one whose pattern matches the key name is used and {key} is replaced by the key name. The module keys not matching any rule aren't supported"`
+	SourceTransform    string `long:"sourcetransform" value-name:"RULE" default:"" description:"normalize the value fetched from the source before comparison, e.g., 'pkt:*=>swap4' reverses the byte order of every 4 bytes of the keys matching 'pkt:*'. The built-in transforms are swap2, swap4 and swap8, more can be registered by the library. Multiple rules are split by '|' and the first matching one is used. Applied to the string value, hash field value and list element fetched whole. Only used in comparemode 1 and 4"`
+	TargetTransform    string `long:"targettransform" value-name:"RULE" default:"" description:"the same as sourcetransform but for the value fetched from the target"`
+	DBMapping          string `long:"dbmapping" value-name:"MAPPING" default:"" description:"compare the source db with a different target db, split by semicolon(;), e.g., \"0:3;1:4\" means compare source db 0 with target db 3 and source db 1 with target db 4. The db not in the mapping is compared with the same db on the target"`
+	KeyFile            string `long:"keyfile" value-name:"FILE" default:"" description:"only compare the keys in the file instead of scanning the source, one key per line. The line 'db<TAB>key' gives the db of the key, otherwise the key is in db 0. The keys missing on the source are reported as lack_source when existing on the target, and logged when missing on both sides. Not supported with checkpoint or fanouttarget"`
 	ResultDBFile       string `short:"d" long:"db" value-name:"Sqlite3-DB-FILE" default:"result.db" description:"sqlite3 db file for store result. If exist, it will be removed and a new file is created."`
 	ResultFile         string `long:"result" value-name:"FILE" description:"store all diff result into the file, format is 'db\tdiff-type\tkey\tfield'"`
+	UnverifiedFile     string `long:"unverified" value-name:"FILE" description:"store the keys left unverified since the network error lasts after all the retries into the file, format is 'db\tkey' which can be given by keyfile"`
+	UnverifiedLimit    int64  `long:"unverifiedthreshold" value-name:"COUNT" default:"0" description:"exit with 5 when more keys than the given count are left unverified since the network error lasts after all the retries"`
+	StrictVersion      bool   `long:"strictversion" description:"exit when the versions of the source and target are incompatible with the comparemode, e.g., comparemode 8 across major versions. By default it's only warned. The version, maxmemory-policy and cluster_enabled of both sides are logged by INFO before comparing anyway"`
+	StrictDB           bool   `long:"strictdb" description:"exit when any db fails to be connected on the source or target, e.g., SELECT is rejected since the db is beyond the databases of the target. By default the db is skipped and reported as unverified, the other dbs are still compared and it exits with 5"`
+	EncodeKey          bool   `long:"encodekey" description:"always write the key and field names as 'hex:' followed by the hex string in the log and result. Otherwise only the names which aren't printable utf8, e.g., binary or containing the tab and newline, are encoded. The encoded key can be given in the keyfile"`
+	ResultMaxSize      int64  `long:"resultmaxsize" value-name:"MB" default:"0" description:"rotate the result file when it reaches the given size, the rotated files are renamed to FILE.1, FILE.2... from the newest, and every csv file begins with the header. The file is rotated at the end of the line, so the record isn't split. 0 means never rotate"`
+	ResultMaxFiles     int    `long:"resultmaxfiles" value-name:"COUNT" default:"5" description:"the count of the rotated result files kept besides the current one, the older ones are removed"`
+	ResultFormat       string `long:"resultformat" value-name:"FORMAT" default:"text" description:"format of the result file, valid value text/json/csv. 'json' writes one json object per conflict key per line and a summary object in the last line. 'csv' writes the columns db,key,type,conflict_type,source_len,target_len,detail with a header line, one line per conflict field"`
 	CompareTimes       string `long:"comparetimes" value-name:"COUNT" default:"3" description:"Total compare count, at least 1. In the first round, all keys will be compared. The subsequent rounds of the comparison will be done on the previous results."`
-	CompareMode        int    `short:"m" long:"comparemode" default:"2" description:"compare mode, 1: compare full value, 2: only compare value length, 3: only compare keys outline, 4: compare full value, but only compare value length when meets big key"`
+	CompareMode        int    `short:"m" long:"comparemode" default:"2" description:"compare mode, 1: compare full value, 2: only compare value length, 3: only compare keys outline, 4: compare full value, but only compare value length when meets big key, 5: compare the digest(DEBUG DIGEST-VALUE) of the value, fallback to compare full value when the debug command isn't available, 6: only compare the existence of keys, the target is also scanned in the first round to find the keys only on the target, 7: compare the digest of the value computed by the lua script on the server, the big keys are compared as comparemode 1, fallback to compare full value when scripting is disabled, 8: compare the serialized value(DUMP) without the footer of rdb version and crc, the keys whose serialized value differs are confirmed as comparemode 1 since it depends on the encoding except the module keys, fallback to compare full value when the rdb versions differ, 9: only compare the absolute expire time(PEXPIRETIME) of keys within ttltolerance, PTTL is used on the side older than redis 7.0"`
 	Id                 string `long:"id" default:"unknown" description:"used in metric, run id, useless for open source"`
 	JobId              string `long:"jobid" default:"unknown" description:"used in metric, job id, useless for open source"`
 	TaskId             string `long:"taskid" default:"unknown" description:"used in metric, task id, useless for open source"`
 	Qps                int    `short:"q" long:"qps" default:"15000" description:"max batch qps limit: e.g., if qps is 10, full-check fetches 10 * $batch keys every second"`
-	Interval           int    `long:"interval" value-name:"Second" default:"5" description:"The time interval for each round of comparison(Second)"`
+	Interval           int    `long:"interval" value-name:"Second" default:"5" description:"The time interval for each round of comparison(Second). The conflicting keys of the previous round are fetched again after at least this delay, so the keys being synchronized by an active replication aren't reported, set a longer interval when the replication lag is large"`
 	BatchCount         string `long:"batchcount" value-name:"COUNT" default:"256" description:"the count of key/field per batch compare, valid value [1, 10000]"`
+	ScanCount          int    `long:"scancount" value-name:"COUNT" default:"0" description:"the COUNT hint of the SCAN enumerating the keys of every db and cluster node, 0 means use batchcount. The keys are never enumerated by KEYS, which blocks the server"`
+	DedupWindow        int    `long:"dedupwindow" value-name:"COUNT" default:"65536" description:"SCAN may return a key more than once, e.g., during rehashing, so the key returned again within the given count of the most recently scanned keys of the same node is skipped. 0 means don't deduplicate"`
+	DedupFilter        int64  `long:"dedupfilter" value-name:"MB" default:"0" description:"remember all the keys scanned of every db in a bloom filter of the given memory, so the duplicate returned long after is skipped as well, e.g., on the instance resizing. The key never scanned may be skipped by the false positive. The memory of one filter is used per db compared concurrently. 0 means disable"`
+	DedupFalsePositive string `long:"dedupfalsepositive" value-name:"PERCENT" default:"0.01" description:"the false positive rate of dedupfilter, e.g., 0.01 means 0.01% of the keys never scanned are skipped. It's exceeded and warned when more keys are scanned than the capacity of the memory"`
+	HscanCount         int    `long:"hscancount" value-name:"COUNT" default:"0" description:"the COUNT hint of hscan when fetching the big hash, 0 means use batchcount"`
+	SscanCount         int    `long:"sscancount" value-name:"COUNT" default:"0" description:"the COUNT hint of sscan when fetching the big set, 0 means use batchcount"`
+	ZscanCount         int    `long:"zscancount" value-name:"COUNT" default:"0" description:"the COUNT hint of zscan when fetching the big zset, 0 means use batchcount"`
+	AdaptiveScan       bool   `long:"adaptivescan" description:"tune the COUNT of hscan/sscan/zscan fetching the big hash/set/zset page by page, starting from hscancount/sscancount/zscancount: halve it when the page takes more than 10ms or 1MB, and double it when the page takes less than a quarter of both. The COUNT is kept in [10, 10000]"`
+	LrangeCount        int    `long:"lrangecount" value-name:"COUNT" default:"0" description:"the lists longer than the given count are compared by LRANGE in windows of this size instead of fetching the whole list, and the comparison stops at the first differing index. 0 means only the big lists are compared in windows of batchcount*10"`
+	StringWindow       int64  `long:"stringwindow" value-name:"BYTES" default:"0" description:"the strings longer than the given bytes are compared by GETRANGE in windows of this size instead of fetching the whole value, a few windows are fetched in one pipeline and the comparison stops at the first differing window, which is reported as the field 'start-end'. Not used with comparehll, sourcetransform or targettransform. 0 means disable"`
 	Parallel           int    `long:"parallel" value-name:"COUNT" default:"5" description:"concurrent goroutine number for comparison, valid value [1, 100]"`
+	DbParallel         int    `long:"dbparallel" value-name:"COUNT" default:"1" description:"the number of logical dbs compared concurrently, valid value [1, 16]. The qps limit is shared by all dbs"`
+	PoolMaxIdle        int    `long:"poolmaxidle" value-name:"COUNT" default:"0" description:"max idle connections in the pool of each host and db, 0 means disable the connection pool. Useless for cluster"`
+	PoolMaxActive      int    `long:"poolmaxactive" value-name:"COUNT" default:"0" description:"max active connections in the pool of each host and db, 0 means no limit"`
+	PoolIdleTimeout    int    `long:"poolidletimeout" value-name:"Second" default:"300" description:"close the connection after remaining idle for this duration in the pool, 0 means never close"`
+	PoolKeepAlive      int    `long:"poolkeepalive" value-name:"Second" default:"0" description:"send PING to the connections remaining idle in the pool for this duration, checked every duration, so they aren't closed by the server with a low timeout and reconnected with the penalty of one second. 0 means disable. Only used when poolmaxidle > 0"`
+	PoolWarmUp         bool   `long:"poolwarmup" description:"establish the connections of the pool concurrently before comparing every db instead of connecting lazily on the first commands, and exit if any of them fails to connect or auth. Only used when poolmaxidle > 0"`
+	DiffFieldLimit     int    `long:"difffieldlimit" value-name:"COUNT" default:"10" description:"log at most the given count of the differing fields of the conflict hash/set/zset, e.g., 'key[k] conflict fields: f1(value), f2(lack_target)'. All fields are stored in the result db. 0 means don't log"`
+	ConnectTimeout     int    `long:"connecttimeout" value-name:"MILLISECOND" default:"0" description:"timeout of connecting to the redis, 0 means no timeout"`
+	CommandTimeout     int    `long:"commandtimeout" value-name:"MILLISECOND" default:"0" description:"timeout of reading and writing the command, should be long enough for fetching the big value, e.g., hgetall on a big hash. 0 means no timeout"`
+	Bandwidth          int64  `long:"bandwidth" value-name:"BYTES" default:"0" description:"max bytes per second of the replies from the source and target in total, e.g., 10485760 for 10MB/s. The big value is fetched at once and the following commands wait, so both qps and bandwidth are respected. 0 means no limit"`
+	PipelineBatch      int    `long:"pipelinebatch" value-name:"COUNT" default:"0" description:"max commands sent in one pipeline, the larger pipeline is sent and received in chunks to avoid hitting the client output buffer limit of the server. 0 means no limit"`
+	FailThreshold      int64  `long:"failthreshold" value-name:"COUNT" default:"0" description:"exit with 1 when more keys than the given count conflict in the last round, e.g., for CI gating. The exit code is 0 when not more keys conflict, 2 on the invalid option, connection failure or other errors, 3 when stopped by the signal, 4 when stopped by maxduration and 5 when more keys than unverifiedthreshold are left unverified"`
 	LogFile            string `long:"log" value-name:"FILE" description:"log file, if not specified, log is put to console"`
 	LogLevel           string `long:"loglevel" value-name:"LEVEL" description:"log level: 'debug', 'info', 'warn', 'error', default is 'info'"`
+	LatencyHistogram   bool   `long:"latencyhistogram" description:"record the latency of every command and pipeline sent to the source and target, the p50/p95/p99 by side and key type are logged at the end and added to the json summary"`
+	SizeHistogram      bool   `long:"sizehistogram" description:"fetch the length(element count, or bytes of the string) and the memory usage(MEMORY USAGE) of the source keys scanned in the first round, the distribution by key type and the largest key are logged at the end and added to the json summary. It costs 2 more pipelines on the source for every batch"`
+	LogFormat          string `long:"logformat" value-name:"FORMAT" default:"text" description:"log format, valid value text/json. 'json' writes one json object per log line with the fields time, level, file, msg and the context like db, key and retry_count"`
 	MetricPrint        bool   `long:"metric" value-name:"BOOL" description:"print metric in log"`
+	MetricPort         int    `long:"metricport" value-name:"PORT" default:"0" description:"port of the http server which exposes prometheus metrics on '/metrics', 0 means disable"`
+	StatusSocket       string `long:"statussocket" value-name:"FILE" description:"path of the unix socket serving the status of the run in json on '/status', e.g., the current db, keys scanned, conflicts, throughput and the estimated time to finish the first round. The prometheus metrics are served on '/metrics' as well. '/status' is also served by the metric server of metricport"`
 	BigKeyThreshold    int64  `long:"bigkeythreshold" value-name:"COUNT" default:"16384"`
 	FilterList         string `short:"f" long:"filterlist" value-name:"FILTER" default:"" description:"if the filter list isn't empty, all elements in list will be synced. The input should be split by '|'. The end of the string is followed by a * to indicate a prefix match, otherwise it is a full match. e.g.: 'abc*|efg|m*' matches 'abc', 'abc1', 'efg', 'm', 'mxyz', but 'efgh', 'p' aren't'"`
+	Match              string `long:"match" value-name:"PATTERN" default:"" description:"only compare the keys that match the glob-style pattern, e.g., 'session:*'. Multiple patterns are split by '|' and the key that matches any one of them is compared"`
+	SampleRate         string `long:"samplerate" value-name:"PERCENT" default:"100" description:"only compare the given percent of keys in the first round, e.g., 1 means 1%. The keys are selected by the hash of the key name, so the sample is reproducible across runs. The sample size and the extrapolated conflict count are reported in the end"`
+	ScanType           string `long:"scantype" value-name:"TYPE" default:"" description:"only compare the keys of the given types, split by semicolon(;), e.g., 'hash;zset'. Valid value: string/hash/list/set/zset/stream"`
+	MaxDuration        int64  `long:"maxduration" value-name:"Second" default:"0" description:"stop after the given seconds, e.g., in a fixed maintenance window, the conflicts found so far are flushed and the partial result is reported with the percent of the keyspace scanned. Exit with 4 when stopped by it, 0 means no limit"`
+	MaxIdleTime        int64  `long:"maxidletime" value-name:"Second" default:"0" description:"only compare the keys whose idle time(OBJECT IDLETIME) on the source isn't longer than this value in the first round, 0 means compare all keys. It fails when the maxmemory-policy of the source is lfu since the idle time isn't tracked"`
+	TargetStaleness    int64  `long:"targetstaleness" value-name:"MILLISECOND" default:"0" description:"the target is a replica lagging behind the source. The conflict keys modified on the source within this duration, by OBJECT IDLETIME, are verified again once the duration has passed since the modification instead of being reported at once, and the replication lag measured by INFO replication before every round is added to the json summary. The idle time is unknown for the lfu policy, so all the conflict keys are verified again. 0 means disable"`
+	CompareTTL         bool   `long:"comparettl" description:"compare the ttl of the keys whose value is equal"`
+	ExpireWindow       int64  `long:"expirewindow" value-name:"MILLISECOND" default:"0" description:"the key missing on one side may have expired on the other side but not been deleted lazily yet. The remaining ttl(PTTL) is fetched on the side holding the key, which also deletes the expired key, and the key expiring within this duration is regarded as missing on both sides instead of 'lack_target' or 'lack_source'. The keys only on the target are checked as well. 0 means only the key whose ttl(TTL) on the source is 0 is regarded as expired"`
+	TTLTolerance       int64  `long:"ttltolerance" value-name:"MILLISECOND" default:"5000" description:"max difference of the remaining ttl between source and target when comparettl is enabled, the ttl of both sides is aligned to the same instant by the time it is fetched. Keys which are persistent on one side but volatile on the other are always reported. Also the max difference of the absolute expire time in comparemode 9, which should cover the clock difference of the servers"`
+	RetryCount         int    `long:"retrycount" value-name:"COUNT" default:"20" description:"max attempts of the command on the network error"`
+	RetryInterval      int    `long:"retryinterval" value-name:"MILLISECOND" default:"1000" description:"the wait before reconnecting after the network error, randomized in [1/2, 3/2) of it"`
+	RetryBackoff       string `long:"retrybackoff" value-name:"STRATEGY" default:"constant" description:"the backoff strategy of the retries on the network error, valid value constant/exponential. 'constant' waits retryinterval every time, 'exponential' doubles the wait on every retry of the same command up to retrymaxinterval"`
+	RetryMaxInterval   int    `long:"retrymaxinterval" value-name:"MILLISECOND" default:"30000" description:"the cap of the wait of the exponential backoff, 0 means no cap"`
+	CompareEncoding    bool   `long:"compareencoding" description:"compare the object encoding of the keys whose value is equal, the difference is reported as 'encoding' conflict type instead of 'value'"`
+	CompareDigest      bool   `long:"comparedigest" description:"compare the digest(DEBUG DIGEST-VALUE) of the value first and only fetch the value when the digest differs. Only used in comparemode 1 and 4, the debug command should be enabled on both sides"`
+	CompareHll         bool   `long:"comparehll" description:"compare the strings in HyperLogLog format by the cardinality(PFCOUNT) instead of the raw bytes, source_len and target_len in the result are the cardinalities. Only used in comparemode 1 and 4"`
+	HllTolerance       int    `long:"hlltolerance" value-name:"PERCENT" default:"0" description:"max difference of the cardinality in percent of the larger one when comparehll is enabled"`
+	GeoMatch           string `long:"geomatch" value-name:"PATTERN" default:"" description:"the zsets matching the glob-style pattern are compared as geo keys: the members whose distance of coordinates(GEOPOS) doesn't exceed geotolerance are regarded as equal. Multiple patterns are split by '|'. Only used in comparemode 1 and 4"`
+	GeoTolerance       int    `long:"geotolerance" value-name:"METER" default:"1" description:"max distance in meters between the coordinates of the same geo member"`
+	ScoreEpsilon       string `long:"scoreepsilon" value-name:"EPSILON" default:"0" description:"the zset scores are parsed as float and regarded as equal when the difference doesn't exceed the given value, e.g., 0.000001. The member names are still compared exactly. Only used in comparemode 1 and 4"`
+	BitmapMatch        string `long:"bitmapmatch" value-name:"PATTERN" default:"" description:"the strings matching the glob-style pattern are compared as bitmap: regarded as equal when both the length and BITCOUNT are equal, otherwise the differing byte ranges are located by segment and reported as fields. Multiple patterns are split by '|'. Only used in comparemode 1 and 4"`
+	MaxValueSize       int64  `long:"maxvaluesize" value-name:"BYTES" default:"0" description:"the keys whose value exceeds the given bytes(strlen for string, MEMORY USAGE for others) on either side are compared incrementally(GETRANGE for string, SCAN for hash/set/zset, LRANGE for list) instead of fetching the whole value, or skipped when skiptoolarge is enabled. 0 means no limit. Only used in comparemode 1 and 4"`
+	MaxValueCount      int64  `long:"maxvaluecount" value-name:"COUNT" default:"0" description:"the same as maxvaluesize but limits the element count of hash/list/set/zset/stream, 0 means no limit"`
+	SkipTooLarge       bool   `long:"skiptoolarge" description:"skip the keys exceeding maxvaluesize or maxvaluecount and record them as 'skipped-too-large' conflict type"`
+	ShallowTooLarge    bool   `long:"shallowtoolarge" description:"compare the keys exceeding maxvaluesize or maxvaluecount only by the length and the encoding(OBJECT ENCODING) without fetching the value. The key whose length and encoding are both equal is regarded as equal but counted as shallow in the result, since its value isn't compared"`
+	SetPrecheck        int64  `long:"setprecheck" value-name:"COUNT" default:"0" description:"precheck the sets larger than bigkeythreshold before the full member diff in the first round: the set is regarded as equal when the cardinality(SCARD) is equal on both sides and the given count of members sampled by SRANDMEMBER on the source all exist on the target, and counted as shallow in the result since not all members are compared. Otherwise the full diff runs. 0 means disable. Only used in comparemode 1"`
+	SetSpotCheck       int64  `long:"setspotcheck" value-name:"COUNT" default:"0" description:"the sets with more members than the given count on either side are compared without fetching the whole set: the members of each side are fetched by SSCAN page by page and checked by SISMEMBER on the other side, so the memory is bounded by setdiffsample regardless of the cardinality, e.g., the sets of tens of millions of members. 0 means disable. Only used in comparemode 1 and 4"`
+	SetDiffSample      int    `long:"setdiffsample" value-name:"COUNT" default:"100" description:"at most the given count of the members only on the source and of the members only on the target are recorded as the conflict fields of the set compared by setspotcheck, the total counts are logged. 0 means no limit, then the memory grows with the differing members"`
+	ListHeadDrift      int    `long:"listheaddrift" value-name:"COUNT" default:"0" description:"the lists are regarded as equal when they only differ in at most the given count of elements pushed or popped at the head, e.g., the queue consumed during the comparison. The conflict is reported with the first differing index when the difference is out of the drift window. 0 means disable. Only used in comparemode 1 and 4"`
+	ListTailDrift      int    `long:"listtaildrift" value-name:"COUNT" default:"0" description:"the same as listheaddrift but for the elements pushed or popped at the tail"`
+	MemoryRatio        int    `long:"memoryratio" value-name:"PERCENT" default:"0" description:"compare the memory usage(MEMORY USAGE) of the keys whose value is equal, report 'memory' conflict type when the difference exceeds the given percent of the smaller one, e.g., 50 means 50%. 0 means disable"`
+	EmptyAsMissing     bool   `long:"emptyasmissing" description:"regard the key existing but empty on one side, e.g., the empty string, as equal to the key missing on the other side. By default the key missing on the target is reported as 'lack_target' even if it's empty on the source, and the empty key on the target is reported as 'value' when it isn't empty on the source. Not used in comparemode 3 and 6 which only compare the existence"`
+	OneWay             bool   `long:"oneway" description:"regard the source as authoritative, the keys only on the target, i.e., 'lack_source', aren't reported as conflicts but only counted, e.g., the data existing on the target before the migration. The keys missing or differing on the target are still reported, including the extra fields or members of the keys existing on both sides"`
+	FreshRecheck       bool   `long:"freshrecheck" description:"verify the conflict keys again on new connections of both sides before recording them, only the persistent conflicts are reported, so the conflicts caused by the state of the connection, e.g., the replies desynchronized after an error, are ruled out"`
+	Checkpoint         string `long:"checkpoint" value-name:"FILE" description:"save the progress into the checkpoint file periodically, the file is removed after all finished"`
+	CheckpointInterval int    `long:"checkpointinterval" value-name:"Second" default:"10" description:"the interval of saving checkpoint"`
+	Resume             bool   `long:"resume" description:"resume from the checkpoint file, the result db and result file of the previous run are kept"`
+	DryRun             bool   `long:"dryrun" description:"only compare the key count of every db(INFO Keyspace) and every key type without fetching the value, print the result and exit with 1 when the count diverges"`
+	Key                string `long:"key" value-name:"KEY" description:"only compare the given key once without scanning, print the type, ttl, the value of both sides and the diff, and exit with 1 when it conflicts. The key can be encoded as 'hex:' followed by the hex string. Used to investigate one conflict key"`
+	KeyDB              int32  `long:"keydb" value-name:"DB" default:"0" description:"the source db of the key given by key"`
+	ConfigFile         string `long:"conf" value-name:"FILE" no-ini:"true" description:"load the options from the YAML(.yaml, .yml), TOML(.toml) or INI file by the extension, the key is the long option name, e.g., \"source: 10.1.1.1:6379\" in YAML, and the INI options are in the section [Application Options]. The options given by the command line override the ones in the file"`
 	SystemProfile      uint   `long:"systemprofile" value-name:"SYSTEM-PROFILE" default:"20445" description:"port that used to print golang inner head and stack message"`
 	Version            bool   `short:"v" long:"version"`
+
+	// repeatable so the regex can contain any splitter
+	KeyHashTag []string `long:"keyhashtag" value-name:"RULE" description:"replace the key name matching the regex with the template expanded by the submatches before fetching from the target, after keyrewrite, e.g., '^(user:\\d+):(.+)$=>{$1}:$2' means the source key 'user:1:profile' is compared with the target key '{user:1}:profile' which is routed by the hash tag in the cluster. The template replaces the whole name, so the regex should match the whole name. It can be given more than once and the first matching one is used. The conflict is reported with the source key name"`
+
+	// called on every new connection to fetch the password, e.g., the short-lived token, only used by the library
+	SourceCredential func() (string, error) `no-flag:"true" no-ini:"true"`
+	TargetCredential func() (string, error) `no-flag:"true" no-ini:"true"`
+
+	// the key name on the target, overrides keyrewrite and keyhashtag, only used by the library
+	TargetKeyTransform func(key []byte) []byte `no-flag:"true" no-ini:"true"`
 }
+
+var Opts Options
@@ -0,0 +1,211 @@
+package full_check
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"sync"
+
+	"full_check/common"
+)
+
+const (
+	CursorFinished = -1 // the scan of the physical db has finished
+
+	checkpointSqliteNode = "sqlite" // node name used when keys are fetched from the last round result
+)
+
+/*
+ * Checkpoint records the progress which has been verified and written into the result. The rows in
+ * the result db and result file beyond the recorded position are removed when resuming, so the
+ * conflicts won't be duplicated.
+ */
+type Checkpoint struct {
+	Times      int              `json:"times"`
+	Db         int32            `json:"db"`
+	FinishedDb []int32          `json:"finished_db"` // dbs have been finished in the current round
+	Cursor     map[string]int64 `json:"cursor"`      // physical db -> scan cursor, or last id in the last round result
+	KeyId      int64            `json:"key_id"`      // max id of the key table in the current round
+	FieldId    int64            `json:"field_id"`    // max id of the field table in the current round
+	FinalRowId int64            `json:"final_row_id"`
+	ResultSize int64            `json:"result_size"` // size of the result file
+}
+
+func (p *Checkpoint) IsDbFinished(db int32) bool {
+	for _, ele := range p.FinishedDb {
+		if ele == db {
+			return true
+		}
+	}
+	return false
+}
+
+func LoadCheckpoint(file string) (*Checkpoint, error) {
+	data, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+
+	cp := new(Checkpoint)
+	if err := json.Unmarshal(data, cp); err != nil {
+		return nil, fmt.Errorf("parse checkpoint file[%v] failed[%v]", file, err)
+	}
+	if cp.Cursor == nil {
+		cp.Cursor = make(map[string]int64)
+	}
+	return cp, nil
+}
+
+type pendingBatch struct {
+	cursor int64
+	done   bool
+}
+
+/*
+ * CheckpointManager tracks the batches sent to the verifiers. The cursor of one physical db only
+ * moves forward when all the batches before it have been verified.
+ */
+type CheckpointManager struct {
+	file string
+
+	lock     sync.Mutex
+	state    Checkpoint
+	pending  map[string][]*pendingBatch    // physical db -> batches in scan order
+	batchMap map[*common.Key]*pendingBatch // first key of the batch -> batch
+}
+
+func NewCheckpointManager(file string) *CheckpointManager {
+	return &CheckpointManager{
+		file: file,
+	}
+}
+
+// restore the state from the checkpoint loaded
+func (p *CheckpointManager) Restore(cp *Checkpoint) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	p.state = *cp
+	p.state.FinishedDb = append([]int32{}, cp.FinishedDb...)
+}
+
+// start tracking a new db, cursor is the position to resume from
+func (p *CheckpointManager) StartDB(times int, db int32, cursor map[string]int64) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if p.state.Times != times {
+		p.state.FinishedDb = nil
+	}
+	p.state.Times = times
+	p.state.Db = db
+	p.state.Cursor = make(map[string]int64)
+	for node, pos := range cursor {
+		p.state.Cursor[node] = pos
+	}
+	p.pending = make(map[string][]*pendingBatch)
+	p.batchMap = make(map[*common.Key]*pendingBatch)
+}
+
+// record the batch that will be sent, cursor is the position after this batch
+func (p *CheckpointManager) Add(node string, cursor int64, keyInfo []*common.Key) {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	batch := &pendingBatch{cursor: cursor}
+	if len(keyInfo) == 0 {
+		batch.done = true
+	} else {
+		p.batchMap[keyInfo[0]] = batch
+	}
+	p.pending[node] = append(p.pending[node], batch)
+}
+
+// mark the batch as verified
+func (p *CheckpointManager) Done(keyInfo []*common.Key) {
+	if len(keyInfo) == 0 {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	if batch, ok := p.batchMap[keyInfo[0]]; ok {
+		batch.done = true
+		delete(p.batchMap, keyInfo[0])
+	}
+}
+
+// move the cursor forward and return the copy of current state
+func (p *CheckpointManager) Snapshot() Checkpoint {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	for node, batches := range p.pending {
+		i := 0
+		for ; i < len(batches) && batches[i].done; i++ {
+			p.state.Cursor[node] = batches[i].cursor
+		}
+		p.pending[node] = batches[i:]
+	}
+
+	cp := p.state
+	cp.FinishedDb = append([]int32{}, p.state.FinishedDb...)
+	cp.Cursor = make(map[string]int64, len(p.state.Cursor))
+	for node, pos := range p.state.Cursor {
+		cp.Cursor[node] = pos
+	}
+	return cp
+}
+
+// mark the current db finished and return the copy of current state
+func (p *CheckpointManager) FinishDB() Checkpoint {
+	p.lock.Lock()
+	p.state.FinishedDb = append(p.state.FinishedDb, p.state.Db)
+	p.state.Cursor = make(map[string]int64)
+	p.pending = make(map[string][]*pendingBatch)
+	p.lock.Unlock()
+
+	return p.Snapshot()
+}
+
+// start a new round, the result position is reset because every round has its own result db
+func (p *CheckpointManager) NextRound(times int) Checkpoint {
+	p.lock.Lock()
+	p.state.Times = times
+	p.state.FinishedDb = nil
+	p.state.KeyId, p.state.FieldId, p.state.FinalRowId = 0, 0, 0
+	p.lock.Unlock()
+
+	return p.Snapshot()
+}
+
+// save the state into the file. write into a temporary file first to avoid broken file when crash
+func (p *CheckpointManager) Save(cp Checkpoint) {
+	data, err := json.Marshal(cp)
+	if err != nil {
+		common.Logger.Errorf("marshal checkpoint[%v] failed[%v]", cp, err)
+		return
+	}
+
+	tmpFile := p.file + ".tmp"
+	if err := ioutil.WriteFile(tmpFile, data, 0666); err != nil {
+		common.Logger.Errorf("write checkpoint file[%v] failed[%v]", tmpFile, err)
+		return
+	}
+	if err := os.Rename(tmpFile, p.file); err != nil {
+		common.Logger.Errorf("rename checkpoint file[%v] failed[%v]", tmpFile, err)
+		return
+	}
+
+	p.lock.Lock()
+	p.state.KeyId, p.state.FieldId, p.state.FinalRowId = cp.KeyId, cp.FieldId, cp.FinalRowId
+	p.state.ResultSize = cp.ResultSize
+	p.lock.Unlock()
+	common.Logger.Debugf("save checkpoint: %s", string(data))
+}
+
+func (p *CheckpointManager) Remove() {
+	os.Remove(p.file)
+}
@@ -0,0 +1,184 @@
+package full_check
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"full_check/client"
+	"full_check/common"
+
+	"github.com/garyburd/redigo/redis"
+	"github.com/jinzhu/copier"
+)
+
+// the key count from the keyspace
+const keyCountTotal = "total"
+
+/*
+ * Compare the key count of every logical db and every key type without fetching the value. The total
+ * count is from "info keyspace", the count of every key type is from scan and type which is skipped
+ * for proxy. Return false if the count diverges.
+ */
+func (p *FullCheck) DryRun() bool {
+	sourceCount := p.countKeys(p.SourceHost)
+	targetCount := p.countKeys(p.TargetHost)
+
+	// source db -> target db, the target db not mapped from any source db is listed alone
+	dbMap := make(map[int32]int32)
+	mappedTarget := make(map[int32]bool)
+	for db := range sourceCount {
+		dbMap[db] = p.TargetDB(db)
+		mappedTarget[p.TargetDB(db)] = true
+	}
+	for db := range targetCount {
+		if _, ok := dbMap[db]; !ok && !mappedTarget[db] {
+			dbMap[db] = db
+		}
+	}
+	dbList := make([]int, 0, len(dbMap))
+	for db := range dbMap {
+		dbList = append(dbList, int(db))
+	}
+	sort.Ints(dbList)
+
+	countTypes := []string{keyCountTotal}
+	for i := common.KeyTypeIndex(0); i < common.EndKeyTypeIndex; i++ {
+		countTypes = append(countTypes, i.String())
+	}
+
+	var buf bytes.Buffer
+	equal := true
+	w := tabwriter.NewWriter(&buf, 0, 8, 2, ' ', 0)
+	fmt.Fprintln(w, "db\ttype\tsource\ttarget\t")
+	for _, db := range dbList {
+		targetDb := dbMap[int32(db)]
+		dbName := strconv.Itoa(db)
+		if targetDb != int32(db) {
+			dbName = fmt.Sprintf("%d->%d", db, targetDb)
+		}
+		for _, tp := range countTypes {
+			source, target := sourceCount[int32(db)][tp], targetCount[targetDb][tp]
+			if source == 0 && target == 0 && tp != keyCountTotal {
+				continue
+			}
+			diff := ""
+			if source != target {
+				diff = "diff"
+				equal = false
+			}
+			fmt.Fprintf(w, "%s\t%s\t%d\t%d\t%s\n", dbName, tp, source, target, diff)
+		}
+	}
+	w.Flush()
+
+	fmt.Print(buf.String())
+	common.Logger.Infof("dry run finished, key count equal: %v", equal)
+	return equal
+}
+
+// logical db -> key type -> count
+func (p *FullCheck) countKeys(host client.RedisHost) map[int32]map[string]int64 {
+	nodeList := []client.RedisHost{host}
+	if host.IsCluster() {
+		// count on every master
+		nodeList = make([]client.RedisHost, 0, len(host.Addr))
+		for _, addr := range host.Addr {
+			var singleHost client.RedisHost
+			copier.Copy(&singleHost, &host)
+			singleHost.Addr = []string{addr}
+			singleHost.DBType = common.TypeDB
+			nodeList = append(nodeList, singleHost)
+		}
+	}
+
+	result := make(map[int32]map[string]int64)
+	for _, node := range nodeList {
+		c, err := client.NewRedisClient(node, 0)
+		if err != nil {
+			panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]", node, 0, err))
+		}
+		keyspaceContent, err := c.Do("info", "Keyspace")
+		if err != nil {
+			panic(common.Logger.Errorf("get keyspace of %v failed[%v]", c, err))
+		}
+		keyspace, err := common.ParseKeyspace(keyspaceContent.([]byte))
+		if err != nil {
+			panic(common.Logger.Errorf("parse keyspace of %v failed[%v]", c, err))
+		}
+		c.Close()
+
+		for db, keyNum := range keyspace {
+			if _, ok := node.DBFilterList[int(db)]; len(node.DBFilterList) != 0 && !ok {
+				continue
+			}
+			if _, ok := result[db]; !ok {
+				result[db] = make(map[string]int64)
+			}
+			result[db][keyCountTotal] += keyNum
+
+			if node.DBType == common.TypeDB {
+				p.countKeyType(node, db, result[db])
+			}
+		}
+	}
+	if host.DBType != common.TypeDB && host.DBType != common.TypeCluster {
+		common.Logger.Warnf("%v: count of every key type isn't supported for proxy", host)
+	}
+	return result
+}
+
+func (p *FullCheck) countKeyType(host client.RedisHost, db int32, count map[string]int64) {
+	c, err := client.NewRedisClient(host, db)
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]", host, db, err))
+	}
+	defer c.Close()
+
+	scanCount := p.scanCount()
+	dedup := common.NewDedupWindow(p.DedupWindow)
+	cursor := int64(0)
+	for {
+		reply, err := c.Do("scan", cursor, "count", scanCount)
+		if err != nil {
+			panic(common.Logger.Critical(err))
+		}
+		replyList, ok := reply.([]interface{})
+		if ok == false || len(replyList) != 2 {
+			panic(common.Logger.Criticalf("scan %d count %d failed, result: %+v", cursor, scanCount, reply))
+		}
+		if cursor, err = redis.Int64(replyList[0], nil); err != nil {
+			panic(common.Logger.Criticalf("scan %d count %d failed[%v], result: %+v", cursor, scanCount, err, reply))
+		}
+		keyList, err := redis.ByteSlices(replyList[1], nil)
+		if err != nil {
+			panic(common.Logger.Criticalf("scan %d count %d failed[%v], result: %+v", cursor, scanCount, err, reply))
+		}
+
+		// the key returned again by scan is only counted once
+		keyInfo := make([]*common.Key, 0, len(keyList))
+		for _, key := range keyList {
+			if dedup.Seen(key) == false {
+				keyInfo = append(keyInfo, &common.Key{Key: key})
+			}
+		}
+		if len(keyInfo) != 0 {
+			keyType, err := c.PipeTypeCommand(keyInfo)
+			if err != nil {
+				panic(common.Logger.Critical(err))
+			}
+			for _, tp := range keyType {
+				// key has been deleted
+				if tp != common.NoneKeyType.Name {
+					count[tp]++
+				}
+			}
+		}
+
+		if cursor == 0 {
+			break
+		}
+	}
+}
@@ -1,15 +1,19 @@
 package full_check
 
 import (
+	"bufio"
 	"bytes"
 	"context"
 	"database/sql"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"os"
 	_ "path"
+	"sort"
 	"strconv"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"full_check/common"
@@ -28,6 +32,17 @@ const (
 	ValueLengthOutline   = 2
 	KeyOutline           = 3
 	FullValueWithOutline = 4
+	DigestValue          = 5
+	KeyExistence         = 6
+	ScriptDigest         = 7
+	DumpPayload          = 8
+	ExpireTime           = 9
+)
+
+const (
+	conflictKeyBuffer     = 10240       // the verifiers won't be blocked until so many conflict keys are pending
+	conflictFlushInterval = time.Second // interval of committing the conflict keys and flushing the result file
+	resultBufferSize      = 64 * 1024
 )
 
 type FullCheck struct {
@@ -36,6 +51,7 @@ type FullCheck struct {
 	stat                 metric.Stat
 	currentDB            int32
 	times                int
+	roundLock            sync.RWMutex // the metric server reads times and currentDB while they're changed
 	db                   [100]*sql.DB
 	sourcePhysicalDBList []string
 	sourceLogicalDBMap   map[int32]int64
@@ -44,14 +60,74 @@ type FullCheck struct {
 	totalKeyConflict   int64
 	totalFieldConflict int64
 
-	verifier checker.IVerifier
+	startTime      time.Time
+	totalScanKeys  int64                       // keys scanned in the first round
+	targetOnly     int64                       // keys only on the target, counted instead of written when oneway is set
+	resultConflict map[string]int64            // conflict keys of each conflict type in the last round
+	conflictByType map[string]map[string]int64 // key type -> conflict type -> conflict keys in the last round
+	resultByDB     map[int32]*DBResult         // db -> keys scanned and conflict keys of the db
+
+	checkpoint   *CheckpointManager
+	resume       *Checkpoint      // checkpoint loaded when resuming
+	resumeCursor map[string]int64 // cursor of the current db to resume from
+
+	checkType CheckType
+	qos       *common.Qos // shared by all the dbs compared concurrently
+	writeLock *sync.Mutex // sqlite only allows one write transaction at the same time
+	verifier  checker.IVerifier
+
+	resultFile  string               // the result file of this target
+	result      *common.RotateWriter // writes the result file, shared by the dbs compared concurrently
+	unverified  *UnverifiedRecorder
+	sizes       *SizeRecorder    // nil unless sizehistogram is set, shared by the dbs compared concurrently
+	skippedDB   map[int32]error  // the dbs failing to be connected, shared by the dbs compared concurrently
+	skipLock    *sync.Mutex
+	workers     map[*FullCheck]struct{} // the workers comparing the dbs concurrently, read by the metric server
+	workerLock  sync.Mutex
+	targetLag   *ReplicaLag      // measured before every round when targetstaleness is set
+	sourceSnapshot *SourceSnapshot // recorded before the first round and after the last one when sourcereplica is set
+	fanOut      []*FullCheck     // one per fan-out target, verifies the keys scanned by p in the first round
+	isFanOut    bool             // p is one of the fan-out targets
+	conflictKey chan *common.Key // the conflict keys of the fan-out target in the first round
+
+	stop     chan struct{} // closed when stopping, shared by the dbs compared concurrently
+	stopOnce *sync.Once
+	failure  *failure // the first error of the goroutines, shared by the dbs compared concurrently
+	expired  int32 // set when stopped by maxduration
+	ctx      context.Context // the redis commands are aborted when it's done
+
+	// called with every conflict key of the last round, concurrently when dbparallel > 1. The
+	// conflicts of the fan-out targets aren't included
+	ConflictHandler func(db int32, oneKeyInfo *common.Key)
 }
 
+var ErrStopped = errors.New("stopped before finished")
+
+var ErrMaxDuration = errors.New("stopped by max duration before finished")
+
 func NewFullCheck(f checker.FullCheckParameter, checktype CheckType) *FullCheck {
 	var verifier checker.IVerifier
 
 	fullcheck := &FullCheck{
 		FullCheckParameter: f,
+		resultConflict:     make(map[string]int64),
+		conflictByType:     make(map[string]map[string]int64),
+		resultByDB:         make(map[int32]*DBResult),
+		checkType:          checktype,
+		writeLock:          new(sync.Mutex),
+		stop:               make(chan struct{}),
+		stopOnce:           new(sync.Once),
+		failure:            new(failure),
+		ctx:                context.Background(),
+		resultFile:         conf.Opts.ResultFile,
+		unverified:         NewUnverifiedRecorder(conf.Opts.UnverifiedFile),
+		result:             newResultWriter(conf.Opts.ResultFile),
+		skippedDB:          make(map[int32]error),
+		skipLock:           new(sync.Mutex),
+	}
+	fullcheck.stat.IgnoreLackSource = f.OneWay
+	if conf.Opts.SizeHistogram {
+		fullcheck.sizes = NewSizeRecorder()
 	}
 
 	switch checktype {
@@ -63,14 +139,48 @@ func NewFullCheck(f checker.FullCheckParameter, checktype CheckType) *FullCheck
 		verifier = checker.NewFullValueVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter, false)
 	case FullValueWithOutline:
 		verifier = checker.NewFullValueVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter, true)
+	case DigestValue:
+		verifier = checker.NewDigestVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter)
+	case KeyExistence:
+		verifier = checker.NewKeyExistenceVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter)
+	case ScriptDigest:
+		verifier = checker.NewScriptDigestVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter)
+	case DumpPayload:
+		verifier = checker.NewDumpVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter)
+	case ExpireTime:
+		verifier = checker.NewExpireVerifier(&fullcheck.stat, &fullcheck.FullCheckParameter)
 	default:
 		panic(fmt.Sprintf("no such check type : %d", checktype))
 	}
 
 	fullcheck.verifier = verifier
+
+	for i, host := range f.FanOutHosts {
+		param := f
+		param.TargetHost = host
+		param.TargetHost.Role = fmt.Sprintf("target%d", i+1)
+		param.FanOutHosts = nil
+		param.ResultDBFile = fanOutFile(f.ResultDBFile, i+1)
+		lane := NewFullCheck(param, checktype)
+		lane.isFanOut = true
+		lane.sizes = nil // the same source keys as p
+		if len(lane.resultFile) != 0 {
+			lane.resultFile = fanOutFile(lane.resultFile, i+1)
+			lane.result = newResultWriter(lane.resultFile)
+		}
+		if len(conf.Opts.UnverifiedFile) != 0 {
+			lane.unverified = NewUnverifiedRecorder(fanOutFile(conf.Opts.UnverifiedFile, i+1))
+		}
+		fullcheck.fanOut = append(fullcheck.fanOut, lane)
+	}
 	return fullcheck
 }
 
+// the result db and result file of the Nth fan-out target
+func fanOutFile(name string, n int) string {
+	return fmt.Sprintf("%s.target%d", name, n)
+}
+
 func (p *FullCheck) PrintStat(finished bool) {
 	var buf bytes.Buffer
 
@@ -210,10 +320,65 @@ func (p *FullCheck) IncrScanStat(a int) {
 
 func (p *FullCheck) Start() {
 	var err error
+	p.startTime = time.Now()
+
+	if p.MaxDuration > 0 {
+		deadline := time.AfterFunc(time.Duration(p.MaxDuration)*time.Second, func() {
+			common.Logger.Warnf("max duration %ds is reached, stopping...", p.MaxDuration)
+			atomic.StoreInt32(&p.expired, 1)
+			p.Stop()
+		})
+		defer deadline.Stop()
+	}
+
+	if conf.Opts.MetricPort != 0 {
+		p.StartMetricServer(conf.Opts.MetricPort)
+	}
+	if len(conf.Opts.StatusSocket) != 0 {
+		if listener := p.StartStatusSocket(conf.Opts.StatusSocket); listener != nil {
+			defer listener.Close()
+		}
+	}
+
+	// limit qps
+	p.qos = common.StartQoS(conf.Opts.Qps)
+	defer p.qos.Close()
+	defer client.ClosePools()
+	defer p.unverified.Close()
+	defer p.result.Close()
+	for _, lane := range p.fanOut {
+		defer lane.unverified.Close()
+		defer lane.result.Close()
+	}
+
+	if len(conf.Opts.Checkpoint) != 0 {
+		p.checkpoint = NewCheckpointManager(conf.Opts.Checkpoint)
+		if conf.Opts.Resume {
+			if p.resume, err = LoadCheckpoint(conf.Opts.Checkpoint); err == nil {
+				common.Logger.Infof("resume from checkpoint: %+v", *p.resume)
+				p.checkpoint.Restore(p.resume)
+			} else if os.IsNotExist(err) {
+				common.Logger.Infof("checkpoint file[%v] not exists, start from the beginning", conf.Opts.Checkpoint)
+				p.resume = nil
+				if len(p.resultFile) > 0 {
+					os.Remove(p.resultFile)
+				}
+			} else {
+				panic(common.Logger.Critical(err))
+			}
+		}
+	}
+
+	// the result file of the previous run already has the header when resuming
+	if len(p.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatCsv && p.resume == nil {
+		writeCsvHeader(p.result)
+	}
 
 	for i := 1; i <= p.CompareCount; i++ {
-		// init sqlite db
-		os.Remove(p.ResultDBFile + "." + strconv.Itoa(i))
+		// init sqlite db, keep the result of the previous run when resuming
+		if p.resume == nil {
+			os.Remove(p.ResultDBFile + "." + strconv.Itoa(i))
+		}
 		p.db[i], err = sql.Open("sqlite3", p.ResultDBFile+"."+strconv.Itoa(i))
 		if err != nil {
 			panic(common.Logger.Critical(err))
@@ -221,13 +386,13 @@ func (p *FullCheck) Start() {
 		defer p.db[i].Close()
 	}
 
-	sourceClient, err := client.NewRedisClient(p.SourceHost, 0)
+	sourceClient, err := client.NewRedisClientContext(p.ctx, p.SourceHost, 0)
 	if err != nil {
 		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
 			p.SourceHost, 0, err))
 	}
 
-	p.sourceLogicalDBMap, p.sourcePhysicalDBList, err = sourceClient.FetchBaseInfo(conf.Opts.SourceDBType == 1)
+	p.sourceLogicalDBMap, p.sourcePhysicalDBList, err = sourceClient.FetchBaseInfo(p.SourceHost.IsCluster())
 	if err != nil {
 		panic(common.Logger.Critical(err))
 	}
@@ -236,6 +401,25 @@ func (p *FullCheck) Start() {
 		p.sourcePhysicalDBList)
 
 	sourceClient.Close()
+	// only the dbs in the key file are compared
+	if p.KeyList != nil {
+		p.sourceLogicalDBMap = make(map[int32]int64, len(p.KeyList))
+		for db, keys := range p.KeyList {
+			p.sourceLogicalDBMap[db] = int64(len(keys))
+		}
+	}
+	// the target without SELECT only serves db 0
+	if p.TargetHost.NoSelect {
+		for db := range p.sourceLogicalDBMap {
+			if p.TargetDB(db) != 0 {
+				panic(common.Logger.Errorf("source db[%v] can't be compared with the target without SELECT, "+
+					"map it to 0 by dbmapping", db))
+			}
+		}
+	}
+	p.CheckServer()
+	p.startFanOut()
+	defer p.closeFanOut()
 	for db, keyNum := range p.sourceLogicalDBMap {
 		if p.SourceHost.IsCluster() == true {
 			common.Logger.Infof("db=%d:keys=%d(inaccurate for type cluster)", db, keyNum)
@@ -244,87 +428,617 @@ func (p *FullCheck) Start() {
 		}
 	}
 
-	for p.times = 1; p.times <= p.CompareCount; p.times++ {
+	startTimes := 1
+	if p.resume != nil {
+		startTimes = p.resume.Times
+	}
+	if len(p.SourcePrimary) != 0 {
+		p.snapshotSource(true)
+	}
+	for p.setRound(startTimes, p.currentDB); p.times <= p.CompareCount; p.setRound(p.times+1, p.currentDB) {
 		p.CreateDbTable(p.times)
-		if p.times != 1 {
-			common.Logger.Infof("wait %d seconds before start", p.Interval)
-			time.Sleep(time.Second * time.Duration(p.Interval))
+		for _, lane := range p.fanOut {
+			lane.times = p.times
+			lane.CreateDbTable(p.times)
 		}
-		common.Logger.Infof("---------------- start %dth time compare", p.times)
-
-		for db := range p.sourceLogicalDBMap {
-			p.currentDB = db
-			p.stat.Reset(false)
-			// init stat timer
-			tickerStat := time.NewTicker(time.Second * common.StatRollFrequency)
-			ctxStat, cancelStat := context.WithCancel(context.Background()) // 主动cancel
-			go func(ctx context.Context) {
-				defer func() {
-					tickerStat.Stop()
-				}()
-
-				for range tickerStat.C {
-					select { // 判断是否结束
-					case <-ctx.Done():
-						return
-					default:
-					}
-					p.stat.Rotate()
-					p.PrintStat(false)
+		resumed := p.resume != nil && p.resume.Times == p.times
+		if resumed {
+			p.TruncateResult(p.resume)
+		} else {
+			if p.times != 1 {
+				common.Logger.Infof("wait %d seconds before start", p.Interval)
+				select {
+				case <-time.After(time.Second * time.Duration(p.Interval)):
+				case <-p.stop:
 				}
-			}(ctxStat)
-
-			common.Logger.Infof("start compare db %d", p.currentDB)
-			keys := make(chan []*common.Key, 1024)
-			conflictKey := make(chan *common.Key, 1024)
-			var wg, wg2 sync.WaitGroup
-			// start scan, get all keys
-			if p.times == 1 {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					p.ScanFromSourceRedis(keys)
-				}()
-			} else {
-				wg.Add(1)
-				go func() {
-					defer wg.Done()
-					p.ScanFromDB(keys)
-				}()
 			}
-
-			// start check
-			wg.Add(p.Parallel)
-			for i := 0; i < p.Parallel; i++ {
-				go func() {
-					defer wg.Done()
-					p.VerifyAllKeyInfo(keys, conflictKey)
-				}()
+			if p.IsStopped() {
+				break
+			}
+			if p.checkpoint != nil {
+				p.SaveCheckpoint(p.checkpoint.NextRound(p.times))
 			}
+		}
+		common.Logger.Infof("---------------- start %dth time compare", p.times)
+		if p.TargetStaleness > 0 {
+			p.measureLag()
+			for _, lane := range p.fanOut {
+				lane.measureLag()
+			}
+		}
 
-			// start write conflictKey
-			wg2.Add(1)
-			go func() {
-				defer wg2.Done()
-				p.WriteConflictKey(conflictKey)
-			}()
+		if p.DbParallel > 1 {
+			p.CompareDBConcurrently()
+		} else {
+			for db := range p.sourceLogicalDBMap {
+				if p.CheckDB(db) == false {
+					continue
+				}
+				p.resumeCursor = nil
+				if resumed {
+					if p.resume.IsDbFinished(db) {
+						common.Logger.Infof("skip db %d which has been finished before resuming", db)
+						continue
+					}
+					if db == p.resume.Db {
+						p.resumeCursor = p.resume.Cursor
+					}
+				}
+				if p.checkpoint != nil {
+					p.checkpoint.StartDB(p.times, db, p.resumeCursor)
+				}
 
-			wg.Wait()
-			close(conflictKey)
-			wg2.Wait()
-			cancelStat() // stop stat goroutine
-			p.PrintStat(true)
-		} // for db, keyNum := range dbNums
+				p.CompareDB(db)
+				// the db isn't finished when stopped
+				if p.IsStopped() {
+					break
+				}
+				if p.checkpoint != nil {
+					p.SaveCheckpoint(p.checkpoint.FinishDB())
+				}
+			} // for db, keyNum := range dbNums
+		}
+		p.resume = nil
+		if p.IsStopped() {
+			break
+		}
 
 		// do not reset when run the final time
 		if p.times < p.CompareCount {
 			p.stat.Reset(true)
+			for _, lane := range p.fanOut {
+				lane.stat.Reset(true)
+			}
 		}
 	} // end for
+	// all the goroutines have exited, pass on their error in the goroutine of the caller
+	p.failure.raise()
 
 	p.stat.Reset(false)
+	for _, lane := range p.fanOut {
+		lane.stat.Reset(false)
+	}
+	if len(p.SourcePrimary) != 0 {
+		p.snapshotSource(false)
+	}
+	stopped := p.IsStopped()
+	if stopped && p.checkpoint != nil {
+		// keep the checkpoint to resume from, the summary written below is removed when resuming
+		p.SaveCheckpoint(p.checkpoint.Snapshot())
+	}
+	if len(p.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatJson {
+		p.writeJsonSummary()
+		for _, lane := range p.fanOut {
+			lane.writeJsonSummary()
+		}
+	}
+	if stopped {
+		common.Logger.Warnf("--------------- stopped! ----------------\nstopped in the %dth time compare, partial result: "+
+			"%d key(s) and %d field(s) conflict", p.times, p.stat.TotalConflictKeys, p.stat.TotalConflictFields)
+		common.Logger.Warnf("partial result covers %.2f%% of the keyspace, %d key(s) scanned in the first round",
+			p.coverage(), p.totalScanKeys)
+		p.logConflictByType()
+		p.logFanOut()
+		p.logLatency()
+		p.logSize()
+		return
+	}
+	if p.checkpoint != nil {
+		p.checkpoint.Remove()
+	}
+	if p.SampleRate < 1 {
+		p.logSample()
+	}
 	common.Logger.Infof("--------------- finished! ----------------\nall finish successfully, totally %d key(s) and %d field(s) conflict",
 		p.stat.TotalConflictKeys, p.stat.TotalConflictFields)
+	p.logConflictByType()
+	p.logFanOut()
+	p.logLatency()
+	p.logSize()
+}
+
+// the fan-out targets share the qps limit and the stop with p, but have their own result
+func (p *FullCheck) startFanOut() {
+	for _, lane := range p.fanOut {
+		lane.startTime = p.startTime
+		lane.sourcePhysicalDBList = p.sourcePhysicalDBList
+		lane.sourceLogicalDBMap = p.sourceLogicalDBMap
+		lane.qos = p.qos
+		lane.stop = p.stop
+		lane.stopOnce = p.stopOnce
+		lane.failure = p.failure
+		lane.ctx = p.ctx
+
+		for i := 1; i <= p.CompareCount; i++ {
+			os.Remove(lane.ResultDBFile + "." + strconv.Itoa(i))
+			db, err := sql.Open("sqlite3", lane.ResultDBFile+"."+strconv.Itoa(i))
+			if err != nil {
+				panic(common.Logger.Critical(err))
+			}
+			lane.db[i] = db
+		}
+		if len(lane.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatCsv {
+			writeCsvHeader(lane.result)
+		}
+	}
+}
+
+func (p *FullCheck) closeFanOut() {
+	for _, lane := range p.fanOut {
+		for i := 1; i <= p.CompareCount; i++ {
+			if lane.db[i] != nil {
+				lane.db[i].Close()
+			}
+		}
+	}
+}
+
+func (p *FullCheck) logFanOut() {
+	for _, lane := range p.fanOut {
+		common.Logger.Infof("fan-out %v: totally %d key(s) and %d field(s) conflict, result db %v",
+			lane.TargetHost, lane.stat.TotalConflictKeys, lane.stat.TotalConflictFields, lane.ResultDBFile)
+		lane.logConflictByType()
+	}
+}
+
+// the fan-out targets verify the keys scanned by p in the first round, and their own conflicts later
+func (p *FullCheck) activeFanOut() []*FullCheck {
+	if p.times != 1 {
+		return nil
+	}
+	return p.fanOut
+}
+
+// set the current round and db, only called by the comparing goroutine so it reads them without the lock
+func (p *FullCheck) setRound(times int, db int32) {
+	p.roundLock.Lock()
+	p.times, p.currentDB = times, db
+	p.roundLock.Unlock()
+}
+
+// the current round and db read by the other goroutines, e.g., the metric server
+func (p *FullCheck) round() (int, int32) {
+	p.roundLock.RLock()
+	defer p.roundLock.RUnlock()
+	return p.times, p.currentDB
+}
+
+// compare one logical db in the current round
+func (p *FullCheck) CompareDB(db int32) {
+	p.setRound(p.times, db)
+	p.stat.Reset(false)
+	fanOut := p.activeFanOut()
+	for _, lane := range fanOut {
+		lane.currentDB = db
+		lane.stat.Reset(false)
+	}
+	// key count in the keyspace is meaningless for cluster
+	var progress *Progress
+	if p.times == 1 && p.SourceHost.IsCluster() == false {
+		progress = NewProgress(db, p.sourceLogicalDBMap[db])
+	}
+	// init stat timer
+	tickerStat := time.NewTicker(time.Second * common.StatRollFrequency)
+	ctxStat, cancelStat := context.WithCancel(context.Background()) // 主动cancel
+	go func(ctx context.Context) {
+		defer func() {
+			tickerStat.Stop()
+		}()
+
+		for range tickerStat.C {
+			select { // 判断是否结束
+			case <-ctx.Done():
+				return
+			default:
+			}
+			p.stat.Rotate()
+			p.PrintStat(false)
+			for _, lane := range fanOut {
+				lane.stat.Rotate()
+				common.Logger.Infof("stat of fan-out %v", lane.TargetHost)
+				lane.PrintStat(false)
+			}
+			if progress != nil {
+				common.Logger.Infof("progress %s", progress.Update(p.stat.Scan.Total()))
+			}
+		}
+	}(ctxStat)
+
+	if p.PoolWarmUp {
+		p.warmUpPools()
+	}
+
+	common.Logger.Infof("start compare db %d", p.currentDB)
+	keys := make(chan []*common.Key, 1024)
+	conflictKey := make(chan *common.Key, conflictKeyBuffer)
+	var wg, wg2 sync.WaitGroup
+	// the keys given by the key file are checked on the source before verifying
+	scanned := keys
+	if p.KeyList != nil {
+		scanned = make(chan []*common.Key, 1024)
+		wg.Add(1)
+		go func(scanned chan []*common.Key) {
+			defer wg.Done()
+			p.FilterMissingOnSource(scanned, keys, conflictKey)
+		}(scanned)
+	}
+	// start scan, get all keys
+	if p.times == 1 && p.KeyList != nil {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.recoverCanceled()
+			p.ScanFromKeyList(scanned)
+		}()
+	} else if p.times == 1 {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.recoverCanceled()
+			p.ScanFromSourceRedis(scanned)
+		}()
+	} else {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			defer p.recoverCanceled()
+			p.ScanFromDB(scanned)
+		}()
+	}
+
+	// start check
+	wg.Add(p.Parallel)
+	for i := 0; i < p.Parallel; i++ {
+		go func() {
+			defer wg.Done()
+			// keep draining the keys so the scanner isn't blocked after the failure
+			defer func() {
+				for range keys {
+				}
+			}()
+			defer p.recoverCanceled()
+			p.VerifyAllKeyInfo(keys, conflictKey)
+		}()
+	}
+
+	// start write conflictKey
+	wg2.Add(1)
+	go func() {
+		defer wg2.Done()
+		// keep draining the conflict keys so the verifiers aren't blocked after the failure
+		defer func() {
+			for range conflictKey {
+			}
+		}()
+		defer p.recoverCanceled()
+		p.WriteConflictKey(conflictKey)
+	}()
+	for _, lane := range fanOut {
+		lane.conflictKey = make(chan *common.Key, conflictKeyBuffer)
+		wg2.Add(1)
+		go func(lane *FullCheck) {
+			defer wg2.Done()
+			defer func() {
+				for range lane.conflictKey {
+				}
+			}()
+			defer lane.recoverCanceled()
+			lane.WriteConflictKey(lane.conflictKey)
+		}(lane)
+	}
+
+	// the fan-out targets compare the conflicts of their own in the later rounds
+	var wgFanOut sync.WaitGroup
+	if p.times != 1 {
+		wgFanOut.Add(len(p.fanOut))
+		for _, lane := range p.fanOut {
+			go func(lane *FullCheck) {
+				defer wgFanOut.Done()
+				defer lane.recoverCanceled()
+				lane.CompareDB(db)
+			}(lane)
+		}
+	}
+
+	wg.Wait()
+	close(conflictKey)
+	for _, lane := range fanOut {
+		close(lane.conflictKey)
+	}
+	wg2.Wait()
+	cancelStat() // stop stat goroutine
+	p.PrintStat(true)
+	for _, lane := range fanOut {
+		common.Logger.Infof("stat of fan-out %v", lane.TargetHost)
+		lane.PrintStat(true)
+		lane.totalScanKeys += lane.stat.Scan.Total()
+		lane.dbResult(lane.currentDB).ScanKeys += lane.stat.Scan.Total()
+	}
+	if p.times == 1 {
+		p.totalScanKeys += p.stat.Scan.Total()
+		p.dbResult(p.currentDB).ScanKeys += p.stat.Scan.Total()
+	}
+	wgFanOut.Wait()
+}
+
+/*
+ * Connect to the db on the source and all the targets before comparing it. The db failing to auth or
+ * select, e.g., beyond the databases of the target, is skipped and reported as unverified unless strictdb
+ * is set, so one misconfigured db doesn't block the others.
+ */
+func (p *FullCheck) CheckDB(db int32) bool {
+	hosts := []client.RedisHost{p.SourceHost, p.TargetHost}
+	dbs := []int32{db, p.TargetDB(db)}
+	for _, lane := range p.fanOut {
+		hosts = append(hosts, lane.TargetHost)
+		dbs = append(dbs, lane.TargetDB(db))
+	}
+	for i, host := range hosts {
+		c, err := client.NewRedisClientContext(p.ctx, host, dbs[i])
+		if err == nil {
+			c.Close()
+			continue
+		}
+		if p.StrictDB || p.ctx.Err() != nil {
+			panic(common.Logger.Errorf("connect to %v db[%v] failed[%v]", host.Addr, dbs[i], err))
+		}
+		common.Logger.Warnf("skip db[%v] since connecting to %v db[%v] failed[%v], set strictdb to exit instead",
+			db, host.Addr, dbs[i], err)
+		p.skipLock.Lock()
+		p.skippedDB[db] = err
+		p.skipLock.Unlock()
+		return false
+	}
+	return true
+}
+
+// the dbs skipped since failing to be connected in any round, sorted
+func (p *FullCheck) SkippedDB() []int32 {
+	p.skipLock.Lock()
+	defer p.skipLock.Unlock()
+	dbs := make([]int32, 0, len(p.skippedDB))
+	for db := range p.skippedDB {
+		dbs = append(dbs, db)
+	}
+	sort.Slice(dbs, func(i, j int) bool {
+		return dbs[i] < dbs[j]
+	})
+	return dbs
+}
+
+// compare the logical dbs concurrently, every db has its own stat and verifier
+func (p *FullCheck) CompareDBConcurrently() {
+	dbList := make(chan int32, len(p.sourceLogicalDBMap))
+	for db := range p.sourceLogicalDBMap {
+		dbList <- db
+	}
+	close(dbList)
+
+	var wg sync.WaitGroup
+	var lock sync.Mutex
+	wg.Add(p.DbParallel)
+	for i := 0; i < p.DbParallel; i++ {
+		go func() {
+			defer wg.Done()
+			defer p.recoverCanceled()
+			for db := range dbList {
+				if p.IsStopped() {
+					break
+				}
+				if p.CheckDB(db) == false {
+					continue
+				}
+				worker := p.newDBWorker()
+				p.trackWorker(worker, true)
+				worker.CompareDB(db)
+				p.trackWorker(worker, false)
+				worker.stat.Reset(false)
+
+				lock.Lock()
+				p.totalScanKeys += worker.totalScanKeys
+				p.targetOnly += worker.targetOnly
+				for i, count := range worker.stat.ShallowKeys {
+					p.stat.ShallowKeys[i] += count
+				}
+				p.stat.TotalConflictKeys += worker.stat.TotalConflictKeys
+				p.stat.TotalConflictFields += worker.stat.TotalConflictFields
+				for conflictType, count := range worker.resultConflict {
+					p.resultConflict[conflictType] += count
+				}
+				for db, result := range worker.resultByDB {
+					p.dbResult(db).ScanKeys += result.ScanKeys
+					p.dbResult(db).ConflictKeys += result.ConflictKeys
+				}
+				for keyType, conflict := range worker.conflictByType {
+					for conflictType, count := range conflict {
+						p.addConflictByType(keyType, conflictType, count)
+					}
+				}
+				lock.Unlock()
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+// add the worker comparing a db concurrently, or remove it when it's done
+func (p *FullCheck) trackWorker(worker *FullCheck, running bool) {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+	if p.workers == nil {
+		p.workers = make(map[*FullCheck]struct{})
+	}
+	if running {
+		p.workers[worker] = struct{}{}
+	} else {
+		delete(p.workers, worker)
+	}
+}
+
+/*
+ * The stats of the dbs being compared and the lowest of the dbs, which are read by the metric server.
+ * Every worker has its own stat when dbparallel > 1 while the stat of p stays empty.
+ */
+func (p *FullCheck) currentStats() ([]*metric.Stat, int32) {
+	p.workerLock.Lock()
+	defer p.workerLock.Unlock()
+	if len(p.workers) == 0 {
+		_, db := p.round()
+		return []*metric.Stat{&p.stat}, db
+	}
+	stats := make([]*metric.Stat, 0, len(p.workers))
+	lowest := int32(-1)
+	for worker := range p.workers {
+		stats = append(stats, &worker.stat)
+		if _, db := worker.round(); lowest < 0 || db < lowest {
+			lowest = db
+		}
+	}
+	return stats, lowest
+}
+
+// the worker shares the result db and the qps limit with p
+func (p *FullCheck) newDBWorker() *FullCheck {
+	worker := NewFullCheck(p.FullCheckParameter, p.checkType)
+	worker.times = p.times
+	worker.db = p.db
+	worker.sourcePhysicalDBList = p.sourcePhysicalDBList
+	worker.sourceLogicalDBMap = p.sourceLogicalDBMap
+	worker.qos = p.qos
+	worker.writeLock = p.writeLock
+	worker.unverified = p.unverified
+	worker.result = p.result
+	worker.sizes = p.sizes
+	worker.stop = p.stop
+	worker.stopOnce = p.stopOnce
+	worker.failure = p.failure
+	worker.ConflictHandler = p.ConflictHandler
+	worker.ctx = p.ctx
+	return worker
+}
+
+/*
+ * Run is Start for the library: the redis commands are aborted and the keys being verified are
+ * dropped when ctx is done, then the summary of the finished part is returned along with the error
+ * of ctx. ErrStopped is returned when stopped by Stop. The other errors of the comparison, e.g., the
+ * connection failure, stop it and are returned instead of panicking. Only one FullCheck can run in
+ * the process at the same time since the options and the log are shared by the whole process.
+ */
+func (p *FullCheck) Run(ctx context.Context) (summary ResultSummary, err error) {
+	p.ctx = ctx
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			p.Stop()
+		case <-done:
+		}
+	}()
+
+	defer func() {
+		if r := recover(); r != nil {
+			if e, ok := r.(error); ok {
+				err = e
+			} else {
+				err = fmt.Errorf("%v", r)
+			}
+		}
+	}()
+
+	p.Start()
+	summary = p.Summary()
+	if ctx.Err() != nil {
+		err = ctx.Err()
+	} else if p.IsExpired() {
+		err = ErrMaxDuration
+	} else if p.IsStopped() {
+		err = ErrStopped
+	}
+	return summary, err
+}
+
+/*
+ * Deferred by the goroutines of the comparison. The panic caused by the done context is expected, the
+ * others stop the comparison and the first one is passed on by Start once all the goroutines exit.
+ */
+func (p *FullCheck) recoverCanceled() {
+	if r := recover(); r != nil {
+		if p.ctx.Err() != nil {
+			common.Logger.Warnf("aborted since %v: %v", p.ctx.Err(), r)
+			return
+		}
+		p.failure.keep(r)
+		p.Stop()
+	}
+}
+
+// the first panic of the goroutines of the comparison
+type failure struct {
+	lock  sync.Mutex
+	cause interface{}
+}
+
+func (f *failure) keep(cause interface{}) {
+	f.lock.Lock()
+	defer f.lock.Unlock()
+	if f.cause == nil {
+		f.cause = cause
+	}
+}
+
+// panic again with the first panic kept, if any
+func (f *failure) raise() {
+	f.lock.Lock()
+	cause := f.cause
+	f.lock.Unlock()
+	if cause != nil {
+		panic(cause)
+	}
+}
+
+/*
+ * Stop dispatching new keys, the keys being verified are finished and their conflicts are written
+ * before Start returns. The checkpoint is kept so the comparison can be resumed.
+ */
+func (p *FullCheck) Stop() {
+	p.stopOnce.Do(func() {
+		close(p.stop)
+	})
+}
+
+func (p *FullCheck) IsStopped() bool {
+	select {
+	case <-p.stop:
+		return true
+	default:
+		return false
+	}
+}
+
+// stopped since maxduration is reached
+func (p *FullCheck) IsExpired() bool {
+	return atomic.LoadInt32(&p.expired) == 1
 }
 
 func (p *FullCheck) GetCurrentResultTable() (key string, field string) {
@@ -344,7 +1058,7 @@ func (p *FullCheck) CreateDbTable(times int) {
 	conflictKeyTableName, conflictFieldTableName := p.GetCurrentResultTable()
 
 	conflictKeyTableSql := fmt.Sprintf(`
-CREATE TABLE %s(
+CREATE TABLE IF NOT EXISTS %s(
    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
    key            TEXT NOT NULL,
    type           TEXT NOT NULL,
@@ -359,7 +1073,7 @@ CREATE TABLE %s(
 		panic(common.Logger.Errorf("exec sql %s failed: %s", conflictKeyTableSql, err))
 	}
 	conflictFieldTableSql := fmt.Sprintf(`
-CREATE TABLE %s(
+CREATE TABLE IF NOT EXISTS %s(
    id INTEGER PRIMARY KEY AUTOINCREMENT NOT NULL,
    field          TEXT NOT NULL,
    conflict_type  TEXT NOT NULL,
@@ -386,72 +1100,209 @@ CREATE TABLE IF NOT EXISTS %s(
 	}
 }
 
+// one connection for every verifier, and the scanner on the source
+func (p *FullCheck) warmUpPools() {
+	hosts := []struct {
+		host  client.RedisHost
+		db    int32
+		count int
+	}{
+		{p.SourceHost, p.currentDB, p.Parallel + 1},
+		{p.TargetHost, p.TargetDB(p.currentDB), p.Parallel},
+	}
+	for _, lane := range p.activeFanOut() {
+		target := hosts[1]
+		target.host, target.db = lane.TargetHost, lane.TargetDB(p.currentDB)
+		hosts = append(hosts, target)
+	}
+	for _, ele := range hosts {
+		if ele.host.IsPooled() == false {
+			continue
+		}
+		count := ele.count
+		if count > ele.host.PoolMaxIdle {
+			count = ele.host.PoolMaxIdle
+		}
+		if ele.host.PoolMaxActive > 0 && count > ele.host.PoolMaxActive {
+			count = ele.host.PoolMaxActive
+		}
+
+		start := time.Now()
+		if err := client.WarmUpPool(ele.host, ele.db, count); err != nil {
+			panic(common.Logger.Errorf("warm up %d connection(s) of %v db[%v] failed[%v]", count, ele.host, ele.db, err))
+		}
+		common.Logger.Infof("warm up %d connection(s) of %v db[%v] in %v", count, ele.host, ele.db, time.Since(start))
+	}
+}
+
 func (p *FullCheck) VerifyAllKeyInfo(allKeys <-chan []*common.Key, conflictKey chan<- *common.Key) {
-	sourceClient, err := client.NewRedisClient(p.SourceHost, p.currentDB)
+	// keep draining the keys so the scanner isn't blocked when aborted
+	drain := func() {
+		for range allKeys {
+		}
+	}
+
+	sourceClient, err := client.NewRedisClientContext(p.ctx, p.SourceHost, p.currentDB)
 	if err != nil {
+		if p.ctx.Err() != nil {
+			drain()
+			return
+		}
 		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
 			p.SourceHost, p.currentDB, err))
 	}
 	defer sourceClient.Close()
 
-	targetClient, err := client.NewRedisClient(p.TargetHost, p.currentDB)
+	targetClient, err := client.NewRedisClientContext(p.ctx, p.TargetHost, p.TargetDB(p.currentDB))
 	if err != nil {
+		if p.ctx.Err() != nil {
+			drain()
+			return
+		}
 		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
-			p.TargetHost, p.currentDB, err))
+			p.TargetHost, p.TargetDB(p.currentDB), err))
 	}
 	defer targetClient.Close()
 
-	// limit qps
-	qos := common.StartQoS(conf.Opts.Qps)
+	fanOut := p.activeFanOut()
+	fanOutClients := make([]client.RedisClient, len(fanOut))
+	for i, lane := range fanOut {
+		fanOutClients[i], err = client.NewRedisClientContext(p.ctx, lane.TargetHost, lane.TargetDB(p.currentDB))
+		if err != nil {
+			if p.ctx.Err() != nil {
+				drain()
+				return
+			}
+			panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
+				lane.TargetHost, lane.TargetDB(p.currentDB), err))
+		}
+		defer fanOutClients[i].Close()
+	}
+
 	for keyInfo := range allKeys {
-		<-qos.Bucket
-		p.verifier.VerifyOneGroupKeyInfo(keyInfo, conflictKey, &sourceClient, &targetClient)
+		// drop the keys not verified yet, the scanner is unblocked and then exits
+		if p.IsStopped() || p.ctx.Err() != nil {
+			continue
+		}
+		<-p.qos.Bucket
+		if len(fanOut) != 0 {
+			p.verifyFanOut(keyInfo, conflictKey, &sourceClient, &targetClient, fanOutClients)
+		} else if p.verifyOneGroup(keyInfo, conflictKey, &sourceClient, &targetClient) && p.checkpoint != nil {
+			p.checkpoint.Done(keyInfo)
+		}
+		if p.sizes != nil && p.times == 1 && p.IsStopped() == false && p.ctx.Err() == nil {
+			p.sizes.Record(keyInfo, &sourceClient)
+		}
 	} // for oneGroupKeys := range allKeys
+}
+
+// return false when aborted by the context
+func (p *FullCheck) verifyOneGroup(keyInfo []*common.Key, conflictKey chan<- *common.Key,
+		sourceClient, targetClient *client.RedisClient) (verified bool) {
+	defer p.recoverCanceled()
+	defer p.recoverUnverified(keyInfo, sourceClient, targetClient)
+	if p.TargetStaleness > 0 {
+		p.verifyStale(keyInfo, conflictKey, sourceClient, targetClient)
+		return true
+	}
+	p.verifyKeys(keyInfo, conflictKey, sourceClient, targetClient)
+	return true
+}
+
+// verify the keys with every target, the source replies are cached so the value is fetched once
+func (p *FullCheck) verifyFanOut(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient, fanOutClients []client.RedisClient) {
+	// every verifier fills the keys with the attributes of its own target
+	copies := make([][]*common.Key, len(p.fanOut))
+	for i := range p.fanOut {
+		copies[i] = make([]*common.Key, len(keyInfo))
+		for j, key := range keyInfo {
+			copied := *key
+			copies[i][j] = &copied
+		}
+	}
 
-	qos.Close()
+	sourceClient.CacheReplies(true)
+	defer sourceClient.CacheReplies(false)
+	// every target is verified even if the others fail, e.g., one target is unreachable
+	p.verifyOneGroup(keyInfo, conflictKey, sourceClient, targetClient)
+	for i, lane := range p.fanOut {
+		lane.IncrScanStat(len(copies[i]))
+		// the targets skipped when aborted by the context are told by their unverified keys
+		if p.ctx.Err() != nil {
+			lane.unverified.Record(lane.currentDB, copies[i])
+			continue
+		}
+		lane.verifyOneGroup(copies[i], lane.conflictKey, sourceClient, &fanOutClients[i])
+	}
 }
 
 func (p *FullCheck) WriteConflictKey(conflictKey <-chan *common.Key) {
 	conflictKeyTableName, conflictFieldTableName := p.GetCurrentResultTable()
 
-	var resultfile *os.File
-	if len(conf.Opts.ResultFile) > 0 {
-		resultfile, _ = os.OpenFile(conf.Opts.ResultFile, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
-		defer resultfile.Close()
+	// the result file is flushed when the transaction committed
+	var resultfile *bufio.Writer
+	if len(p.resultFile) > 0 {
+		resultfile = bufio.NewWriterSize(p.result, resultBufferSize)
 	}
 
-	tx, _ := p.db[p.times].Begin()
-	statInsertKey, err := tx.Prepare(fmt.Sprintf("insert into %s (key, type, conflict_type, db, source_len, target_len) values(?,?,?,?,?,?)", conflictKeyTableName))
-	if err != nil {
-		panic(common.Logger.Error(err))
-	}
-	statInsertField, err := tx.Prepare(fmt.Sprintf("insert into %s (field, conflict_type, key_id) values (?,?,?)", conflictFieldTableName))
-	if err != nil {
-		panic(common.Logger.Error(err))
-	}
+	// the write lock is held until the transaction committed
+	var tx *sql.Tx
+	var statInsertKey, statInsertField, statInsertFinal *sql.Stmt
+	begin := func() {
+		var err error
+		p.writeLock.Lock()
+		tx, _ = p.db[p.times].Begin()
+		statInsertKey, err = tx.Prepare(fmt.Sprintf("insert into %s (key, type, conflict_type, db, source_len, target_len) values(?,?,?,?,?,?)", conflictKeyTableName))
+		if err != nil {
+			panic(common.Logger.Error(err))
+		}
 
-	count := 0
-	for oneKeyInfo := range conflictKey {
-		if count%1000 == 0 {
-			var err error
-			statInsertKey.Close()
-			statInsertField.Close()
-			e := tx.Commit()
-			if e != nil {
-				common.Logger.Error(e.Error())
-			}
+		statInsertField, err = tx.Prepare(fmt.Sprintf("insert into %s (field, conflict_type, key_id) values (?,?,?)", conflictFieldTableName))
+		if err != nil {
+			panic(common.Logger.Error(err))
+		}
 
-			tx, _ = p.db[p.times].Begin()
-			statInsertKey, err = tx.Prepare(fmt.Sprintf("insert into %s (key, type, conflict_type, db, source_len, target_len) values(?,?,?,?,?,?)", conflictKeyTableName))
+		if p.times == p.CompareCount {
+			statInsertFinal, err = tx.Prepare("insert into FINAL_RESULT (InstanceA, InstanceB, Key, Schema, InconsistentType, Extra) VALUES(?, ?, ?, ?, ?, ?)")
 			if err != nil {
 				panic(common.Logger.Error(err))
 			}
-
-			statInsertField, err = tx.Prepare(fmt.Sprintf("insert into %s (field, conflict_type, key_id) values (?,?,?)", conflictFieldTableName))
-			if err != nil {
-				panic(common.Logger.Error(err))
+		}
+	}
+	commit := func() {
+		if tx == nil {
+			return
+		}
+		statInsertKey.Close()
+		statInsertField.Close()
+		if statInsertFinal != nil {
+			statInsertFinal.Close()
+			statInsertFinal = nil
+		}
+		e := tx.Commit()
+		if e != nil {
+			common.Logger.Error(e.Error())
+		}
+		if resultfile != nil {
+			if e := resultfile.Flush(); e != nil {
+				common.Logger.Errorf("flush result file[%v] failed[%v]", p.resultFile, e)
 			}
 		}
+		tx = nil
+		p.writeLock.Unlock()
+	}
+
+	count := 0
+	write := func(oneKeyInfo *common.Key) {
+		// the key only on the target isn't verified again in the next round
+		if p.OneWay && oneKeyInfo.ConflictType == common.LackSourceConflict {
+			p.targetOnly++
+			return
+		}
+		if tx == nil {
+			begin()
+		}
 		count += 1
 
 		result, err := statInsertKey.Exec(string(oneKeyInfo.Key), oneKeyInfo.Tp.Name, oneKeyInfo.ConflictType.String(), p.currentDB, oneKeyInfo.SourceAttr.ItemCount, oneKeyInfo.TargetAttr.ItemCount)
@@ -467,45 +1318,142 @@ func (p *FullCheck) WriteConflictKey(conflictKey <-chan *common.Key) {
 				}
 
 				if p.times == p.CompareCount {
-					finalstat, err := tx.Prepare(fmt.Sprintf("insert into FINAL_RESULT (InstanceA, InstanceB, Key, Schema, InconsistentType, Extra) VALUES(?, ?, ?, ?, ?, ?)"))
-					if err != nil {
-						panic(common.Logger.Error(err))
-					}
-					// defer finalstat.Close()
-					_, err = finalstat.Exec("", "", string(oneKeyInfo.Key), strconv.Itoa(int(p.currentDB)),
+					_, err = statInsertFinal.Exec("", "", string(oneKeyInfo.Key), strconv.Itoa(int(p.currentDB)),
 						oneKeyInfo.Field[i].ConflictType.String(),
 						string(oneKeyInfo.Field[i].Field))
 					if err != nil {
 						panic(common.Logger.Error(err))
 					}
 
-					finalstat.Close()
-
-					if len(conf.Opts.ResultFile) != 0 {
-						resultfile.WriteString(fmt.Sprintf("%d\t%s\t%s\t%s\n", int(p.currentDB), oneKeyInfo.Field[i].ConflictType.String(), string(oneKeyInfo.Key), string(oneKeyInfo.Field[i].Field)))
+					if len(p.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatText {
+						resultfile.WriteString(fmt.Sprintf("%d\t%s\t%s\t%s\n", int(p.currentDB), oneKeyInfo.Field[i].ConflictType.String(), common.EncodeName(oneKeyInfo.Key), common.EncodeName(oneKeyInfo.Field[i].Field)))
 					}
 				}
 			}
 		} else {
 			if p.times == p.CompareCount {
-				finalstat, err := tx.Prepare(fmt.Sprintf("insert into FINAL_RESULT (InstanceA, InstanceB, Key, Schema, InconsistentType, Extra) VALUES(?, ?, ?, ?, ?, ?)"))
+				_, err = statInsertFinal.Exec("", "", string(oneKeyInfo.Key), strconv.Itoa(int(p.currentDB)), oneKeyInfo.ConflictType.String(), "")
 				if err != nil {
 					panic(common.Logger.Error(err))
 				}
-				// defer finalstat.Close()
-				_, err = finalstat.Exec("", "", string(oneKeyInfo.Key), strconv.Itoa(int(p.currentDB)), oneKeyInfo.ConflictType.String(), "")
-				if err != nil {
-					panic(common.Logger.Error(err))
+
+				if len(p.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatText {
+					resultfile.WriteString(fmt.Sprintf("%d\t%s\t%s\t%s\n", int(p.currentDB), oneKeyInfo.ConflictType.String(), common.EncodeName(oneKeyInfo.Key), ""))
 				}
-				finalstat.Close()
+			}
+		}
 
-				if len(conf.Opts.ResultFile) != 0 {
-					resultfile.WriteString(fmt.Sprintf("%d\t%s\t%s\t%s\n", int(p.currentDB), oneKeyInfo.ConflictType.String(), string(oneKeyInfo.Key), ""))
+		if p.times == p.CompareCount {
+			p.resultConflict[oneKeyInfo.ConflictType.String()]++
+			p.dbResult(p.currentDB).ConflictKeys++
+			p.addConflictByType(oneKeyInfo.Tp.Name, oneKeyInfo.ConflictType.String(), 1)
+			if p.ConflictHandler != nil {
+				p.ConflictHandler(p.currentDB, oneKeyInfo)
+			}
+			if len(p.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatJson {
+				p.writeJsonResult(resultfile, oneKeyInfo)
+			} else if len(p.resultFile) != 0 && conf.Opts.ResultFormat == ResultFormatCsv {
+				p.writeCsvResult(resultfile, oneKeyInfo)
+			}
+		}
+
+		if count%1000 == 0 {
+			commit()
+		}
+	}
+
+	// commit periodically so the conflicts aren't kept in memory for a long time
+	tickerFlush := time.NewTicker(conflictFlushInterval)
+	defer tickerFlush.Stop()
+
+	// save checkpoint periodically
+	var checkpointC <-chan time.Time
+	if p.checkpoint != nil {
+		tickerCheckpoint := time.NewTicker(time.Second * time.Duration(conf.Opts.CheckpointInterval))
+		defer tickerCheckpoint.Stop()
+		checkpointC = tickerCheckpoint.C
+	}
+
+	for closed := false; !closed; {
+		select {
+		case oneKeyInfo, ok := <-conflictKey:
+			if !ok {
+				closed = true
+				break
+			}
+			write(oneKeyInfo)
+			// other dbs compared concurrently are waiting for the write lock
+			if p.DbParallel > 1 && len(conflictKey) == 0 {
+				commit()
+			}
+		case <-tickerFlush.C:
+			commit()
+		case <-checkpointC:
+			cp := p.checkpoint.Snapshot()
+			// the conflict keys of the verified batches have been sent before the snapshot, write them all
+			for drained := false; !drained && !closed; {
+				select {
+				case oneKeyInfo, ok := <-conflictKey:
+					if ok {
+						write(oneKeyInfo)
+					} else {
+						closed = true
+					}
+				default:
+					drained = true
 				}
 			}
+			commit()
+			p.SaveCheckpoint(cp)
+		}
+	}
+	commit()
+}
+
+// fill the position of the result and then save the checkpoint
+func (p *FullCheck) SaveCheckpoint(cp Checkpoint) {
+	conflictKeyTableName, conflictFieldTableName := p.GetCurrentResultTable()
+	positions := []struct {
+		sql string
+		id  *int64
+	}{
+		{fmt.Sprintf("select ifnull(max(id), 0) from %s", conflictKeyTableName), &cp.KeyId},
+		{fmt.Sprintf("select ifnull(max(id), 0) from %s", conflictFieldTableName), &cp.FieldId},
+		{"select ifnull(max(rowid), 0) from FINAL_RESULT", &cp.FinalRowId},
+	}
+	for _, pos := range positions {
+		if err := p.db[p.times].QueryRow(pos.sql).Scan(pos.id); err != nil {
+			common.Logger.Errorf("query sql %s failed[%v], skip saving checkpoint", pos.sql, err)
+			return
+		}
+	}
+
+	if len(p.resultFile) != 0 {
+		if info, err := os.Stat(p.resultFile); err == nil {
+			cp.ResultSize = info.Size()
+		}
+	}
+	p.checkpoint.Save(cp)
+}
+
+// remove the result beyond the position recorded in the checkpoint
+func (p *FullCheck) TruncateResult(cp *Checkpoint) {
+	conflictKeyTableName, conflictFieldTableName := p.GetCurrentResultTable()
+	sqls := []string{
+		fmt.Sprintf("delete from %s where id>%d", conflictKeyTableName, cp.KeyId),
+		fmt.Sprintf("delete from %s where id>%d", conflictFieldTableName, cp.FieldId),
+		fmt.Sprintf("delete from FINAL_RESULT where rowid>%d", cp.FinalRowId),
+	}
+	for _, sql := range sqls {
+		if _, err := p.db[p.times].Exec(sql); err != nil {
+			panic(common.Logger.Errorf("exec sql %s failed: %s", sql, err))
+		}
+	}
+
+	// the file rotated after the checkpoint is smaller, the result beyond it is kept
+	if info, err := os.Stat(p.resultFile); err == nil && info.Size() >= cp.ResultSize {
+		if err := os.Truncate(p.resultFile, cp.ResultSize); err != nil {
+			panic(common.Logger.Errorf("truncate result file[%v] failed[%v]", p.resultFile, err))
 		}
 	}
-	statInsertKey.Close()
-	statInsertField.Close()
-	tx.Commit()
 }
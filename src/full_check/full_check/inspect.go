@@ -0,0 +1,253 @@
+package full_check
+
+import (
+	"bytes"
+	"fmt"
+	"sort"
+	"time"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+// the elements printed for each side and for the diff, the rest is counted
+const inspectMaxElements = 100
+
+/*
+ * Compare the type, ttl and value of one key without scanning, print the value of both sides and the
+ * diff to stdout. It's used to investigate one conflict reported before. The value is fetched whole,
+ * and only string, hash, list, set and zset are compared by the value. Return false if the key conflicts.
+ */
+func (p *FullCheck) InspectKey(db int32, key []byte) bool {
+	sourceClient, err := client.NewRedisClient(p.SourceHost, db)
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]", p.SourceHost, db, err))
+	}
+	defer sourceClient.Close()
+	targetClient, err := client.NewRedisClient(p.TargetHost, p.TargetDB(db))
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]", p.TargetHost,
+			p.TargetDB(db), err))
+	}
+	defer targetClient.Close()
+
+	var buf bytes.Buffer
+	conflict := p.inspectKey(&buf, db, key, &sourceClient, &targetClient)
+	if len(conflict) == 0 {
+		fmt.Fprintf(&buf, "result: equal\n")
+	} else {
+		fmt.Fprintf(&buf, "result: conflict[%s]\n", conflict)
+	}
+	fmt.Print(buf.String())
+	common.Logger.Infof("inspect key[%s] of db[%v] finished, conflict[%s]", common.EncodeName(key), db, conflict)
+	return len(conflict) == 0
+}
+
+// print the key and return the conflict type, empty string means no conflict
+func (p *FullCheck) inspectKey(buf *bytes.Buffer, db int32, key []byte, sourceClient,
+	targetClient *client.RedisClient) string {
+	keyInfo := []*common.Key{{Key: key, Db: db}}
+	fmt.Fprintf(buf, "db: %d->%d key: %s\n", db, p.TargetDB(db), common.EncodeName(key))
+
+	sourceType, err := sourceClient.PipeTypeCommand(keyInfo)
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+	targetType, err := targetClient.PipeTypeCommand(keyInfo)
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+	fmt.Fprintf(buf, "type: source[%s] target[%s]\n", sourceType[0], targetType[0])
+	switch {
+	case sourceType[0] == common.NoneKeyType.Name && targetType[0] == common.NoneKeyType.Name:
+		fmt.Fprintf(buf, "the key doesn't exist on both sides\n")
+		return ""
+	case sourceType[0] == common.NoneKeyType.Name:
+		if p.EmptyAsMissing && inspectEmpty(keyInfo, targetType[0], targetClient) {
+			fmt.Fprintf(buf, "the key is empty on the target and regarded as missing\n")
+			return ""
+		}
+		return common.LackSourceConflict.String()
+	case targetType[0] == common.NoneKeyType.Name:
+		if p.EmptyAsMissing && inspectEmpty(keyInfo, sourceType[0], sourceClient) {
+			fmt.Fprintf(buf, "the key is empty on the source and regarded as missing\n")
+			return ""
+		}
+		return common.LackTargetConflict.String()
+	case sourceType[0] != targetType[0]:
+		return common.TypeMismatchConflict.String()
+	}
+
+	sourceAt := time.Now()
+	sourceTTL, err := sourceClient.PipePTTLCommand(keyInfo)
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+	targetAt := time.Now()
+	targetTTL, err := targetClient.PipePTTLCommand(keyInfo)
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+	fmt.Fprintf(buf, "ttl(ms): source[%d] target[%d]\n", sourceTTL[0], targetTTL[0])
+
+	conflict := ""
+	keyInfo[0].Tp = common.NewKeyType(sourceType[0])
+	switch keyInfo[0].Tp {
+	case common.StringKeyType, common.HashKeyType, common.ListKeyType, common.SetKeyType, common.ZsetKeyType:
+		sourceValue, err := sourceClient.PipeValueCommand(keyInfo)
+		if err != nil {
+			panic(common.Logger.Critical(err))
+		}
+		targetValue, err := targetClient.PipeValueCommand(keyInfo)
+		if err != nil {
+			panic(common.Logger.Critical(err))
+		}
+		if inspectValue(buf, keyInfo[0].Tp, sourceValue[0], targetValue[0]) == false {
+			conflict = common.ValueConflict.String()
+		}
+	default:
+		fmt.Fprintf(buf, "the value of %s isn't compared\n", sourceType[0])
+	}
+
+	diff := common.AlignedTTLDiff(sourceAt, sourceTTL[0], targetAt, targetTTL[0])
+	if (sourceTTL[0] == -1) != (targetTTL[0] == -1) || (sourceTTL[0] != -1 && diff > p.TTLTolerance) {
+		fmt.Fprintf(buf, "ttl differs by %dms, tolerance %dms\n", diff, p.TTLTolerance)
+		if len(conflict) == 0 {
+			conflict = common.ExpireConflict.String()
+		}
+	}
+	return conflict
+}
+
+// whether the key existing in the given type is empty, e.g., the empty string
+func inspectEmpty(keyInfo []*common.Key, keyType string, c *client.RedisClient) bool {
+	keyInfo[0].Tp = common.NewKeyType(keyType)
+	if keyInfo[0].Tp == common.EndKeyType {
+		return false
+	}
+	keyLen, err := c.PipeLenCommand(keyInfo)
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+	return keyLen[0] == 0
+}
+
+// print the value of both sides and the diff, return true if equal
+func inspectValue(buf *bytes.Buffer, tp *common.KeyType, sourceReply, targetReply interface{}) bool {
+	switch tp {
+	case common.StringKeyType:
+		source, _ := sourceReply.([]byte)
+		target, _ := targetReply.([]byte)
+		fmt.Fprintf(buf, "source value(%d bytes): %s\n", len(source), common.EncodeName(source))
+		fmt.Fprintf(buf, "target value(%d bytes): %s\n", len(target), common.EncodeName(target))
+		if bytes.Equal(source, target) {
+			return true
+		}
+		offset := 0
+		for offset < len(source) && offset < len(target) && source[offset] == target[offset] {
+			offset++
+		}
+		fmt.Fprintf(buf, "diff:\n  first differing byte at offset %d\n", offset)
+		return false
+	case common.ListKeyType:
+		source, target := common.ValueHelper_List(sourceReply), common.ValueHelper_List(targetReply)
+		printList(buf, "source", source)
+		printList(buf, "target", target)
+		diff := make([]string, 0)
+		for i := 0; i < len(source) || i < len(target); i++ {
+			switch {
+			case i >= len(target):
+				diff = append(diff, fmt.Sprintf("- [%d] %s", i, common.EncodeName(source[i])))
+			case i >= len(source):
+				diff = append(diff, fmt.Sprintf("+ [%d] %s", i, common.EncodeName(target[i])))
+			case bytes.Equal(source[i], target[i]) == false:
+				diff = append(diff, fmt.Sprintf("~ [%d] source[%s] target[%s]", i, common.EncodeName(source[i]),
+					common.EncodeName(target[i])))
+			}
+		}
+		return printDiff(buf, diff)
+	default:
+		// hash field -> value, zset member -> score, set member -> nil
+		var source, target map[string][]byte
+		if tp == common.SetKeyType {
+			source, target = common.ValueHelper_Set(sourceReply), common.ValueHelper_Set(targetReply)
+		} else {
+			source, target = common.ValueHelper_Hash_SortedSet(sourceReply), common.ValueHelper_Hash_SortedSet(targetReply)
+		}
+		printMap(buf, "source", source)
+		printMap(buf, "target", target)
+		diff := make([]string, 0)
+		for _, field := range sortedFields(source, target) {
+			sourceValue, inSource := source[field]
+			targetValue, inTarget := target[field]
+			switch {
+			case inTarget == false:
+				diff = append(diff, "- "+formatField(field, sourceValue))
+			case inSource == false:
+				diff = append(diff, "+ "+formatField(field, targetValue))
+			case bytes.Equal(sourceValue, targetValue) == false:
+				diff = append(diff, fmt.Sprintf("~ %s: source[%s] target[%s]", common.EncodeName([]byte(field)),
+					common.EncodeName(sourceValue), common.EncodeName(targetValue)))
+			}
+		}
+		return printDiff(buf, diff)
+	}
+}
+
+func printList(buf *bytes.Buffer, side string, value [][]byte) {
+	fmt.Fprintf(buf, "%s value(%d elements):\n", side, len(value))
+	for i := 0; i < len(value) && i < inspectMaxElements; i++ {
+		fmt.Fprintf(buf, "  [%d] %s\n", i, common.EncodeName(value[i]))
+	}
+	printMore(buf, len(value))
+}
+
+func printMap(buf *bytes.Buffer, side string, value map[string][]byte) {
+	fmt.Fprintf(buf, "%s value(%d elements):\n", side, len(value))
+	fields := sortedFields(value, nil)
+	for i := 0; i < len(fields) && i < inspectMaxElements; i++ {
+		fmt.Fprintf(buf, "  %s\n", formatField(fields[i], value[fields[i]]))
+	}
+	printMore(buf, len(fields))
+}
+
+// "-" is only on the source, "+" is only on the target and "~" differs
+func printDiff(buf *bytes.Buffer, diff []string) bool {
+	if len(diff) == 0 {
+		return true
+	}
+	fmt.Fprintf(buf, "diff(%d):\n", len(diff))
+	for i := 0; i < len(diff) && i < inspectMaxElements; i++ {
+		fmt.Fprintf(buf, "  %s\n", diff[i])
+	}
+	printMore(buf, len(diff))
+	return false
+}
+
+func printMore(buf *bytes.Buffer, count int) {
+	if count > inspectMaxElements {
+		fmt.Fprintf(buf, "  ... and %d more\n", count-inspectMaxElements)
+	}
+}
+
+// the set member has no value
+func formatField(field string, value []byte) string {
+	if value == nil {
+		return common.EncodeName([]byte(field))
+	}
+	return fmt.Sprintf("%s => %s", common.EncodeName([]byte(field)), common.EncodeName(value))
+}
+
+func sortedFields(source, target map[string][]byte) []string {
+	fields := make([]string, 0, len(source)+len(target))
+	for field := range source {
+		fields = append(fields, field)
+	}
+	for field := range target {
+		if _, ok := source[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+	return fields
+}
@@ -0,0 +1,182 @@
+package full_check
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+const maxKeyFileLine = 64 * 1024 * 1024
+
+/*
+ * Load the keys of every db from the key file, one key per line. The line "db\tkey" gives the db of
+ * the key, otherwise the key is in db 0. The key encoded as "hex:..." in the result is decoded. The
+ * empty lines and the duplicate keys are skipped.
+ */
+func LoadKeyFile(path string) (map[int32][][]byte, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	keyList := make(map[int32][][]byte)
+	loaded := make(map[int32]map[string]struct{})
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), maxKeyFileLine)
+	for nr := 1; scanner.Scan(); nr++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if len(line) == 0 {
+			continue
+		}
+
+		db := int32(0)
+		key := line
+		if idx := strings.IndexByte(line, '\t'); idx != -1 {
+			n, err := strconv.ParseInt(line[:idx], 10, 32)
+			if err != nil || n < 0 {
+				return nil, fmt.Errorf("invalid db[%s] in line %d", line[:idx], nr)
+			}
+			db, key = int32(n), line[idx+1:]
+		}
+		decoded, err := common.DecodeName(key)
+		if err != nil {
+			return nil, fmt.Errorf("invalid encoded key[%s] in line %d: %v", key, nr, err)
+		}
+		key = string(decoded)
+		if len(key) == 0 {
+			return nil, fmt.Errorf("empty key in line %d", nr)
+		}
+
+		if _, ok := loaded[db]; !ok {
+			loaded[db] = make(map[string]struct{})
+		}
+		if _, ok := loaded[db][key]; ok {
+			continue
+		}
+		loaded[db][key] = struct{}{}
+		keyList[db] = append(keyList[db], []byte(key))
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+	return keyList, nil
+}
+
+// pass on the keys of the current db given by the key file instead of scanning the source
+func (p *FullCheck) ScanFromKeyList(allKeys chan<- []*common.Key) {
+	defer close(allKeys)
+
+	keys := p.KeyList[p.currentDB]
+	for start := 0; start < len(keys); start += p.BatchCount {
+		if p.IsStopped() {
+			common.Logger.Infof("stop passing on the keys of db[%v] in the key file", p.currentDB)
+			break
+		}
+		end := start + p.BatchCount
+		if end > len(keys) {
+			end = len(keys)
+		}
+
+		keysInfo := make([]*common.Key, 0, end-start)
+		for _, key := range keys[start:end] {
+			keysInfo = append(keysInfo, &common.Key{
+				Key:          key,
+				Tp:           common.EndKeyType,
+				ConflictType: common.EndConflict,
+			})
+		}
+		p.IncrScanStat(len(keysInfo))
+		allKeys <- keysInfo
+	}
+}
+
+/*
+ * The key given by the key file is expected to exist, so the keys missing on the source aren't
+ * passed on to the verifiers, which regard them as deleted. They are reported as lack_source when
+ * existing on the target, and logged when missing on both sides.
+ */
+func (p *FullCheck) FilterMissingOnSource(scanned <-chan []*common.Key, allKeys chan<- []*common.Key,
+		conflictKey chan<- *common.Key) {
+	defer close(allKeys)
+	// keep draining the keys so the scanner isn't blocked when aborted
+	defer func() {
+		for range scanned {
+		}
+	}()
+	defer p.recoverCanceled()
+
+	sourceClient, err := client.NewRedisClientContext(p.ctx, p.SourceHost, p.currentDB)
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
+			p.SourceHost, p.currentDB, err))
+	}
+	defer sourceClient.Close()
+	targetClient, err := client.NewRedisClientContext(p.ctx, p.TargetHost, p.TargetDB(p.currentDB))
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
+			p.TargetHost, p.TargetDB(p.currentDB), err))
+	}
+	defer targetClient.Close()
+
+	missing := 0
+	for keysInfo := range scanned {
+		if p.IsStopped() || p.ctx.Err() != nil {
+			continue
+		}
+
+		sourceKeyType, err := sourceClient.PipeTypeCommand(keysInfo)
+		if err != nil {
+			panic(common.Logger.Critical(err))
+		}
+		existing := make([]*common.Key, 0, len(keysInfo))
+		lack := make([]*common.Key, 0)
+		for i, t := range sourceKeyType {
+			if t == common.NoneKeyType.Name {
+				lack = append(lack, keysInfo[i])
+			} else {
+				existing = append(existing, keysInfo[i])
+			}
+		}
+
+		if len(lack) != 0 {
+			targetKeyType, err := targetClient.PipeTypeCommand(lack)
+			if err != nil {
+				panic(common.Logger.Critical(err))
+			}
+			for i, t := range targetKeyType {
+				oneKeyInfo := lack[i]
+				if t == common.NoneKeyType.Name {
+					missing++
+					common.Logger.Warnf("key[%s] of db[%v] in the key file doesn't exist on both sides",
+						common.EncodeName(oneKeyInfo.Key), p.currentDB)
+					continue
+				}
+
+				oneKeyInfo.Tp = common.NewKeyType(t)
+				// the type name of the module is given by the module itself
+				if oneKeyInfo.Tp == common.EndKeyType {
+					oneKeyInfo.Tp = common.ModuleKeyType
+				}
+				oneKeyInfo.ConflictType = common.LackSourceConflict
+				oneKeyInfo.SourceAttr.ItemCount = 0
+				oneKeyInfo.TargetAttr.ItemCount = 1
+				oneKeyInfo.Field = nil
+				p.stat.ConflictKey[oneKeyInfo.Tp.Index][common.LackSourceConflict].Inc(1)
+				conflictKey <- oneKeyInfo
+			}
+		}
+
+		if len(existing) != 0 {
+			allKeys <- existing
+		}
+	}
+	if missing != 0 {
+		common.Logger.Warnf("%d key(s) of db[%v] in the key file don't exist on both sides", missing, p.currentDB)
+	}
+}
@@ -0,0 +1,646 @@
+package full_check
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"full_check/checker"
+	"full_check/client"
+	"full_check/common"
+	"full_check/configure"
+)
+
+/*
+ * New validates the options and builds the FullCheck in the same way as the command line, so the
+ * comparison can be embedded into other programs by New and Run. The options are shared by the whole
+ * process, so conf.Opts is replaced by opts and only one FullCheck can be built and run at the same
+ * time. The default values of the command line aren't filled for the library, all the options should
+ * be given.
+ */
+func New(opts conf.Options) (*FullCheck, error) {
+	conf.Opts = opts
+	// the command line has initialized the log before
+	if common.Logger == nil {
+		logLevel, err := common.HandleLogLevel(conf.Opts.LogLevel)
+		if err != nil {
+			return nil, err
+		}
+		if common.Logger, err = common.InitLog(conf.Opts.LogFile, logLevel, conf.Opts.LogFormat); err != nil {
+			return nil, fmt.Errorf("init log failed: %v", err)
+		}
+	}
+
+	if conf.Opts.SourceAddr == "" || conf.Opts.TargetAddr == "" {
+		return nil, fmt.Errorf("source or target address not specified")
+	}
+
+	compareCount, err := strconv.Atoi(conf.Opts.CompareTimes)
+	if err != nil || compareCount < 1 {
+		return nil, fmt.Errorf("invalid option cmpcount %s, expect int >=1", conf.Opts.CompareTimes)
+	}
+	if conf.Opts.Interval < 0 {
+		return nil, fmt.Errorf("invalid option interval %d, expect int >=0", conf.Opts.Interval)
+	}
+	batchCount, err := strconv.Atoi(conf.Opts.BatchCount)
+	if err != nil || batchCount < 1 || batchCount > 10000 {
+		return nil, fmt.Errorf("invalid option batchcount %s, expect int 1<=batchcount<=10000", conf.Opts.BatchCount)
+	}
+	if conf.Opts.ScanCount < 0 || conf.Opts.ScanCount > 10000 {
+		return nil, fmt.Errorf("invalid option scancount %d, expect int 0<=scancount<=10000", conf.Opts.ScanCount)
+	}
+	if conf.Opts.DedupWindow < 0 {
+		return nil, fmt.Errorf("invalid option dedupwindow %d, expect int >=0", conf.Opts.DedupWindow)
+	}
+	if conf.Opts.DedupFilter < 0 {
+		return nil, fmt.Errorf("invalid option dedupfilter %d, expect int >=0", conf.Opts.DedupFilter)
+	}
+	dedupFalsePositive, err := strconv.ParseFloat(conf.Opts.DedupFalsePositive, 64)
+	if err != nil || dedupFalsePositive <= 0 || dedupFalsePositive >= 100 {
+		return nil, fmt.Errorf("invalid option dedupfalsepositive %s, expect 0<dedupfalsepositive<100",
+			conf.Opts.DedupFalsePositive)
+	}
+	for _, count := range []int{conf.Opts.HscanCount, conf.Opts.SscanCount, conf.Opts.ZscanCount} {
+		if count < 0 || count > 10000 {
+			return nil, fmt.Errorf("invalid option hscancount/sscancount/zscancount %d, expect int 0<=count<=10000", count)
+		}
+	}
+	if conf.Opts.LrangeCount < 0 || conf.Opts.LrangeCount > 100000 {
+		return nil, fmt.Errorf("invalid option lrangecount %d, expect int 0<=lrangecount<=100000", conf.Opts.LrangeCount)
+	}
+	if conf.Opts.StringWindow < 0 {
+		return nil, fmt.Errorf("invalid option stringwindow %d, expect int >=0", conf.Opts.StringWindow)
+	}
+	parallel := conf.Opts.Parallel
+	if parallel < 1 || parallel > 100 {
+		return nil, fmt.Errorf("invalid option parallel %d, expect 1<=parallel<=100", conf.Opts.Parallel)
+	}
+	if conf.Opts.DbParallel < 1 || conf.Opts.DbParallel > 16 {
+		return nil, fmt.Errorf("invalid option dbparallel %d, expect 1<=dbparallel<=16", conf.Opts.DbParallel)
+	}
+	if conf.Opts.DbParallel > 1 && len(conf.Opts.Checkpoint) != 0 {
+		return nil, fmt.Errorf("checkpoint isn't supported when dbparallel > 1")
+	}
+	if conf.Opts.PoolMaxIdle < 0 || conf.Opts.PoolMaxActive < 0 || conf.Opts.PoolIdleTimeout < 0 {
+		return nil, fmt.Errorf("invalid option poolmaxidle %d, poolmaxactive %d or poolidletimeout %d, expect int >=0",
+			conf.Opts.PoolMaxIdle, conf.Opts.PoolMaxActive, conf.Opts.PoolIdleTimeout)
+	}
+	if conf.Opts.PoolKeepAlive < 0 {
+		return nil, fmt.Errorf("invalid option poolkeepalive %d, expect int >=0", conf.Opts.PoolKeepAlive)
+	}
+	if conf.Opts.PoolKeepAlive > 0 && conf.Opts.PoolMaxIdle == 0 {
+		return nil, fmt.Errorf("poolkeepalive needs the connection pool, please set poolmaxidle")
+	}
+	if conf.Opts.PoolWarmUp && conf.Opts.PoolMaxIdle == 0 {
+		return nil, fmt.Errorf("poolwarmup needs the connection pool, please set poolmaxidle")
+	}
+	qps := conf.Opts.Qps
+	if qps < 1 || qps > 5000000 {
+		return nil, fmt.Errorf("invalid option qps %d, expect 1<=qps<=5000000", conf.Opts.Qps)
+	}
+	if conf.Opts.SourceAuthType != "auth" && conf.Opts.SourceAuthType != "adminauth" {
+		return nil, fmt.Errorf("invalid sourceauthtype %s, expect auth/adminauth", conf.Opts.SourceAuthType)
+	}
+	if conf.Opts.TargetAuthType != "auth" && conf.Opts.TargetAuthType != "adminauth" {
+		return nil, fmt.Errorf("invalid targetauthtype %s, expect auth/adminauth", conf.Opts.TargetAuthType)
+	}
+	if conf.Opts.CompareMode < FullValue || conf.Opts.CompareMode > ExpireTime {
+		return nil, fmt.Errorf("invalid compare mode %d", conf.Opts.CompareMode)
+	}
+	if conf.Opts.CompareMode == KeyExistence {
+		// the keys scanned from the target are looked up on the source by the same name
+		if len(conf.Opts.KeyRewrite) != 0 || len(conf.Opts.KeyHashTag) != 0 || conf.Opts.TargetKeyTransform != nil {
+			return nil, fmt.Errorf("keyrewrite, keyhashtag and the key transform aren't supported in comparemode %d",
+				KeyExistence)
+		}
+		if conf.Opts.TargetDBType != common.TypeDB && conf.Opts.TargetDBType != common.TypeCluster {
+			return nil, fmt.Errorf("targetdbtype %d isn't supported in comparemode %d", conf.Opts.TargetDBType,
+				KeyExistence)
+		}
+	}
+	if conf.Opts.BigKeyThreshold < 0 {
+		return nil, fmt.Errorf("invalid big key threshold: %d", conf.Opts.BigKeyThreshold)
+	} else if conf.Opts.BigKeyThreshold == 0 {
+		common.BigKeyThreshold = 16384
+	} else {
+		common.BigKeyThreshold = conf.Opts.BigKeyThreshold
+	}
+
+	if conf.Opts.ResultFormat != ResultFormatText && conf.Opts.ResultFormat != ResultFormatJson &&
+		conf.Opts.ResultFormat != ResultFormatCsv {
+		return nil, fmt.Errorf("invalid result format %s, expect text/json/csv", conf.Opts.ResultFormat)
+	}
+	common.AlwaysEncodeName = conf.Opts.EncodeKey
+	if conf.Opts.UnverifiedLimit < 0 {
+		return nil, fmt.Errorf("invalid option unverifiedthreshold %d, expect int >=0", conf.Opts.UnverifiedLimit)
+	}
+	if conf.Opts.FailThreshold < 0 {
+		return nil, fmt.Errorf("invalid option failthreshold %d, expect int >=0", conf.Opts.FailThreshold)
+	}
+	if conf.Opts.MetricPort < 0 || conf.Opts.MetricPort > 65535 {
+		return nil, fmt.Errorf("invalid metric port %d, expect 0<=metricport<=65535", conf.Opts.MetricPort)
+	}
+	if conf.Opts.MaxDuration < 0 {
+		return nil, fmt.Errorf("invalid max duration: %d", conf.Opts.MaxDuration)
+	}
+	if conf.Opts.MaxIdleTime < 0 {
+		return nil, fmt.Errorf("invalid max idle time: %d", conf.Opts.MaxIdleTime)
+	}
+	if conf.Opts.TargetStaleness < 0 {
+		return nil, fmt.Errorf("invalid option targetstaleness %d, expect int >=0", conf.Opts.TargetStaleness)
+	}
+	if conf.Opts.MemoryRatio < 0 {
+		return nil, fmt.Errorf("invalid memory ratio: %d", conf.Opts.MemoryRatio)
+	}
+	if conf.Opts.HllTolerance < 0 || conf.Opts.HllTolerance > 100 {
+		return nil, fmt.Errorf("invalid hll tolerance %d, expect 0<=hlltolerance<=100", conf.Opts.HllTolerance)
+	}
+	if conf.Opts.GeoTolerance < 0 {
+		return nil, fmt.Errorf("invalid geo tolerance: %d", conf.Opts.GeoTolerance)
+	}
+	scoreEpsilon, err := strconv.ParseFloat(conf.Opts.ScoreEpsilon, 64)
+	if err != nil || scoreEpsilon < 0 {
+		return nil, fmt.Errorf("invalid option scoreepsilon %s, expect scoreepsilon>=0", conf.Opts.ScoreEpsilon)
+	}
+	sampleRate, err := strconv.ParseFloat(conf.Opts.SampleRate, 64)
+	if err != nil || sampleRate <= 0 || sampleRate > 100 {
+		return nil, fmt.Errorf("invalid option samplerate %s, expect 0<samplerate<=100", conf.Opts.SampleRate)
+	}
+	if conf.Opts.DiffFieldLimit < 0 {
+		return nil, fmt.Errorf("invalid option difffieldlimit %d, expect int >=0", conf.Opts.DiffFieldLimit)
+	}
+	if conf.Opts.ConnectTimeout < 0 || conf.Opts.CommandTimeout < 0 {
+		return nil, fmt.Errorf("invalid option connecttimeout %d or commandtimeout %d, expect int >=0",
+			conf.Opts.ConnectTimeout, conf.Opts.CommandTimeout)
+	}
+	if conf.Opts.PipelineBatch < 0 {
+		return nil, fmt.Errorf("invalid option pipelinebatch %d, expect int >=0", conf.Opts.PipelineBatch)
+	}
+	if conf.Opts.MaxValueSize < 0 {
+		return nil, fmt.Errorf("invalid max value size: %d", conf.Opts.MaxValueSize)
+	}
+	var bandwidth *common.ByteLimiter
+	if conf.Opts.Bandwidth < 0 {
+		return nil, fmt.Errorf("invalid option bandwidth %d, expect int >=0", conf.Opts.Bandwidth)
+	} else if conf.Opts.Bandwidth > 0 {
+		bandwidth = common.NewByteLimiter(conf.Opts.Bandwidth)
+	}
+	if conf.Opts.MaxValueCount < 0 {
+		return nil, fmt.Errorf("invalid max value count: %d", conf.Opts.MaxValueCount)
+	}
+	if conf.Opts.ResultMaxSize < 0 || conf.Opts.ResultMaxFiles < 0 {
+		return nil, fmt.Errorf("invalid option resultmaxsize %d or resultmaxfiles %d, expect int >=0",
+			conf.Opts.ResultMaxSize, conf.Opts.ResultMaxFiles)
+	}
+	if conf.Opts.ShallowTooLarge && conf.Opts.SkipTooLarge {
+		return nil, fmt.Errorf("shallowtoolarge and skiptoolarge can't be both set")
+	}
+	if conf.Opts.ShallowTooLarge && conf.Opts.MaxValueSize == 0 && conf.Opts.MaxValueCount == 0 {
+		return nil, fmt.Errorf("shallowtoolarge requires maxvaluesize or maxvaluecount")
+	}
+	if conf.Opts.SetPrecheck < 0 {
+		return nil, fmt.Errorf("invalid option setprecheck %d, expect int >=0", conf.Opts.SetPrecheck)
+	}
+	if conf.Opts.SetSpotCheck < 0 || conf.Opts.SetDiffSample < 0 {
+		return nil, fmt.Errorf("invalid option setspotcheck %d or setdiffsample %d, expect int >=0",
+			conf.Opts.SetSpotCheck, conf.Opts.SetDiffSample)
+	}
+	if conf.Opts.ListHeadDrift < 0 || conf.Opts.ListTailDrift < 0 {
+		return nil, fmt.Errorf("invalid option listheaddrift %d or listtaildrift %d, expect int >=0",
+			conf.Opts.ListHeadDrift, conf.Opts.ListTailDrift)
+	}
+	if conf.Opts.ExpireWindow < 0 {
+		return nil, fmt.Errorf("invalid option expirewindow %d, expect int >=0", conf.Opts.ExpireWindow)
+	}
+	if conf.Opts.TTLTolerance < 0 {
+		return nil, fmt.Errorf("invalid ttl tolerance: %d", conf.Opts.TTLTolerance)
+	}
+	if conf.Opts.RetryCount <= 0 {
+		return nil, fmt.Errorf("invalid option retrycount %d, expect int >0", conf.Opts.RetryCount)
+	}
+	if conf.Opts.RetryInterval <= 0 {
+		return nil, fmt.Errorf("invalid option retryinterval %d, expect int >0", conf.Opts.RetryInterval)
+	}
+	if conf.Opts.RetryMaxInterval < 0 {
+		return nil, fmt.Errorf("invalid option retrymaxinterval %d, expect int >=0", conf.Opts.RetryMaxInterval)
+	}
+	retryBackoff, err := common.NewBackoff(conf.Opts.RetryBackoff,
+		time.Duration(conf.Opts.RetryInterval)*time.Millisecond, time.Duration(conf.Opts.RetryMaxInterval)*time.Millisecond)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option retrybackoff: %v", err)
+	}
+
+	if strings.HasPrefix(conf.Opts.SourceAddr, client.RdbFilePrefix) &&
+		(conf.Opts.SourceDBType != common.TypeDB || len(conf.Opts.SourceSentinel) != 0) {
+		return nil, fmt.Errorf("rdb file source is only supported when sourcedbtype is 0 without sentinel")
+	}
+	if len(conf.Opts.SourceReplica) != 0 && (conf.Opts.SourceDBType != common.TypeDB ||
+		len(conf.Opts.SourceSentinel) != 0 || strings.HasPrefix(conf.Opts.SourceAddr, client.RdbFilePrefix)) {
+		return nil, fmt.Errorf("sourcereplica is only supported when sourcedbtype is 0 without sentinel, " +
+			"use sourcereadonly with \"slave@\" for the cluster")
+	}
+	if strings.HasPrefix(conf.Opts.SourceAddr, client.RdbFilePrefix) && conf.Opts.TargetStaleness > 0 {
+		return nil, fmt.Errorf("targetstaleness isn't supported for the rdb file source")
+	}
+
+	// the credentials are fetched on every new connection, the password of the address discovery is fetched once here
+	sourceCredential := credential(conf.Opts.SourcePasswordEnv, conf.Opts.SourceCredential)
+	targetCredential := credential(conf.Opts.TargetPasswordEnv, conf.Opts.TargetCredential)
+	sourcePassword, err := initialPassword(conf.Opts.SourcePassword, sourceCredential)
+	if err != nil {
+		return nil, fmt.Errorf("fetch source credential failed[%v]", err)
+	}
+	targetPassword, err := initialPassword(conf.Opts.TargetPassword, targetCredential)
+	if err != nil {
+		return nil, fmt.Errorf("fetch target credential failed[%v]", err)
+	}
+
+	var sourceAddressList, sourceSentinelList []string
+	if len(conf.Opts.SourceSentinel) != 0 {
+		if conf.Opts.SourceDBType != common.TypeDB {
+			return nil, fmt.Errorf("sentinel is only supported when sourcedbtype is 0")
+		}
+		sourceSentinelList, sourceAddressList, err = client.HandleSentinelAddress(conf.Opts.SourceAddr,
+			conf.Opts.SourceSentinel)
+	} else {
+		sourceAddressList, err = client.HandleAddress(conf.Opts.SourceAddr, sourcePassword,
+			conf.Opts.SourceAuthType, conf.Opts.SourceDBType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("source address[%v] illegal[%v]", conf.Opts.SourceAddr, err)
+	} else if len(sourceAddressList) > 1 && conf.Opts.SourceDBType != 1 {
+		return nil, fmt.Errorf("looks like the source is cluster? please set sourcedbtype")
+	} else if len(sourceAddressList) == 0 {
+		return nil, fmt.Errorf("input source address is empty")
+	}
+
+	// the replica is read as the source, the primary is only asked for the replication offset
+	var sourcePrimary []string
+	if len(conf.Opts.SourceReplica) != 0 {
+		replicaAddressList, err := client.HandleAddress(conf.Opts.SourceReplica, sourcePassword,
+			conf.Opts.SourceAuthType, conf.Opts.SourceDBType)
+		if err != nil || len(replicaAddressList) != 1 {
+			return nil, fmt.Errorf("source replica address[%v] illegal[%v]", conf.Opts.SourceReplica, err)
+		}
+		sourcePrimary, sourceAddressList = sourceAddressList, replicaAddressList
+	}
+
+	var targetAddressList, targetSentinelList []string
+	if len(conf.Opts.TargetSentinel) != 0 {
+		if conf.Opts.TargetDBType != common.TypeDB {
+			return nil, fmt.Errorf("sentinel is only supported when targetdbtype is 0")
+		}
+		targetSentinelList, targetAddressList, err = client.HandleSentinelAddress(conf.Opts.TargetAddr,
+			conf.Opts.TargetSentinel)
+	} else {
+		targetAddressList, err = client.HandleAddress(conf.Opts.TargetAddr, targetPassword,
+			conf.Opts.TargetAuthType, conf.Opts.TargetDBType)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("target address[%v] illegal[%v]", conf.Opts.TargetAddr, err)
+	} else if len(targetAddressList) > 1 && conf.Opts.TargetDBType != 1 {
+		return nil, fmt.Errorf("looks like the target is cluster? please set targetdbtype")
+	} else if len(targetAddressList) == 0 {
+		return nil, fmt.Errorf("input target address is empty")
+	}
+
+	// the fan-out targets share the target options except the address
+	var fanOutAddressList [][]string
+	if len(conf.Opts.FanOutTarget) != 0 {
+		if len(conf.Opts.TargetSentinel) != 0 || conf.Opts.DbParallel > 1 || len(conf.Opts.Checkpoint) != 0 ||
+			conf.Opts.DryRun || conf.Opts.CompareMode == KeyExistence {
+			return nil, fmt.Errorf("fanouttarget isn't supported with targetsentinel, dbparallel, checkpoint, "+
+				"dryrun or comparemode %d", KeyExistence)
+		}
+		for _, addr := range strings.Split(conf.Opts.FanOutTarget, "|") {
+			addressList, err := client.HandleAddress(addr, targetPassword, conf.Opts.TargetAuthType,
+				conf.Opts.TargetDBType)
+			if err != nil {
+				return nil, fmt.Errorf("fan-out target address[%v] illegal[%v]", addr, err)
+			} else if len(addressList) > 1 && conf.Opts.TargetDBType != 1 {
+				return nil, fmt.Errorf("looks like the fan-out target[%v] is cluster? please set targetdbtype", addr)
+			} else if len(addressList) == 0 {
+				return nil, fmt.Errorf("input fan-out target address is empty")
+			}
+			fanOutAddressList = append(fanOutAddressList, addressList)
+		}
+	}
+
+	// filter list
+	var filterTree *common.Trie
+	if len(conf.Opts.FilterList) != 0 {
+		filterTree = common.NewTrie()
+		filterList := strings.Split(conf.Opts.FilterList, "|")
+		for _, filter := range filterList {
+			if filter == "" {
+				return nil, fmt.Errorf("invalid input filter list: %v", filterList)
+			}
+			filterTree.Insert([]byte(filter))
+		}
+		common.Logger.Infof("filter list enabled: %v", filterList)
+	}
+
+	// match pattern list
+	var matchList []string
+	if len(conf.Opts.Match) != 0 {
+		matchList = strings.Split(conf.Opts.Match, "|")
+		for _, pattern := range matchList {
+			if pattern == "" {
+				return nil, fmt.Errorf("invalid input match pattern: %v", matchList)
+			}
+		}
+		common.Logger.Infof("match pattern enabled: %v", matchList)
+	}
+
+	var bitmapMatchList []string
+	if len(conf.Opts.BitmapMatch) != 0 {
+		bitmapMatchList = strings.Split(conf.Opts.BitmapMatch, "|")
+		for _, pattern := range bitmapMatchList {
+			if len(pattern) == 0 {
+				return nil, fmt.Errorf("invalid input bitmap match pattern: %v", bitmapMatchList)
+			}
+		}
+		common.Logger.Infof("bitmap match pattern enabled: %v", bitmapMatchList)
+	}
+
+	var geoMatchList []string
+	if len(conf.Opts.GeoMatch) != 0 {
+		geoMatchList = strings.Split(conf.Opts.GeoMatch, "|")
+		for _, pattern := range geoMatchList {
+			if len(pattern) == 0 {
+				return nil, fmt.Errorf("invalid input geo match pattern: %v", geoMatchList)
+			}
+		}
+		common.Logger.Infof("geo match pattern enabled: %v", geoMatchList)
+	}
+
+	// scan type list
+	var typeList []string
+	if len(conf.Opts.ScanType) != 0 {
+		typeList = strings.Split(conf.Opts.ScanType, common.Splitter)
+		for _, tp := range typeList {
+			if keyType := common.NewKeyType(tp); keyType == common.EndKeyType || keyType == common.NoneKeyType ||
+				keyType == common.ModuleKeyType {
+				return nil, fmt.Errorf("invalid input scan type: %v", typeList)
+			}
+		}
+		common.Logger.Infof("scan type enabled: %v", typeList)
+	}
+
+	if conf.Opts.Resume && len(conf.Opts.Checkpoint) == 0 {
+		return nil, fmt.Errorf("checkpoint file should be given when resume is enabled")
+	}
+	if conf.Opts.CheckpointInterval < 1 {
+		return nil, fmt.Errorf("invalid checkpoint interval %d, expect int >=1", conf.Opts.CheckpointInterval)
+	}
+
+	var keyList map[int32][][]byte
+	if len(conf.Opts.KeyFile) != 0 {
+		if len(conf.Opts.Checkpoint) != 0 || len(conf.Opts.FanOutTarget) != 0 {
+			return nil, fmt.Errorf("keyfile isn't supported with checkpoint or fanouttarget")
+		}
+		if keyList, err = LoadKeyFile(conf.Opts.KeyFile); err != nil {
+			return nil, fmt.Errorf("load key file[%v] failed: %v", conf.Opts.KeyFile, err)
+		}
+		for db := range keyList {
+			if db != 0 && (conf.Opts.SourceDBType == common.TypeCluster || conf.Opts.SourceNoSelect) {
+				return nil, fmt.Errorf("only db 0 is supported in the key file for cluster or sourcenoselect, got db %d", db)
+			}
+		}
+		common.Logger.Infof("key file enabled: %v", conf.Opts.KeyFile)
+	}
+	if len(conf.Opts.Key) != 0 {
+		if _, err := common.DecodeName(conf.Opts.Key); err != nil {
+			return nil, fmt.Errorf("invalid option key[%v]: %v", conf.Opts.Key, err)
+		}
+		if conf.Opts.KeyDB != 0 && (conf.Opts.SourceDBType == common.TypeCluster || conf.Opts.SourceNoSelect) {
+			return nil, fmt.Errorf("only db 0 is supported in key for cluster or sourcenoselect, got db %d",
+				conf.Opts.KeyDB)
+		}
+	}
+
+	if conf.Opts.SourceNoTouch && conf.Opts.SourceDBType == common.TypeCluster {
+		return nil, fmt.Errorf("sourcenotouch isn't supported for cluster")
+	}
+
+	sourceDBFilter, err := common.FilterDBList(conf.Opts.SourceDBFilterList)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option sourcedbfilterlist: %v", err)
+	}
+	targetDBFilter, err := common.FilterDBList(conf.Opts.TargetDBFilterList)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option targetdbfilterlist: %v", err)
+	}
+
+	dbMapping, err := common.ParseDBMapping(conf.Opts.DBMapping)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option dbmapping: %v", err)
+	}
+	if len(dbMapping) != 0 {
+		if conf.Opts.SourceDBType == common.TypeCluster || conf.Opts.TargetDBType == common.TypeCluster {
+			return nil, fmt.Errorf("dbmapping isn't supported for cluster")
+		}
+		common.Logger.Infof("db mapping enabled: %v", dbMapping)
+	}
+
+	keyRewrite, err := common.ParseKeyRewrite(conf.Opts.KeyRewrite)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option keyrewrite: %v", err)
+	}
+	var keyMap *common.KeyMap
+	if len(conf.Opts.KeyMapFile) != 0 {
+		if keyMap, err = common.LoadKeyMap(conf.Opts.KeyMapFile); err != nil {
+			return nil, fmt.Errorf("load keymapfile[%v] failed: %v", conf.Opts.KeyMapFile, err)
+		}
+		// the keys scanned from the target are looked up on the source by the reversed pairs
+		if conf.Opts.CompareMode == KeyExistence && keyMap.Reversible() == false {
+			return nil, fmt.Errorf("the regex rules of keymapfile aren't supported in comparemode %d", KeyExistence)
+		}
+	}
+
+	sourceTransform, err := common.ParseValueTransform(conf.Opts.SourceTransform)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option sourcetransform[%v]: %v", conf.Opts.SourceTransform, err)
+	}
+	targetTransform, err := common.ParseValueTransform(conf.Opts.TargetTransform)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option targettransform[%v]: %v", conf.Opts.TargetTransform, err)
+	}
+	keyHashTag, err := common.ParseKeyHashTag(conf.Opts.KeyHashTag)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option keyhashtag: %v", err)
+	}
+	valueCommand, err := common.ParseValueCommand(conf.Opts.ValueCommand)
+	if err != nil {
+		return nil, fmt.Errorf("invalid option valuecommand: %v", err)
+	}
+
+	// remove result file if has, keep it when resuming
+	if len(conf.Opts.ResultFile) > 0 && conf.Opts.Resume == false {
+		os.Remove(conf.Opts.ResultFile)
+		for i := range fanOutAddressList {
+			os.Remove(fanOutFile(conf.Opts.ResultFile, i+1))
+		}
+	}
+
+	fullCheckParameter := checker.FullCheckParameter{
+		SourceHost: client.RedisHost{
+			Addr:         sourceAddressList,
+			Password:     conf.Opts.SourcePassword,
+			Credential:   sourceCredential,
+			Role:         "source",
+			Authtype:     conf.Opts.SourceAuthType,
+			DBType:       conf.Opts.SourceDBType,
+			DBFilterList: sourceDBFilter,
+			ReadOnly:     conf.Opts.SourceReadOnly,
+			NoSelect:     conf.Opts.SourceNoSelect,
+			NoTouch:      conf.Opts.SourceNoTouch,
+
+			SentinelList:   sourceSentinelList,
+			SentinelMaster: conf.Opts.SourceSentinel,
+			ValueCommand:   valueCommand,
+			ValueTransform: sourceTransform,
+
+			PoolMaxIdle:     conf.Opts.PoolMaxIdle,
+			PoolMaxActive:   conf.Opts.PoolMaxActive,
+			PoolIdleTimeout: conf.Opts.PoolIdleTimeout,
+			PoolKeepAlive:   conf.Opts.PoolKeepAlive,
+
+			ConnectTimeoutMs: uint64(conf.Opts.ConnectTimeout),
+			CommandTimeoutMs: uint64(conf.Opts.CommandTimeout),
+			PipelineBatch:    conf.Opts.PipelineBatch,
+			AdaptiveScan:     conf.Opts.AdaptiveScan,
+			RecordLatency:    conf.Opts.LatencyHistogram,
+
+			RetryCount:   conf.Opts.RetryCount,
+			RetryBackoff: retryBackoff,
+
+			Bandwidth: bandwidth,
+		},
+		TargetHost: client.RedisHost{
+			Addr:         targetAddressList,
+			Password:     conf.Opts.TargetPassword,
+			Credential:   targetCredential,
+			Role:         "target",
+			Authtype:     conf.Opts.TargetAuthType,
+			DBType:       conf.Opts.TargetDBType,
+			DBFilterList: targetDBFilter,
+			ReadOnly:     conf.Opts.TargetReadOnly,
+			NoSelect:     conf.Opts.TargetNoSelect,
+
+			SentinelList:   targetSentinelList,
+			SentinelMaster: conf.Opts.TargetSentinel,
+			KeyMap:         keyMap,
+			KeyRewrite:     keyRewrite,
+			KeyHashTag:     keyHashTag,
+			KeyTransform:   conf.Opts.TargetKeyTransform,
+			ValueCommand:   valueCommand,
+			ValueTransform: targetTransform,
+
+			PoolMaxIdle:     conf.Opts.PoolMaxIdle,
+			PoolMaxActive:   conf.Opts.PoolMaxActive,
+			PoolIdleTimeout: conf.Opts.PoolIdleTimeout,
+			PoolKeepAlive:   conf.Opts.PoolKeepAlive,
+
+			ConnectTimeoutMs: uint64(conf.Opts.ConnectTimeout),
+			CommandTimeoutMs: uint64(conf.Opts.CommandTimeout),
+			PipelineBatch:    conf.Opts.PipelineBatch,
+			AdaptiveScan:     conf.Opts.AdaptiveScan,
+			RecordLatency:    conf.Opts.LatencyHistogram,
+
+			RetryCount:   conf.Opts.RetryCount,
+			RetryBackoff: retryBackoff,
+
+			Bandwidth: bandwidth,
+		},
+		SourcePrimary:   sourcePrimary,
+		ResultDBFile:    conf.Opts.ResultDBFile,
+		CompareCount:    compareCount,
+		Interval:        conf.Opts.Interval,
+		BatchCount:      batchCount,
+		KeyScanCount:    conf.Opts.ScanCount,
+		DedupWindow:     conf.Opts.DedupWindow,
+		DedupFilter:     conf.Opts.DedupFilter * 1024 * 1024,
+		DedupFpRate:     dedupFalsePositive / 100,
+		HscanCount:      conf.Opts.HscanCount,
+		SscanCount:      conf.Opts.SscanCount,
+		ZscanCount:      conf.Opts.ZscanCount,
+		LrangeCount:     conf.Opts.LrangeCount,
+		StringWindow:    conf.Opts.StringWindow,
+		Parallel:        parallel,
+		DbParallel:      conf.Opts.DbParallel,
+		PoolWarmUp:      conf.Opts.PoolWarmUp,
+		StrictDB:        conf.Opts.StrictDB,
+		StrictVersion:   conf.Opts.StrictVersion,
+		DBMapping:       dbMapping,
+		FilterTree:      filterTree,
+		MatchList:       matchList,
+		KeyList:         keyList,
+		TypeList:        typeList,
+		MaxIdleTime:     conf.Opts.MaxIdleTime,
+		TargetStaleness: conf.Opts.TargetStaleness,
+		MaxDuration:     conf.Opts.MaxDuration,
+		SampleRate:      sampleRate / 100,
+		CompareTTL:      conf.Opts.CompareTTL,
+		TTLTolerance:    conf.Opts.TTLTolerance,
+		ExpireWindow:    conf.Opts.ExpireWindow,
+		CompareEncoding: conf.Opts.CompareEncoding,
+		MemoryRatio:     float64(conf.Opts.MemoryRatio) / 100,
+		CompareDigest:   conf.Opts.CompareDigest,
+		CompareHll:      conf.Opts.CompareHll,
+		HllTolerance:    float64(conf.Opts.HllTolerance) / 100,
+		GeoMatchList:    geoMatchList,
+		GeoTolerance:    float64(conf.Opts.GeoTolerance),
+		ScoreEpsilon:    scoreEpsilon,
+		BitmapMatchList: bitmapMatchList,
+		DiffFieldLimit:  conf.Opts.DiffFieldLimit,
+		MaxValueSize:    conf.Opts.MaxValueSize,
+		MaxValueCount:   conf.Opts.MaxValueCount,
+		SkipTooLarge:    conf.Opts.SkipTooLarge,
+		ShallowTooLarge: conf.Opts.ShallowTooLarge,
+		SetSpotCheck:    conf.Opts.SetSpotCheck,
+		SetPrecheck:     conf.Opts.SetPrecheck,
+		SetDiffSample:   conf.Opts.SetDiffSample,
+		ListHeadDrift:   conf.Opts.ListHeadDrift,
+		ListTailDrift:   conf.Opts.ListTailDrift,
+		EmptyAsMissing:  conf.Opts.EmptyAsMissing,
+		OneWay:          conf.Opts.OneWay,
+		FreshRecheck:    conf.Opts.FreshRecheck,
+	}
+	for _, addressList := range fanOutAddressList {
+		host := fullCheckParameter.TargetHost
+		host.Addr = addressList
+		fullCheckParameter.FanOutHosts = append(fullCheckParameter.FanOutHosts, host)
+	}
+
+	common.Logger.Info("configuration: ", conf.Opts)
+	common.Logger.Info("---------")
+
+	return NewFullCheck(fullCheckParameter, CheckType(conf.Opts.CompareMode)), nil
+}
+
+// the provider given by the library first, then the environment variable, nil means the static password
+func credential(env string, provider func() (string, error)) client.CredentialProvider {
+	if provider != nil {
+		return provider
+	}
+	if len(env) == 0 {
+		return nil
+	}
+	return func() (string, error) {
+		password, ok := os.LookupEnv(env)
+		if ok == false {
+			return "", fmt.Errorf("environment variable %s not set", env)
+		}
+		return password, nil
+	}
+}
+
+func initialPassword(password string, provider client.CredentialProvider) (string, error) {
+	if provider == nil {
+		return password, nil
+	}
+	return provider()
+}
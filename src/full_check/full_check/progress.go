@@ -0,0 +1,55 @@
+package full_check
+
+import (
+	"fmt"
+	"time"
+)
+
+// samples used to calculate the rolling scan speed
+const progressWindow = 6
+
+type progressSample struct {
+	time    time.Time
+	scanned int64
+}
+
+// estimate the progress of scanning one db by the key count in the keyspace
+type Progress struct {
+	db      int32
+	total   int64
+	samples []progressSample
+}
+
+func NewProgress(db int32, total int64) *Progress {
+	return &Progress{
+		db:      db,
+		total:   total,
+		samples: []progressSample{{time: time.Now(), scanned: 0}},
+	}
+}
+
+// record the keys scanned so far and return the progress, e.g., "db3: 420000/1000000 keys, 42%, ETA 6m0s"
+func (p *Progress) Update(scanned int64) string {
+	now := time.Now()
+	p.samples = append(p.samples, progressSample{time: now, scanned: scanned})
+	if len(p.samples) > progressWindow {
+		p.samples = p.samples[len(p.samples)-progressWindow:]
+	}
+
+	percent := int64(100)
+	if p.total > 0 && scanned < p.total {
+		percent = scanned * 100 / p.total
+	}
+
+	eta := "unknown"
+	first := p.samples[0]
+	if elapsed := now.Sub(first.time).Seconds(); elapsed > 0 && scanned > first.scanned {
+		speed := float64(scanned-first.scanned) / elapsed
+		remain := p.total - scanned
+		if remain < 0 {
+			remain = 0
+		}
+		eta = (time.Duration(float64(remain)/speed) * time.Second).String()
+	}
+	return fmt.Sprintf("db%d: %d/%d keys, %d%%, ETA %s", p.db, scanned, p.total, percent, eta)
+}
@@ -0,0 +1,94 @@
+package full_check
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+// start http server which exposes the metrics in prometheus text format on "/metrics"
+func (p *FullCheck) StartMetricServer(port int) {
+	mux := p.serveMux()
+	go func() {
+		if err := http.ListenAndServe(fmt.Sprintf(":%d", port), mux); err != nil {
+			common.Logger.Errorf("metric server on port[%v] exit[%v]", port, err)
+		}
+	}()
+	common.Logger.Infof("metric server listen on port[%v]", port)
+}
+
+// the handlers shared by the metric server and the status socket, all of them are read-only
+func (p *FullCheck) serveMux() *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/metrics", p.handleMetric)
+	mux.HandleFunc("/status", p.handleStatus)
+	return mux
+}
+
+func (p *FullCheck) handleMetric(w http.ResponseWriter, r *http.Request) {
+	var buf bytes.Buffer
+	times, _ := p.round()
+	// summed over the dbs compared concurrently when dbparallel > 1
+	stats, currentDB := p.currentStats()
+	var scanKeys, scanSpeed int64
+	var conflictKeys, conflictFields [common.EndKeyTypeIndex][common.NoneConflict]int64
+	for _, stat := range stats {
+		scanKeys += stat.Scan.Total()
+		scanSpeed += stat.Scan.Speed()
+		for i := common.KeyTypeIndex(0); i < common.EndKeyTypeIndex; i++ {
+			for j := common.ConflictType(0); j < common.NoneConflict; j++ {
+				conflictKeys[i][j] += stat.ConflictKey[i][j].Total()
+				conflictFields[i][j] += stat.ConflictField[i][j].Total()
+			}
+		}
+	}
+
+	writeMetricHead(&buf, "redis_full_check_compare_times", "gauge", "current round of the comparison")
+	fmt.Fprintf(&buf, "redis_full_check_compare_times %d\n", times)
+
+	writeMetricHead(&buf, "redis_full_check_current_db", "gauge",
+		"logical db being compared, the lowest one when dbparallel > 1")
+	fmt.Fprintf(&buf, "redis_full_check_current_db %d\n", currentDB)
+
+	writeMetricHead(&buf, "redis_full_check_scan_keys", "gauge", "keys scanned of the current dbs in the current round")
+	fmt.Fprintf(&buf, "redis_full_check_scan_keys %d\n", scanKeys)
+
+	writeMetricHead(&buf, "redis_full_check_scan_speed", "gauge", "keys scanned per second")
+	fmt.Fprintf(&buf, "redis_full_check_scan_speed %d\n", scanSpeed)
+
+	writeMetricHead(&buf, "redis_full_check_conflict_keys", "gauge",
+		"conflict keys of the current dbs in the current round")
+	for i := common.KeyTypeIndex(0); i < common.EndKeyTypeIndex; i++ {
+		for j := common.ConflictType(0); j < common.NoneConflict; j++ {
+			fmt.Fprintf(&buf, "redis_full_check_conflict_keys{type=\"%s\",conflict=\"%s\"} %d\n", i, j,
+				conflictKeys[i][j])
+		}
+	}
+
+	writeMetricHead(&buf, "redis_full_check_conflict_fields", "gauge",
+		"conflict fields of the current dbs in the current round")
+	for i := common.KeyTypeIndex(0); i < common.EndKeyTypeIndex; i++ {
+		for j := common.ConflictType(0); j < common.NoneConflict; j++ {
+			fmt.Fprintf(&buf, "redis_full_check_conflict_fields{type=\"%s\",conflict=\"%s\"} %d\n", i, j,
+				conflictFields[i][j])
+		}
+	}
+
+	writeMetricHead(&buf, "redis_full_check_net_error_retry_total", "counter",
+		"retries caused by the network error")
+	fmt.Fprintf(&buf, "redis_full_check_net_error_retry_total %d\n", client.NetErrorRetryCount())
+
+	writeMetricHead(&buf, "redis_full_check_server_busy_retry_total", "counter",
+		"retries caused by the LOADING or BUSY error reply")
+	fmt.Fprintf(&buf, "redis_full_check_server_busy_retry_total %d\n", client.ServerBusyRetryCount())
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4")
+	w.Write(buf.Bytes())
+}
+
+func writeMetricHead(buf *bytes.Buffer, name, tp, help string) {
+	fmt.Fprintf(buf, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, tp)
+}
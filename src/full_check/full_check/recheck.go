@@ -0,0 +1,75 @@
+package full_check
+
+import (
+	"full_check/client"
+	"full_check/common"
+)
+
+/*
+ * The reply read from a connection desynchronized by an earlier error may be taken as the value of
+ * another key. The conflict keys are verified again on new connections of both sides, and only the
+ * conflicts of the second time are reported.
+ */
+func (p *FullCheck) verifyFresh(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) {
+	conflicts := p.collectConflict(func(conflicts chan<- *common.Key) {
+		p.verifier.VerifyOneGroupKeyInfo(keyInfo, conflicts, sourceClient, targetClient)
+	})
+	if len(conflicts) == 0 {
+		return
+	}
+
+	freshSource, err := client.NewRedisClientContext(p.ctx, p.SourceHost, p.currentDB)
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
+			p.SourceHost, p.currentDB, err))
+	}
+	defer freshSource.Close()
+	freshTarget, err := client.NewRedisClientContext(p.ctx, p.TargetHost, p.TargetDB(p.currentDB))
+	if err != nil {
+		panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
+			p.TargetHost, p.TargetDB(p.currentDB), err))
+	}
+	defer freshTarget.Close()
+	defer p.recoverUnverified(conflicts, &freshSource, &freshTarget)
+
+	common.Logger.Debugf("verify %d conflict key(s) again on new connections", len(conflicts))
+	p.resetConflict(conflicts)
+	p.verifier.VerifyOneGroupKeyInfo(conflicts, conflictKey, &freshSource, &freshTarget)
+}
+
+// verify the keys, the conflicts are verified again on new connections when freshrecheck is set
+func (p *FullCheck) verifyKeys(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) {
+	if p.FreshRecheck {
+		p.verifyFresh(keyInfo, conflictKey, sourceClient, targetClient)
+	} else {
+		p.verifier.VerifyOneGroupKeyInfo(keyInfo, conflictKey, sourceClient, targetClient)
+	}
+}
+
+// the conflict keys sent by verify, which are held instead of being reported
+func (p *FullCheck) collectConflict(verify func(conflicts chan<- *common.Key)) []*common.Key {
+	conflicts := make(chan *common.Key)
+	done := make(chan []*common.Key, 1)
+	go func() {
+		collected := make([]*common.Key, 0)
+		for key := range conflicts {
+			collected = append(collected, key)
+		}
+		done <- collected
+	}()
+	func() {
+		defer close(conflicts)
+		verify(conflicts)
+	}()
+	return <-done
+}
+
+// the keys are verified again from fetching the type, the conflicts counted before are taken back
+func (p *FullCheck) resetConflict(keyInfo []*common.Key) {
+	for _, key := range keyInfo {
+		p.stat.ConflictKey[key.Tp.Index][key.ConflictType].Inc(-1)
+		*key = common.Key{Key: key.Key, Db: key.Db, Tp: common.EndKeyType, ConflictType: common.EndConflict}
+	}
+}
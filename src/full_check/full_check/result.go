@@ -0,0 +1,349 @@
+package full_check
+
+import (
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"sort"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"full_check/client"
+	"full_check/common"
+	"full_check/configure"
+)
+
+const (
+	ResultFormatText = "text"
+	ResultFormatJson = "json"
+	ResultFormatCsv  = "csv"
+)
+
+var csvHeader = []string{"db", "key", "type", "conflict_type", "source_len", "target_len", "detail"}
+
+type ResultField struct {
+	Field        string `json:"field"`
+	ConflictType string `json:"conflict_type"`
+}
+
+// one conflict key per line in json format
+type ResultKey struct {
+	Db           int32         `json:"db"`
+	Key          string        `json:"key"`
+	Type         string        `json:"type"`
+	ConflictType string        `json:"conflict_type"`
+	SourceLen    int64         `json:"source_len"`
+	TargetLen    int64         `json:"target_len"`
+	Field        []ResultField `json:"field,omitempty"`
+}
+
+// the keys scanned in the first round and the conflict keys in the last round of one db
+type DBResult struct {
+	ScanKeys     int64 `json:"scan_keys"`
+	ConflictKeys int64 `json:"conflict_keys"`
+}
+
+// the last line in json format
+type ResultSummary struct {
+	Summary        bool                        `json:"summary"`
+	ScanKeys       int64                       `json:"scan_keys"`
+	ConflictKeys   int64                       `json:"conflict_keys"`
+	ConflictFields int64                       `json:"conflict_fields"`
+	Conflict       map[string]int64            `json:"conflict"`
+	TypeMismatch   int64                       `json:"type_mismatch,omitempty"`
+	UnverifiedKeys int64                       `json:"unverified_keys,omitempty"`
+	TargetOnlyKeys int64                       `json:"target_only_keys,omitempty"` // not conflicts when oneway is set
+	ShallowKeys    int64                       `json:"shallow_keys,omitempty"`     // equal without comparing the whole value
+	SkippedDBs     []int32                     `json:"skipped_dbs,omitempty"`
+	TargetLag      *ReplicaLag                 `json:"target_lag,omitempty"`
+	SourceSnapshot *SourceSnapshot             `json:"source_snapshot,omitempty"`
+	ConflictByType map[string]map[string]int64 `json:"conflict_by_type"` // key type -> conflict type -> count
+	ConflictByDB   map[int32]*DBResult         `json:"conflict_by_db,omitempty"`
+	ElapsedMs      int64                       `json:"elapsed_ms"`
+	SampleRate     float64                     `json:"sample_rate,omitempty"`   // percent, omitted when not sampling
+	ConflictRate   float64                     `json:"conflict_rate,omitempty"` // percent of the sampled keys
+	EstimatedKeys  int64                       `json:"estimated_conflict_keys,omitempty"`
+	FanOut         []ResultSummary             `json:"fan_out,omitempty"` // one per fan-out target in order
+	Partial        bool                        `json:"partial,omitempty"`
+	Coverage       float64                     `json:"coverage,omitempty"`
+	Latency        LatencySummary              `json:"latency,omitempty"`
+	Size           *SizeSummary                `json:"size,omitempty"` // recorded when sizehistogram is set
+}
+
+// role -> key type -> latency, recorded when latencyhistogram is enabled
+type LatencySummary map[string]map[string]common.LatencyStat
+
+func (p *FullCheck) writeJsonResult(resultfile io.Writer, oneKeyInfo *common.Key) {
+	result := ResultKey{
+		Db:           p.currentDB,
+		Key:          common.EncodeName(oneKeyInfo.Key),
+		Type:         oneKeyInfo.Tp.Name,
+		ConflictType: oneKeyInfo.ConflictType.String(),
+		SourceLen:    oneKeyInfo.SourceAttr.ItemCount,
+		TargetLen:    oneKeyInfo.TargetAttr.ItemCount,
+	}
+	for _, field := range oneKeyInfo.Field {
+		result.Field = append(result.Field, ResultField{
+			Field:        common.EncodeName(field.Field),
+			ConflictType: field.ConflictType.String(),
+		})
+	}
+	writeJsonLine(resultfile, result)
+}
+
+func (p *FullCheck) writeJsonSummary() {
+	writeJsonLine(p.result, p.Summary())
+}
+
+// the result file is rotated by resultmaxsize, every csv file begins with the header
+func newResultWriter(path string) *common.RotateWriter {
+	if len(path) == 0 {
+		return nil
+	}
+	writer := common.NewRotateWriter(path, conf.Opts.ResultMaxSize*1024*1024, conf.Opts.ResultMaxFiles)
+	if conf.Opts.ResultFormat == ResultFormatCsv {
+		writer.Header = writeCsvHeader
+	}
+	return writer
+}
+
+// the conflicts of the last round, or the partial result when stopped
+func (p *FullCheck) Summary() ResultSummary {
+	summary := ResultSummary{
+		Summary:        true,
+		ScanKeys:       p.totalScanKeys,
+		ConflictKeys:   p.stat.TotalConflictKeys,
+		ConflictFields: p.stat.TotalConflictFields,
+		Conflict:       p.resultConflict,
+		TypeMismatch:   p.resultConflict[common.TypeMismatchConflict.String()],
+		UnverifiedKeys: p.unverified.Count(),
+		TargetOnlyKeys: p.targetOnly,
+		ShallowKeys:    p.shallowKeys(),
+		SkippedDBs:     p.SkippedDB(),
+		TargetLag:      p.targetLag,
+		SourceSnapshot: p.sourceSnapshot,
+		ConflictByType: p.conflictByType,
+		ConflictByDB:   p.resultByDB,
+		ElapsedMs:      int64(time.Since(p.startTime) / time.Millisecond),
+	}
+	if p.SampleRate < 1 {
+		summary.SampleRate = p.SampleRate * 100
+		summary.ConflictRate, summary.EstimatedKeys = p.extrapolateConflict()
+	}
+	for _, lane := range p.fanOut {
+		summary.FanOut = append(summary.FanOut, lane.Summary())
+	}
+	// stopped before finished, the percent of the keyspace scanned tells how much is compared
+	if p.IsStopped() {
+		summary.Partial = true
+		summary.Coverage = p.coverage()
+	}
+	// the fan-out targets are included by the role target1, target2...
+	if p.SourceHost.RecordLatency && p.isFanOut == false {
+		summary.Latency = client.LatencyStats()
+	}
+	if p.sizes != nil {
+		summary.Size = p.sizes.Summary()
+	}
+	return summary
+}
+
+// percent of the keys in the keyspace scanned in the first round, all keys are scanned in the later rounds
+func (p *FullCheck) coverage() float64 {
+	if p.times > 1 {
+		return 100
+	}
+	var total int64
+	for _, keyNum := range p.sourceLogicalDBMap {
+		total += keyNum
+	}
+	if total == 0 || p.totalScanKeys >= total {
+		return 100
+	}
+	return float64(p.totalScanKeys) * 100 / float64(total)
+}
+
+// one line per role and key type, e.g., "latency of source hash: 100 call(s), p50 512µs, p95 1.024ms..."
+func (p *FullCheck) logLatency() {
+	if p.SourceHost.RecordLatency == false {
+		return
+	}
+	stats := client.LatencyStats()
+	roles := make([]string, 0, len(stats))
+	for role := range stats {
+		roles = append(roles, role)
+	}
+	sort.Strings(roles)
+
+	for _, role := range roles {
+		keyTypes := make([]string, 0, len(stats[role]))
+		for keyType := range stats[role] {
+			keyTypes = append(keyTypes, keyType)
+		}
+		sort.Strings(keyTypes)
+		for _, keyType := range keyTypes {
+			stat := stats[role][keyType]
+			common.Logger.Infof("latency of %s %s: %d call(s), p50 %v, p95 %v, p99 %v, max %v", role, keyType,
+				stat.Count, time.Duration(stat.P50Us)*time.Microsecond, time.Duration(stat.P95Us)*time.Microsecond,
+				time.Duration(stat.P99Us)*time.Microsecond, time.Duration(stat.MaxUs)*time.Microsecond)
+		}
+	}
+}
+
+// the keys regarded as equal without comparing the whole value in all the rounds
+func (p *FullCheck) shallowKeys() int64 {
+	var total int64
+	for i := range p.stat.ShallowKeys {
+		total += atomic.LoadInt64(&p.stat.ShallowKeys[i])
+	}
+	return total
+}
+
+func (p *FullCheck) logSize() {
+	if p.sizes != nil {
+		p.sizes.Log()
+	}
+}
+
+func (p *FullCheck) dbResult(db int32) *DBResult {
+	if _, ok := p.resultByDB[db]; !ok {
+		p.resultByDB[db] = new(DBResult)
+	}
+	return p.resultByDB[db]
+}
+
+func (p *FullCheck) addConflictByType(keyType, conflictType string, count int64) {
+	if _, ok := p.conflictByType[keyType]; !ok {
+		p.conflictByType[keyType] = make(map[string]int64)
+	}
+	p.conflictByType[keyType][conflictType] += count
+}
+
+// one line per key type, e.g., "zset: 3 key(s) conflict, lack_target: 2, value: 1"
+func (p *FullCheck) logConflictByType() {
+	// not conflicts, but the comparison doesn't cover them
+	if count := p.unverified.Count(); count > 0 {
+		common.Logger.Warnf("%d key(s) are left unverified since the network error lasts after all the retries",
+			count)
+	}
+	if dbs := p.SkippedDB(); len(dbs) != 0 {
+		common.Logger.Warnf("db %v are skipped and left unverified since failing to be connected", dbs)
+	}
+	if p.targetOnly > 0 {
+		common.Logger.Infof("%d key(s) only exist on the target, they aren't reported as conflicts since oneway "+
+			"is set", p.targetOnly)
+	}
+	// regarded as equal, but the value isn't compared
+	for i, count := range p.stat.ShallowKeys {
+		if count > 0 {
+			common.Logger.Infof("%d %v key(s) are regarded as equal without comparing the whole value, by the "+
+				"length and encoding of shallowtoolarge or the sampled members of setprecheck", count,
+				common.KeyTypeIndex(i))
+		}
+	}
+	// the keys of different types are usually written by a wrong client, so they are warned separately
+	if count := p.resultConflict[common.TypeMismatchConflict.String()]; count > 0 {
+		common.Logger.Warnf("%d key(s) exist in different types on source and target, see the conflict type %v",
+			count, common.TypeMismatchConflict)
+	}
+
+	keyTypes := make([]string, 0, len(p.conflictByType))
+	for keyType := range p.conflictByType {
+		keyTypes = append(keyTypes, keyType)
+	}
+	sort.Strings(keyTypes)
+
+	for _, keyType := range keyTypes {
+		conflict := p.conflictByType[keyType]
+		conflictTypes := make([]string, 0, len(conflict))
+		var total int64
+		for conflictType, count := range conflict {
+			conflictTypes = append(conflictTypes, conflictType)
+			total += count
+		}
+		sort.Strings(conflictTypes)
+
+		var buf bytes.Buffer
+		fmt.Fprintf(&buf, "%s: %d key(s) conflict", keyType, total)
+		for _, conflictType := range conflictTypes {
+			fmt.Fprintf(&buf, ", %s: %d", conflictType, conflict[conflictType])
+		}
+		common.Logger.Info(buf.String())
+	}
+
+	// the breakdown of every db when more than one is compared
+	if len(p.resultByDB) > 1 {
+		dbs := make([]int32, 0, len(p.resultByDB))
+		for db := range p.resultByDB {
+			dbs = append(dbs, db)
+		}
+		sort.Slice(dbs, func(i, j int) bool {
+			return dbs[i] < dbs[j]
+		})
+		for _, db := range dbs {
+			common.Logger.Infof("db %d: %d key(s) scanned, %d key(s) conflict", db, p.resultByDB[db].ScanKeys,
+				p.resultByDB[db].ConflictKeys)
+		}
+	}
+}
+
+// the conflict rate in percent of the sampled keys and the conflict keys extrapolated to all keys
+func (p *FullCheck) extrapolateConflict() (float64, int64) {
+	if p.totalScanKeys == 0 {
+		return 0, 0
+	}
+	rate := float64(p.stat.TotalConflictKeys) / float64(p.totalScanKeys)
+	return rate * 100, int64(float64(p.stat.TotalConflictKeys)/p.SampleRate + 0.5)
+}
+
+func (p *FullCheck) logSample() {
+	conflictRate, estimatedKeys := p.extrapolateConflict()
+	common.Logger.Infof("sample %v%% of keys: %d key(s) sampled, %d key(s) conflict, conflict rate %.4f%%, "+
+		"about %d key(s) conflict in all keys", p.SampleRate*100, p.totalScanKeys, p.stat.TotalConflictKeys,
+		conflictRate, estimatedKeys)
+}
+
+// one row per conflict field, or one row with empty detail when the key has no conflict field
+func (p *FullCheck) writeCsvResult(resultfile io.Writer, oneKeyInfo *common.Key) {
+	row := func(conflictType, detail string) []string {
+		return []string{
+			strconv.Itoa(int(p.currentDB)),
+			common.EncodeName(oneKeyInfo.Key),
+			oneKeyInfo.Tp.Name,
+			conflictType,
+			strconv.FormatInt(oneKeyInfo.SourceAttr.ItemCount, 10),
+			strconv.FormatInt(oneKeyInfo.TargetAttr.ItemCount, 10),
+			detail,
+		}
+	}
+
+	writer := csv.NewWriter(resultfile)
+	if len(oneKeyInfo.Field) == 0 {
+		writer.Write(row(oneKeyInfo.ConflictType.String(), ""))
+	}
+	for _, field := range oneKeyInfo.Field {
+		writer.Write(row(field.ConflictType.String(), common.EncodeName(field.Field)))
+	}
+	writer.Flush()
+	if err := writer.Error(); err != nil {
+		common.Logger.Errorf("write csv result of key[%s] failed[%v]", common.EncodeName(oneKeyInfo.Key), err)
+	}
+}
+
+func writeCsvHeader(resultfile io.Writer) {
+	writer := csv.NewWriter(resultfile)
+	writer.Write(csvHeader)
+	writer.Flush()
+}
+
+func writeJsonLine(resultfile io.Writer, v interface{}) {
+	line, err := json.Marshal(v)
+	if err != nil {
+		common.Logger.Errorf("marshal result[%v] failed[%v]", v, err)
+		return
+	}
+	resultfile.Write(append(line, '\n'))
+}
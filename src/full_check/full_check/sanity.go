@@ -0,0 +1,99 @@
+package full_check
+
+import (
+	"fmt"
+	"strings"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+/*
+ * Fetch the server attributes by INFO on the source and every target before comparing, and warn about
+ * the misconfigurations affecting the comparison, e.g., the keys evicted during the comparison are
+ * reported as missing. The incompatible versions for the comparemode abort the run when strictversion
+ * is set, otherwise they are only warned. The check is skipped when INFO fails, e.g., the rdb file.
+ */
+func (p *FullCheck) CheckServer() {
+	source, err := fetchInfo(p.SourceHost, 0, "default")
+	if err != nil {
+		common.Logger.Warnf("fetch the info of the source failed[%v], skip checking the server", err)
+		return
+	}
+	logServer("source", p.SourceHost, source)
+	checkEviction("source", source)
+	checkClusterEnabled("source", p.SourceHost, source)
+
+	incompatible := make([]string, 0)
+	for _, lane := range append([]*FullCheck{p}, p.fanOut...) {
+		target, err := fetchInfo(lane.TargetHost, lane.TargetDB(0), "default")
+		if err != nil {
+			common.Logger.Warnf("fetch the info of the target %v failed[%v], skip checking it", lane.TargetHost.Addr,
+				err)
+			continue
+		}
+		logServer("target", lane.TargetHost, target)
+		checkEviction("target", target)
+		checkClusterEnabled("target", lane.TargetHost, target)
+		incompatible = append(incompatible, p.checkVersion(source, target)...)
+	}
+
+	if len(incompatible) == 0 {
+		return
+	}
+	if p.StrictVersion {
+		panic(common.Logger.Errorf("incompatible version: %s", strings.Join(incompatible, "; ")))
+	}
+	for _, reason := range incompatible {
+		common.Logger.Warnf("incompatible version: %s, set strictversion to exit instead", reason)
+	}
+}
+
+func logServer(role string, host client.RedisHost, info map[string]string) {
+	common.Logger.Infof("%s %v: redis_version[%v], maxmemory_policy[%v], cluster_enabled[%v]", role, host.Addr,
+		info["redis_version"], info["maxmemory_policy"], info["cluster_enabled"])
+}
+
+// the keys evicted during the comparison are reported as lack_source or lack_target
+func checkEviction(role string, info map[string]string) {
+	policy := info["maxmemory_policy"]
+	if len(policy) != 0 && policy != "noeviction" && info["maxmemory"] != "0" {
+		common.Logger.Warnf("maxmemory-policy of the %s is %v, the keys evicted during the comparison are "+
+			"reported as missing on the %s", role, policy, role)
+	}
+}
+
+func checkClusterEnabled(role string, host client.RedisHost, info map[string]string) {
+	if enabled := info["cluster_enabled"] == "1"; len(info["cluster_enabled"]) != 0 && enabled != host.IsCluster() {
+		common.Logger.Warnf("cluster_enabled of the %s %v is %v but the dbtype is %v", role, host.Addr,
+			info["cluster_enabled"], host.DBType)
+	}
+}
+
+// the comparemode whose result depends on the versions of both sides
+func (p *FullCheck) checkVersion(source, target map[string]string) []string {
+	sourceMajor, _, sourceOk := common.ParseVersion(source["redis_version"])
+	targetMajor, _, targetOk := common.ParseVersion(target["redis_version"])
+	if !sourceOk || !targetOk {
+		return nil
+	}
+	ret := make([]string, 0)
+	if sourceMajor != targetMajor {
+		common.Logger.Warnf("the source %v and the target %v are of different major versions, the encoding "+
+			"and memory usage may differ", source["redis_version"], target["redis_version"])
+	}
+	switch p.checkType {
+	case DumpPayload:
+		// the rdb version changes across the major versions, all the keys fall back to comparemode 1
+		if sourceMajor != targetMajor {
+			ret = append(ret, fmt.Sprintf("the DUMP payload of the source %v and the target %v isn't comparable "+
+				"in comparemode %d", source["redis_version"], target["redis_version"], DumpPayload))
+		}
+	case ExpireTime:
+		if sourceMajor < 7 || targetMajor < 7 {
+			common.Logger.Warnf("PEXPIRETIME is supported since redis 7.0, the source %v or the target %v falls "+
+				"back to PTTL", source["redis_version"], target["redis_version"])
+		}
+	}
+	return ret
+}
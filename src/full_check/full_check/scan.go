@@ -2,6 +2,7 @@ package full_check
 
 import (
 	"strconv"
+	"strings"
 	"fmt"
 
 	"full_check/common"
@@ -12,14 +13,25 @@ import (
 )
 
 func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
+	defer close(allKeys)
 	var wg sync.WaitGroup
+	filter := p.newDedupFilter()
 
 	wg.Add(len(p.sourcePhysicalDBList))
 	for idx := 0; idx < len(p.sourcePhysicalDBList); idx++ {
 		// use goroutine to run db concurrently
 		go func(index int) {
 			defer wg.Done()
+			defer p.recoverCanceled()
+			node := p.sourcePhysicalDBList[index]
 			cursor := 0
+			if pos, ok := p.resumeCursor[node]; ok {
+				if pos == CursorFinished {
+					common.Logger.Infof("skip physical db[%v] which has been finished before resuming", node)
+					return
+				}
+				cursor = int(pos)
+			}
 			var sourceClient client.RedisClient
 			var err error
 
@@ -31,11 +43,11 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 				singleHost.Addr = []string{singleHost.Addr[index]}
 				singleHost.DBType = common.TypeDB
 				// build client by single db
-				if sourceClient, err = client.NewRedisClient(singleHost, p.currentDB); err != nil {
+				if sourceClient, err = client.NewRedisClientContext(p.ctx, singleHost, p.currentDB); err != nil {
 					panic(common.Logger.Critical(err))
 				}
 			} else {
-				sourceClient, err = client.NewRedisClient(p.SourceHost, p.currentDB)
+				sourceClient, err = client.NewRedisClientContext(p.ctx, p.SourceHost, p.currentDB)
 				if err != nil {
 					panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
 						p.SourceHost, p.currentDB, err))
@@ -45,7 +57,25 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 
 			common.Logger.Infof("build connection[%v]", sourceClient.String())
 
+			// only one pattern can be given in scan, so filter on the client side when multiple patterns given
+			var scanMatch []interface{}
+			if len(p.MatchList) == 1 {
+				scanMatch = []interface{}{"match", p.MatchList[0]}
+			}
+			// scan type is supported since redis 6.0, fallback to filter on the client side if not supported
+			var scanType []interface{}
+			if len(p.TypeList) == 1 {
+				scanType = []interface{}{"type", p.TypeList[0]}
+			}
+			scanCount := p.scanCount()
+			dedup := common.NewDedupWindow(p.DedupWindow)
+
 			for {
+				if p.IsStopped() {
+					common.Logger.Infof("stop scanning physical db[%v]", node)
+					break
+				}
+
 				var reply interface{}
 				var err error
 
@@ -53,11 +83,17 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 				case common.TypeDB:
 					fallthrough
 				case common.TypeCluster:
-					reply, err = sourceClient.Do("scan", cursor, "count", p.BatchCount)
+					args := append([]interface{}{cursor, "count", scanCount}, scanMatch...)
+					reply, err = sourceClient.Do("scan", append(args, scanType...)...)
+					if err != nil && len(scanType) != 0 && strings.HasPrefix(err.Error(), "ERR") {
+						common.Logger.Warnf("scan with type isn't supported[%v], filter type on the client side", err)
+						scanType = nil
+						continue
+					}
 				case common.TypeAliyunProxy:
-					reply, err = sourceClient.Do("iscan", index, cursor, "count", p.BatchCount)
+					reply, err = sourceClient.Do("iscan", index, cursor, "count", scanCount)
 				case common.TypeTencentProxy:
-					reply, err = sourceClient.Do("scan", cursor, "count", p.BatchCount, p.sourcePhysicalDBList[index])
+					reply, err = sourceClient.Do("scan", cursor, "count", scanCount, p.sourcePhysicalDBList[index])
 				}
 				if err != nil {
 					panic(common.Logger.Critical(err))
@@ -65,12 +101,12 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 
 				replyList, ok := reply.([]interface{})
 				if ok == false || len(replyList) != 2 {
-					panic(common.Logger.Criticalf("scan %d count %d failed, result: %+v", cursor, p.BatchCount, reply))
+					panic(common.Logger.Criticalf("scan %d count %d failed, result: %+v", cursor, scanCount, reply))
 				}
 
 				bytes, ok := replyList[0].([]byte)
 				if ok == false {
-					panic(common.Logger.Criticalf("scan %d count %d failed, result: %+v", cursor, p.BatchCount, reply))
+					panic(common.Logger.Criticalf("scan %d count %d failed, result: %+v", cursor, scanCount, reply))
 				}
 
 				cursor, err = strconv.Atoi(string(bytes))
@@ -94,6 +130,21 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 						continue
 					}
 
+					// check match pattern
+					if common.CheckMatch(p.MatchList, bytes) == false {
+						continue
+					}
+
+					// check sample
+					if common.CheckSample(bytes, p.SampleRate) == false {
+						continue
+					}
+
+					// the key returned again by scan
+					if dedup.Seen(bytes) || filter.Seen(bytes) {
+						continue
+					}
+
 					keysInfo = append(keysInfo, &common.Key{
 						Key:          bytes,
 						Tp:           common.EndKeyType,
@@ -101,6 +152,21 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 					})
 					// common.Logger.Debugf("read key: %v", string(bytes))
 				}
+				// check type list on the client side
+				if len(p.TypeList) != 0 && len(scanType) == 0 {
+					keysInfo = p.filterKeyType(&sourceClient, keysInfo)
+				}
+				// skip the cold keys
+				if p.MaxIdleTime != 0 {
+					keysInfo = p.filterIdleTime(&sourceClient, keysInfo)
+				}
+				if p.checkpoint != nil {
+					pos := int64(cursor)
+					if cursor == 0 {
+						pos = CursorFinished
+					}
+					p.checkpoint.Add(node, pos, keysInfo)
+				}
 				p.IncrScanStat(len(keysInfo))
 				allKeys <- keysInfo
 
@@ -112,10 +178,201 @@ func (p *FullCheck) ScanFromSourceRedis(allKeys chan<- []*common.Key) {
 	} // end fo for idx := 0; idx < p.sourcePhysicalDBList; idx++
 
 	wg.Wait()
-	close(allKeys)
+	p.checkDedupFilter(filter)
+	if p.checkType == KeyExistence {
+		p.ScanFromTargetRedis(allKeys)
+	}
+}
+
+// the bloom filter of all the keys scanned on the nodes of the db, nil when disabled
+func (p *FullCheck) newDedupFilter() *common.BloomFilter {
+	if p.DedupFilter == 0 {
+		return nil
+	}
+	return common.NewBloomFilter(p.DedupFilter, p.DedupFpRate)
+}
+
+func (p *FullCheck) checkDedupFilter(filter *common.BloomFilter) {
+	if filter.Overflow() {
+		common.Logger.Warnf("more keys of db[%v] are scanned than the capacity of dedupfilter %d byte(s), the "+
+			"false positive rate exceeds %v%%, increase dedupfilter", p.currentDB, p.DedupFilter,
+			p.DedupFpRate*100)
+	}
+}
+
+/*
+ * Scan the target and only pass on the keys missing on the source, which are reported as lack_source
+ * by the verifier. The keys existing on both sides have been verified by the scan on the source. The
+ * checkpoint isn't saved, so the target is scanned from the beginning when resuming.
+ */
+func (p *FullCheck) ScanFromTargetRedis(allKeys chan<- []*common.Key) {
+	targetDB := p.TargetDB(p.currentDB)
+	var hosts []client.RedisHost
+	if p.TargetHost.IsCluster() {
+		for _, addr := range p.TargetHost.Addr {
+			var singleHost client.RedisHost
+			copier.Copy(&singleHost, &p.TargetHost)
+			singleHost.Addr = []string{addr}
+			singleHost.DBType = common.TypeDB
+			hosts = append(hosts, singleHost)
+		}
+	} else {
+		hosts = append(hosts, p.TargetHost)
+	}
+
+	var wg sync.WaitGroup
+	filter := p.newDedupFilter()
+	wg.Add(len(hosts))
+	for _, host := range hosts {
+		go func(host client.RedisHost) {
+			defer wg.Done()
+			defer p.recoverCanceled()
+
+			targetClient, err := client.NewRedisClientContext(p.ctx, host, targetDB)
+			if err != nil {
+				panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]", host, targetDB, err))
+			}
+			defer targetClient.Close()
+			sourceClient, err := client.NewRedisClientContext(p.ctx, p.SourceHost, p.currentDB)
+			if err != nil {
+				panic(common.Logger.Errorf("create redis client with host[%v] db[%v] error[%v]",
+					p.SourceHost, p.currentDB, err))
+			}
+			defer sourceClient.Close()
+
+			common.Logger.Infof("scan target[%v] db[%v] for the keys missing on the source", host.Addr, targetDB)
+			var scanMatch []interface{}
+			if len(p.MatchList) == 1 {
+				scanMatch = []interface{}{"match", p.MatchList[0]}
+			}
+			scanCount := p.scanCount()
+			dedup := common.NewDedupWindow(p.DedupWindow)
+			cursor := 0
+			for {
+				if p.IsStopped() {
+					common.Logger.Infof("stop scanning target[%v]", host.Addr)
+					break
+				}
+
+				args := append([]interface{}{cursor, "count", scanCount}, scanMatch...)
+				reply, err := targetClient.Do("scan", args...)
+				if err != nil {
+					panic(common.Logger.Critical(err))
+				}
+				next, keys, err := common.ParseScanReply(reply)
+				if err != nil {
+					panic(common.Logger.Criticalf("scan %d count %d on target failed[%v], result: %+v", cursor,
+						scanCount, err, reply))
+				}
+				cursor = next
+
+				keysInfo := make([]*common.Key, 0, len(keys))
+				for _, key := range keys {
+					if common.CheckFilter(p.FilterTree, key) == false || common.CheckMatch(p.MatchList, key) == false ||
+						common.CheckSample(key, p.SampleRate) == false || dedup.Seen(key) ||
+						filter.Seen(key) {
+						continue
+					}
+					keysInfo = append(keysInfo, &common.Key{
+						Key:          key,
+						Tp:           common.EndKeyType,
+						ConflictType: common.EndConflict,
+					})
+				}
+				// the keys are verified by the source name, which is mapped to the target name again
+				if p.TargetHost.KeyMap != nil {
+					for _, keyInfo := range keysInfo {
+						keyInfo.Key = p.TargetHost.KeyMap.Source(keyInfo.Key)
+					}
+				}
+				if len(p.TypeList) != 0 {
+					keysInfo = p.filterKeyType(&targetClient, keysInfo)
+				}
+
+				if len(keysInfo) != 0 {
+					exists, err := sourceClient.PipeExistsCommand(keysInfo)
+					if err != nil {
+						panic(common.Logger.Critical(err))
+					}
+					missing := make([]*common.Key, 0)
+					for i, exist := range exists {
+						if exist == 0 {
+							missing = append(missing, keysInfo[i])
+						}
+					}
+					if len(missing) != 0 {
+						p.IncrScanStat(len(missing))
+						allKeys <- missing
+					}
+				}
+
+				if cursor == 0 {
+					break
+				}
+			}
+		}(host)
+	}
+	wg.Wait()
+	p.checkDedupFilter(filter)
+}
+
+// the COUNT of the scan enumerating the keys
+func (p *FullCheck) scanCount() int {
+	if p.KeyScanCount == 0 {
+		return p.BatchCount
+	}
+	return p.KeyScanCount
+}
+
+// only keep the keys whose type is in the type list
+func (p *FullCheck) filterKeyType(sourceClient *client.RedisClient, keysInfo []*common.Key) []*common.Key {
+	if len(keysInfo) == 0 {
+		return keysInfo
+	}
+
+	keyTypeStr, err := sourceClient.PipeTypeCommand(keysInfo)
+	if err != nil {
+		panic(common.Logger.Critical(err))
+	}
+
+	ret := make([]*common.Key, 0, len(keysInfo))
+	for i, t := range keyTypeStr {
+		for _, tp := range p.TypeList {
+			if t == tp {
+				ret = append(ret, keysInfo[i])
+				break
+			}
+		}
+	}
+	return ret
+}
+
+// only keep the keys whose idle time isn't longer than the max idle time
+func (p *FullCheck) filterIdleTime(sourceClient *client.RedisClient, keysInfo []*common.Key) []*common.Key {
+	if len(keysInfo) == 0 {
+		return keysInfo
+	}
+
+	idleTime, err := sourceClient.PipeObjectIdletimeCommand(keysInfo)
+	if client.IsErrorReply(err) {
+		panic(common.Logger.Errorf("fetch idle time failed[%v], maxidletime can't be used when the "+
+			"maxmemory-policy of the source is lfu", err))
+	} else if err != nil {
+		panic(common.Logger.Errorf("fetch idle time failed[%v]", err))
+	}
+
+	ret := make([]*common.Key, 0, len(keysInfo))
+	for i, idle := range idleTime {
+		// idle == -1 means key has been deleted, leave it to the verifier
+		if idle <= p.MaxIdleTime {
+			ret = append(ret, keysInfo[i])
+		}
+	}
+	return ret
 }
 
 func (p *FullCheck) ScanFromDB(allKeys chan<- []*common.Key) {
+	defer close(allKeys)
 	conflictKeyTableName, conflictFieldTableName := p.GetLastResultTable()
 
 	keyQuery := fmt.Sprintf("select id,key,type,conflict_type,source_len,target_len from %s where id>? and db=%d limit %d",
@@ -134,7 +391,14 @@ func (p *FullCheck) ScanFromDB(allKeys chan<- []*common.Key) {
 	defer fieldStatm.Close()
 
 	var startId int64 = 0
+	if pos, ok := p.resumeCursor[checkpointSqliteNode]; ok {
+		startId = pos
+	}
 	for {
+		if p.IsStopped() {
+			break
+		}
+
 		rows, err := keyStatm.Query(startId)
 		if err != nil {
 			panic(common.Logger.Error(err))
@@ -198,9 +462,11 @@ func (p *FullCheck) ScanFromDB(allKeys chan<- []*common.Key) {
 		rows.Close()
 		// 结束
 		if len(keyInfo) == 0 {
-			close(allKeys)
 			break
 		}
+		if p.checkpoint != nil {
+			p.checkpoint.Add(checkpointSqliteNode, startId, keyInfo)
+		}
 		p.IncrScanStat(len(keyInfo))
 		allKeys <- keyInfo
 	} // for{}
@@ -0,0 +1,179 @@
+package full_check
+
+import (
+	"sort"
+	"sync"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+/*
+ * The size distribution of the source keys scanned in the first round when sizehistogram is set. The
+ * length is the element count, or the bytes of the string, fetched by the length command of the type.
+ * The memory is the bytes by memory usage, skipped when the source doesn't support it. Shared by the
+ * dbs compared concurrently.
+ */
+type SizeRecorder struct {
+	lock              sync.Mutex
+	length            map[string]*common.SizeHistogram // key type -> histogram
+	memory            map[string]*common.SizeHistogram // key type -> histogram
+	memoryUnsupported bool
+}
+
+// key type -> stat of the length and the memory
+type SizeSummary struct {
+	Length map[string]common.SizeStat `json:"length"`
+	Memory map[string]common.SizeStat `json:"memory,omitempty"`
+}
+
+func NewSizeRecorder() *SizeRecorder {
+	return &SizeRecorder{
+		length: make(map[string]*common.SizeHistogram),
+		memory: make(map[string]*common.SizeHistogram),
+	}
+}
+
+/*
+ * Record fetches the sizes of the verified keys from the source. The type fetched by the verifier is
+ * reused and only fetched for the keys the verifier doesn't fetch it, e.g., keyoutline. The sizes are
+ * only for the report, so the keys are dropped with a warning instead of panicking on the error.
+ */
+func (p *SizeRecorder) Record(keyInfo []*common.Key, sourceClient *client.RedisClient) {
+	var unknown []*common.Key
+	for _, key := range keyInfo {
+		if key.Tp == nil || key.Tp == common.EndKeyType {
+			unknown = append(unknown, key)
+		}
+	}
+	types := make(map[*common.Key]*common.KeyType, len(unknown))
+	if len(unknown) != 0 {
+		names, err := sourceClient.PipeTypeCommand(unknown)
+		if err != nil {
+			p.drop(keyInfo, sourceClient, err)
+			return
+		}
+		for i, key := range unknown {
+			types[key] = common.NewKeyType(names[i])
+		}
+	}
+
+	// copied since the keys may be written as the conflicts concurrently
+	sized := make([]*common.Key, 0, len(keyInfo))
+	for _, key := range keyInfo {
+		tp := key.Tp
+		if t, ok := types[key]; ok {
+			tp = t
+		}
+		if key.SourceAbsent || tp.Index >= common.NoneTypeIndex {
+			continue
+		}
+		sized = append(sized, &common.Key{Key: key.Key, Db: key.Db, Tp: tp})
+	}
+	if len(sized) == 0 {
+		return
+	}
+
+	length, err := sourceClient.PipeLenCommand(sized)
+	if err != nil {
+		p.drop(sized, sourceClient, err)
+		return
+	}
+	memory := p.fetchMemory(sized, sourceClient)
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	for i, key := range sized {
+		name := common.EncodeName(key.Key)
+		// the length of the module type is only the existence
+		if key.Tp != common.ModuleKeyType {
+			histogram(p.length, key.Tp.Name).Observe(name, length[i])
+		}
+		if memory != nil {
+			histogram(p.memory, key.Tp.Name).Observe(name, memory[i])
+		}
+	}
+}
+
+func (p *SizeRecorder) fetchMemory(keyInfo []*common.Key, sourceClient *client.RedisClient) []int64 {
+	p.lock.Lock()
+	unsupported := p.memoryUnsupported
+	p.lock.Unlock()
+	if unsupported {
+		return nil
+	}
+
+	memory, err := sourceClient.PipeMemoryUsageCommand(keyInfo)
+	if err == nil {
+		return memory
+	}
+	// the command is unknown or disabled on the server, the client has been closed
+	if client.IsErrorReply(err) {
+		p.lock.Lock()
+		if p.memoryUnsupported == false {
+			p.memoryUnsupported = true
+			common.Logger.Warnf("%v doesn't support memory usage[%v], skip the memory of the size histogram",
+				sourceClient, err)
+		}
+		p.lock.Unlock()
+		return nil
+	}
+	common.Logger.Warnf("fetch the memory of %d key(s) for the size histogram failed[%v]", len(keyInfo), err)
+	sourceClient.Close()
+	return nil
+}
+
+func (p *SizeRecorder) drop(keyInfo []*common.Key, sourceClient *client.RedisClient, err error) {
+	common.Logger.Warnf("fetch the size of %d key(s) for the size histogram failed[%v]", len(keyInfo), err)
+	// drop the connection because the remaining replies haven't been read
+	sourceClient.Close()
+}
+
+func histogram(histograms map[string]*common.SizeHistogram, keyType string) *common.SizeHistogram {
+	if _, ok := histograms[keyType]; !ok {
+		histograms[keyType] = new(common.SizeHistogram)
+	}
+	return histograms[keyType]
+}
+
+func (p *SizeRecorder) Summary() *SizeSummary {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+
+	summary := &SizeSummary{
+		Length: make(map[string]common.SizeStat, len(p.length)),
+	}
+	for keyType, histogram := range p.length {
+		summary.Length[keyType] = histogram.Stat()
+	}
+	if len(p.memory) != 0 {
+		summary.Memory = make(map[string]common.SizeStat, len(p.memory))
+		for keyType, histogram := range p.memory {
+			summary.Memory[keyType] = histogram.Stat()
+		}
+	}
+	return summary
+}
+
+// one line per key type and size, e.g., "size of hash length: 100 key(s), total 5000, p50 31, p99 127, max 900[user:1]"
+func (p *SizeRecorder) Log() {
+	summary := p.Summary()
+	for _, size := range []struct {
+		name  string
+		stats map[string]common.SizeStat
+	}{{"length", summary.Length}, {"memory", summary.Memory}} {
+		keyTypes := make([]string, 0, len(size.stats))
+		for keyType := range size.stats {
+			keyTypes = append(keyTypes, keyType)
+		}
+		sort.Strings(keyTypes)
+		for _, keyType := range keyTypes {
+			stat := size.stats[keyType]
+			common.Logger.Infof("size of %s %s: %d key(s), total %d, p50 %d, p95 %d, p99 %d, max %d[%s]", keyType,
+				size.name, stat.Count, stat.Total, stat.P50, stat.P95, stat.P99, stat.Max, stat.MaxKey)
+			for _, bucket := range stat.Buckets {
+				common.Logger.Infof("size of %s %s <= %d: %d key(s)", keyType, size.name, bucket.Le, bucket.Count)
+			}
+		}
+	}
+}
@@ -0,0 +1,80 @@
+package full_check
+
+import (
+	"strconv"
+
+	"full_check/common"
+)
+
+// the replication state of the source replica read instead of the primary when sourcereplica is set
+type SourceSnapshot struct {
+	Replica     string `json:"replica"`
+	Detached    bool   `json:"detached"` // the role is master, e.g., detached by REPLICAOF NO ONE
+	StartOffset int64  `json:"start_offset"`
+	EndOffset   int64  `json:"end_offset"`
+	OffsetLag   int64  `json:"offset_lag"` // bytes behind the primary before the first round, -1 when unknown
+	Stable      bool   `json:"stable"`     // the offset didn't move during the comparison
+}
+
+/*
+ * Record the replication offset of the source replica before the first round and after the last one.
+ * The comparison has a stable reference when the offset doesn't move, e.g., the replica detached by
+ * REPLICAOF NO ONE is a point-in-time snapshot of the primary. The offset lag behind the primary is
+ * only known when the replica is still replicating from it.
+ */
+func (p *FullCheck) snapshotSource(start bool) {
+	replica, err := fetchInfo(p.SourceHost, 0, "replication")
+	if err != nil {
+		common.Logger.Warnf("fetch the replication info of the source replica %v failed[%v]", p.SourceHost.Addr, err)
+		return
+	}
+	detached := replica["role"] == "master"
+	var offset int64
+	if detached {
+		offset, _ = strconv.ParseInt(replica["master_repl_offset"], 10, 64)
+	} else {
+		offset, _ = strconv.ParseInt(replica["slave_repl_offset"], 10, 64)
+	}
+
+	if start {
+		p.sourceSnapshot = &SourceSnapshot{
+			Replica:     p.SourceHost.Addr[0],
+			Detached:    detached,
+			StartOffset: offset,
+			OffsetLag:   -1,
+		}
+		if detached {
+			common.Logger.Infof("the source replica %v is detached, compared as the snapshot at offset %d",
+				p.SourceHost.Addr[0], offset)
+			return
+		}
+
+		primaryHost := p.SourceHost
+		primaryHost.Addr = p.SourcePrimary
+		primary, err := fetchInfo(primaryHost, 0, "replication")
+		if err != nil {
+			common.Logger.Warnf("fetch the replication info of the source primary %v failed[%v]", p.SourcePrimary, err)
+		} else if len(primary["master_replid"]) != 0 && primary["master_replid"] == replica["master_replid"] {
+			primaryOffset, _ := strconv.ParseInt(primary["master_repl_offset"], 10, 64)
+			p.sourceSnapshot.OffsetLag = primaryOffset - offset
+		}
+		common.Logger.Warnf("the source replica %v keeps replicating, link status[%v], %d byte(s) behind the "+
+			"primary at offset %d. The keys written during the comparison may be read in different states, "+
+			"detach it by REPLICAOF NO ONE for a stable view", p.SourceHost.Addr[0], replica["master_link_status"],
+			p.sourceSnapshot.OffsetLag, offset)
+		return
+	}
+
+	if p.sourceSnapshot == nil {
+		return
+	}
+	p.sourceSnapshot.EndOffset = offset
+	p.sourceSnapshot.Stable = offset == p.sourceSnapshot.StartOffset
+	if p.sourceSnapshot.Stable {
+		common.Logger.Infof("the source replica %v stayed at offset %d during the comparison",
+			p.sourceSnapshot.Replica, offset)
+	} else {
+		common.Logger.Warnf("the source replica %v moved from offset %d to %d during the comparison",
+			p.sourceSnapshot.Replica, p.sourceSnapshot.StartOffset, offset)
+	}
+}
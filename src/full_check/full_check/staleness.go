@@ -0,0 +1,156 @@
+package full_check
+
+import (
+	"fmt"
+	"strconv"
+	"sync/atomic"
+	"time"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+// the replication lag of the target replica, the max of the measurements before every round
+type ReplicaLag struct {
+	LinkDown      bool  `json:"link_down,omitempty"`
+	LastIOSeconds int64 `json:"last_io_seconds"` // master_last_io_seconds_ago
+	OffsetLag     int64 `json:"offset_lag"`      // bytes behind the source, -1 when the source isn't its master
+}
+
+/*
+ * Measure the lag of the target replica by INFO replication on both sides. The offset lag is only known
+ * when the target replicates from the source directly, i.e., they share the same master_replid.
+ */
+func (p *FullCheck) measureLag() {
+	if p.TargetHost.IsCluster() {
+		common.Logger.Warnf("the replication lag of the cluster target %v isn't measured", p.TargetHost.Addr)
+		return
+	}
+	source, err := fetchInfo(p.SourceHost, 0, "replication")
+	if err != nil {
+		common.Logger.Warnf("fetch the replication info of the source failed[%v]", err)
+		return
+	}
+	target, err := fetchInfo(p.TargetHost, p.TargetDB(0), "replication")
+	if err != nil {
+		common.Logger.Warnf("fetch the replication info of the target %v failed[%v]", p.TargetHost.Addr, err)
+		return
+	}
+	if target["role"] != "slave" {
+		common.Logger.Warnf("the target %v isn't a replica, role[%v]", p.TargetHost.Addr, target["role"])
+		return
+	}
+
+	lag := ReplicaLag{LinkDown: target["master_link_status"] != "up", OffsetLag: -1}
+	lag.LastIOSeconds, _ = strconv.ParseInt(target["master_last_io_seconds_ago"], 10, 64)
+	if len(source["master_replid"]) != 0 && source["master_replid"] == target["master_replid"] {
+		sourceOffset, _ := strconv.ParseInt(source["master_repl_offset"], 10, 64)
+		targetOffset, _ := strconv.ParseInt(target["slave_repl_offset"], 10, 64)
+		lag.OffsetLag = sourceOffset - targetOffset
+	}
+	common.Logger.Infof("replication lag of the target %v: link down[%v], last io %ds ago, %d byte(s) behind",
+		p.TargetHost.Addr, lag.LinkDown, lag.LastIOSeconds, lag.OffsetLag)
+
+	if p.targetLag == nil {
+		p.targetLag = &lag
+		return
+	}
+	p.targetLag.LinkDown = p.targetLag.LinkDown || lag.LinkDown
+	if lag.LastIOSeconds > p.targetLag.LastIOSeconds {
+		p.targetLag.LastIOSeconds = lag.LastIOSeconds
+	}
+	if lag.OffsetLag > p.targetLag.OffsetLag {
+		p.targetLag.OffsetLag = lag.OffsetLag
+	}
+}
+
+// the fields of the INFO section, "default" for the default sections
+func fetchInfo(host client.RedisHost, db int32, section string) (map[string]string, error) {
+	c, err := client.NewRedisClient(host, db)
+	if err != nil {
+		return nil, err
+	}
+	defer c.Close()
+	reply, err := c.Do("info", section)
+	if err != nil {
+		return nil, err
+	}
+	info, ok := reply.([]byte)
+	if !ok {
+		return nil, fmt.Errorf("info %s return invalid reply[%v]", section, reply)
+	}
+	return common.ParseInfo(info), nil
+}
+
+/*
+ * The keys written recently on the source may not be replicated to the lagging target yet. The idle
+ * time is fetched before verifying since reading the value touches the key. The conflict keys modified
+ * within targetstaleness are verified again once the duration has passed since the modification, and
+ * only the conflicts of the second time are reported.
+ */
+func (p *FullCheck) verifyStale(keyInfo []*common.Key, conflictKey chan<- *common.Key, sourceClient,
+		targetClient *client.RedisClient) {
+	start := time.Now()
+	idleTime := fetchIdleTime(keyInfo, sourceClient)
+	idle := make(map[*common.Key]int64, len(keyInfo))
+	for i, key := range keyInfo {
+		idle[key] = idleTime[i]
+	}
+
+	conflicts := p.collectConflict(func(conflicts chan<- *common.Key) {
+		p.verifyKeys(keyInfo, conflicts, sourceClient, targetClient)
+	})
+
+	recent := make([]*common.Key, 0)
+	var wait time.Duration
+	for _, key := range conflicts {
+		// -1 means missing on the source or unknown, e.g., the lfu policy
+		idleMs := idle[key] * 1000
+		if idle[key] < 0 {
+			idleMs = 0
+		}
+		if idleMs > p.TargetStaleness {
+			conflictKey <- key
+			continue
+		}
+		recent = append(recent, key)
+		if d := time.Duration(p.TargetStaleness-idleMs) * time.Millisecond; d > wait {
+			wait = d
+		}
+	}
+	if len(recent) == 0 {
+		return
+	}
+
+	common.Logger.Debugf("%d conflict key(s) modified recently, verify again after %v", len(recent), wait)
+	select {
+	case <-time.After(wait - time.Since(start)):
+	case <-p.stop:
+	}
+	p.resetConflict(recent)
+	p.verifyKeys(recent, conflictKey, sourceClient, targetClient)
+}
+
+// set once the idle time of the source is found untracked, i.e., the maxmemory-policy is lfu
+var idleTimeUntracked int32
+
+// the idle time of the keys on the source, all -1 when it isn't tracked
+func fetchIdleTime(keyInfo []*common.Key, sourceClient *client.RedisClient) []int64 {
+	if atomic.LoadInt32(&idleTimeUntracked) == 0 {
+		idleTime, err := sourceClient.PipeObjectIdletimeCommand(keyInfo)
+		if err == nil {
+			return idleTime
+		} else if client.IsErrorReply(err) == false {
+			panic(common.Logger.Critical(err))
+		}
+		if atomic.CompareAndSwapInt32(&idleTimeUntracked, 0, 1) {
+			common.Logger.Warnf("fetch idle time failed[%v], every conflict key is verified again after "+
+				"targetstaleness since the maxmemory-policy of the source is lfu", err)
+		}
+	}
+	idleTime := make([]int64, len(keyInfo))
+	for i := range idleTime {
+		idleTime[i] = -1
+	}
+	return idleTime
+}
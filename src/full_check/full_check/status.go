@@ -0,0 +1,90 @@
+package full_check
+
+import (
+	"encoding/json"
+	"net"
+	"net/http"
+	"os"
+	"time"
+
+	"full_check/common"
+)
+
+// the status of the run in flight, returned in json on "/status"
+type RunStatus struct {
+	Round          int     `json:"round"`
+	CompareCount   int     `json:"compare_count"`
+	CurrentDB      int32   `json:"current_db"`
+	DBKeys         int64   `json:"db_keys"`       // keys of the current db by INFO Keyspace
+	ScanKeys       int64   `json:"scan_keys"`     // keys scanned of the current db in the current round
+	ScanSpeed      int64   `json:"scan_speed"`    // keys scanned per second
+	ConflictKeys   int64   `json:"conflict_keys"` // conflicts of the current db in the current round
+	ConflictFields int64   `json:"conflict_fields"`
+	Progress       float64 `json:"progress"` // percent of the keyspace scanned in the first round
+	ElapsedMs      int64   `json:"elapsed_ms"`
+	EtaMs          int64   `json:"eta_ms,omitempty"` // estimated to finish the first round, omitted when unknown
+	Stopped        bool    `json:"stopped,omitempty"`
+}
+
+/*
+ * Serve "/status" and "/metrics" on the unix socket, so the controller on the same host can poll the
+ * run without a tcp port. The stale socket file left by the previous run is removed, and the file is
+ * removed again when the returned listener is closed.
+ */
+func (p *FullCheck) StartStatusSocket(path string) net.Listener {
+	os.Remove(path)
+	listener, err := net.Listen("unix", path)
+	if err != nil {
+		common.Logger.Errorf("listen on status socket[%v] failed[%v]", path, err)
+		return nil
+	}
+	go func() {
+		if err := http.Serve(listener, p.serveMux()); err != nil {
+			common.Logger.Debugf("status socket[%v] exit[%v]", path, err)
+		}
+	}()
+	common.Logger.Infof("status server listen on socket[%v]", path)
+	return listener
+}
+
+func (p *FullCheck) Status() RunStatus {
+	times, _ := p.round()
+	stats, currentDB := p.currentStats()
+	status := RunStatus{
+		Round:        times,
+		CompareCount: p.CompareCount,
+		CurrentDB:    currentDB,
+		DBKeys:       p.sourceLogicalDBMap[currentDB],
+		ElapsedMs:    int64(time.Since(p.startTime) / time.Millisecond),
+		Stopped:      p.IsStopped(),
+	}
+	// summed over the dbs compared concurrently when dbparallel > 1
+	for _, stat := range stats {
+		conflictKeys, conflictFields := stat.ConflictTotal()
+		status.ScanKeys += stat.Scan.Total()
+		status.ScanSpeed += stat.Scan.Speed()
+		status.ConflictKeys += conflictKeys
+		status.ConflictFields += conflictFields
+	}
+
+	// the later rounds only verify the conflict keys, so the progress is measured by the first round
+	var total int64
+	for _, keyNum := range p.sourceLogicalDBMap {
+		total += keyNum
+	}
+	scanned := p.totalScanKeys + status.ScanKeys
+	if times > 1 || (total != 0 && scanned >= total) {
+		status.Progress = 100
+	} else if total != 0 {
+		status.Progress = float64(scanned) * 100 / float64(total)
+		if scanned != 0 {
+			status.EtaMs = int64(float64(status.ElapsedMs) * float64(total-scanned) / float64(scanned))
+		}
+	}
+	return status
+}
+
+func (p *FullCheck) handleStatus(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(p.Status())
+}
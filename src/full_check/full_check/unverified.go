@@ -0,0 +1,85 @@
+package full_check
+
+import (
+	"fmt"
+	"os"
+	"sync"
+	"sync/atomic"
+
+	"full_check/client"
+	"full_check/common"
+)
+
+/*
+ * The keys left unverified since the network error lasts after all the retries. They aren't conflicts
+ * and aren't compared in the later rounds, so they are counted separately and written to the file in
+ * the format of the key file, "db\tkey" per line, to be compared again by keyfile.
+ */
+type UnverifiedRecorder struct {
+	count int64
+	path  string
+	lock  sync.Mutex
+	file  *os.File // created on the first unverified key
+}
+
+func NewUnverifiedRecorder(path string) *UnverifiedRecorder {
+	return &UnverifiedRecorder{path: path}
+}
+
+func (p *UnverifiedRecorder) Record(db int32, keyInfo []*common.Key) {
+	atomic.AddInt64(&p.count, int64(len(keyInfo)))
+	if len(p.path) == 0 {
+		return
+	}
+
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.file == nil {
+		file, err := os.OpenFile(p.path, os.O_RDWR|os.O_CREATE|os.O_APPEND, 0666)
+		if err != nil {
+			common.Logger.Errorf("open unverified file[%v] failed[%v]", p.path, err)
+			return
+		}
+		p.file = file
+	}
+	for _, oneKeyInfo := range keyInfo {
+		fmt.Fprintf(p.file, "%d\t%s\n", db, common.EncodeName(oneKeyInfo.Key))
+	}
+}
+
+func (p *UnverifiedRecorder) Count() int64 {
+	return atomic.LoadInt64(&p.count)
+}
+
+func (p *UnverifiedRecorder) Close() {
+	p.lock.Lock()
+	defer p.lock.Unlock()
+	if p.file != nil {
+		p.file.Close()
+		p.file = nil
+	}
+}
+
+/*
+ * The keys are recorded as unverified when the group fails after exhausting the retries of either
+ * client, and the comparison goes on with the next group. The other panics are passed on.
+ */
+func (p *FullCheck) recoverUnverified(keyInfo []*common.Key, clients ...*client.RedisClient) {
+	r := recover()
+	if r == nil {
+		return
+	}
+	exhausted := false
+	for _, c := range clients {
+		// check all the clients to clear the flags
+		if c.RetryExhausted() {
+			exhausted = true
+		}
+	}
+	if exhausted == false {
+		panic(r)
+	}
+	common.Logger.Warn(common.LogFields(fmt.Sprintf("%d key(s) unverified: %v", len(keyInfo), r),
+		"db", p.currentDB))
+	p.unverified.Record(p.currentDB, keyInfo)
+}
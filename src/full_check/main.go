@@ -1,15 +1,17 @@
 package main
 
 import (
+	"bytes"
+	"context"
 	"fmt"
+	"io/ioutil"
 	"os"
+	"os/signal"
 	"strconv"
-	"strings"
+	"syscall"
 
 	"full_check/configure"
 	"full_check/full_check"
-	"full_check/checker"
-	"full_check/client"
 	"full_check/common"
 
 	"github.com/jessevdk/go-flags"
@@ -18,9 +20,25 @@ import (
 
 var VERSION = "$"
 
+// the exit code, a panic also exits with 2
+const (
+	ExitConflict = 1 // more keys conflict than failthreshold, or the key count diverges in the dry run
+	ExitError    = 2 // invalid option, connection failure, or any other error
+	ExitStopped  = 3 // stopped by the signal before finished
+	ExitPartial  = 4 // stopped by maxduration before finished, the partial result is reported
+	ExitUnverify = 5 // more keys than unverifiedthreshold are left unverified by the network error, or any db is skipped
+)
+
 func main() {
 	// parse conf.Opts
-	args, err := flags.Parse(&conf.Opts)
+	parser := flags.NewParser(&conf.Opts, flags.Default)
+	args, err := parser.Parse()
+	if err == nil && len(conf.Opts.ConfigFile) != 0 {
+		if err = parseConfigFile(parser, conf.Opts.ConfigFile); err == nil {
+			// parse again so the command line overrides the config file
+			args, err = parser.Parse()
+		}
+	}
 
 	if conf.Opts.Version {
 		fmt.Println(VERSION)
@@ -32,141 +50,144 @@ func main() {
 		if flagsErr, ok := err.(*flags.Error); ok && flagsErr.Type == flags.ErrHelp {
 			os.Exit(0)
 		} else {
-			fmt.Fprintf(os.Stderr, "flag err %s\n", flagsErr)
-			os.Exit(1)
+			fmt.Fprintf(os.Stderr, "flag err %s\n", err)
+			os.Exit(ExitError)
 		}
 	}
 
 	if conf.Opts.SourceAddr == "" || conf.Opts.TargetAddr == "" {
 		fmt.Fprintf(os.Stderr, "-s, --source or -t, --target not specified\n")
-		os.Exit(1)
+		os.Exit(ExitError)
 	}
 
 	if len(args) != 0 {
 		fmt.Fprintf(os.Stderr, "unexpected args %+v", args)
-		os.Exit(1)
+		os.Exit(ExitError)
 	}
 
 	// init log
 	logLevel, err := common.HandleLogLevel(conf.Opts.LogLevel)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, err)
-		os.Exit(1)
+		os.Exit(ExitError)
 	}
 
 	nimo.Profiling(int(conf.Opts.SystemProfile))
 
-	common.Logger, err = common.InitLog(conf.Opts.LogFile, logLevel)
+	common.Logger, err = common.InitLog(conf.Opts.LogFile, logLevel, conf.Opts.LogFormat)
 	if err != nil {
 		fmt.Fprintln(os.Stderr, "init log failed: ", err)
-		os.Exit(1)
+		os.Exit(ExitError)
 	}
 	common.Logger.Info("init log success")
 	defer common.Logger.Flush()
 
-	compareCount, err := strconv.Atoi(conf.Opts.CompareTimes)
-	if err != nil || compareCount < 1 {
-		panic(common.Logger.Errorf("invalid option cmpcount %s, expect int >=1", conf.Opts.CompareTimes))
-	}
-	if conf.Opts.Interval < 0 {
-		panic(common.Logger.Errorf("invalid option interval %d, expect int >=0", conf.Opts.Interval))
-	}
-	batchCount, err := strconv.Atoi(conf.Opts.BatchCount)
-	if err != nil || batchCount < 1 || batchCount > 10000 {
-		panic(common.Logger.Errorf("invalid option batchcount %s, expect int 1<=batchcount<=10000", conf.Opts.BatchCount))
-	}
-	parallel := conf.Opts.Parallel
-	if parallel < 1 || parallel > 100 {
-		panic(common.Logger.Errorf("invalid option parallel %d, expect 1<=parallel<=100", conf.Opts.Parallel))
-	}
-	qps := conf.Opts.Qps
-	if qps < 1 || qps > 5000000 {
-		panic(common.Logger.Errorf("invalid option qps %d, expect 1<=qps<=5000000", conf.Opts.Qps))
-	}
-	if conf.Opts.SourceAuthType != "auth" && conf.Opts.SourceAuthType != "adminauth" {
-		panic(common.Logger.Errorf("invalid sourceauthtype %s, expect auth/adminauth", conf.Opts.SourceAuthType))
-	}
-	if conf.Opts.TargetAuthType != "auth" && conf.Opts.TargetAuthType != "adminauth" {
-		panic(common.Logger.Errorf("invalid targetauthtype %s, expect auth/adminauth", conf.Opts.TargetAuthType))
+	fullCheck, err := full_check.New(conf.Opts)
+	if err != nil {
+		common.Logger.Critical(err)
+		common.Logger.Flush()
+		os.Exit(ExitError)
+	}
+	if conf.Opts.DryRun {
+		if fullCheck.DryRun() == false {
+			common.Logger.Flush()
+			os.Exit(ExitConflict)
+		}
+		return
 	}
-	if conf.Opts.CompareMode < full_check.FullValue || conf.Opts.CompareMode > full_check.FullValueWithOutline {
-		panic(common.Logger.Errorf("invalid compare mode %d", conf.Opts.CompareMode))
+	if len(conf.Opts.Key) != 0 {
+		key, _ := common.DecodeName(conf.Opts.Key)
+		if fullCheck.InspectKey(conf.Opts.KeyDB, key) == false {
+			common.Logger.Flush()
+			os.Exit(ExitConflict)
+		}
+		return
+	}
+
+	// stop gracefully on the first signal, force quit on the second one
+	signals := make(chan os.Signal, 2)
+	signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+	go func() {
+		sig := <-signals
+		common.Logger.Warnf("receive signal[%v], stopping... send again to force quit", sig)
+		fullCheck.Stop()
+		sig = <-signals
+		common.Logger.Warnf("receive signal[%v] again, force quit", sig)
+		common.Logger.Flush()
+		os.Exit(ExitStopped)
+	}()
+
+	summary, err := fullCheck.Run(context.Background())
+	if err == full_check.ErrMaxDuration {
+		common.Logger.Flush()
+		os.Exit(ExitPartial)
+	} else if err == full_check.ErrStopped {
+		common.Logger.Flush()
+		os.Exit(ExitStopped)
+	} else if err != nil {
+		common.Logger.Error(err)
+		common.Logger.Flush()
+		os.Exit(ExitError)
+	}
+
+	// the conflict keys of all the targets in the last round
+	conflictKeys := summary.ConflictKeys
+	for _, fanOut := range summary.FanOut {
+		conflictKeys += fanOut.ConflictKeys
+	}
+	// the keys not compared at all are worse than the conflicts
+	unverifiedKeys := summary.UnverifiedKeys
+	for _, fanOut := range summary.FanOut {
+		unverifiedKeys += fanOut.UnverifiedKeys
+	}
+	if len(summary.SkippedDBs) != 0 {
+		common.Logger.Warnf("db %v skipped since failing to be connected", summary.SkippedDBs)
+		common.Logger.Flush()
+		os.Exit(ExitUnverify)
+	}
+	if unverifiedKeys > conf.Opts.UnverifiedLimit {
+		common.Logger.Warnf("%d key(s) unverified, more than the unverified threshold %d", unverifiedKeys,
+			conf.Opts.UnverifiedLimit)
+		common.Logger.Flush()
+		os.Exit(ExitUnverify)
+	}
+	if conflictKeys > conf.Opts.FailThreshold {
+		common.Logger.Warnf("%d key(s) conflict, more than the fail threshold %d", conflictKeys,
+			conf.Opts.FailThreshold)
+		common.Logger.Flush()
+		os.Exit(ExitConflict)
 	}
-	if conf.Opts.BigKeyThreshold < 0 {
-		panic(common.Logger.Errorf("invalid big key threshold: %d", conf.Opts.BigKeyThreshold))
-	} else if conf.Opts.BigKeyThreshold == 0 {
-		common.BigKeyThreshold = 16384
-	} else {
-		common.BigKeyThreshold = conf.Opts.BigKeyThreshold
+}
+
+// the YAML and TOML files are converted to the INI options of the parser, the others are read as INI
+func parseConfigFile(parser *flags.Parser, file string) error {
+	format := common.ConfigFormat(file)
+	if len(format) == 0 {
+		return flags.NewIniParser(parser).ParseFile(file)
 	}
 
-	sourceAddressList, err := client.HandleAddress(conf.Opts.SourceAddr, conf.Opts.SourcePassword, conf.Opts.SourceAuthType)
+	data, err := ioutil.ReadFile(file)
 	if err != nil {
-		panic(common.Logger.Errorf("source address[%v] illegal[%v]", conf.Opts.SourceAddr, err))
-	} else if len(sourceAddressList) > 1 && conf.Opts.SourceDBType != 1 {
-		panic(common.Logger.Errorf("looks like the source is cluster? please set sourcedbtype"))
-	} else if len(sourceAddressList) == 0 {
-		panic(common.Logger.Errorf("input source address is empty"))
+		return err
 	}
-
-	targetAddressList, err := client.HandleAddress(conf.Opts.TargetAddr, conf.Opts.TargetPassword, conf.Opts.TargetAuthType)
+	options, err := common.ParseConfigFile(format, data)
 	if err != nil {
-		panic(common.Logger.Errorf("target address[%v] illegal[%v]", conf.Opts.TargetAddr, err))
-	} else if len(targetAddressList) > 1 && conf.Opts.TargetDBType != 1 {
-		panic(common.Logger.Errorf("looks like the target is cluster? please set targetdbtype"))
-	} else if len(targetAddressList) == 0 {
-		panic(common.Logger.Errorf("input target address is empty"))
-	}
-
-	// filter list
-	var filterTree *common.Trie
-	if len(conf.Opts.FilterList) != 0 {
-		filterTree = common.NewTrie()
-		filterList := strings.Split(conf.Opts.FilterList, "|")
-		for _, filter := range filterList {
-			if filter == "" {
-				panic(common.Logger.Errorf("invalid input filter list: %v", filterList))
+		return fmt.Errorf("parse config file[%v] failed: %v", file, err)
+	}
+	var ini bytes.Buffer
+	ini.WriteString("[Application Options]\n")
+	for _, option := range options {
+		fmt.Fprintf(&ini, "%s = %s\n", option.Name, strconv.Quote(option.Value))
+	}
+	if err = flags.NewIniParser(parser).Parse(&ini); err != nil {
+		// the line of the converted options follows the section line
+		if iniErr, ok := err.(*flags.IniError); ok {
+			if index := int(iniErr.LineNumber) - 2; index >= 0 && index < len(options) {
+				return fmt.Errorf("parse config file[%v] failed: option %v: %v", file, options[index].Name,
+					iniErr.Message)
 			}
-			filterTree.Insert([]byte(filter))
 		}
-		common.Logger.Infof("filter list enabled: %v", filterList)
-	}
-
-	// remove result file if has
-	if len(conf.Opts.ResultFile) > 0 {
-		os.Remove(conf.Opts.ResultFile)
-	}
-
-	fullCheckParameter := checker.FullCheckParameter{
-		SourceHost: client.RedisHost{
-			Addr:         sourceAddressList,
-			Password:     conf.Opts.SourcePassword,
-			TimeoutMs:    0,
-			Role:         "source",
-			Authtype:     conf.Opts.SourceAuthType,
-			DBType:       conf.Opts.SourceDBType,
-			DBFilterList: common.FilterDBList(conf.Opts.SourceDBFilterList),
-		},
-		TargetHost: client.RedisHost{
-			Addr:         targetAddressList,
-			Password:     conf.Opts.TargetPassword,
-			TimeoutMs:    0,
-			Role:         "target",
-			Authtype:     conf.Opts.TargetAuthType,
-			DBType:       conf.Opts.TargetDBType,
-			DBFilterList: common.FilterDBList(conf.Opts.TargetDBFilterList),
-		},
-		ResultDBFile: conf.Opts.ResultDBFile,
-		CompareCount: compareCount,
-		Interval:     conf.Opts.Interval,
-		BatchCount:   batchCount,
-		Parallel:     parallel,
-		FilterTree:   filterTree,
-	}
-
-	common.Logger.Info("configuration: ", conf.Opts)
-	common.Logger.Info("---------")
-
-	fullCheck := full_check.NewFullCheck(fullCheckParameter, full_check.CheckType(conf.Opts.CompareMode))
-	fullCheck.Start()
+		return err
+	}
+	return nil
 }
@@ -11,6 +11,13 @@ type Stat struct {
 
 	TotalConflictFields int64
 	TotalConflictKeys int64
+
+	// the keys only on the target aren't added to TotalConflictKeys
+	IgnoreLackSource bool
+
+	// the keys regarded as equal without comparing the whole value, i.e., the keys too large by the length
+	// and encoding and the big sets by the sampled members, kept across the rounds
+	ShallowKeys [common.EndKeyTypeIndex]int64
 }
 
 func (p *Stat) Rotate() {
@@ -23,6 +30,19 @@ func (p *Stat) Rotate() {
 	}
 }
 
+// the conflict keys and fields counted so far, which are added to the totals by Reset
+func (p *Stat) ConflictTotal() (keys, fields int64) {
+	for keyType := common.KeyTypeIndex(0); keyType < common.EndKeyTypeIndex; keyType++ {
+		for conType := common.ConflictType(0); conType < common.NoneConflict; conType++ {
+			if conType != common.LackSourceConflict || p.IgnoreLackSource == false {
+				keys += p.ConflictKey[keyType][conType].Total()
+			}
+			fields += p.ConflictField[keyType][conType].Total()
+		}
+	}
+	return keys, fields
+}
+
 func (p *Stat) Reset(clear bool) {
 	p.Scan.Reset()
 	if clear {
@@ -35,6 +55,9 @@ func (p *Stat) Reset(clear bool) {
 			if conType < common.NoneConflict {
 				keyConflict := p.ConflictKey[keyType][conType].Total()
 				fieldConflict := p.ConflictField[keyType][conType].Total()
+				if conType == common.LackSourceConflict && p.IgnoreLackSource {
+					keyConflict = 0
+				}
 				if keyConflict != 0 {
 					p.TotalConflictKeys += keyConflict
 					common.Logger.Debugf("key conflict: keyType[%v] conType[%v]", keyType, conType)